@@ -14,11 +14,12 @@ const getAuthHeaders = async (supabase) => {
   }
 }
 
-// Create a new mission
+// Create a new mission. The planner fills in default speeds, so ask the
+// server to lower those the selected drone cannot fly instead of rejecting them
 export const createMission = async (missionData, supabase) => {
   const headers = await getAuthHeaders(supabase)
 
-  const response = await fetch(`${API_URL}/missions`, {
+  const response = await fetch(`${API_URL}/missions?clampSpeeds=true`, {
     method: 'POST',
     headers,
     body: JSON.stringify(missionData),
@@ -66,11 +67,11 @@ export const getMission = async (missionId, supabase) => {
   return response.json()
 }
 
-// Update a mission
+// Update a mission, lowering default speeds like createMission
 export const updateMission = async (missionId, missionData, supabase) => {
   const headers = await getAuthHeaders(supabase)
 
-  const response = await fetch(`${API_URL}/missions/${missionId}`, {
+  const response = await fetch(`${API_URL}/missions/${missionId}?clampSpeeds=true`, {
     method: 'PUT',
     headers,
     body: JSON.stringify(missionData),
@@ -0,0 +1,130 @@
+package airspace
+
+import (
+	"math"
+
+	"drone-planner/server/geometry"
+)
+
+// Vertical positions of a route against a zone's floor and ceiling
+const (
+	VerticalInside  = "inside"
+	VerticalBelow   = "below"
+	VerticalAbove   = "above"
+	VerticalUnknown = "unknown" // The height needed to compare is not known
+)
+
+// Position is a route point with its heights above sea level and above
+// ground, each nil when it can't be determined
+type Position struct {
+	Latitude  float64
+	Longitude float64
+	MSL       *float64
+	AGL       *float64
+}
+
+// WaypointConflict is a waypoint inside a zone's area
+type WaypointConflict struct {
+	Waypoint int    `json:"waypoint"`
+	Zone     *Zone  `json:"zone"`
+	Vertical string `json:"vertical"`
+}
+
+// SegmentConflict is a segment that crosses a zone's area
+type SegmentConflict struct {
+	From     int    `json:"from"`
+	To       int    `json:"to"`
+	Zone     *Zone  `json:"zone"`
+	Vertical string `json:"vertical"`
+}
+
+// Report lists every waypoint and segment that enters a zone's area. Clear is
+// false when any of them is, or may be, within the zone's vertical extent.
+type Report struct {
+	Clear     bool               `json:"clear"`
+	Zones     int                `json:"zones"`
+	Waypoints []WaypointConflict `json:"waypoints"`
+	Segments  []SegmentConflict  `json:"segments"`
+}
+
+// Check tests a route against the indexed zones. Segments are checked along
+// their straight chord, and their heights are taken to vary between those of
+// their ends.
+func (idx *Index) Check(positions []Position) *Report {
+	report := &Report{
+		Clear:     true,
+		Zones:     idx.Len(),
+		Waypoints: []WaypointConflict{},
+		Segments:  []SegmentConflict{},
+	}
+
+	for i, p := range positions {
+		bounds := geometry.Bounds{
+			MinLatitude: p.Latitude, MinLongitude: p.Longitude,
+			MaxLatitude: p.Latitude, MaxLongitude: p.Longitude,
+		}
+		for _, zone := range idx.Query(bounds) {
+			if !zone.Contains(p.Latitude, p.Longitude) {
+				continue
+			}
+			vertical := zone.vertical(p, p)
+			report.Waypoints = append(report.Waypoints, WaypointConflict{Waypoint: i, Zone: zone, Vertical: vertical})
+			report.Clear = report.Clear && isClear(vertical)
+		}
+	}
+
+	for i := 1; i < len(positions); i++ {
+		from, to := positions[i-1], positions[i]
+		a := geometry.Point{Latitude: from.Latitude, Longitude: from.Longitude}
+		b := geometry.Point{Latitude: to.Latitude, Longitude: to.Longitude}
+		for _, zone := range idx.Query(geometry.SegmentBounds(a, b)) {
+			if !zone.IntersectsSegment(a, b) {
+				continue
+			}
+			vertical := zone.vertical(from, to)
+			report.Segments = append(report.Segments, SegmentConflict{From: i - 1, To: i, Zone: zone, Vertical: vertical})
+			report.Clear = report.Clear && isClear(vertical)
+		}
+	}
+	return report
+}
+
+// isClear reports whether a vertical position keeps out of the zone
+func isClear(vertical string) bool {
+	return vertical == VerticalBelow || vertical == VerticalAbove
+}
+
+// vertical compares the height range spanned by two positions with the
+// zone's floor and ceiling. Ground-referenced limits are compared with
+// heights above ground, the others with heights above sea level.
+func (z *Zone) vertical(from, to Position) string {
+	known := true
+	if !z.Ceiling.Unlimited {
+		low, _, ok := heightRange(z.Ceiling, from, to)
+		if ok && low > z.Ceiling.Meters() {
+			return VerticalAbove
+		}
+		known = known && ok
+	}
+	_, high, ok := heightRange(z.Floor, from, to)
+	if ok && high < z.Floor.Meters() {
+		return VerticalBelow
+	}
+	if !known || !ok {
+		return VerticalUnknown
+	}
+	return VerticalInside
+}
+
+// heightRange returns the lowest and highest of two positions' heights in a
+// limit's reference
+func heightRange(limit Limit, from, to Position) (float64, float64, bool) {
+	a, b := from.MSL, to.MSL
+	if limit.Reference == ReferenceGround {
+		a, b = from.AGL, to.AGL
+	}
+	if a == nil || b == nil {
+		return 0, 0, false
+	}
+	return math.Min(*a, *b), math.Max(*a, *b), true
+}
@@ -0,0 +1,150 @@
+package airspace
+
+import "testing"
+
+func height(value float64) *float64 {
+	return &value
+}
+
+func TestCheck(t *testing.T) {
+	index, err := LoadDirectory("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// position returns a route point with heights above sea level and ground
+	position := func(lat, lng float64, msl, agl *float64) Position {
+		return Position{Latitude: lat, Longitude: lng, MSL: msl, AGL: agl}
+	}
+	type conflict struct {
+		waypoint bool
+		index    int
+		zone     string
+		vertical string
+	}
+	tests := []struct {
+		name  string
+		route []Position
+		want  []conflict
+		clear bool
+	}{
+		{"inside the park", []Position{
+			position(46.02, 7.02, height(600), height(100)),
+			position(46.03, 7.03, height(600), height(100)),
+		}, []conflict{
+			{true, 0, "local.geojson#1", VerticalInside},
+			{true, 1, "local.geojson#1", VerticalInside},
+			{false, 0, "local.geojson#1", VerticalInside},
+		}, false},
+		{"above the park", []Position{
+			position(46.02, 7.02, nil, height(150)),
+			position(46.03, 7.03, nil, height(130)),
+		}, []conflict{
+			{true, 0, "local.geojson#1", VerticalAbove},
+			{true, 1, "local.geojson#1", VerticalAbove},
+			{false, 0, "local.geojson#1", VerticalAbove},
+		}, true},
+		{"park without heights above ground", []Position{
+			position(46.02, 7.02, height(600), nil),
+			position(45.9, 7.02, height(600), nil),
+		}, []conflict{
+			{true, 0, "local.geojson#1", VerticalUnknown},
+			{false, 0, "local.geojson#1", VerticalUnknown},
+		}, false},
+		{"in the park's hole", []Position{
+			position(46.045, 7.045, nil, height(50)),
+			position(46.055, 7.055, nil, height(50)),
+		}, []conflict{}, true},
+		{"across the park", []Position{
+			position(46.05, 6.95, nil, height(50)),
+			position(46.05, 7.15, nil, height(90)),
+		}, []conflict{
+			{false, 0, "local.geojson#1", VerticalInside},
+		}, false},
+		{"climbing out of the park", []Position{
+			position(46.05, 6.95, nil, height(100)),
+			position(46.05, 7.15, nil, height(200)),
+		}, []conflict{
+			// Part of the climb is below the ceiling
+			{false, 0, "local.geojson#1", VerticalInside},
+		}, false},
+		{"under the range floor", []Position{
+			position(46.05, 6.05, height(100), height(50)),
+			position(46.05, 6.55, height(140), height(90)),
+		}, []conflict{
+			{true, 0, "42", VerticalBelow},
+			{true, 1, "42", VerticalBelow},
+			{false, 0, "42", VerticalBelow},
+		}, true},
+		{"within the range", []Position{
+			position(46.05, 6.05, height(1000), nil),
+		}, []conflict{
+			{true, 0, "42", VerticalInside},
+		}, false},
+		{"above the range", []Position{
+			position(46.05, 6.55, height(2000), nil),
+		}, []conflict{
+			{true, 0, "42", VerticalAbove},
+		}, true},
+		{"control zone from the ground", []Position{
+			position(47.45, 8.55, height(900), height(400)),
+		}, []conflict{
+			{true, 0, "150001", VerticalInside},
+		}, false},
+		{"above the control zone", []Position{
+			position(47.45, 8.55, height(1100), height(600)),
+		}, []conflict{
+			{true, 0, "150001", VerticalAbove},
+		}, true},
+		{"below the TMA", []Position{
+			position(47.1, 8.1, height(700), height(200)),
+			position(47.2, 8.2, height(750), height(200)),
+		}, []conflict{
+			{true, 0, "switzerland.xml#2", VerticalBelow},
+			{true, 1, "switzerland.xml#2", VerticalBelow},
+			{false, 0, "switzerland.xml#2", VerticalBelow},
+		}, true},
+		{"climbing into the TMA", []Position{
+			position(47.1, 8.1, height(700), nil),
+			position(47.2, 8.2, height(800), nil),
+		}, []conflict{
+			{true, 0, "switzerland.xml#2", VerticalBelow},
+			{true, 1, "switzerland.xml#2", VerticalInside},
+			{false, 0, "switzerland.xml#2", VerticalInside},
+		}, false},
+		{"TMA without heights above sea level", []Position{
+			position(47.1, 8.1, nil, height(100)),
+		}, []conflict{
+			{true, 0, "switzerland.xml#2", VerticalUnknown},
+		}, false},
+		{"clear of every zone", []Position{
+			position(45, 5, nil, nil),
+			position(45.1, 5.1, nil, nil),
+		}, []conflict{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := index.Check(tt.route)
+			var got []conflict
+			for _, c := range report.Waypoints {
+				got = append(got, conflict{true, c.Waypoint, c.Zone.ID, c.Vertical})
+			}
+			for _, c := range report.Segments {
+				if c.To != c.From+1 {
+					t.Errorf("segment %d-%d", c.From, c.To)
+				}
+				got = append(got, conflict{false, c.From, c.Zone.ID, c.Vertical})
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got conflicts %+v, want %+v", got, tt.want)
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("conflict %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+			if report.Clear != tt.clear || report.Zones != 4 {
+				t.Errorf("clear = %v with %d zones, want %v with 4", report.Clear, report.Zones, tt.clear)
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package airspace
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"drone-planner/server/geometry"
+)
+
+type geoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []geoJSONFeature `json:"features"`
+}
+
+type geoJSONFeature struct {
+	ID         interface{}                `json:"id"`
+	Geometry   *geoJSONGeometry           `json:"geometry"`
+	Properties map[string]json.RawMessage `json:"properties"`
+}
+
+type geoJSONGeometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+// geoJSONLimit is a floor or ceiling in either the plain form
+// {value, unit, reference} or the OpenAIP form {value, unit, referenceDatum}
+// with numeric codes
+type geoJSONLimit struct {
+	Value          float64     `json:"value"`
+	Unit           interface{} `json:"unit"`
+	Reference      interface{} `json:"reference"`
+	ReferenceDatum interface{} `json:"referenceDatum"`
+}
+
+// OpenAIP numeric codes
+var (
+	openAIPClasses    = []string{"A", "B", "C", "D", "E", "F", "G"}
+	openAIPUnitCodes  = map[float64]string{0: UnitMeters, 1: UnitFeet, 6: UnitFlightLevel}
+	openAIPReferences = []string{ReferenceGround, ReferenceMSL, ReferenceStandard}
+)
+
+// geoJSONUnits maps textual units to limit units
+var geoJSONUnits = map[string]string{
+	"m": UnitMeters, "meters": UnitMeters, "metres": UnitMeters,
+	"ft": UnitFeet, "feet": UnitFeet, "f": UnitFeet,
+	"fl": UnitFlightLevel,
+}
+
+// geoJSONReferences maps textual references to limit references
+var geoJSONReferences = map[string]string{
+	"gnd": ReferenceGround, "agl": ReferenceGround, "sfc": ReferenceGround,
+	"msl": ReferenceMSL, "amsl": ReferenceMSL,
+	"std": ReferenceStandard,
+}
+
+// parseGeoJSON reads the Polygon and MultiPolygon features of a GeoJSON
+// feature collection. Other geometries are skipped.
+func parseGeoJSON(data []byte, source string) ([]*Zone, error) {
+	var collection geoJSONFeatureCollection
+	if err := json.Unmarshal(data, &collection); err != nil {
+		return nil, fmt.Errorf("invalid GeoJSON: %v", err)
+	}
+	if collection.Type != "FeatureCollection" {
+		return nil, fmt.Errorf("expected a FeatureCollection, got %q", collection.Type)
+	}
+
+	var zones []*Zone
+	for i, feature := range collection.Features {
+		if feature.Geometry == nil {
+			continue
+		}
+		var polygons []geometry.Polygon
+		var err error
+		switch feature.Geometry.Type {
+		case "Polygon":
+			var rings [][][]float64
+			if err = json.Unmarshal(feature.Geometry.Coordinates, &rings); err == nil {
+				var polygon geometry.Polygon
+				polygon, err = geoJSONPolygon(rings)
+				polygons = append(polygons, polygon)
+			}
+		case "MultiPolygon":
+			var parts [][][][]float64
+			if err = json.Unmarshal(feature.Geometry.Coordinates, &parts); err == nil {
+				for _, rings := range parts {
+					var polygon geometry.Polygon
+					if polygon, err = geoJSONPolygon(rings); err != nil {
+						break
+					}
+					polygons = append(polygons, polygon)
+				}
+			}
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("feature %d: %v", i+1, err)
+		}
+
+		zone, err := geoJSONZone(feature, polygons)
+		if err != nil {
+			return nil, fmt.Errorf("feature %d: %v", i+1, err)
+		}
+		zone.Source = source
+		if zone.ID == "" {
+			zone.ID = fmt.Sprintf("%s#%d", source, i+1)
+		}
+		zones = append(zones, zone)
+	}
+	return zones, nil
+}
+
+// geoJSONPolygon converts the [lng, lat] rings of a GeoJSON polygon
+func geoJSONPolygon(rings [][][]float64) (geometry.Polygon, error) {
+	var polygon geometry.Polygon
+	if len(rings) == 0 {
+		return polygon, fmt.Errorf("polygon has no rings")
+	}
+	for i, coords := range rings {
+		ring := make([]geometry.Point, 0, len(coords))
+		for _, c := range coords {
+			if len(c) < 2 {
+				return polygon, fmt.Errorf("invalid position %v", c)
+			}
+			ring = append(ring, geometry.Point{Latitude: c[1], Longitude: c[0]})
+		}
+		ring, err := closeRing(ring)
+		if err != nil {
+			return polygon, err
+		}
+		if i == 0 {
+			polygon.Outer = ring
+		} else {
+			polygon.Holes = append(polygon.Holes, ring)
+		}
+	}
+	return polygon, nil
+}
+
+// geoJSONZone reads a zone's attributes from feature properties
+func geoJSONZone(feature geoJSONFeature, polygons []geometry.Polygon) (*Zone, error) {
+	zone := &Zone{Floor: ground, Ceiling: unlimited, Polygons: polygons}
+	props := feature.Properties
+
+	zone.ID = propertyString(props, "id")
+	if zone.ID == "" && feature.ID != nil {
+		zone.ID = fmt.Sprint(feature.ID)
+	}
+	zone.Name = propertyString(props, "name")
+	zone.Type = strings.ToUpper(propertyString(props, "type", "category"))
+
+	if raw, ok := firstProperty(props, "class", "icaoClass"); ok {
+		var value interface{}
+		json.Unmarshal(raw, &value)
+		switch v := value.(type) {
+		case string:
+			zone.Class = strings.ToUpper(v)
+		case float64:
+			if v >= 0 && int(v) < len(openAIPClasses) {
+				zone.Class = openAIPClasses[int(v)]
+			}
+		}
+	}
+
+	var err error
+	if raw, ok := firstProperty(props, "floor", "lowerLimit"); ok {
+		if zone.Floor, err = geoJSONLimitValue(raw); err != nil {
+			return nil, fmt.Errorf("floor: %v", err)
+		}
+	}
+	if raw, ok := firstProperty(props, "ceiling", "upperLimit"); ok {
+		if zone.Ceiling, err = geoJSONLimitValue(raw); err != nil {
+			return nil, fmt.Errorf("ceiling: %v", err)
+		}
+	}
+	return zone, nil
+}
+
+// geoJSONLimitValue parses a limit object, or a plain number of meters above
+// ground
+func geoJSONLimitValue(raw json.RawMessage) (Limit, error) {
+	var meters float64
+	if err := json.Unmarshal(raw, &meters); err == nil {
+		return Limit{Value: meters, Unit: UnitMeters, Reference: ReferenceGround}, nil
+	}
+	var parsed geoJSONLimit
+	if err := json.Unmarshal(raw, &parsed); err != nil {
+		return Limit{}, fmt.Errorf("invalid limit: %v", err)
+	}
+
+	limit := Limit{Value: parsed.Value, Unit: UnitMeters, Reference: ReferenceGround}
+	switch unit := parsed.Unit.(type) {
+	case nil:
+	case string:
+		mapped, ok := geoJSONUnits[strings.ToLower(unit)]
+		if !ok {
+			return Limit{}, fmt.Errorf("unknown unit %q", unit)
+		}
+		limit.Unit = mapped
+	case float64:
+		mapped, ok := openAIPUnitCodes[unit]
+		if !ok {
+			return Limit{}, fmt.Errorf("unknown unit code %v", unit)
+		}
+		limit.Unit = mapped
+	}
+
+	reference := parsed.Reference
+	if reference == nil {
+		reference = parsed.ReferenceDatum
+	}
+	switch ref := reference.(type) {
+	case nil:
+		// Flight levels are always pressure altitudes
+		if limit.Unit == UnitFlightLevel {
+			limit.Reference = ReferenceStandard
+		}
+	case string:
+		mapped, ok := geoJSONReferences[strings.ToLower(ref)]
+		if !ok {
+			return Limit{}, fmt.Errorf("unknown reference %q", ref)
+		}
+		limit.Reference = mapped
+	case float64:
+		if ref < 0 || int(ref) >= len(openAIPReferences) {
+			return Limit{}, fmt.Errorf("unknown reference code %v", ref)
+		}
+		limit.Reference = openAIPReferences[int(ref)]
+	}
+	return limit, nil
+}
+
+// firstProperty returns the first of the given properties that is set
+func firstProperty(props map[string]json.RawMessage, keys ...string) (json.RawMessage, bool) {
+	for _, key := range keys {
+		if raw, ok := props[key]; ok && string(raw) != "null" {
+			return raw, true
+		}
+	}
+	return nil, false
+}
+
+// propertyString returns the first of the given properties as text
+func propertyString(props map[string]json.RawMessage, keys ...string) string {
+	raw, ok := firstProperty(props, keys...)
+	if !ok {
+		return ""
+	}
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return ""
+	}
+	switch v := value.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case float64:
+		return fmt.Sprint(v)
+	}
+	return ""
+}
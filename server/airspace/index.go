@@ -0,0 +1,122 @@
+package airspace
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"drone-planner/server/geometry"
+)
+
+// cellSize is the side of an index grid cell (degrees)
+const cellSize = 0.5
+
+// Index is an in-memory grid index of zones by bounding box
+type Index struct {
+	zones []*Zone
+	cells map[[2]int][]int
+}
+
+// NewIndex indexes the given zones
+func NewIndex(zones []*Zone) *Index {
+	index := &Index{cells: make(map[[2]int][]int)}
+	for _, zone := range zones {
+		index.Add(zone)
+	}
+	return index
+}
+
+// LoadDirectory indexes every OpenAIP XML (.xml) and GeoJSON (.geojson,
+// .json) file in a directory
+func LoadDirectory(dir string) (*Index, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read airspace directory: %v", err)
+	}
+
+	index := NewIndex(nil)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		var parse func([]byte, string) ([]*Zone, error)
+		switch strings.ToLower(filepath.Ext(name)) {
+		case ".xml":
+			parse = parseOpenAIP
+		case ".geojson", ".json":
+			parse = parseGeoJSON
+		default:
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", name, err)
+		}
+		zones, err := parse(data, name)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		for _, zone := range zones {
+			index.Add(zone)
+		}
+	}
+	return index, nil
+}
+
+// Add indexes a zone
+func (idx *Index) Add(zone *Zone) {
+	zone.updateBounds()
+	id := len(idx.zones)
+	idx.zones = append(idx.zones, zone)
+	minLat, minLng, maxLat, maxLng := cellRange(zone.bounds)
+	for i := minLat; i <= maxLat; i++ {
+		for j := minLng; j <= maxLng; j++ {
+			key := [2]int{i, j}
+			idx.cells[key] = append(idx.cells[key], id)
+		}
+	}
+}
+
+// Len returns the number of indexed zones
+func (idx *Index) Len() int {
+	return len(idx.zones)
+}
+
+// Query returns the zones whose bounding box overlaps the given one, in the
+// order they were added
+func (idx *Index) Query(bounds geometry.Bounds) []*Zone {
+	seen := make(map[int]bool)
+	var ids []int
+	minLat, minLng, maxLat, maxLng := cellRange(bounds)
+	for i := minLat; i <= maxLat; i++ {
+		for j := minLng; j <= maxLng; j++ {
+			for _, id := range idx.cells[[2]int{i, j}] {
+				if seen[id] {
+					continue
+				}
+				seen[id] = true
+				if idx.zones[id].bounds.Intersects(bounds) {
+					ids = append(ids, id)
+				}
+			}
+		}
+	}
+	sort.Ints(ids)
+
+	zones := make([]*Zone, len(ids))
+	for i, id := range ids {
+		zones[i] = idx.zones[id]
+	}
+	return zones
+}
+
+// cellRange returns the grid cells covered by a bounding box
+func cellRange(b geometry.Bounds) (minLat, minLng, maxLat, maxLng int) {
+	return int(math.Floor(b.MinLatitude / cellSize)), int(math.Floor(b.MinLongitude / cellSize)),
+		int(math.Floor(b.MaxLatitude / cellSize)), int(math.Floor(b.MaxLongitude / cellSize))
+}
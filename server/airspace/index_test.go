@@ -0,0 +1,151 @@
+package airspace
+
+import (
+	"strings"
+	"testing"
+
+	"drone-planner/server/geometry"
+)
+
+func TestLoadDirectory(t *testing.T) {
+	index, err := LoadDirectory("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Files load in name order; the point and the feature without a geometry
+	// are skipped
+	want := []Zone{
+		{ID: "local.geojson#1", Name: "Nature park", Type: "RESTRICTED", Source: "local.geojson",
+			Floor: Limit{Value: 0, Unit: UnitMeters, Reference: ReferenceGround}, Ceiling: Limit{Value: 120, Unit: UnitMeters, Reference: ReferenceGround}},
+		{ID: "42", Name: "Range", Class: "G", Type: "DANGER", Source: "local.geojson",
+			Floor: Limit{Value: 500, Unit: UnitFeet, Reference: ReferenceMSL}, Ceiling: Limit{Value: 65, Unit: UnitFlightLevel, Reference: ReferenceStandard}},
+		{ID: "150001", Name: "CTR ZURICH", Type: "CTR", Source: "switzerland.xml",
+			Floor: Limit{Value: 0, Unit: UnitFeet, Reference: ReferenceGround}, Ceiling: Limit{Value: 3500, Unit: UnitFeet, Reference: ReferenceMSL}},
+		{ID: "switzerland.xml#2", Name: "TMA ZURICH 1", Class: "D", Type: "D", Source: "switzerland.xml",
+			Floor: Limit{Value: 2500, Unit: UnitFeet, Reference: ReferenceMSL}, Ceiling: Limit{Value: 100, Unit: UnitFlightLevel, Reference: ReferenceStandard}},
+	}
+	if index.Len() != len(want) {
+		t.Fatalf("indexed %d zones, want %d", index.Len(), len(want))
+	}
+	for i, w := range want {
+		zone := index.zones[i]
+		if zone.ID != w.ID || zone.Name != w.Name || zone.Class != w.Class || zone.Type != w.Type || zone.Source != w.Source {
+			t.Errorf("zone %d = %s %q class %q type %q from %s, want %s %q class %q type %q from %s",
+				i, zone.ID, zone.Name, zone.Class, zone.Type, zone.Source, w.ID, w.Name, w.Class, w.Type, w.Source)
+		}
+		if zone.Floor != w.Floor || zone.Ceiling != w.Ceiling {
+			t.Errorf("zone %d limits %+v to %+v, want %+v to %+v", i, zone.Floor, zone.Ceiling, w.Floor, w.Ceiling)
+		}
+	}
+
+	park, rangeZone, ctr, tma := index.zones[0], index.zones[1], index.zones[2], index.zones[3]
+	if len(park.Polygons) != 1 || len(park.Polygons[0].Outer) != 4 || len(park.Polygons[0].Holes) != 1 {
+		t.Errorf("park polygons = %+v, want a square with a hole", park.Polygons)
+	}
+	if len(rangeZone.Polygons) != 2 {
+		t.Errorf("range has %d polygons, want 2", len(rangeZone.Polygons))
+	}
+	// The closing point of the OpenAIP ring is dropped
+	if len(ctr.Polygons) != 1 || len(ctr.Polygons[0].Outer) != 4 || len(tma.Polygons[0].Outer) != 4 {
+		t.Errorf("OpenAIP rings = %+v and %+v, want 4 points each", ctr.Polygons, tma.Polygons)
+	}
+	if !ctr.Contains(47.45, 8.55) || ctr.Contains(47.45, 8.65) || park.Contains(46.05, 7.05) || !park.Contains(46.02, 7.02) {
+		t.Error("zone containment does not match the fixture polygons")
+	}
+}
+
+func TestLimitMeters(t *testing.T) {
+	tests := []struct {
+		limit Limit
+		want  float64
+	}{
+		{Limit{Value: 120, Unit: UnitMeters}, 120},
+		{Limit{Value: 3500, Unit: UnitFeet}, 1066.8},
+		{Limit{Value: 65, Unit: UnitFlightLevel}, 1981.2},
+	}
+	for _, tt := range tests {
+		if got := tt.limit.Meters(); got < tt.want-1e-9 || got > tt.want+1e-9 {
+			t.Errorf("%+v = %g m, want %g", tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	asp := func(top, bottom, polygon string) string {
+		return `<OPENAIP><AIRSPACES><ASP CATEGORY="CTR"><NAME>X</NAME>` + top + bottom +
+			`<GEOMETRY><POLYGON>` + polygon + `</POLYGON></GEOMETRY></ASP></AIRSPACES></OPENAIP>`
+	}
+	top := `<ALTLIMIT_TOP REFERENCE="MSL"><ALT UNIT="F">3500</ALT></ALTLIMIT_TOP>`
+	bottom := `<ALTLIMIT_BOTTOM REFERENCE="GND"><ALT UNIT="F">0</ALT></ALTLIMIT_BOTTOM>`
+	square := "8 47, 9 47, 9 48, 8 48"
+	feature := func(geometry, properties string) string {
+		return `{"type": "FeatureCollection", "features": [{"type": "Feature", "geometry": ` + geometry + `, "properties": ` + properties + `}]}`
+	}
+	polygon := `{"type": "Polygon", "coordinates": [[[8, 47], [9, 47], [9, 48]]]}`
+
+	tests := []struct {
+		name  string
+		parse func([]byte, string) ([]*Zone, error)
+		data  string
+		err   string
+	}{
+		{"not XML", parseOpenAIP, "{}", "invalid OpenAIP XML"},
+		{"two point polygon", parseOpenAIP, asp(top, bottom, "8 47, 9 47, 8 47"), "fewer than 3 points"},
+		{"bad polygon point", parseOpenAIP, asp(top, bottom, "8 47, 9, 9 48"), "invalid polygon point"},
+		{"unknown unit", parseOpenAIP, asp(`<ALTLIMIT_TOP REFERENCE="MSL"><ALT UNIT="NM">1</ALT></ALTLIMIT_TOP>`, bottom, square), "ceiling: unknown altitude unit"},
+		{"unknown reference", parseOpenAIP, asp(top, `<ALTLIMIT_BOTTOM REFERENCE="QNH"><ALT UNIT="F">0</ALT></ALTLIMIT_BOTTOM>`, square), "floor: unknown altitude reference"},
+		{"not a collection", parseGeoJSON, `{"type": "Feature"}`, "expected a FeatureCollection"},
+		{"short position", parseGeoJSON, feature(`{"type": "Polygon", "coordinates": [[[8], [9, 47], [9, 48]]]}`, `{}`), "invalid position"},
+		{"no rings", parseGeoJSON, feature(`{"type": "Polygon", "coordinates": []}`, `{}`), "no rings"},
+		{"unknown limit unit", parseGeoJSON, feature(polygon, `{"ceiling": {"value": 1, "unit": "nm"}}`), "ceiling: unknown unit"},
+		{"unknown reference code", parseGeoJSON, feature(polygon, `{"floor": {"value": 1, "referenceDatum": 7}}`), "floor: unknown reference code"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.parse([]byte(tt.data), "test")
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestIndexQuery(t *testing.T) {
+	square := func(id string, lat, lng, size float64) *Zone {
+		return &Zone{ID: id, Polygons: []geometry.Polygon{{Outer: []geometry.Point{
+			{Latitude: lat, Longitude: lng}, {Latitude: lat, Longitude: lng + size},
+			{Latitude: lat + size, Longitude: lng + size}, {Latitude: lat + size, Longitude: lng},
+		}}}}
+	}
+	// "wide" spans four grid cells, "west" lies across the prime meridian
+	index := NewIndex([]*Zone{
+		square("wide", 46.8, 7.8, 0.4),
+		square("small", 47.1, 8.1, 0.05),
+		square("west", 51.4, -0.1, 0.2),
+	})
+
+	tests := []struct {
+		name   string
+		bounds geometry.Bounds
+		want   []string
+	}{
+		{"point in both", geometry.Bounds{MinLatitude: 47.12, MinLongitude: 8.12, MaxLatitude: 47.12, MaxLongitude: 8.12}, []string{"wide", "small"}},
+		{"another cell of the wide zone", geometry.Bounds{MinLatitude: 46.9, MinLongitude: 7.9, MaxLatitude: 46.9, MaxLongitude: 7.9}, []string{"wide"}},
+		{"same cell outside the bounds", geometry.Bounds{MinLatitude: 47.4, MinLongitude: 8.4, MaxLatitude: 47.4, MaxLongitude: 8.4}, nil},
+		{"box over everything", geometry.Bounds{MinLatitude: 40, MinLongitude: -5, MaxLatitude: 55, MaxLongitude: 10}, []string{"wide", "small", "west"}},
+		{"negative longitudes", geometry.Bounds{MinLatitude: 51.5, MinLongitude: -0.05, MaxLatitude: 51.5, MaxLongitude: -0.05}, []string{"west"}},
+		{"elsewhere", geometry.Bounds{MinLatitude: -33, MinLongitude: 151, MaxLatitude: -33, MaxLongitude: 151}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			zones := index.Query(tt.bounds)
+			var got []string
+			for _, zone := range zones {
+				got = append(got, zone.ID)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Query = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package airspace
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"drone-planner/server/geometry"
+)
+
+// openAIPFile is the root of an OpenAIP airspace export (format 1.1)
+type openAIPFile struct {
+	XMLName   xml.Name          `xml:"OPENAIP"`
+	Airspaces []openAIPAirspace `xml:"AIRSPACES>ASP"`
+}
+
+type openAIPAirspace struct {
+	Category string       `xml:"CATEGORY,attr"`
+	ID       string       `xml:"ID"`
+	Country  string       `xml:"COUNTRY"`
+	Name     string       `xml:"NAME"`
+	Top      openAIPLimit `xml:"ALTLIMIT_TOP"`
+	Bottom   openAIPLimit `xml:"ALTLIMIT_BOTTOM"`
+	Polygon  string       `xml:"GEOMETRY>POLYGON"`
+}
+
+type openAIPLimit struct {
+	Reference string `xml:"REFERENCE,attr"`
+	Alt       struct {
+		Unit  string `xml:"UNIT,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"ALT"`
+}
+
+// openAIPUnits maps OpenAIP altitude units to limit units
+var openAIPUnits = map[string]string{
+	"F":  UnitFeet,
+	"FL": UnitFlightLevel,
+	"M":  UnitMeters,
+}
+
+// parseOpenAIP reads the zones of an OpenAIP XML airspace file
+func parseOpenAIP(data []byte, source string) ([]*Zone, error) {
+	var file openAIPFile
+	if err := xml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid OpenAIP XML: %v", err)
+	}
+
+	zones := make([]*Zone, 0, len(file.Airspaces))
+	for i, asp := range file.Airspaces {
+		ring, err := parseOpenAIPPolygon(asp.Polygon)
+		if err != nil {
+			return nil, fmt.Errorf("airspace %d (%s): %v", i+1, asp.Name, err)
+		}
+		floor, err := asp.Bottom.limit()
+		if err != nil {
+			return nil, fmt.Errorf("airspace %d (%s) floor: %v", i+1, asp.Name, err)
+		}
+		ceiling, err := asp.Top.limit()
+		if err != nil {
+			return nil, fmt.Errorf("airspace %d (%s) ceiling: %v", i+1, asp.Name, err)
+		}
+
+		zone := &Zone{
+			ID:       asp.ID,
+			Name:     strings.TrimSpace(asp.Name),
+			Type:     asp.Category,
+			Floor:    floor,
+			Ceiling:  ceiling,
+			Source:   source,
+			Polygons: []geometry.Polygon{{Outer: ring}},
+		}
+		// Classified airspace uses its class letter as the category
+		if len(asp.Category) == 1 && asp.Category >= "A" && asp.Category <= "G" {
+			zone.Class = asp.Category
+		}
+		if zone.ID == "" {
+			zone.ID = fmt.Sprintf("%s#%d", source, i+1)
+		}
+		zones = append(zones, zone)
+	}
+	return zones, nil
+}
+
+// limit converts an OpenAIP altitude limit
+func (l openAIPLimit) limit() (Limit, error) {
+	value, err := strconv.ParseFloat(strings.TrimSpace(l.Alt.Value), 64)
+	if err != nil {
+		return Limit{}, fmt.Errorf("invalid altitude %q", l.Alt.Value)
+	}
+	unit, ok := openAIPUnits[l.Alt.Unit]
+	if !ok {
+		return Limit{}, fmt.Errorf("unknown altitude unit %q", l.Alt.Unit)
+	}
+	reference := strings.ToUpper(l.Reference)
+	switch reference {
+	case ReferenceGround, ReferenceMSL, ReferenceStandard:
+	default:
+		return Limit{}, fmt.Errorf("unknown altitude reference %q", l.Reference)
+	}
+	return Limit{Value: value, Unit: unit, Reference: reference}, nil
+}
+
+// parseOpenAIPPolygon reads a ring of comma-separated "lng lat" pairs
+func parseOpenAIPPolygon(text string) ([]geometry.Point, error) {
+	var ring []geometry.Point
+	for _, pair := range strings.Split(text, ",") {
+		fields := strings.Fields(pair)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid polygon point %q", strings.TrimSpace(pair))
+		}
+		lng, lngErr := strconv.ParseFloat(fields[0], 64)
+		lat, latErr := strconv.ParseFloat(fields[1], 64)
+		if lngErr != nil || latErr != nil {
+			return nil, fmt.Errorf("invalid polygon point %q", strings.TrimSpace(pair))
+		}
+		ring = append(ring, geometry.Point{Latitude: lat, Longitude: lng})
+	}
+	return closeRing(ring)
+}
+
+// closeRing drops a repeated closing point and checks the ring has an area
+func closeRing(ring []geometry.Point) ([]geometry.Point, error) {
+	if n := len(ring); n > 1 && ring[0] == ring[n-1] {
+		ring = ring[:n-1]
+	}
+	if len(ring) < 3 {
+		return nil, fmt.Errorf("polygon has fewer than 3 points")
+	}
+	return ring, nil
+}
@@ -0,0 +1,108 @@
+// Package airspace loads airspace and no-fly zones from OpenAIP XML and
+// GeoJSON files and checks routes against them.
+package airspace
+
+import (
+	"math"
+
+	"drone-planner/server/geometry"
+)
+
+// Limit units
+const (
+	UnitMeters      = "m"
+	UnitFeet        = "ft"
+	UnitFlightLevel = "FL"
+)
+
+// Limit references
+const (
+	ReferenceGround   = "GND"
+	ReferenceMSL      = "MSL"
+	ReferenceStandard = "STD" // Pressure altitudes, used by flight levels
+)
+
+const (
+	metersPerFoot = 0.3048
+	// feetPerFlightLevel converts flight levels to pressure altitudes
+	feetPerFlightLevel = 100
+)
+
+// Limit is the floor or ceiling of a zone
+type Limit struct {
+	Value     float64 `json:"value"`
+	Unit      string  `json:"unit"`
+	Reference string  `json:"reference"`
+	// Unlimited marks a ceiling with no upper bound
+	Unlimited bool `json:"unlimited,omitempty"`
+}
+
+// Meters returns the limit in meters above its reference. Pressure altitudes
+// are taken as heights above sea level, which is close enough at the heights
+// drones fly.
+func (l Limit) Meters() float64 {
+	switch l.Unit {
+	case UnitFeet:
+		return l.Value * metersPerFoot
+	case UnitFlightLevel:
+		return l.Value * feetPerFlightLevel * metersPerFoot
+	}
+	return l.Value
+}
+
+// ground is the floor of zones that reach down to the ground
+var ground = Limit{Value: 0, Unit: UnitMeters, Reference: ReferenceGround}
+
+// unlimited is the ceiling of zones without an upper bound
+var unlimited = Limit{Unit: UnitMeters, Reference: ReferenceMSL, Unlimited: true}
+
+// Zone is an airspace or no-fly zone
+type Zone struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Class is the ICAO airspace class (A-G), empty for zones without one
+	Class string `json:"class,omitempty"`
+	// Type is the kind of zone, such as CTR, RESTRICTED or PROHIBITED
+	Type    string `json:"type,omitempty"`
+	Floor   Limit  `json:"floor"`
+	Ceiling Limit  `json:"ceiling"`
+	// Source is the file the zone was loaded from
+	Source   string             `json:"source"`
+	Polygons []geometry.Polygon `json:"-"`
+	bounds   geometry.Bounds
+}
+
+// Contains reports whether a position lies inside the zone's area
+func (z *Zone) Contains(lat, lng float64) bool {
+	for _, polygon := range z.Polygons {
+		if polygon.Contains(lat, lng) {
+			return true
+		}
+	}
+	return false
+}
+
+// IntersectsSegment reports whether a straight segment enters the zone's area
+func (z *Zone) IntersectsSegment(from, to geometry.Point) bool {
+	for _, polygon := range z.Polygons {
+		if polygon.IntersectsSegment(from, to) {
+			return true
+		}
+	}
+	return false
+}
+
+// updateBounds computes the bounding box of every polygon
+func (z *Zone) updateBounds() {
+	for i, polygon := range z.Polygons {
+		b := polygon.Bounds()
+		if i == 0 {
+			z.bounds = b
+			continue
+		}
+		z.bounds.MinLatitude = math.Min(z.bounds.MinLatitude, b.MinLatitude)
+		z.bounds.MinLongitude = math.Min(z.bounds.MinLongitude, b.MinLongitude)
+		z.bounds.MaxLatitude = math.Max(z.bounds.MaxLatitude, b.MaxLatitude)
+		z.bounds.MaxLongitude = math.Max(z.bounds.MaxLongitude, b.MaxLongitude)
+	}
+}
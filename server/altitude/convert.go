@@ -0,0 +1,163 @@
+// Package altitude converts waypoint altitudes between the reference modes
+// used by the planner and by flight platforms: relative to takeoff, above
+// ground, above mean sea level (EGM96) and above the WGS84 ellipsoid.
+package altitude
+
+import (
+	"errors"
+	"fmt"
+
+	"drone-planner/server/geometry"
+	"drone-planner/server/models"
+	"drone-planner/server/terrain"
+)
+
+var (
+	// ErrNoTerrain is returned for conversions that need ground elevations
+	// when the converter has no terrain provider
+	ErrNoTerrain = errors.New("terrain data is not configured")
+	// ErrNoGeoid is returned for conversions to or from ellipsoid heights
+	// when the converter has no geoid
+	ErrNoGeoid = errors.New("geoid model is not configured")
+)
+
+// Converter converts altitudes between reference modes. Relative and above
+// ground altitudes need Terrain, ellipsoid heights need Geoid; either may be
+// nil when the conversions using it are not needed. A nil Converter only
+// "converts" a mode to itself.
+type Converter struct {
+	Terrain terrain.Provider
+	Geoid   *Geoid
+}
+
+// Target returns mode when a platform supports it, otherwise the platform's
+// preferred mode, which is the first of supported
+func Target(mode string, supported ...string) string {
+	mode = models.NormalizeAltitudeMode(mode)
+	for _, candidate := range supported {
+		if candidate == mode {
+			return mode
+		}
+	}
+	return supported[0]
+}
+
+// Convert converts an altitude at a position from one mode to another.
+// Relative altitudes are measured from the ground at home.
+func (c *Converter) Convert(home geometry.Point, lat, lng, alt float64, from, to string) (float64, error) {
+	return c.frame(home).convert(lat, lng, alt, from, to)
+}
+
+// Mission returns a copy of a waypoint mission with its altitudes converted
+// to a mode, using the mission's home point
+func (c *Converter) Mission(settings models.GlobalMissionSettings, config *models.WaypointMissionConfig, to string) (*models.WaypointMissionConfig, error) {
+	converted := *config
+	if len(config.Waypoints) == 0 {
+		converted.AltitudeMode = to
+		return &converted, nil
+	}
+
+	waypoints, err := c.frame(geometry.HomePoint(settings, config)).waypoints(config.Waypoints, config.AltitudeMode, to)
+	if err != nil {
+		return nil, err
+	}
+	converted.Waypoints = waypoints
+	converted.AltitudeMode = to
+	return &converted, nil
+}
+
+// Flight returns a copy of a flight with its altitudes converted to a mode.
+// Flights have no home point, so relative altitudes are measured from the
+// ground at the first waypoint.
+func (c *Converter) Flight(flight *models.Flight, to string) (*models.Flight, error) {
+	converted := *flight
+	if len(flight.Waypoints) == 0 {
+		converted.AltitudeMode = to
+		return &converted, nil
+	}
+
+	first := flight.Waypoints[0].Coordinate
+	home := geometry.Point{Latitude: first.Latitude, Longitude: first.Longitude}
+	waypoints, err := c.frame(home).waypoints(flight.Waypoints, flight.AltitudeMode, to)
+	if err != nil {
+		return nil, err
+	}
+	converted.Waypoints = waypoints
+	converted.AltitudeMode = to
+	return &converted, nil
+}
+
+// frame converts altitudes around one home point, looking up the ground at
+// home only once
+type frame struct {
+	converter  *Converter
+	home       geometry.Point
+	homeGround *float64
+}
+
+func (c *Converter) frame(home geometry.Point) *frame {
+	return &frame{converter: c, home: home}
+}
+
+// waypoints returns copies of waypoints with their altitudes converted
+func (f *frame) waypoints(waypoints []models.Waypoint, from, to string) ([]models.Waypoint, error) {
+	converted := make([]models.Waypoint, len(waypoints))
+	for i, wp := range waypoints {
+		alt, err := f.convert(wp.Coordinate.Latitude, wp.Coordinate.Longitude, wp.Altitude, from, to)
+		if err != nil {
+			return nil, fmt.Errorf("waypoint %d: %v", i+1, err)
+		}
+		wp.Altitude = alt
+		converted[i] = wp
+	}
+	return converted, nil
+}
+
+func (f *frame) convert(lat, lng, alt float64, from, to string) (float64, error) {
+	from, to = models.NormalizeAltitudeMode(from), models.NormalizeAltitudeMode(to)
+	if from == to {
+		return alt, nil
+	}
+	fromDatum, err := f.datum(lat, lng, from)
+	if err != nil {
+		return 0, fmt.Errorf("converting %s to %s altitude: %w", from, to, err)
+	}
+	toDatum, err := f.datum(lat, lng, to)
+	if err != nil {
+		return 0, fmt.Errorf("converting %s to %s altitude: %w", from, to, err)
+	}
+	return alt + fromDatum - toDatum, nil
+}
+
+// datum returns the height above mean sea level of the surface a mode
+// measures altitudes from, at a position
+func (f *frame) datum(lat, lng float64, mode string) (float64, error) {
+	c := f.converter
+	switch mode {
+	case models.AltitudeMSL:
+		return 0, nil
+	case models.AltitudeEllipsoid:
+		if c == nil || c.Geoid == nil {
+			return 0, ErrNoGeoid
+		}
+		return -c.Geoid.Undulation(lat, lng), nil
+	case models.AltitudeAGL:
+		if c == nil || c.Terrain == nil {
+			return 0, ErrNoTerrain
+		}
+		return c.Terrain.Elevation(lat, lng)
+	case models.AltitudeRelative:
+		if f.homeGround == nil {
+			if c == nil || c.Terrain == nil {
+				return 0, ErrNoTerrain
+			}
+			ground, err := c.Terrain.Elevation(f.home.Latitude, f.home.Longitude)
+			if err != nil {
+				return 0, fmt.Errorf("elevation at home: %v", err)
+			}
+			f.homeGround = &ground
+		}
+		return *f.homeGround, nil
+	}
+	return 0, fmt.Errorf("unknown altitude mode %q", mode)
+}
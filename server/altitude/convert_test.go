@@ -0,0 +1,125 @@
+package altitude
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"drone-planner/server/geometry"
+	"drone-planner/server/models"
+)
+
+// slope is terrain rising 4 m per degree of longitude east
+type slope struct{}
+
+func (slope) Elevation(lat, lng float64) (float64, error) {
+	return 40 + 4*lng, nil
+}
+
+func TestConvert(t *testing.T) {
+	full := &Converter{Terrain: slope{}, Geoid: testGeoid(t)}
+	// Home is on 400 m ground; the waypoint is on 220 m ground, 30 m of
+	// geoid above the ellipsoid
+	home := geometry.Point{Latitude: 0, Longitude: 90}
+	tests := []struct {
+		name      string
+		converter *Converter
+		alt       float64
+		from, to  string
+		want      float64
+		err       error
+	}{
+		{"relative to msl", full, 50, models.AltitudeRelative, models.AltitudeMSL, 450, nil},
+		{"relative to agl", full, 50, models.AltitudeRelative, models.AltitudeAGL, 230, nil},
+		{"relative to ellipsoid", full, 50, models.AltitudeRelative, models.AltitudeEllipsoid, 480, nil},
+		{"empty mode is relative", full, 50, "", models.AltitudeMSL, 450, nil},
+		{"msl to relative", full, 450, models.AltitudeMSL, models.AltitudeRelative, 50, nil},
+		{"agl to msl", full, 100, models.AltitudeAGL, models.AltitudeMSL, 320, nil},
+		{"ellipsoid to agl", full, 480, models.AltitudeEllipsoid, models.AltitudeAGL, 230, nil},
+		{"same mode without data", nil, 75, models.AltitudeAGL, models.AltitudeAGL, 75, nil},
+		{"agl without terrain", &Converter{}, 100, models.AltitudeAGL, models.AltitudeMSL, 0, ErrNoTerrain},
+		{"relative without terrain", &Converter{Geoid: testGeoid(t)}, 50, models.AltitudeRelative, models.AltitudeEllipsoid, 0, ErrNoTerrain},
+		{"ellipsoid without geoid", &Converter{Terrain: slope{}}, 100, models.AltitudeMSL, models.AltitudeEllipsoid, 0, ErrNoGeoid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.converter.Convert(home, 0, 45, tt.alt, tt.from, tt.to)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("got error %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if math.Abs(got-tt.want) > 1e-6 {
+				t.Errorf("Convert(%g %s to %s) = %g, want %g", tt.alt, tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+
+	if _, err := full.Convert(home, 0, 45, 50, "underground", models.AltitudeMSL); err == nil {
+		t.Error("expected an error for an unknown mode")
+	}
+}
+
+func TestConvertMission(t *testing.T) {
+	converter := &Converter{Terrain: slope{}, Geoid: testGeoid(t)}
+	homeLat, homeLng := 0.0, 90.0
+	settings := models.GlobalMissionSettings{HomeLat: &homeLat, HomeLng: &homeLng}
+	config := &models.WaypointMissionConfig{
+		AltitudeMode: models.AltitudeRelative,
+		Waypoints: []models.Waypoint{
+			{Coordinate: models.Coordinate{Latitude: 0, Longitude: 45}, Altitude: 50},
+			{Coordinate: models.Coordinate{Latitude: 0, Longitude: 0}, Altitude: 60},
+		},
+	}
+
+	converted, err := converter.Mission(settings, config, models.AltitudeAGL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if converted.AltitudeMode != models.AltitudeAGL {
+		t.Errorf("altitude mode = %q, want agl", converted.AltitudeMode)
+	}
+	// 400 m of ground at home, 220 m and 40 m under the waypoints
+	for i, want := range []float64{230, 420} {
+		if got := converted.Waypoints[i].Altitude; math.Abs(got-want) > 1e-6 {
+			t.Errorf("waypoint %d at %g m agl, want %g", i+1, got, want)
+		}
+	}
+	if config.Waypoints[0].Altitude != 50 || config.AltitudeMode != models.AltitudeRelative {
+		t.Error("converting changed the original mission")
+	}
+
+	// Flights measure relative altitudes from their first waypoint
+	flight := &models.Flight{AltitudeMode: models.AltitudeRelative, Waypoints: config.Waypoints}
+	convertedFlight, err := converter.Flight(flight, models.AltitudeMSL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []float64{270, 280} {
+		if got := convertedFlight.Waypoints[i].Altitude; math.Abs(got-want) > 1e-6 {
+			t.Errorf("flight waypoint %d at %g m msl, want %g", i+1, got, want)
+		}
+	}
+}
+
+func TestTarget(t *testing.T) {
+	tests := []struct {
+		mode      string
+		supported []string
+		want      string
+	}{
+		{models.AltitudeAGL, []string{models.AltitudeRelative, models.AltitudeAGL}, models.AltitudeAGL},
+		{models.AltitudeMSL, []string{models.AltitudeRelative, models.AltitudeAGL}, models.AltitudeRelative},
+		{"", []string{models.AltitudeMSL, models.AltitudeRelative}, models.AltitudeRelative},
+		{models.AltitudeEllipsoid, []string{models.AltitudeMSL, models.AltitudeRelative}, models.AltitudeMSL},
+	}
+	for _, tt := range tests {
+		if got := Target(tt.mode, tt.supported...); got != tt.want {
+			t.Errorf("Target(%q, %v) = %q, want %q", tt.mode, tt.supported, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package altitude
+
+import (
+	"bufio"
+	"bytes"
+	"embed"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Geoid is a grid of EGM96 geoid undulations, the height of mean sea level
+// above the WGS84 ellipsoid. Rows run from 90N to 90S and columns east from
+// the prime meridian.
+type Geoid struct {
+	width, height int
+	offset, scale float64
+	samples       []uint16
+}
+
+// embeddedGeoidFile is the 15′ EGM96 grid from GeographicLib's
+// geoids-distrib, about 2 MB
+const embeddedGeoidFile = "geoids/egm96-15.pgm"
+
+//go:generate sh -c "curl -sSfL https://downloads.sourceforge.net/project/geographiclib/geoids-distrib/egm96-15.tar.bz2 | tar -xjO geoids/egm96-15.pgm > geoids/egm96-15.pgm"
+//go:embed geoids
+var embeddedGeoids embed.FS
+
+// DefaultGeoid returns the EGM96 grid built into the binary
+func DefaultGeoid() (*Geoid, error) {
+	data, err := embeddedGeoids.ReadFile(embeddedGeoidFile)
+	if err != nil {
+		return nil, fmt.Errorf("no embedded geoid, run go generate in the altitude package: %v", err)
+	}
+	geoid, err := readGeoid(bufio.NewReader(bytes.NewReader(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", embeddedGeoidFile, err)
+	}
+	return geoid, nil
+}
+
+// LoadGeoid reads a geoid grid in the GeographicLib PGM format, such as
+// egm96-5.pgm or egm96-15.pgm
+func LoadGeoid(path string) (*Geoid, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open geoid: %v", err)
+	}
+	defer file.Close()
+
+	geoid, err := readGeoid(bufio.NewReader(file))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return geoid, nil
+}
+
+// readGeoid parses a 16-bit binary PGM whose "# Offset" and "# Scale"
+// comments convert samples to meters
+func readGeoid(reader *bufio.Reader) (*Geoid, error) {
+	g := &Geoid{scale: 1}
+	var header []int
+	magic := false
+	for len(header) < 3 {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("failed to read PGM header: %v", err)
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case !magic:
+			if line != "P5" {
+				return nil, fmt.Errorf("not a binary PGM file")
+			}
+			magic = true
+		case strings.HasPrefix(line, "#"):
+			fields := strings.Fields(strings.TrimPrefix(line, "#"))
+			if len(fields) < 2 {
+				continue
+			}
+			value, err := strconv.ParseFloat(fields[1], 64)
+			switch {
+			case fields[0] == "Offset" && err == nil:
+				g.offset = value
+			case fields[0] == "Scale" && err == nil:
+				g.scale = value
+			}
+		default:
+			for _, field := range strings.Fields(line) {
+				value, err := strconv.Atoi(field)
+				if err != nil {
+					return nil, fmt.Errorf("invalid PGM header value %q", field)
+				}
+				header = append(header, value)
+			}
+		}
+	}
+
+	g.width, g.height = header[0], header[1]
+	if header[2] != 65535 {
+		return nil, fmt.Errorf("expected 16-bit samples, got a maximum of %d", header[2])
+	}
+	if g.width < 2 || g.height < 2 {
+		return nil, fmt.Errorf("invalid grid size %dx%d", g.width, g.height)
+	}
+
+	data := make([]byte, g.width*g.height*2)
+	if _, err := io.ReadFull(reader, data); err != nil {
+		return nil, fmt.Errorf("failed to read geoid samples: %v", err)
+	}
+	g.samples = make([]uint16, g.width*g.height)
+	for i := range g.samples {
+		g.samples[i] = binary.BigEndian.Uint16(data[i*2:])
+	}
+	return g, nil
+}
+
+// Undulation interpolates the geoid height above the ellipsoid (m) at a position
+func (g *Geoid) Undulation(lat, lng float64) float64 {
+	lng = math.Mod(lng, 360)
+	if lng < 0 {
+		lng += 360
+	}
+	x := lng / 360 * float64(g.width)
+	y := math.Min(math.Max((90-lat)/180*float64(g.height-1), 0), float64(g.height-1))
+
+	col := int(math.Floor(x)) % g.width
+	row := int(math.Min(math.Floor(y), float64(g.height-2)))
+	// The grid wraps around the antimeridian
+	next := (col + 1) % g.width
+	fx, fy := x-math.Floor(x), y-float64(row)
+
+	top := g.sample(row, col) + (g.sample(row, next)-g.sample(row, col))*fx
+	bottom := g.sample(row+1, col) + (g.sample(row+1, next)-g.sample(row+1, col))*fx
+	return top + (bottom-top)*fy
+}
+
+// sample returns a grid sample in meters
+func (g *Geoid) sample(row, col int) float64 {
+	return g.offset + g.scale*float64(g.samples[row*g.width+col])
+}
@@ -0,0 +1,94 @@
+package altitude
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"testing"
+)
+
+// encodeGeoid writes a grid of undulations (m) as a GeographicLib PGM with
+// centimeter samples
+func encodeGeoid(width, height int, undulations []float64) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "P5\n# Offset -100\n# Scale 0.01\n%d %d\n65535\n", width, height)
+	for _, u := range undulations {
+		binary.Write(&buf, binary.BigEndian, uint16(math.Round((u+100)/0.01)))
+	}
+	return buf.Bytes()
+}
+
+// testGeoid is a 90° grid: rows at 90N, the equator and 90S
+func testGeoid(t *testing.T) *Geoid {
+	t.Helper()
+	geoid, err := readGeoid(bufio.NewReader(bytes.NewReader(encodeGeoid(4, 3, []float64{
+		10, 10, 10, 10,
+		20, 40, -20, 0,
+		-30, -30, -30, -30,
+	}))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return geoid
+}
+
+func TestGeoidUndulation(t *testing.T) {
+	geoid := testGeoid(t)
+	tests := []struct {
+		name     string
+		lat, lng float64
+		want     float64
+	}{
+		{"grid point", 0, 90, 40},
+		{"between columns", 0, 45, 30},
+		{"between rows", 45, 0, 15},
+		{"west longitude", 0, -90, 0},
+		{"across the antimeridian", 0, 315, 10},
+		{"north pole", 90, 123, 10},
+		{"beyond the south pole", -95, 0, -30},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := geoid.Undulation(tt.lat, tt.lng); math.Abs(got-tt.want) > 0.01 {
+				t.Errorf("Undulation(%g, %g) = %g, want %g", tt.lat, tt.lng, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadGeoidErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"not a PGM", "P2\n4 3\n65535\n"},
+		{"8-bit samples", "P5\n4 3\n255\n"},
+		{"truncated samples", "P5\n4 3\n65535\n\x00\x01"},
+		{"too small", "P5\n1 3\n65535\n\x00\x00\x00\x00\x00\x00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := readGeoid(bufio.NewReader(bytes.NewReader([]byte(tt.data)))); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestDefaultGeoid(t *testing.T) {
+	if _, err := fs.Stat(embeddedGeoids, embeddedGeoidFile); errors.Is(err, fs.ErrNotExist) {
+		t.Skip("geoid grid not generated")
+	}
+	geoid, err := DefaultGeoid()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// EGM96 is 17.16 m above the ellipsoid at 0°N 0°E
+	if got := geoid.Undulation(0, 0); math.Abs(got-17.16) > 0.5 {
+		t.Errorf("Undulation(0, 0) = %g, want about 17.16", got)
+	}
+}
@@ -0,0 +1,122 @@
+package cameras
+
+import (
+	"fmt"
+
+	"drone-planner/server/timeline"
+)
+
+// CalcInput holds the known side of a photogrammetry calculation. Height is
+// given as Altitude or GSD, and each overlap either as a percentage or as
+// the spacing that produces it.
+type CalcInput struct {
+	// Altitude is the height above the ground (m); GSD is used when it is 0
+	Altitude float64 `json:"altitude"`
+	GSD      float64 `json:"gsd"`
+	// FrontOverlap and SideOverlap are percentages, used when TriggerDistance
+	// and LineSpacing (m) are 0
+	FrontOverlap    float64 `json:"frontOverlap"`
+	SideOverlap     float64 `json:"sideOverlap"`
+	TriggerDistance float64 `json:"triggerDistance"`
+	LineSpacing     float64 `json:"lineSpacing"`
+	// AutoFlightSpeed is the planned ground speed (m/s), 0 when not planned
+	AutoFlightSpeed float64 `json:"autoFlightSpeed"`
+	// MaxSpeed is the aircraft's top speed (m/s), 0 when unknown
+	MaxSpeed float64 `json:"-"`
+}
+
+// Footprint is the ground area covered by a photo (m)
+type Footprint struct {
+	Across float64 `json:"across"`
+	Along  float64 `json:"along"`
+}
+
+// Calculation is the full set of photogrammetry figures for a camera
+type Calculation struct {
+	Camera          Profile   `json:"camera"`
+	Altitude        float64   `json:"altitude"`
+	GSD             float64   `json:"gsd"`
+	Footprint       Footprint `json:"footprint"`
+	FrontOverlap    float64   `json:"frontOverlap"`
+	SideOverlap     float64   `json:"sideOverlap"`
+	TriggerDistance float64   `json:"triggerDistance"`
+	LineSpacing     float64   `json:"lineSpacing"`
+	// MaxSpeed is the fastest the photos can be taken at the trigger
+	// distance, capped by the aircraft's top speed; 0 when unlimited
+	MaxSpeed float64 `json:"maxSpeed"`
+	// TriggerInterval is the time between photos at AutoFlightSpeed (s)
+	TriggerInterval float64  `json:"triggerInterval,omitempty"`
+	Warnings        []string `json:"warnings"`
+}
+
+// Calculate derives every photogrammetry figure from the known ones
+func Calculate(p *Profile, in CalcInput) (*Calculation, error) {
+	errs := timeline.NewFieldErrors("")
+	if err := p.Validate(); err != nil {
+		errs.Add("camera", "%v", err)
+	}
+	if in.Altitude <= 0 && in.GSD <= 0 {
+		errs.Add("altitude", "altitude or gsd must be greater than 0")
+	}
+	if in.TriggerDistance < 0 {
+		errs.Add("triggerDistance", "must not be negative")
+	} else if in.TriggerDistance == 0 {
+		errs.Between("frontOverlap", in.FrontOverlap, 0, 99)
+	}
+	if in.LineSpacing < 0 {
+		errs.Add("lineSpacing", "must not be negative")
+	} else if in.LineSpacing == 0 {
+		errs.Between("sideOverlap", in.SideOverlap, 0, 99)
+	}
+	if in.AutoFlightSpeed < 0 {
+		errs.Add("autoFlightSpeed", "must not be negative")
+	}
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+
+	calc := &Calculation{Camera: *p, Altitude: in.Altitude, Warnings: []string{}}
+	if calc.Altitude <= 0 {
+		calc.Altitude = p.HeightForGSD(in.GSD)
+	}
+	calc.GSD = p.GSD(calc.Altitude)
+	across, along := p.Footprint(calc.Altitude)
+	calc.Footprint = Footprint{Across: across, Along: along}
+
+	calc.TriggerDistance, calc.FrontOverlap = in.TriggerDistance, in.FrontOverlap
+	if in.TriggerDistance > 0 {
+		calc.FrontOverlap = (1 - in.TriggerDistance/along) * 100
+	} else {
+		calc.TriggerDistance = along * (1 - in.FrontOverlap/100)
+	}
+	calc.LineSpacing, calc.SideOverlap = in.LineSpacing, in.SideOverlap
+	if in.LineSpacing > 0 {
+		calc.SideOverlap = (1 - in.LineSpacing/across) * 100
+	} else {
+		calc.LineSpacing = across * (1 - in.SideOverlap/100)
+	}
+	if calc.FrontOverlap < 0 {
+		calc.Warnings = append(calc.Warnings, fmt.Sprintf("trigger distance %.1f m leaves gaps between photos %.1f m long", calc.TriggerDistance, along))
+	}
+	if calc.SideOverlap < 0 {
+		calc.Warnings = append(calc.Warnings, fmt.Sprintf("line spacing %.1f m leaves gaps between lines %.1f m wide", calc.LineSpacing, across))
+	}
+
+	calc.MaxSpeed = p.MaxSpeed(calc.TriggerDistance)
+	if in.MaxSpeed > 0 && (calc.MaxSpeed == 0 || in.MaxSpeed < calc.MaxSpeed) {
+		calc.MaxSpeed = in.MaxSpeed
+	}
+
+	if speed := in.AutoFlightSpeed; speed > 0 {
+		calc.TriggerInterval = calc.TriggerDistance / speed
+		if p.MinTriggerInterval > 0 && calc.TriggerInterval < p.MinTriggerInterval {
+			calc.Warnings = append(calc.Warnings, fmt.Sprintf(
+				"%s needs %.1f s between photos but at %.1f m/s they are %.1f s apart; fly at most %.1f m/s or lower the front overlap",
+				p.Name, p.MinTriggerInterval, speed, calc.TriggerInterval, p.MaxSpeed(calc.TriggerDistance)))
+		}
+		if in.MaxSpeed > 0 && speed > in.MaxSpeed {
+			calc.Warnings = append(calc.Warnings, fmt.Sprintf("%.1f m/s is above the aircraft's top speed of %.1f m/s", speed, in.MaxSpeed))
+		}
+	}
+	return calc, nil
+}
@@ -0,0 +1,118 @@
+package cameras
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"drone-planner/server/timeline"
+)
+
+// testCamera covers 100 x 75 m at 100 m with a GSD of 10 cm/px
+var testCamera = Profile{
+	ID: "TEST", Name: "Test camera",
+	SensorWidth: 10, SensorHeight: 7.5, FocalLength: 10,
+	ImageWidth: 1000, ImageHeight: 750, MinTriggerInterval: 2,
+}
+
+func TestCalculateRoundTrip(t *testing.T) {
+	for _, altitude := range []float64{30, 100, 120.5} {
+		byAltitude, err := Calculate(&testCamera, CalcInput{Altitude: altitude, FrontOverlap: 70, SideOverlap: 60})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := altitude / 10; math.Abs(byAltitude.GSD-want) > 1e-9 {
+			t.Errorf("GSD at %g m = %g, want %g", altitude, byAltitude.GSD, want)
+		}
+		byGSD, err := Calculate(&testCamera, CalcInput{GSD: byAltitude.GSD, FrontOverlap: 70, SideOverlap: 60})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(byGSD.Altitude-altitude) > 1e-9 {
+			t.Errorf("altitude for %g cm/px = %g, want %g", byAltitude.GSD, byGSD.Altitude, altitude)
+		}
+
+		// The spacings give back the overlaps they were derived from
+		bySpacing, err := Calculate(&testCamera, CalcInput{
+			Altitude: altitude, TriggerDistance: byAltitude.TriggerDistance, LineSpacing: byAltitude.LineSpacing,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(bySpacing.FrontOverlap-70) > 1e-9 || math.Abs(bySpacing.SideOverlap-60) > 1e-9 {
+			t.Errorf("overlaps at %g m = %g%% and %g%%, want 70 and 60", altitude, bySpacing.FrontOverlap, bySpacing.SideOverlap)
+		}
+		if len(byAltitude.Warnings)+len(byGSD.Warnings)+len(bySpacing.Warnings) != 0 {
+			t.Errorf("unexpected warnings %v %v %v", byAltitude.Warnings, byGSD.Warnings, bySpacing.Warnings)
+		}
+	}
+}
+
+func TestCalculate(t *testing.T) {
+	tests := []struct {
+		name string
+		in   CalcInput
+		// trigger and spacing are the expected distances (m), maxSpeed the
+		// fastest speed (m/s)
+		trigger, spacing, maxSpeed float64
+		warnings                   []string
+	}{
+		{"overlaps", CalcInput{Altitude: 100, FrontOverlap: 70, SideOverlap: 60}, 22.5, 40, 11.25, nil},
+		{"within the trigger interval", CalcInput{Altitude: 100, FrontOverlap: 70, SideOverlap: 60, AutoFlightSpeed: 10}, 22.5, 40, 11.25, nil},
+		{"too fast for the trigger interval", CalcInput{Altitude: 100, FrontOverlap: 70, SideOverlap: 60, AutoFlightSpeed: 15}, 22.5, 40, 11.25,
+			[]string{"Test camera needs 2.0 s between photos but at 15.0 m/s they are 1.5 s apart; fly at most 11.3 m/s"}},
+		{"capped by the aircraft", CalcInput{Altitude: 100, FrontOverlap: 70, SideOverlap: 60, MaxSpeed: 8}, 22.5, 40, 8, nil},
+		{"above the aircraft's top speed", CalcInput{Altitude: 100, FrontOverlap: 70, SideOverlap: 60, AutoFlightSpeed: 10, MaxSpeed: 8}, 22.5, 40, 8,
+			[]string{"10.0 m/s is above the aircraft's top speed of 8.0 m/s"}},
+		{"gaps", CalcInput{Altitude: 100, TriggerDistance: 80, LineSpacing: 110}, 80, 110, 40,
+			[]string{"trigger distance 80.0 m leaves gaps", "line spacing 110.0 m leaves gaps"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calc, err := Calculate(&testCamera, tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if math.Abs(calc.TriggerDistance-tt.trigger) > 1e-9 || math.Abs(calc.LineSpacing-tt.spacing) > 1e-9 || math.Abs(calc.MaxSpeed-tt.maxSpeed) > 1e-9 {
+				t.Errorf("trigger %g m, spacing %g m, max speed %g m/s, want %g, %g and %g",
+					calc.TriggerDistance, calc.LineSpacing, calc.MaxSpeed, tt.trigger, tt.spacing, tt.maxSpeed)
+			}
+			if calc.Footprint.Across != 100 || calc.Footprint.Along != 75 {
+				t.Errorf("footprint = %+v, want 100 x 75", calc.Footprint)
+			}
+			if len(calc.Warnings) != len(tt.warnings) {
+				t.Fatalf("warnings = %q, want %q", calc.Warnings, tt.warnings)
+			}
+			for i, w := range tt.warnings {
+				if !strings.HasPrefix(calc.Warnings[i], w) {
+					t.Errorf("warning %d = %q, want %q", i, calc.Warnings[i], w)
+				}
+			}
+		})
+	}
+}
+
+func TestCalculateValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile Profile
+		in      CalcInput
+		path    string
+	}{
+		{"no height", testCamera, CalcInput{FrontOverlap: 70, SideOverlap: 60}, "altitude"},
+		{"front overlap", testCamera, CalcInput{Altitude: 100, FrontOverlap: 100, SideOverlap: 60}, "frontOverlap"},
+		{"negative line spacing", testCamera, CalcInput{Altitude: 100, FrontOverlap: 70, LineSpacing: -1}, "lineSpacing"},
+		{"negative speed", testCamera, CalcInput{Altitude: 100, AutoFlightSpeed: -1}, "autoFlightSpeed"},
+		{"camera", Profile{Name: "Broken"}, CalcInput{Altitude: 100}, "camera"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Calculate(&tt.profile, tt.in)
+			var validation *timeline.ValidationError
+			if !errors.As(err, &validation) || len(validation.Errors) != 1 || validation.Errors[0].Path != tt.path {
+				t.Fatalf("got error %v, want a validation error at %q", err, tt.path)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+// Package cameras is the catalog of camera sensors the planner knows, with
+// the photogrammetry relations between altitude, ground sampling distance and
+// image footprint.
+package cameras
+
+import "fmt"
+
+// Profile describes a camera sensor and lens. Images are taken in landscape
+// orientation with their width across the flight direction.
+type Profile struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+
+	// SensorWidth and SensorHeight are the sensor dimensions (mm)
+	SensorWidth  float64 `json:"sensorWidth"`
+	SensorHeight float64 `json:"sensorHeight"`
+	// FocalLength is the real (not 35 mm equivalent) focal length (mm)
+	FocalLength float64 `json:"focalLength"`
+	// ImageWidth and ImageHeight are the image dimensions (px)
+	ImageWidth  int `json:"imageWidth"`
+	ImageHeight int `json:"imageHeight"`
+	// MinTriggerInterval is the shortest time between two photos (s)
+	MinTriggerInterval float64 `json:"minTriggerInterval"`
+}
+
+// Validate checks that a profile describes a usable camera
+func (p *Profile) Validate() error {
+	switch {
+	case p.SensorWidth <= 0 || p.SensorHeight <= 0:
+		return fmt.Errorf("camera sensor size must be greater than 0")
+	case p.FocalLength <= 0:
+		return fmt.Errorf("camera focal length must be greater than 0")
+	case p.ImageWidth <= 0 || p.ImageHeight <= 0:
+		return fmt.Errorf("camera image size must be greater than 0")
+	case p.MinTriggerInterval < 0:
+		return fmt.Errorf("camera trigger interval must not be negative")
+	}
+	return nil
+}
+
+// GSD returns the ground sampling distance (cm/px) of nadir photos taken at
+// a height above ground (m)
+func (p *Profile) GSD(height float64) float64 {
+	return p.SensorWidth * height * 100 / (p.FocalLength * float64(p.ImageWidth))
+}
+
+// HeightForGSD returns the height above ground (m) giving a ground sampling
+// distance (cm/px)
+func (p *Profile) HeightForGSD(gsd float64) float64 {
+	return gsd * p.FocalLength * float64(p.ImageWidth) / (p.SensorWidth * 100)
+}
+
+// Footprint returns the ground area (m) covered by a nadir photo taken at a
+// height above ground, across and along the flight direction
+func (p *Profile) Footprint(height float64) (across, along float64) {
+	return p.SensorWidth * height / p.FocalLength, p.SensorHeight * height / p.FocalLength
+}
+
+// Spacing returns the distance between flight lines and between photos (m)
+// giving the side and front overlaps (0-1) at a height above ground
+func (p *Profile) Spacing(height, sideOverlap, frontOverlap float64) (lines, photos float64) {
+	across, along := p.Footprint(height)
+	return across * (1 - sideOverlap), along * (1 - frontOverlap)
+}
+
+// MaxSpeed returns the fastest ground speed (m/s) at which the camera can
+// take a photo every triggerDistance meters, or 0 when it has no interval limit
+func (p *Profile) MaxSpeed(triggerDistance float64) float64 {
+	if p.MinTriggerInterval <= 0 {
+		return 0
+	}
+	return triggerDistance / p.MinTriggerInterval
+}
+
+// catalog lists the known cameras. Intervals are the shortest the aircraft
+// sustain in timed or distance interval shooting.
+var catalog = []Profile{
+	{
+		ID: "M3E_WIDE", Name: "Mavic 3 Enterprise wide camera",
+		SensorWidth: 17.3, SensorHeight: 13, FocalLength: 12.29,
+		ImageWidth: 5280, ImageHeight: 3956, MinTriggerInterval: 0.7,
+	},
+	{
+		ID: "M3T_WIDE", Name: "Mavic 3 Thermal wide camera",
+		SensorWidth: 6.4, SensorHeight: 4.8, FocalLength: 4.4,
+		ImageWidth: 8000, ImageHeight: 6000, MinTriggerInterval: 2,
+	},
+	{
+		ID: "M3M_RGB", Name: "Mavic 3 Multispectral RGB camera",
+		SensorWidth: 17.3, SensorHeight: 13, FocalLength: 12.29,
+		ImageWidth: 5280, ImageHeight: 3956, MinTriggerInterval: 1,
+	},
+	{
+		ID: "M3D_WIDE", Name: "Matrice 3D wide camera",
+		SensorWidth: 17.3, SensorHeight: 13, FocalLength: 12.29,
+		ImageWidth: 5280, ImageHeight: 3956, MinTriggerInterval: 0.5,
+	},
+	{
+		ID: "M3TD_WIDE", Name: "Matrice 3TD wide camera",
+		SensorWidth: 9.6, SensorHeight: 7.2, FocalLength: 6.72,
+		ImageWidth: 8064, ImageHeight: 6048, MinTriggerInterval: 1,
+	},
+	{
+		ID: "M30_WIDE", Name: "Matrice 30 wide camera",
+		SensorWidth: 6.4, SensorHeight: 4.8, FocalLength: 4.5,
+		ImageWidth: 4000, ImageHeight: 3000, MinTriggerInterval: 2,
+	},
+	{
+		ID: "ZENMUSE_P1_24", Name: "Zenmuse P1 (24 mm lens)",
+		SensorWidth: 35.9, SensorHeight: 24, FocalLength: 24,
+		ImageWidth: 8192, ImageHeight: 5460, MinTriggerInterval: 0.7,
+	},
+	{
+		ID: "ZENMUSE_P1_35", Name: "Zenmuse P1 (35 mm lens)",
+		SensorWidth: 35.9, SensorHeight: 24, FocalLength: 35,
+		ImageWidth: 8192, ImageHeight: 5460, MinTriggerInterval: 0.7,
+	},
+	{
+		ID: "ZENMUSE_P1_50", Name: "Zenmuse P1 (50 mm lens)",
+		SensorWidth: 35.9, SensorHeight: 24, FocalLength: 50,
+		ImageWidth: 8192, ImageHeight: 5460, MinTriggerInterval: 0.7,
+	},
+	{
+		ID: "ZENMUSE_H20_WIDE", Name: "Zenmuse H20 wide camera",
+		SensorWidth: 6.17, SensorHeight: 4.55, FocalLength: 4.5,
+		ImageWidth: 4056, ImageHeight: 3040, MinTriggerInterval: 2,
+	},
+	{
+		ID: "MAVIC_3_HASSELBLAD", Name: "Mavic 3 Hasselblad camera",
+		SensorWidth: 17.3, SensorHeight: 13, FocalLength: 12.29,
+		ImageWidth: 5280, ImageHeight: 3956, MinTriggerInterval: 2,
+	},
+	{
+		ID: "M2EA_WIDE", Name: "Mavic 2 Enterprise Advanced visual camera",
+		SensorWidth: 6.4, SensorHeight: 4.8, FocalLength: 4.5,
+		ImageWidth: 8000, ImageHeight: 6000, MinTriggerInterval: 2,
+	},
+	{
+		ID: "M2ED_WIDE", Name: "Mavic 2 Enterprise Dual visual camera",
+		SensorWidth: 6.17, SensorHeight: 4.55, FocalLength: 4.3,
+		ImageWidth: 4056, ImageHeight: 3040, MinTriggerInterval: 2,
+	},
+	{
+		ID: "M2E_WIDE", Name: "Mavic 2 Enterprise camera",
+		SensorWidth: 6.17, SensorHeight: 4.55, FocalLength: 4.3,
+		ImageWidth: 4056, ImageHeight: 3040, MinTriggerInterval: 2,
+	},
+	{
+		ID: "AIR_2S", Name: "Air 2S camera",
+		SensorWidth: 13.2, SensorHeight: 8.8, FocalLength: 8.38,
+		ImageWidth: 5472, ImageHeight: 3648, MinTriggerInterval: 2,
+	},
+	{
+		ID: "MAVIC_AIR_2", Name: "Mavic Air 2 camera",
+		SensorWidth: 6.4, SensorHeight: 4.8, FocalLength: 4.49,
+		ImageWidth: 8000, ImageHeight: 6000, MinTriggerInterval: 2,
+	},
+	{
+		ID: "MAVIC_2_PRO", Name: "Mavic 2 Pro Hasselblad camera",
+		SensorWidth: 13.2, SensorHeight: 8.8, FocalLength: 10.26,
+		ImageWidth: 5472, ImageHeight: 3648, MinTriggerInterval: 2,
+	},
+	{
+		ID: "MAVIC_2_ZOOM", Name: "Mavic 2 Zoom camera (wide end)",
+		SensorWidth: 6.17, SensorHeight: 4.55, FocalLength: 4.3,
+		ImageWidth: 4000, ImageHeight: 3000, MinTriggerInterval: 2,
+	},
+	{
+		ID: "MAVIC_AIR", Name: "Mavic Air camera",
+		SensorWidth: 6.17, SensorHeight: 4.55, FocalLength: 4.5,
+		ImageWidth: 4056, ImageHeight: 3040, MinTriggerInterval: 2,
+	},
+	{
+		ID: "MAVIC_PRO", Name: "Mavic Pro camera",
+		SensorWidth: 6.17, SensorHeight: 4.55, FocalLength: 4.73,
+		ImageWidth: 4000, ImageHeight: 3000, MinTriggerInterval: 2,
+	},
+	{
+		ID: "MINI_2", Name: "Mini 2 camera",
+		SensorWidth: 6.17, SensorHeight: 4.55, FocalLength: 4.49,
+		ImageWidth: 4000, ImageHeight: 3000, MinTriggerInterval: 2,
+	},
+	{
+		ID: "MINI_SE", Name: "Mini SE camera",
+		SensorWidth: 6.17, SensorHeight: 4.55, FocalLength: 4.49,
+		ImageWidth: 4000, ImageHeight: 3000, MinTriggerInterval: 2,
+	},
+	{
+		ID: "MAVIC_MINI", Name: "Mavic Mini camera",
+		SensorWidth: 6.17, SensorHeight: 4.55, FocalLength: 4.49,
+		ImageWidth: 4000, ImageHeight: 3000, MinTriggerInterval: 2,
+	},
+	{
+		ID: "SPARK", Name: "Spark camera",
+		SensorWidth: 6.17, SensorHeight: 4.55, FocalLength: 4.5,
+		ImageWidth: 3968, ImageHeight: 2976, MinTriggerInterval: 2,
+	},
+	{
+		ID: "PHANTOM_4_PRO", Name: "Phantom 4 Pro camera",
+		SensorWidth: 13.2, SensorHeight: 8.8, FocalLength: 8.8,
+		ImageWidth: 5472, ImageHeight: 3648, MinTriggerInterval: 2,
+	},
+	{
+		ID: "PHANTOM_4_RTK", Name: "Phantom 4 RTK camera",
+		SensorWidth: 13.2, SensorHeight: 8.8, FocalLength: 8.8,
+		ImageWidth: 5472, ImageHeight: 3648, MinTriggerInterval: 2,
+	},
+	{
+		ID: "P4_MULTISPECTRAL_RGB", Name: "P4 Multispectral RGB camera",
+		SensorWidth: 4.87, SensorHeight: 3.96, FocalLength: 5.74,
+		ImageWidth: 1600, ImageHeight: 1300, MinTriggerInterval: 1,
+	},
+	{
+		ID: "PHANTOM_4", Name: "Phantom 4 camera",
+		SensorWidth: 6.17, SensorHeight: 4.55, FocalLength: 3.61,
+		ImageWidth: 4000, ImageHeight: 3000, MinTriggerInterval: 2,
+	},
+	{
+		ID: "PHANTOM_3", Name: "Phantom 3 camera",
+		SensorWidth: 6.17, SensorHeight: 4.55, FocalLength: 3.61,
+		ImageWidth: 4000, ImageHeight: 3000, MinTriggerInterval: 2,
+	},
+	{
+		ID: "ZENMUSE_X3", Name: "Zenmuse X3",
+		SensorWidth: 6.17, SensorHeight: 4.55, FocalLength: 3.61,
+		ImageWidth: 4000, ImageHeight: 3000, MinTriggerInterval: 2,
+	},
+	{
+		ID: "ZENMUSE_X5", Name: "Zenmuse X5 (15 mm lens)",
+		SensorWidth: 17.3, SensorHeight: 13, FocalLength: 15,
+		ImageWidth: 4608, ImageHeight: 3456, MinTriggerInterval: 2,
+	},
+	{
+		ID: "ZENMUSE_X5S", Name: "Zenmuse X5S (15 mm lens)",
+		SensorWidth: 17.3, SensorHeight: 13, FocalLength: 15,
+		ImageWidth: 5280, ImageHeight: 3956, MinTriggerInterval: 2,
+	},
+}
+
+// All returns every camera profile
+func All() []Profile {
+	return append([]Profile{}, catalog...)
+}
+
+// Lookup returns the camera profile with the given ID
+func Lookup(id string) (*Profile, bool) {
+	for i := range catalog {
+		if catalog[i].ID == id {
+			profile := catalog[i]
+			return &profile, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,63 @@
+package coverage
+
+import (
+	"math"
+
+	"drone-planner/server/cameras"
+	"drone-planner/server/geometry"
+)
+
+// maxRangeFactor caps how far from the camera, as a multiple of its height,
+// oblique footprints reach. Corners at or above the horizon are cut there.
+const maxRangeFactor = 10.0
+
+// Footprint returns the ground quadrilateral a photo covers, assuming flat
+// ground at the photo's height below the camera. Images are landscape with
+// their width across the camera's heading. It returns false when the
+// camera is on the ground or every corner of the image is above the horizon.
+func Footprint(photo Photo, camera *cameras.Profile) ([]geometry.Point, bool) {
+	if photo.Height <= 0 {
+		return nil, false
+	}
+
+	heading := photo.Heading * math.Pi / 180
+	pitch := photo.GimbalPitch * math.Pi / 180
+	// East/north unit vectors along and across the heading
+	alongE, alongN := math.Sin(heading), math.Cos(heading)
+	rightE, rightN := math.Cos(heading), -math.Sin(heading)
+	// The boresight, and the image's up direction, in east/north/up
+	forward := [3]float64{alongE * math.Cos(pitch), alongN * math.Cos(pitch), math.Sin(pitch)}
+	up := [3]float64{-alongE * math.Sin(pitch), -alongN * math.Sin(pitch), math.Cos(pitch)}
+	right := [3]float64{rightE, rightN, 0}
+
+	halfWidth := camera.SensorWidth / 2 / camera.FocalLength
+	halfHeight := camera.SensorHeight / 2 / camera.FocalLength
+	corners := [4][2]float64{{-halfWidth, -halfHeight}, {halfWidth, -halfHeight}, {halfWidth, halfHeight}, {-halfWidth, halfHeight}}
+
+	plane := geometry.NewPlane(geometry.Point{Latitude: photo.Latitude, Longitude: photo.Longitude})
+	maxRange := photo.Height * maxRangeFactor
+	ground := 0
+	footprint := make([]geometry.Point, 0, 4)
+	for _, corner := range corners {
+		var ray [3]float64
+		for k := range ray {
+			ray[k] = forward[k] + corner[0]*right[k] + corner[1]*up[k]
+		}
+
+		horizontal := math.Hypot(ray[0], ray[1])
+		var x, y float64
+		if ray[2] < 0 && photo.Height/-ray[2]*horizontal <= maxRange {
+			t := photo.Height / -ray[2]
+			x, y = ray[0]*t, ray[1]*t
+			ground++
+		} else if horizontal > 0 {
+			x, y = ray[0]/horizontal*maxRange, ray[1]/horizontal*maxRange
+			if ray[2] < 0 {
+				ground++
+			}
+		}
+		lat, lng := plane.Unproject(x, y)
+		footprint = append(footprint, geometry.Point{Latitude: lat, Longitude: lng})
+	}
+	return footprint, ground > 0
+}
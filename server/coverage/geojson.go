@@ -0,0 +1,58 @@
+package coverage
+
+import (
+	"drone-planner/server/cameras"
+	"drone-planner/server/geometry"
+)
+
+// FeatureCollection is a GeoJSON feature collection
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
+
+// Feature is a GeoJSON feature with a polygon geometry
+type Feature struct {
+	Type       string                 `json:"type"`
+	Geometry   Polygon                `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// Polygon is a GeoJSON polygon
+type Polygon struct {
+	Type        string         `json:"type"`
+	Coordinates [][][2]float64 `json:"coordinates"`
+}
+
+// Footprints computes the footprint of every photo and returns them with
+// their GeoJSON features. Photos that see no ground are left out.
+func Footprints(photos []Photo, camera *cameras.Profile) ([][]geometry.Point, *FeatureCollection) {
+	var footprints [][]geometry.Point
+	collection := &FeatureCollection{Type: "FeatureCollection", Features: []Feature{}}
+	for i, photo := range photos {
+		footprint, ok := Footprint(photo, camera)
+		if !ok {
+			continue
+		}
+		footprints = append(footprints, footprint)
+
+		ring := make([][2]float64, 0, len(footprint)+1)
+		for _, p := range footprint {
+			ring = append(ring, [2]float64{p.Longitude, p.Latitude})
+		}
+		ring = append(ring, ring[0])
+		collection.Features = append(collection.Features, Feature{
+			Type:     "Feature",
+			Geometry: Polygon{Type: "Polygon", Coordinates: [][][2]float64{ring}},
+			Properties: map[string]interface{}{
+				"photo":       i + 1,
+				"source":      photo.Source,
+				"waypoint":    photo.Waypoint,
+				"height":      photo.Height,
+				"heading":     photo.Heading,
+				"gimbalPitch": photo.GimbalPitch,
+			},
+		})
+	}
+	return footprints, collection
+}
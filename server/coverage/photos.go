@@ -0,0 +1,208 @@
+// Package coverage works out where a mission's photos land on the ground and
+// how well they cover an area of interest.
+package coverage
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"drone-planner/server/geometry"
+	"drone-planner/server/models"
+	"drone-planner/server/timeline"
+)
+
+// Photo sources
+const (
+	SourceWaypoint = "waypoint" // takePhoto waypoint action
+	SourceDistance = "distance" // photoInterval waypoint action
+	SourceTimeline = "timeline" // shoot-photo timeline element
+	SourcePanorama = "panorama" // panorama timeline element
+)
+
+// Photo is a photo a mission takes, with the camera pose it is taken from
+type Photo struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	// Height is the camera's height above the ground (m)
+	Height float64 `json:"height"`
+	// Heading is the aircraft's heading and GimbalPitch the camera's pitch
+	// (degrees, -90 straight down)
+	Heading     float64 `json:"heading"`
+	GimbalPitch float64 `json:"gimbalPitch"`
+	Source      string  `json:"source"`
+	// Waypoint is the 1-based waypoint the photo belongs to, 0 for photos
+	// taken by timeline elements
+	Waypoint int `json:"waypoint"`
+}
+
+// maxLegPhotos bounds the distance-triggered photos listed between two
+// waypoints
+const maxLegPhotos = 10000
+
+// HeightFunc converts a waypoint mission's altitudes to heights above ground
+type HeightFunc func(config *models.WaypointMissionConfig) (*models.WaypointMissionConfig, error)
+
+// pose is the aircraft's state while walking the timeline
+type pose struct {
+	position    geometry.Point
+	heading     float64
+	gimbalPitch float64
+}
+
+// MissionPhotos lists the photos a mission takes, walking its timeline in
+// order. Repeats of a waypoint mission retake the same photos and are not
+// listed again.
+func MissionPhotos(mission *models.Mission, heights HeightFunc) ([]Photo, error) {
+	elements := append([]models.TimelineElement{}, mission.TimelineElements...)
+	sort.SliceStable(elements, func(i, j int) bool { return elements[i].Order < elements[j].Order })
+
+	var photos []Photo
+	state := pose{}
+	for i := range elements {
+		config, err := timeline.Decode(&elements[i])
+		if err != nil {
+			return nil, fmt.Errorf("timeline element %d: %v", i+1, err)
+		}
+
+		switch c := config.(type) {
+		case *models.WaypointMissionConfig:
+			converted, err := heights(c)
+			if err != nil {
+				return nil, err
+			}
+			photos = append(photos, waypointPhotos(converted, &state)...)
+		case *models.TakePhotoActionConfig:
+			count := 1
+			if c.PhotoType == "interval" && c.PhotoCount != nil {
+				count = *c.PhotoCount
+			}
+			for k := 0; k < count; k++ {
+				photos = append(photos, state.photo(SourceTimeline, 0))
+			}
+		case *models.PanoramaActionConfig:
+			step, _ := timeline.PanoramaSteps(c)
+			state.gimbalPitch = c.GimbalPitch
+			for k := 0; k < c.PhotoCount; k++ {
+				photos = append(photos, state.photo(SourcePanorama, 0))
+				state.heading = normalizeHeading(state.heading + step)
+			}
+		case *models.ChangeHeadingActionConfig:
+			state.heading = c.Angle
+		case *models.RotateGimbalActionConfig:
+			state.gimbalPitch = c.Pitch
+		}
+	}
+	return photos, nil
+}
+
+// waypointPhotos lists the photos of a waypoint mission whose altitudes are
+// heights above ground, leaving state at the last waypoint
+func waypointPhotos(config *models.WaypointMissionConfig, state *pose) []Photo {
+	var photos []Photo
+	waypoints := config.Waypoints
+	for i, wp := range waypoints {
+		state.position = geometry.Point{Latitude: wp.Coordinate.Latitude, Longitude: wp.Coordinate.Longitude, Altitude: wp.Altitude}
+		state.heading = waypointHeading(config, i)
+		if config.GimbalPitchRotationEnabled {
+			state.gimbalPitch = wp.GimbalPitch
+		}
+
+		interval := 0.0
+		for _, action := range wp.Actions {
+			switch models.NormalizeActionType(action.ActionType) {
+			case models.ActionTakePhoto:
+				photos = append(photos, state.photo(SourceWaypoint, i+1))
+			case models.ActionRotateGimbal:
+				state.gimbalPitch = action.ActionParam
+			case models.ActionRotateAircraft:
+				state.heading = action.ActionParam
+			case models.ActionPhotoInterval:
+				interval = action.ActionParam
+			}
+		}
+
+		// Distance triggering fires at the waypoint, then every interval
+		// meters until the next waypoint
+		if interval <= 0 || i == len(waypoints)-1 {
+			continue
+		}
+		interval = math.Max(interval, timeline.MinPhotoInterval)
+		next := waypoints[i+1]
+		length := geometry.Distance(wp.Coordinate.Latitude, wp.Coordinate.Longitude, next.Coordinate.Latitude, next.Coordinate.Longitude)
+		count := int(math.Min(math.Ceil(length/interval), maxLegPhotos))
+		for k := 0; k < count; k++ {
+			t := float64(k) * interval / length
+			shot := *state
+			shot.position = geometry.Point{
+				Latitude:  wp.Coordinate.Latitude + t*(next.Coordinate.Latitude-wp.Coordinate.Latitude),
+				Longitude: wp.Coordinate.Longitude + t*(next.Coordinate.Longitude-wp.Coordinate.Longitude),
+				Altitude:  wp.Altitude + t*(next.Altitude-wp.Altitude),
+			}
+			photos = append(photos, shot.photo(SourceDistance, i+1))
+		}
+	}
+	return photos
+}
+
+// waypointHeading returns the heading the aircraft holds at a waypoint under
+// the mission's heading mode
+func waypointHeading(config *models.WaypointMissionConfig, index int) float64 {
+	waypoints := config.Waypoints
+	wp := waypoints[index]
+	switch config.HeadingMode {
+	case "USING_WAYPOINT_HEADING":
+		return wp.Heading
+	case "TOWARD_POINT_OF_INTEREST":
+		target, ok := waypointTarget(wp, config)
+		if ok {
+			return normalizeHeading(geometry.Bearing(wp.Coordinate.Latitude, wp.Coordinate.Longitude, target.Lat, target.Lng))
+		}
+	case "USING_INITIAL_DIRECTION":
+		index = 0
+	}
+
+	// Otherwise the aircraft faces along the leg it flies next
+	from, to := index, index+1
+	if to >= len(waypoints) {
+		from, to = index-1, index
+	}
+	if from < 0 {
+		return 0
+	}
+	a, b := waypoints[from].Coordinate, waypoints[to].Coordinate
+	return normalizeHeading(geometry.Bearing(a.Latitude, a.Longitude, b.Latitude, b.Longitude))
+}
+
+// waypointTarget returns the point of interest a waypoint faces
+func waypointTarget(wp models.Waypoint, config *models.WaypointMissionConfig) (models.Target, bool) {
+	if len(wp.Targets) > 0 {
+		return wp.Targets[0], true
+	}
+	if len(config.Targets) > 0 {
+		return config.Targets[0], true
+	}
+	return models.Target{}, false
+}
+
+// photo returns a photo taken from the current pose
+func (p pose) photo(source string, waypoint int) Photo {
+	return Photo{
+		Latitude:    p.position.Latitude,
+		Longitude:   p.position.Longitude,
+		Height:      p.position.Altitude,
+		Heading:     p.heading,
+		GimbalPitch: p.gimbalPitch,
+		Source:      source,
+		Waypoint:    waypoint,
+	}
+}
+
+// normalizeHeading wraps a heading into the -180..180 range
+func normalizeHeading(heading float64) float64 {
+	heading = math.Mod(heading+180, 360)
+	if heading < 0 {
+		heading += 360
+	}
+	return heading - 180
+}
@@ -0,0 +1,52 @@
+package coverage
+
+import (
+	"testing"
+
+	"drone-planner/server/geometry"
+	"drone-planner/server/models"
+)
+
+func TestWaypointPhotosInterval(t *testing.T) {
+	// The legs run about 1112 m due north
+	tests := []struct {
+		name     string
+		interval float64
+		want     int
+	}{
+		{"none", 0, 0},
+		{"every 100 m", 100, 12},
+		{"below the minimum", 0.01, 1112},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &models.WaypointMissionConfig{Waypoints: []models.Waypoint{
+				{Coordinate: models.Coordinate{Latitude: 47, Longitude: 8}, Altitude: 50, Actions: []models.WaypointAction{{ActionType: models.ActionPhotoInterval, ActionParam: tt.interval}}},
+				{Coordinate: models.Coordinate{Latitude: 47.01, Longitude: 8}, Altitude: 50},
+			}}
+			photos := waypointPhotos(config, &pose{})
+			if len(photos) != tt.want {
+				t.Fatalf("got %d photos, want %d", len(photos), tt.want)
+			}
+			for _, photo := range photos {
+				if photo.Source != SourceDistance || photo.Waypoint != 1 || photo.Height != 50 {
+					t.Fatalf("unexpected photo %+v", photo)
+				}
+			}
+		})
+	}
+}
+
+func TestWaypointPhotosLegCap(t *testing.T) {
+	// A leg of about 22 km at the minimum interval
+	config := &models.WaypointMissionConfig{Waypoints: []models.Waypoint{
+		{Coordinate: models.Coordinate{Latitude: 47, Longitude: 8}, Actions: []models.WaypointAction{{ActionType: models.ActionPhotoInterval, ActionParam: 1}}},
+		{Coordinate: models.Coordinate{Latitude: 47.2, Longitude: 8}},
+	}}
+	if length := geometry.Distance(47, 8, 47.2, 8); length < maxLegPhotos {
+		t.Fatalf("leg of %g m is too short to reach the cap", length)
+	}
+	if photos := waypointPhotos(config, &pose{}); len(photos) != maxLegPhotos {
+		t.Errorf("got %d photos, want the %d photo cap", len(photos), maxLegPhotos)
+	}
+}
@@ -0,0 +1,156 @@
+package coverage
+
+import (
+	"math"
+
+	"drone-planner/server/geometry"
+)
+
+const (
+	// maxRasterCells bounds the raster's rows and columns
+	maxRasterCells = 256
+	// minCellSize is the smallest raster cell (m)
+	minCellSize = 1.0
+)
+
+// Raster counts how many photos cover each cell of a grid
+type Raster struct {
+	// West, South, East and North bound the grid (degrees)
+	West  float64 `json:"west"`
+	South float64 `json:"south"`
+	East  float64 `json:"east"`
+	North float64 `json:"north"`
+	// CellSize is the side of a cell (m)
+	CellSize float64 `json:"cellSize"`
+	Rows     int     `json:"rows"`
+	Cols     int     `json:"cols"`
+	// Counts holds the overlap of each cell row by row, starting at the
+	// north-west corner
+	Counts []int `json:"counts"`
+}
+
+// Coverage is the share of an area of interest covered by enough photos
+type Coverage struct {
+	MinOverlap int `json:"minOverlap"`
+	// Percent is the share of the area covered at MinOverlap or more
+	Percent float64 `json:"percent"`
+	// Histogram holds the percentage of the area covered by 0, 1, 2, ...
+	// photos
+	Histogram []float64 `json:"histogram"`
+}
+
+// Analyze rasterizes footprints over the area of interest, or over the
+// footprints themselves when aoi is empty, and measures the area covered by
+// minOverlap or more photos. cellSize is enlarged to keep the grid within
+// maxRasterCells on a side; 0 picks the finest allowed.
+func Analyze(footprints [][]geometry.Point, aoi []geometry.Point, cellSize float64, minOverlap int) (*Raster, *Coverage) {
+	extent := aoi
+	if len(extent) == 0 {
+		for _, footprint := range footprints {
+			extent = append(extent, footprint...)
+		}
+	}
+	if len(extent) == 0 {
+		return nil, nil
+	}
+
+	bounds := geometry.Polygon{Outer: extent}.Bounds()
+	plane := geometry.NewPlane(geometry.Point{Latitude: bounds.MinLatitude, Longitude: bounds.MinLongitude})
+	width, height := plane.Project(bounds.MaxLatitude, bounds.MaxLongitude)
+	cellSize = math.Max(cellSize, math.Max(minCellSize, math.Max(width, height)/maxRasterCells))
+	cols := int(math.Max(1, math.Ceil(width/cellSize)))
+	rows := int(math.Max(1, math.Ceil(height/cellSize)))
+
+	north, east := plane.Unproject(float64(cols)*cellSize, float64(rows)*cellSize)
+	raster := &Raster{
+		West: bounds.MinLongitude, South: bounds.MinLatitude, East: east, North: north,
+		CellSize: cellSize, Rows: rows, Cols: cols,
+		Counts: make([]int, rows*cols),
+	}
+
+	// Count in plane coordinates, only over the cells under each footprint
+	for _, footprint := range footprints {
+		ring := project(plane, footprint)
+		minX, minY, maxX, maxY := ringBounds(ring)
+		firstCol, lastCol := clampCell(minX/cellSize, cols), clampCell(maxX/cellSize, cols)
+		firstRow, lastRow := clampCell(minY/cellSize, rows), clampCell(maxY/cellSize, rows)
+		for row := firstRow; row <= lastRow; row++ {
+			for col := firstCol; col <= lastCol; col++ {
+				if ringContains(ring, (float64(col)+0.5)*cellSize, (float64(row)+0.5)*cellSize) {
+					raster.Counts[(rows-1-row)*cols+col]++
+				}
+			}
+		}
+	}
+
+	if len(aoi) == 0 {
+		return raster, nil
+	}
+	area := project(plane, aoi)
+	coverage := &Coverage{MinOverlap: minOverlap}
+	var counts []int
+	inside := 0
+	for row := 0; row < rows; row++ {
+		for col := 0; col < cols; col++ {
+			if !ringContains(area, (float64(col)+0.5)*cellSize, (float64(row)+0.5)*cellSize) {
+				continue
+			}
+			inside++
+			count := raster.Counts[(rows-1-row)*cols+col]
+			for len(counts) <= count {
+				counts = append(counts, 0)
+			}
+			counts[count]++
+		}
+	}
+	if inside == 0 {
+		return raster, coverage
+	}
+	covered := 0
+	for overlap, cells := range counts {
+		coverage.Histogram = append(coverage.Histogram, float64(cells)*100/float64(inside))
+		if overlap >= minOverlap {
+			covered += cells
+		}
+	}
+	coverage.Percent = float64(covered) * 100 / float64(inside)
+	return raster, coverage
+}
+
+// project converts a ring to plane coordinates
+func project(plane geometry.Plane, points []geometry.Point) [][2]float64 {
+	ring := make([][2]float64, len(points))
+	for i, p := range points {
+		x, y := plane.Project(p.Latitude, p.Longitude)
+		ring[i] = [2]float64{x, y}
+	}
+	return ring
+}
+
+// ringBounds returns a plane ring's bounding box
+func ringBounds(ring [][2]float64) (minX, minY, maxX, maxY float64) {
+	minX, minY = math.Inf(1), math.Inf(1)
+	maxX, maxY = math.Inf(-1), math.Inf(-1)
+	for _, p := range ring {
+		minX, maxX = math.Min(minX, p[0]), math.Max(maxX, p[0])
+		minY, maxY = math.Min(minY, p[1]), math.Max(maxY, p[1])
+	}
+	return minX, minY, maxX, maxY
+}
+
+// clampCell returns the grid index of a position in cells, within 0..n-1
+func clampCell(position float64, n int) int {
+	return int(math.Max(0, math.Min(float64(n-1), math.Floor(position))))
+}
+
+// ringContains reports whether a plane point lies inside a ring
+func ringContains(ring [][2]float64, x, y float64) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[j], ring[i]
+		if (a[1] > y) != (b[1] > y) && x < a[0]+(y-a[1])/(b[1]-a[1])*(b[0]-a[0]) {
+			inside = !inside
+		}
+	}
+	return inside
+}
@@ -0,0 +1,133 @@
+// Package czml writes missions as CZML documents, so that CesiumJS plays
+// back the server's simulated flight rather than computing its own.
+package czml
+
+// Packet is one CZML packet. Only the properties the exporter writes are
+// modelled.
+type Packet struct {
+	ID           string       `json:"id"`
+	Name         string       `json:"name,omitempty"`
+	Version      string       `json:"version,omitempty"`
+	Description  string       `json:"description,omitempty"`
+	Clock        *Clock       `json:"clock,omitempty"`
+	Availability string       `json:"availability,omitempty"`
+	Position     *Position    `json:"position,omitempty"`
+	Orientation  *Orientation `json:"orientation,omitempty"`
+	Path         *Path        `json:"path,omitempty"`
+	Point        *Point       `json:"point,omitempty"`
+	Billboard    *Billboard   `json:"billboard,omitempty"`
+	Label        *Label       `json:"label,omitempty"`
+	Polyline     *Polyline    `json:"polyline,omitempty"`
+	Polygon      *Polygon     `json:"polygon,omitempty"`
+	// Properties holds custom sampled values such as the gimbal pitch
+	Properties map[string]*Number `json:"properties,omitempty"`
+}
+
+// Clock sets the playback interval of the document
+type Clock struct {
+	Interval    string  `json:"interval"`
+	CurrentTime string  `json:"currentTime"`
+	Multiplier  float64 `json:"multiplier"`
+	Range       string  `json:"range"`
+	Step        string  `json:"step"`
+}
+
+// Position is a constant or sampled position. Samples are flattened as
+// time (s after Epoch), longitude, latitude and height above the ellipsoid.
+type Position struct {
+	Epoch                  string    `json:"epoch,omitempty"`
+	CartographicDegrees    []float64 `json:"cartographicDegrees"`
+	InterpolationAlgorithm string    `json:"interpolationAlgorithm,omitempty"`
+}
+
+// Orientation is a sampled rotation from the object's axes (x forward,
+// z up) to the Earth-fixed frame, flattened as time, x, y, z and w
+type Orientation struct {
+	Epoch          string    `json:"epoch"`
+	UnitQuaternion []float64 `json:"unitQuaternion"`
+}
+
+// Number is a constant or sampled custom property
+type Number struct {
+	Epoch  string    `json:"epoch,omitempty"`
+	Number []float64 `json:"number"`
+}
+
+// Path draws the line an object follows
+type Path struct {
+	Width     float64  `json:"width"`
+	LeadTime  float64  `json:"leadTime"`
+	TrailTime float64  `json:"trailTime"`
+	Material  Material `json:"material"`
+}
+
+// Point draws a dot at an object's position
+type Point struct {
+	PixelSize    float64 `json:"pixelSize"`
+	Color        Color   `json:"color"`
+	OutlineColor *Color  `json:"outlineColor,omitempty"`
+	OutlineWidth float64 `json:"outlineWidth,omitempty"`
+}
+
+// Billboard draws an image at an object's position
+type Billboard struct {
+	Image          string  `json:"image"`
+	Scale          float64 `json:"scale,omitempty"`
+	VerticalOrigin string  `json:"verticalOrigin,omitempty"`
+}
+
+// Label draws text next to an object
+type Label struct {
+	Text           string     `json:"text"`
+	Font           string     `json:"font,omitempty"`
+	FillColor      Color      `json:"fillColor"`
+	PixelOffset    *Cartesian `json:"pixelOffset,omitempty"`
+	VerticalOrigin string     `json:"verticalOrigin,omitempty"`
+}
+
+// Cartesian is a 2D screen offset (px)
+type Cartesian struct {
+	Cartesian2 [2]float64 `json:"cartesian2"`
+}
+
+// Polyline draws a line through positions
+type Polyline struct {
+	Positions PositionList `json:"positions"`
+	Width     float64      `json:"width"`
+	Material  Material     `json:"material"`
+	ArcType   string       `json:"arcType,omitempty"`
+}
+
+// Polygon draws a filled area
+type Polygon struct {
+	Positions         PositionList `json:"positions"`
+	Material          Material     `json:"material"`
+	PerPositionHeight bool         `json:"perPositionHeight"`
+}
+
+// PositionList is a list of positions, either given or referenced from the
+// positions of other packets ("id#position")
+type PositionList struct {
+	CartographicDegrees []float64 `json:"cartographicDegrees,omitempty"`
+	References          []string  `json:"references,omitempty"`
+}
+
+// Material is a solid color fill
+type Material struct {
+	SolidColor SolidColor `json:"solidColor"`
+}
+
+// SolidColor is the color of a solid material
+type SolidColor struct {
+	Color Color `json:"color"`
+}
+
+// Color is an RGBA color with components from 0 to 255
+type Color struct {
+	RGBA [4]int `json:"rgba"`
+}
+
+// solid returns a solid material of a color
+func solid(rgba [4]int) Material {
+	return Material{SolidColor: SolidColor{Color: Color{RGBA: rgba}}}
+}
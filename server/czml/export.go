@@ -0,0 +1,336 @@
+package czml
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"drone-planner/server/altitude"
+	"drone-planner/server/cameras"
+	"drone-planner/server/coverage"
+	"drone-planner/server/geometry"
+	"drone-planner/server/models"
+	"drone-planner/server/simulator"
+	"drone-planner/server/timeline"
+)
+
+const (
+	// DefaultRate is the sampling rate (Hz) of exported trajectories;
+	// Cesium interpolates between samples
+	DefaultRate = 1.0
+	// frustumCorners is the number of corners of the camera's footprint
+	frustumCorners = 4
+)
+
+// pinImage is the billboard drawn at waypoints and targets, tinted by color
+const pinImage = `<svg xmlns="http://www.w3.org/2000/svg" width="24" height="36" viewBox="0 0 24 36">` +
+	`<path d="M12 0C5.4 0 0 5.4 0 12c0 9 12 24 12 24s12-15 12-24C24 5.4 18.6 0 12 0z" fill="%s" stroke="#ffffff" stroke-width="2"/>` +
+	`<circle cx="12" cy="12" r="4.5" fill="#ffffff"/></svg>`
+
+var (
+	droneColor     = [4]int{255, 255, 0, 255}
+	routeColor     = [4]int{0, 170, 255, 200}
+	frustumColor   = [4]int{255, 255, 255, 160}
+	footprintColor = [4]int{255, 255, 0, 70}
+	labelColor     = [4]int{255, 255, 255, 255}
+	waypointPin    = pinData("#0aaaff")
+	targetPin      = pinData("#ff8c00")
+)
+
+// Export simulates a mission and writes the flight as a CZML document: the
+// aircraft's sampled position and heading, the camera's frustum down to the
+// ground, the planned route with its waypoints and the mission's targets.
+// Playback starts at the mission's date. Heights are above the ellipsoid when
+// altitudes can convert them, otherwise relative to takeoff; the returned
+// notes say so. Without a camera the frustum is left out.
+func Export(mission *models.Mission, altitudes *altitude.Converter, camera *cameras.Profile, options simulator.Options) ([]byte, []string, error) {
+	relative := func(config *models.WaypointMissionConfig) (*models.WaypointMissionConfig, error) {
+		return altitudes.Mission(mission.GlobalSettings, config, models.AltitudeRelative)
+	}
+	trajectory, err := simulator.SimulateMission(mission, relative, options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var notes []string
+	home := geometry.Point{Latitude: trajectory.Home.Latitude, Longitude: trajectory.Home.Longitude}
+	base, err := altitudes.Convert(home, home.Latitude, home.Longitude, 0, models.AltitudeRelative, models.AltitudeEllipsoid)
+	if err != nil {
+		notes = append(notes, fmt.Sprintf("heights are relative to takeoff: %v", err))
+		base = 0
+	}
+
+	start := mission.Date
+	if start.IsZero() {
+		start = mission.CreatedAt
+	}
+	if start.IsZero() {
+		start = time.Now()
+	}
+	start = start.UTC()
+	epoch := start.Format(time.RFC3339Nano)
+	interval := epoch + "/" + start.Add(time.Duration(trajectory.Duration*float64(time.Second))).Format(time.RFC3339Nano)
+
+	name := mission.Name
+	if name == "" {
+		name = "Mission"
+	}
+	packets := []Packet{{
+		ID:      "document",
+		Name:    name,
+		Version: "1.0",
+		Clock: &Clock{
+			Interval:    interval,
+			CurrentTime: epoch,
+			Multiplier:  1,
+			Range:       "CLAMPED",
+			Step:        "SYSTEM_CLOCK_MULTIPLIER",
+		},
+	}}
+	packets = append(packets, dronePacket(trajectory, base, epoch, interval))
+	if camera != nil {
+		packets = append(packets, frustumPackets(trajectory, camera, base, epoch, interval)...)
+	} else {
+		notes = append(notes, "no camera is known for the drone; the frustum is left out")
+	}
+
+	routes, targets, err := routePackets(mission, relative, base)
+	if err != nil {
+		return nil, nil, err
+	}
+	packets = append(packets, routes...)
+	packets = append(packets, targets...)
+
+	data, err := json.Marshal(packets)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode CZML: %v", err)
+	}
+	return data, notes, nil
+}
+
+// dronePacket samples the aircraft's position, orientation, gimbal pitch and
+// speed
+func dronePacket(trajectory *simulator.Trajectory, base float64, epoch, interval string) Packet {
+	n := len(trajectory.Samples)
+	positions := make([]float64, 0, 4*n)
+	orientations := make([]float64, 0, 5*n)
+	pitch := make([]float64, 0, 2*n)
+	speed := make([]float64, 0, 2*n)
+	var previous [4]float64
+	for i, s := range trajectory.Samples {
+		positions = append(positions, s.Time, s.Longitude, s.Latitude, base+s.Altitude)
+		q := headingQuaternion(s.Latitude, s.Longitude, s.Heading)
+		// Keep consecutive quaternions in the same hemisphere so that
+		// interpolation takes the short way round
+		if i > 0 && q[0]*previous[0]+q[1]*previous[1]+q[2]*previous[2]+q[3]*previous[3] < 0 {
+			for k := range q {
+				q[k] = -q[k]
+			}
+		}
+		previous = q
+		orientations = append(orientations, s.Time, q[0], q[1], q[2], q[3])
+		pitch = append(pitch, s.Time, s.GimbalPitch)
+		speed = append(speed, s.Time, s.Speed)
+	}
+
+	return Packet{
+		ID:           "drone",
+		Name:         "Drone",
+		Availability: interval,
+		Position:     &Position{Epoch: epoch, CartographicDegrees: positions, InterpolationAlgorithm: "LINEAR"},
+		Orientation:  &Orientation{Epoch: epoch, UnitQuaternion: orientations},
+		Path:         &Path{Width: 2, LeadTime: 0, TrailTime: trajectory.Duration, Material: solid(droneColor)},
+		Point:        &Point{PixelSize: 10, Color: Color{RGBA: droneColor}, OutlineColor: &Color{RGBA: [4]int{0, 0, 0, 255}}, OutlineWidth: 1},
+		Properties: map[string]*Number{
+			"gimbalPitch": {Epoch: epoch, Number: pitch},
+			"speed":       {Epoch: epoch, Number: speed},
+		},
+	}
+}
+
+// frustumPackets samples the corners of the camera's ground footprint and
+// draws the pyramid from the aircraft to them. The ground is taken to be
+// level with home; while the camera sees no ground the corners collapse onto
+// the aircraft.
+func frustumPackets(trajectory *simulator.Trajectory, camera *cameras.Profile, base float64, epoch, interval string) []Packet {
+	var corners [frustumCorners][]float64
+	for _, s := range trajectory.Samples {
+		footprint, ok := coverage.Footprint(coverage.Photo{
+			Latitude:    s.Latitude,
+			Longitude:   s.Longitude,
+			Height:      s.Altitude,
+			Heading:     s.Heading,
+			GimbalPitch: s.GimbalPitch,
+		}, camera)
+		for k := range corners {
+			if ok {
+				corners[k] = append(corners[k], s.Time, footprint[k].Longitude, footprint[k].Latitude, base)
+			} else {
+				corners[k] = append(corners[k], s.Time, s.Longitude, s.Latitude, base+s.Altitude)
+			}
+		}
+	}
+
+	packets := make([]Packet, 0, 2*frustumCorners+1)
+	references := make([]string, 0, frustumCorners)
+	for k := range corners {
+		id := fmt.Sprintf("frustum/corner%d", k+1)
+		references = append(references, id+"#position")
+		packets = append(packets, Packet{
+			ID:           id,
+			Availability: interval,
+			Position:     &Position{Epoch: epoch, CartographicDegrees: corners[k], InterpolationAlgorithm: "LINEAR"},
+		})
+		packets = append(packets, Packet{
+			ID:           fmt.Sprintf("frustum/edge%d", k+1),
+			Availability: interval,
+			Polyline: &Polyline{
+				Positions: PositionList{References: []string{"drone#position", id + "#position"}},
+				Width:     1,
+				Material:  solid(frustumColor),
+				ArcType:   "NONE",
+			},
+		})
+	}
+	packets = append(packets, Packet{
+		ID:           "frustum/footprint",
+		Name:         "Camera footprint",
+		Availability: interval,
+		Polygon:      &Polygon{Positions: PositionList{References: references}, Material: solid(footprintColor), PerPositionHeight: true},
+	})
+	return packets
+}
+
+// routePackets draws the waypoints and route of every waypoint mission, and
+// the targets of the mission and its waypoints
+func routePackets(mission *models.Mission, relative simulator.AltitudeFunc, base float64) ([]Packet, []Packet, error) {
+	elements := append([]models.TimelineElement{}, mission.TimelineElements...)
+	sort.SliceStable(elements, func(i, j int) bool { return elements[i].Order < elements[j].Order })
+
+	var routes, targets []Packet
+	seen := map[string]bool{}
+	addTarget := func(target models.Target) {
+		key := target.ID
+		if key == "" {
+			key = fmt.Sprintf("%.7f,%.7f", target.Lat, target.Lng)
+		}
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		name := target.Name
+		if name == "" {
+			name = fmt.Sprintf("Target %d", len(targets)+1)
+		}
+		targets = append(targets, markerPacket("target/"+key, name, targetPin, target.Lng, target.Lat, base))
+	}
+
+	for i := range elements {
+		if elements[i].Type != models.ElementWaypointMission {
+			continue
+		}
+		decoded, err := timeline.Decode(&elements[i])
+		if err != nil {
+			return nil, nil, fmt.Errorf("timeline element %d: %v", i+1, err)
+		}
+		config := decoded.(*models.WaypointMissionConfig)
+		if len(config.Waypoints) == 0 {
+			continue
+		}
+		if config, err = relative(config); err != nil {
+			return nil, nil, err
+		}
+
+		line := make([]float64, 0, 3*len(config.Waypoints))
+		for k, wp := range config.Waypoints {
+			height := base + wp.Altitude
+			line = append(line, wp.Coordinate.Longitude, wp.Coordinate.Latitude, height)
+			id := fmt.Sprintf("waypoint/%d/%d", i+1, k+1)
+			routes = append(routes, markerPacket(id, fmt.Sprintf("%d", k+1), waypointPin, wp.Coordinate.Longitude, wp.Coordinate.Latitude, height))
+			for _, target := range wp.Targets {
+				addTarget(target)
+			}
+		}
+		routes = append(routes, Packet{
+			ID:   fmt.Sprintf("route/%d", i+1),
+			Name: "Planned route",
+			Polyline: &Polyline{
+				Positions: PositionList{CartographicDegrees: line},
+				Width:     2,
+				Material:  solid(routeColor),
+				ArcType:   "NONE",
+			},
+		})
+		for _, target := range config.Targets {
+			addTarget(target)
+		}
+	}
+	return routes, targets, nil
+}
+
+// markerPacket returns a labelled pin at a position
+func markerPacket(id, text, image string, lng, lat, height float64) Packet {
+	return Packet{
+		ID:        id,
+		Name:      text,
+		Position:  &Position{CartographicDegrees: []float64{lng, lat, height}},
+		Billboard: &Billboard{Image: image, VerticalOrigin: "BOTTOM"},
+		Label: &Label{
+			Text:           text,
+			Font:           "12pt sans-serif",
+			FillColor:      Color{RGBA: labelColor},
+			PixelOffset:    &Cartesian{Cartesian2: [2]float64{0, -40}},
+			VerticalOrigin: "BOTTOM",
+		},
+	}
+}
+
+// pinData returns the pin image in a color as a data URI
+func pinData(fill string) string {
+	return "data:image/svg+xml;base64," + base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf(pinImage, fill)))
+}
+
+// headingQuaternion returns the rotation (x, y, z, w) from an aircraft's axes
+// (x forward, y left, z up) to the Earth-fixed frame, for a level aircraft
+// at a position facing a heading (degrees clockwise from north)
+func headingQuaternion(lat, lng, heading float64) [4]float64 {
+	phi, lambda, h := lat*math.Pi/180, lng*math.Pi/180, heading*math.Pi/180
+	east := [3]float64{-math.Sin(lambda), math.Cos(lambda), 0}
+	north := [3]float64{-math.Sin(phi) * math.Cos(lambda), -math.Sin(phi) * math.Sin(lambda), math.Cos(phi)}
+	up := [3]float64{math.Cos(phi) * math.Cos(lambda), math.Cos(phi) * math.Sin(lambda), math.Sin(phi)}
+
+	var forward, left [3]float64
+	for k := range forward {
+		forward[k] = math.Sin(h)*east[k] + math.Cos(h)*north[k]
+		left[k] = -math.Cos(h)*east[k] + math.Sin(h)*north[k]
+	}
+	// Columns of the rotation matrix are the aircraft's axes
+	m := [3][3]float64{
+		{forward[0], left[0], up[0]},
+		{forward[1], left[1], up[1]},
+		{forward[2], left[2], up[2]},
+	}
+	return matrixQuaternion(m)
+}
+
+// matrixQuaternion converts a rotation matrix to a unit quaternion (x, y, z, w)
+func matrixQuaternion(m [3][3]float64) [4]float64 {
+	trace := m[0][0] + m[1][1] + m[2][2]
+	switch {
+	case trace > 0:
+		s := 0.5 / math.Sqrt(trace+1)
+		return [4]float64{(m[2][1] - m[1][2]) * s, (m[0][2] - m[2][0]) * s, (m[1][0] - m[0][1]) * s, 0.25 / s}
+	case m[0][0] > m[1][1] && m[0][0] > m[2][2]:
+		s := 2 * math.Sqrt(1+m[0][0]-m[1][1]-m[2][2])
+		return [4]float64{0.25 * s, (m[0][1] + m[1][0]) / s, (m[0][2] + m[2][0]) / s, (m[2][1] - m[1][2]) / s}
+	case m[1][1] > m[2][2]:
+		s := 2 * math.Sqrt(1+m[1][1]-m[0][0]-m[2][2])
+		return [4]float64{(m[0][1] + m[1][0]) / s, 0.25 * s, (m[1][2] + m[2][1]) / s, (m[0][2] - m[2][0]) / s}
+	default:
+		s := 2 * math.Sqrt(1+m[2][2]-m[0][0]-m[1][1])
+		return [4]float64{(m[0][2] + m[2][0]) / s, (m[1][2] + m[2][1]) / s, 0.25 * s, (m[1][0] - m[0][1]) / s}
+	}
+}
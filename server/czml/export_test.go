@@ -0,0 +1,169 @@
+package czml
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"drone-planner/server/altitude"
+	"drone-planner/server/cameras"
+	"drone-planner/server/geometry"
+	"drone-planner/server/models"
+	"drone-planner/server/simulator"
+)
+
+// testMission flies 100 m east at 20 m, looking down, which takes 23 s from
+// takeoff
+func testMission(t *testing.T) *models.Mission {
+	t.Helper()
+	lat, lng := geometry.NewPlane(geometry.Point{Latitude: 47, Longitude: 8}).Unproject(100, 0)
+	config, err := models.EncodeConfig(models.WaypointMissionConfig{
+		AutoFlightSpeed:            10,
+		GimbalPitchRotationEnabled: true,
+		Targets:                    []models.Target{{ID: "mast", Name: "Mast", Lat: 47.0005, Lng: 8.0005}},
+		Waypoints: []models.Waypoint{
+			{Coordinate: models.Coordinate{Latitude: 47, Longitude: 8}, Altitude: 20, GimbalPitch: -90},
+			{Coordinate: models.Coordinate{Latitude: lat, Longitude: lng}, Altitude: 20, GimbalPitch: -90},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &models.Mission{
+		Name:             "Line",
+		Date:             time.Date(2026, 6, 21, 10, 0, 0, 0, time.UTC),
+		TimelineElements: []models.TimelineElement{{Type: models.ElementWaypointMission, Config: config}},
+	}
+}
+
+// rotate turns a vector by a unit quaternion (x, y, z, w)
+func rotate(q [4]float64, v [3]float64) [3]float64 {
+	cross := func(a, b [3]float64) [3]float64 {
+		return [3]float64{a[1]*b[2] - a[2]*b[1], a[2]*b[0] - a[0]*b[2], a[0]*b[1] - a[1]*b[0]}
+	}
+	u := [3]float64{q[0], q[1], q[2]}
+	t := cross(u, v)
+	for k := range t {
+		t[k] *= 2
+	}
+	c := cross(u, t)
+	return [3]float64{v[0] + q[3]*t[0] + c[0], v[1] + q[3]*t[1] + c[1], v[2] + q[3]*t[2] + c[2]}
+}
+
+func TestExport(t *testing.T) {
+	camera, ok := cameras.Lookup("M3E_WIDE")
+	if !ok {
+		t.Fatal("camera M3E_WIDE is not in the catalog")
+	}
+	data, notes, err := Export(testMission(t), &altitude.Converter{}, camera, simulator.Options{Rate: DefaultRate})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Without a geoid, heights stay relative to takeoff
+	if len(notes) != 1 || !strings.HasPrefix(notes[0], "heights are relative to takeoff") {
+		t.Errorf("notes = %q", notes)
+	}
+
+	var packets []Packet
+	if err := json.Unmarshal(data, &packets); err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{
+		"document", "drone",
+		"frustum/corner1", "frustum/edge1", "frustum/corner2", "frustum/edge2",
+		"frustum/corner3", "frustum/edge3", "frustum/corner4", "frustum/edge4", "frustum/footprint",
+		"waypoint/1/1", "waypoint/1/2", "route/1", "target/mast",
+	}
+	if len(packets) != len(ids) {
+		t.Fatalf("got %d packets, want %d", len(packets), len(ids))
+	}
+	for i, id := range ids {
+		if packets[i].ID != id {
+			t.Errorf("packet %d = %q, want %q", i, packets[i].ID, id)
+		}
+	}
+	if clock := packets[0].Clock; clock == nil || clock.Interval != "2026-06-21T10:00:00Z/2026-06-21T10:00:23Z" {
+		t.Errorf("clock = %+v", clock)
+	}
+
+	// Every sample of the simulated flight, one a second over 23 s
+	mission := testMission(t)
+	relative := func(config *models.WaypointMissionConfig) (*models.WaypointMissionConfig, error) { return config, nil }
+	trajectory, err := simulator.SimulateMission(mission, relative, simulator.Options{Rate: DefaultRate})
+	if err != nil {
+		t.Fatal(err)
+	}
+	drone := packets[1]
+	samples := len(trajectory.Samples)
+	if samples < 24 {
+		t.Fatalf("simulated %d samples, want at least 24", samples)
+	}
+	if n := len(drone.Position.CartographicDegrees); n != 4*samples {
+		t.Errorf("%d position values, want %d", n, 4*samples)
+	}
+	if n := len(drone.Orientation.UnitQuaternion); n != 5*samples {
+		t.Fatalf("%d orientation values, want %d", n, 5*samples)
+	}
+	for _, name := range []string{"gimbalPitch", "speed"} {
+		if n := len(drone.Properties[name].Number); n != 2*samples {
+			t.Errorf("%d %s values, want %d", n, name, 2*samples)
+		}
+	}
+	for k := 0; k < frustumCorners; k++ {
+		if n := len(packets[2+2*k].Position.CartographicDegrees); n != 4*samples {
+			t.Errorf("corner %d has %d position values, want %d", k+1, n, 4*samples)
+		}
+	}
+
+	var previous [4]float64
+	for i := 0; i < samples; i++ {
+		values := drone.Orientation.UnitQuaternion[5*i : 5*i+5]
+		q := [4]float64{values[1], values[2], values[3], values[4]}
+		if norm := math.Sqrt(q[0]*q[0] + q[1]*q[1] + q[2]*q[2] + q[3]*q[3]); math.Abs(norm-1) > 1e-9 {
+			t.Errorf("quaternion %d has norm %g", i, norm)
+		}
+		if i > 0 && q[0]*previous[0]+q[1]*previous[1]+q[2]*previous[2]+q[3]*previous[3] < 0 {
+			t.Errorf("quaternion %d flips hemisphere", i)
+		}
+		previous = q
+	}
+
+	// Flying east, the aircraft's nose points east and its top up
+	i := 16
+	position := drone.Position.CartographicDegrees[4*i : 4*i+4]
+	values := drone.Orientation.UnitQuaternion[5*i : 5*i+5]
+	q := [4]float64{values[1], values[2], values[3], values[4]}
+	phi, lambda := position[2]*math.Pi/180, position[1]*math.Pi/180
+	east := [3]float64{-math.Sin(lambda), math.Cos(lambda), 0}
+	up := [3]float64{math.Cos(phi) * math.Cos(lambda), math.Cos(phi) * math.Sin(lambda), math.Sin(phi)}
+	forward, top := rotate(q, [3]float64{1, 0, 0}), rotate(q, [3]float64{0, 0, 1})
+	for k := range east {
+		if math.Abs(forward[k]-east[k]) > 1e-6 || math.Abs(top[k]-up[k]) > 1e-6 {
+			t.Fatalf("at %g s the nose points %v and the top %v, want %v and %v", values[0], forward, top, east, up)
+		}
+	}
+	if height := position[3]; height != 20 {
+		t.Errorf("height at %g s = %g, want 20", position[0], height)
+	}
+}
+
+func TestExportWithoutCamera(t *testing.T) {
+	data, notes, err := Export(testMission(t), &altitude.Converter{}, nil, simulator.Options{Rate: DefaultRate})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notes) != 2 || !strings.Contains(notes[1], "frustum is left out") {
+		t.Errorf("notes = %q", notes)
+	}
+	var packets []Packet
+	if err := json.Unmarshal(data, &packets); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range packets {
+		if strings.HasPrefix(p.ID, "frustum/") {
+			t.Errorf("unexpected packet %q", p.ID)
+		}
+	}
+}
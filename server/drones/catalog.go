@@ -0,0 +1,415 @@
+// Package drones is the catalog of aircraft the planner knows, with the
+// limits missions are validated against.
+package drones
+
+// Profile describes an aircraft's flight envelope and battery. Speeds are in
+// m/s, altitudes in meters relative to takeoff and energy in watt hours.
+type Profile struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	Manufacturer string `json:"manufacturer"`
+	Category     string `json:"category"` // "enterprise", "consumer", "professional" or "industrial"
+
+	MaxHorizontalSpeed float64 `json:"maxHorizontalSpeed"`
+	MaxAscentSpeed     float64 `json:"maxAscentSpeed"`
+	MaxDescentSpeed    float64 `json:"maxDescentSpeed"`
+	MaxAltitude        float64 `json:"maxAltitude"`
+
+	// MaxWaypoints is the most waypoints the aircraft's mission firmware accepts
+	MaxWaypoints int `json:"maxWaypoints"`
+	// MinCornerRadius and MaxCornerRadius bound the corner radius of curved waypoints
+	MinCornerRadius float64 `json:"minCornerRadius"`
+	MaxCornerRadius float64 `json:"maxCornerRadius"`
+
+	BatteryWh float64    `json:"batteryWh"`
+	Power     PowerModel `json:"power"`
+
+	// Weather is the published operating envelope
+	Weather WeatherLimits `json:"weather"`
+
+	// WPML identifies the aircraft in DJI WPML files; nil when it cannot fly them
+	WPML *WPMLEnum `json:"wpml,omitempty"`
+
+	// Cameras lists the camera catalog IDs the aircraft can carry; the first
+	// is the one mapping patterns use by default
+	Cameras []string `json:"cameras,omitempty"`
+}
+
+// PowerModel estimates the electrical power the aircraft draws (W)
+type PowerModel struct {
+	// HoverPower is the draw when hovering in still air
+	HoverPower float64 `json:"hoverPower"`
+	// DragPower is the extra draw when flying level at maximum horizontal speed
+	DragPower float64 `json:"dragPower"`
+	// ClimbPower is the extra draw per m/s of ascent
+	ClimbPower float64 `json:"climbPower"`
+}
+
+// PowerDraw returns the draw (W) when flying at a horizontal and vertical speed
+// (m/s, positive when climbing). Drag grows with the square of the speed;
+// descending is assumed to cost no more than hovering.
+func (p *Profile) PowerDraw(horizontalSpeed, verticalSpeed float64) float64 {
+	power := p.Power.HoverPower
+	if p.MaxHorizontalSpeed > 0 {
+		ratio := horizontalSpeed / p.MaxHorizontalSpeed
+		power += p.Power.DragPower * ratio * ratio
+	}
+	if verticalSpeed > 0 {
+		power += p.Power.ClimbPower * verticalSpeed
+	}
+	return power
+}
+
+// WeatherLimits is the weather an aircraft is rated to fly in
+type WeatherLimits struct {
+	// MaxWind is the published wind resistance (m/s)
+	MaxWind        float64 `json:"maxWind"`
+	MinTemperature float64 `json:"minTemperature"` // °C
+	MaxTemperature float64 `json:"maxTemperature"` // °C
+	// MaxPrecipitation is the heaviest rain the aircraft is sealed against
+	// (mm/h); zero when it has no ingress protection rating
+	MaxPrecipitation float64 `json:"maxPrecipitation"`
+}
+
+// WPMLEnum is the WPML droneEnumValue/droneSubEnumValue pair of an aircraft
+type WPMLEnum struct {
+	Value    int `json:"value"`
+	SubValue int `json:"subValue"`
+}
+
+const (
+	// maxAltitude is the height limit of DJI flight controllers
+	maxAltitude = 500
+	// wpmlMaxWaypoints is the waypoint limit of WPML (Pilot 2 and FlightHub) missions
+	wpmlMaxWaypoints = 65535
+	// sdkMaxWaypoints is the waypoint limit of Mobile SDK v4 missions
+	sdkMaxWaypoints = 99
+	// ipx3Rain, ipx4Rain and ipx5Rain are the rain rates (mm/h) treated as
+	// within IPx3 (spraying), IPx4 (splashing) and IPx5 (water jet) protection
+	ipx3Rain = 2
+	ipx4Rain = 4
+	ipx5Rain = 8
+)
+
+// weather returns the limits of an aircraft without ingress protection
+func weather(maxWind, minTemperature, maxTemperature float64) WeatherLimits {
+	return WeatherLimits{MaxWind: maxWind, MinTemperature: minTemperature, MaxTemperature: maxTemperature}
+}
+
+// sealed returns the limits of an aircraft with an IP rating against rain
+// (mm/h it is rated for)
+func sealed(maxWind, minTemperature, maxTemperature, rain float64) WeatherLimits {
+	limits := weather(maxWind, minTemperature, maxTemperature)
+	limits.MaxPrecipitation = rain
+	return limits
+}
+
+// endurance derives a power model from the battery energy and the published
+// hover time (minutes). Drag and climb terms are scaled from the hover draw.
+func endurance(batteryWh, hoverMinutes float64) PowerModel {
+	hover := batteryWh * 60 / hoverMinutes
+	return PowerModel{
+		HoverPower: hover,
+		DragPower:  hover * 0.5,
+		ClimbPower: hover * 0.12,
+	}
+}
+
+// catalog lists the known aircraft. Where several profiles share a WPML enum,
+// the first one is used when importing.
+var catalog = []Profile{
+	{
+		ID: "M350_RTK", Name: "Matrice 350 RTK", Manufacturer: "DJI", Category: "enterprise",
+		MaxHorizontalSpeed: 23, MaxAscentSpeed: 6, MaxDescentSpeed: 5, MaxAltitude: maxAltitude,
+		MaxWaypoints: wpmlMaxWaypoints, MinCornerRadius: 0.2, MaxCornerRadius: 100,
+		BatteryWh: 526.4, Power: endurance(526.4, 55), Weather: sealed(12, -20, 50, ipx5Rain),
+		WPML: &WPMLEnum{89, 0}, Cameras: []string{"ZENMUSE_P1_35", "ZENMUSE_P1_24", "ZENMUSE_P1_50", "ZENMUSE_H20_WIDE"},
+	},
+	{
+		ID: "M300_RTK", Name: "Matrice 300 RTK", Manufacturer: "DJI", Category: "enterprise",
+		MaxHorizontalSpeed: 23, MaxAscentSpeed: 6, MaxDescentSpeed: 5, MaxAltitude: maxAltitude,
+		MaxWaypoints: wpmlMaxWaypoints, MinCornerRadius: 0.2, MaxCornerRadius: 100,
+		BatteryWh: 548, Power: endurance(548, 55), Weather: sealed(15, -20, 50, ipx4Rain),
+		WPML: &WPMLEnum{60, 0}, Cameras: []string{"ZENMUSE_P1_35", "ZENMUSE_P1_24", "ZENMUSE_P1_50", "ZENMUSE_H20_WIDE"},
+	},
+	{
+		ID: "M30", Name: "Matrice 30", Manufacturer: "DJI", Category: "enterprise",
+		MaxHorizontalSpeed: 23, MaxAscentSpeed: 6, MaxDescentSpeed: 5, MaxAltitude: maxAltitude,
+		MaxWaypoints: wpmlMaxWaypoints, MinCornerRadius: 0.2, MaxCornerRadius: 100,
+		BatteryWh: 263.2, Power: endurance(263.2, 36), Weather: sealed(15, -20, 50, ipx5Rain),
+		WPML: &WPMLEnum{67, 0}, Cameras: []string{"M30_WIDE"},
+	},
+	{
+		ID: "M30T", Name: "Matrice 30T", Manufacturer: "DJI", Category: "enterprise",
+		MaxHorizontalSpeed: 23, MaxAscentSpeed: 6, MaxDescentSpeed: 5, MaxAltitude: maxAltitude,
+		MaxWaypoints: wpmlMaxWaypoints, MinCornerRadius: 0.2, MaxCornerRadius: 100,
+		BatteryWh: 263.2, Power: endurance(263.2, 36), Weather: sealed(15, -20, 50, ipx5Rain),
+		WPML: &WPMLEnum{67, 1}, Cameras: []string{"M30_WIDE"},
+	},
+	{
+		ID: "M3E", Name: "Mavic 3 Enterprise", Manufacturer: "DJI", Category: "enterprise",
+		MaxHorizontalSpeed: 15, MaxAscentSpeed: 6, MaxDescentSpeed: 6, MaxAltitude: maxAltitude,
+		MaxWaypoints: wpmlMaxWaypoints, MinCornerRadius: 0.2, MaxCornerRadius: 100,
+		BatteryWh: 77, Power: endurance(77, 38), Weather: weather(12, -10, 40),
+		WPML: &WPMLEnum{77, 0}, Cameras: []string{"M3E_WIDE"},
+	},
+	{
+		ID: "M3T", Name: "Mavic 3 Thermal", Manufacturer: "DJI", Category: "enterprise",
+		MaxHorizontalSpeed: 15, MaxAscentSpeed: 6, MaxDescentSpeed: 6, MaxAltitude: maxAltitude,
+		MaxWaypoints: wpmlMaxWaypoints, MinCornerRadius: 0.2, MaxCornerRadius: 100,
+		BatteryWh: 77, Power: endurance(77, 38), Weather: weather(12, -10, 40),
+		WPML: &WPMLEnum{77, 1}, Cameras: []string{"M3T_WIDE"},
+	},
+	{
+		ID: "M3M", Name: "Mavic 3 Multispectral", Manufacturer: "DJI", Category: "enterprise",
+		MaxHorizontalSpeed: 15, MaxAscentSpeed: 6, MaxDescentSpeed: 6, MaxAltitude: maxAltitude,
+		MaxWaypoints: wpmlMaxWaypoints, MinCornerRadius: 0.2, MaxCornerRadius: 100,
+		BatteryWh: 77, Power: endurance(77, 37), Weather: weather(12, -10, 40),
+		WPML: &WPMLEnum{77, 2}, Cameras: []string{"M3M_RGB"},
+	},
+	{
+		ID: "M3D", Name: "Matrice 3D", Manufacturer: "DJI", Category: "enterprise",
+		MaxHorizontalSpeed: 15, MaxAscentSpeed: 6, MaxDescentSpeed: 6, MaxAltitude: maxAltitude,
+		MaxWaypoints: wpmlMaxWaypoints, MinCornerRadius: 0.2, MaxCornerRadius: 100,
+		BatteryWh: 120, Power: endurance(120, 47), Weather: sealed(12, -20, 45, ipx4Rain),
+		WPML: &WPMLEnum{91, 0}, Cameras: []string{"M3D_WIDE"},
+	},
+	{
+		ID: "M3TD", Name: "Matrice 3TD", Manufacturer: "DJI", Category: "enterprise",
+		MaxHorizontalSpeed: 15, MaxAscentSpeed: 6, MaxDescentSpeed: 6, MaxAltitude: maxAltitude,
+		MaxWaypoints: wpmlMaxWaypoints, MinCornerRadius: 0.2, MaxCornerRadius: 100,
+		BatteryWh: 120, Power: endurance(120, 45), Weather: sealed(12, -20, 45, ipx4Rain),
+		WPML: &WPMLEnum{91, 1}, Cameras: []string{"M3TD_WIDE"},
+	},
+	{
+		ID: "DJI_Mavic_3", Name: "Mavic 3", Manufacturer: "DJI", Category: "consumer",
+		MaxHorizontalSpeed: 15, MaxAscentSpeed: 6, MaxDescentSpeed: 6, MaxAltitude: maxAltitude,
+		MaxWaypoints: wpmlMaxWaypoints, MinCornerRadius: 0.2, MaxCornerRadius: 100,
+		BatteryWh: 77, Power: endurance(77, 40), Weather: weather(12, -10, 40),
+		WPML: &WPMLEnum{77, 0}, Cameras: []string{"MAVIC_3_HASSELBLAD"},
+	},
+	{
+		ID: "MAVIC_2_ENTERPRISE_ADVANCED", Name: "Mavic 2 Enterprise Advanced", Manufacturer: "DJI", Category: "enterprise",
+		MaxHorizontalSpeed: 15, MaxAscentSpeed: 5, MaxDescentSpeed: 3, MaxAltitude: maxAltitude,
+		MaxWaypoints: sdkMaxWaypoints, MinCornerRadius: 0.2, MaxCornerRadius: 100,
+		BatteryWh: 59.29, Power: endurance(59.29, 29), Weather: weather(10, -10, 40),
+		Cameras: []string{"M2EA_WIDE"},
+	},
+	{
+		ID: "MAVIC_2_ENTERPRISE_DUAL", Name: "Mavic 2 Enterprise Dual", Manufacturer: "DJI", Category: "enterprise",
+		MaxHorizontalSpeed: 15, MaxAscentSpeed: 5, MaxDescentSpeed: 3, MaxAltitude: maxAltitude,
+		MaxWaypoints: sdkMaxWaypoints, MinCornerRadius: 0.2, MaxCornerRadius: 100,
+		BatteryWh: 59.29, Power: endurance(59.29, 29), Weather: weather(10, -10, 40),
+		Cameras: []string{"M2ED_WIDE"},
+	},
+	{
+		ID: "MAVIC_2_ENTERPRISE", Name: "Mavic 2 Enterprise", Manufacturer: "DJI", Category: "enterprise",
+		MaxHorizontalSpeed: 15, MaxAscentSpeed: 5, MaxDescentSpeed: 3, MaxAltitude: maxAltitude,
+		MaxWaypoints: sdkMaxWaypoints, MinCornerRadius: 0.2, MaxCornerRadius: 100,
+		BatteryWh: 59.29, Power: endurance(59.29, 29), Weather: weather(10, -10, 40),
+		Cameras: []string{"M2E_WIDE"},
+	},
+	{
+		ID: "DJI_MINI_2", Name: "Mini 2", Manufacturer: "DJI", Category: "consumer",
+		MaxHorizontalSpeed: 15, MaxAscentSpeed: 5, MaxDescentSpeed: 3.5, MaxAltitude: maxAltitude,
+		MaxWaypoints: sdkMaxWaypoints, MinCornerRadius: 0.2, MaxCornerRadius: 100,
+		BatteryWh: 17.32, Power: endurance(17.32, 28), Weather: weather(8.5, 0, 40),
+		Cameras: []string{"MINI_2"},
+	},
+	{
+		ID: "DJI_MINI_SE", Name: "Mini SE", Manufacturer: "DJI", Category: "consumer",
+		MaxHorizontalSpeed: 13, MaxAscentSpeed: 4, MaxDescentSpeed: 3, MaxAltitude: maxAltitude,
+		MaxWaypoints: sdkMaxWaypoints, MinCornerRadius: 0.2, MaxCornerRadius: 100,
+		BatteryWh: 16.2, Power: endurance(16.2, 27), Weather: weather(8, 0, 40),
+		Cameras: []string{"MINI_SE"},
+	},
+	{
+		ID: "DJI_AIR_2S", Name: "Air 2S", Manufacturer: "DJI", Category: "consumer",
+		MaxHorizontalSpeed: 15, MaxAscentSpeed: 6, MaxDescentSpeed: 6, MaxAltitude: maxAltitude,
+		MaxWaypoints: sdkMaxWaypoints, MinCornerRadius: 0.2, MaxCornerRadius: 100,
+		BatteryWh: 40.42, Power: endurance(40.42, 30), Weather: weather(10.7, 0, 40),
+		Cameras: []string{"AIR_2S"},
+	},
+	{
+		ID: "MAVIC_AIR_2", Name: "Mavic Air 2", Manufacturer: "DJI", Category: "consumer",
+		MaxHorizontalSpeed: 15, MaxAscentSpeed: 4, MaxDescentSpeed: 5, MaxAltitude: maxAltitude,
+		MaxWaypoints: sdkMaxWaypoints, MinCornerRadius: 0.2, MaxCornerRadius: 100,
+		BatteryWh: 40.42, Power: endurance(40.42, 33), Weather: weather(10, -10, 40),
+		Cameras: []string{"MAVIC_AIR_2"},
+	},
+	{
+		ID: "MAVIC_MINI", Name: "Mavic Mini", Manufacturer: "DJI", Category: "consumer",
+		MaxHorizontalSpeed: 13, MaxAscentSpeed: 4, MaxDescentSpeed: 3, MaxAltitude: maxAltitude,
+		MaxWaypoints: sdkMaxWaypoints, MinCornerRadius: 0.2, MaxCornerRadius: 100,
+		BatteryWh: 17.28, Power: endurance(17.28, 28), Weather: weather(8, 0, 40),
+		Cameras: []string{"MAVIC_MINI"},
+	},
+	{
+		ID: "MAVIC_2_SERIES", Name: "Mavic 2 Pro / Zoom", Manufacturer: "DJI", Category: "consumer",
+		MaxHorizontalSpeed: 15, MaxAscentSpeed: 5, MaxDescentSpeed: 3, MaxAltitude: maxAltitude,
+		MaxWaypoints: sdkMaxWaypoints, MinCornerRadius: 0.2, MaxCornerRadius: 100,
+		BatteryWh: 59.29, Power: endurance(59.29, 29), Weather: weather(10, -10, 40),
+		Cameras: []string{"MAVIC_2_PRO", "MAVIC_2_ZOOM"},
+	},
+	{
+		ID: "MAVIC_AIR", Name: "Mavic Air", Manufacturer: "DJI", Category: "consumer",
+		MaxHorizontalSpeed: 15, MaxAscentSpeed: 4, MaxDescentSpeed: 3, MaxAltitude: maxAltitude,
+		MaxWaypoints: sdkMaxWaypoints, MinCornerRadius: 0.2, MaxCornerRadius: 100,
+		BatteryWh: 27.36, Power: endurance(27.36, 20), Weather: weather(10, 0, 40),
+		Cameras: []string{"MAVIC_AIR"},
+	},
+	{
+		ID: "MAVIC_PRO", Name: "Mavic Pro", Manufacturer: "DJI", Category: "consumer",
+		MaxHorizontalSpeed: 15, MaxAscentSpeed: 5, MaxDescentSpeed: 3, MaxAltitude: maxAltitude,
+		MaxWaypoints: sdkMaxWaypoints, MinCornerRadius: 0.2, MaxCornerRadius: 100,
+		BatteryWh: 43.6, Power: endurance(43.6, 24), Weather: weather(10, 0, 40),
+		Cameras: []string{"MAVIC_PRO"},
+	},
+	{
+		ID: "SPARK", Name: "Spark", Manufacturer: "DJI", Category: "consumer",
+		MaxHorizontalSpeed: 14, MaxAscentSpeed: 3, MaxDescentSpeed: 3, MaxAltitude: maxAltitude,
+		MaxWaypoints: sdkMaxWaypoints, MinCornerRadius: 0.2, MaxCornerRadius: 100,
+		BatteryWh: 16.87, Power: endurance(16.87, 15), Weather: weather(8, 0, 40),
+		Cameras: []string{"SPARK"},
+	},
+	{
+		ID: "P4_MULTISPECTRAL", Name: "P4 Multispectral", Manufacturer: "DJI", Category: "professional",
+		MaxHorizontalSpeed: 14, MaxAscentSpeed: 6, MaxDescentSpeed: 3, MaxAltitude: maxAltitude,
+		MaxWaypoints: sdkMaxWaypoints, MinCornerRadius: 0.2, MaxCornerRadius: 100,
+		BatteryWh: 89.2, Power: endurance(89.2, 27), Weather: weather(10, 0, 40),
+		Cameras: []string{"P4_MULTISPECTRAL_RGB"},
+	},
+	{
+		ID: "PHANTOM_4_RTK", Name: "Phantom 4 RTK", Manufacturer: "DJI", Category: "professional",
+		MaxHorizontalSpeed: 16, MaxAscentSpeed: 6, MaxDescentSpeed: 3, MaxAltitude: maxAltitude,
+		MaxWaypoints: sdkMaxWaypoints, MinCornerRadius: 0.2, MaxCornerRadius: 100,
+		BatteryWh: 89.2, Power: endurance(89.2, 30), Weather: weather(10, 0, 40),
+		Cameras: []string{"PHANTOM_4_RTK"},
+	},
+	{
+		ID: "PHANTOM_4_PRO_V2", Name: "Phantom 4 Pro V2.0", Manufacturer: "DJI", Category: "professional",
+		MaxHorizontalSpeed: 20, MaxAscentSpeed: 6, MaxDescentSpeed: 4, MaxAltitude: maxAltitude,
+		MaxWaypoints: sdkMaxWaypoints, MinCornerRadius: 0.2, MaxCornerRadius: 100,
+		BatteryWh: 89.2, Power: endurance(89.2, 30), Weather: weather(10, 0, 40),
+		Cameras: []string{"PHANTOM_4_PRO"},
+	},
+	{
+		ID: "PHANTOM_4", Name: "Phantom 4", Manufacturer: "DJI", Category: "professional",
+		MaxHorizontalSpeed: 20, MaxAscentSpeed: 6, MaxDescentSpeed: 4, MaxAltitude: maxAltitude,
+		MaxWaypoints: sdkMaxWaypoints, MinCornerRadius: 0.2, MaxCornerRadius: 100,
+		BatteryWh: 81.3, Power: endurance(81.3, 28), Weather: weather(10, 0, 40),
+		Cameras: []string{"PHANTOM_4"},
+	},
+	{
+		ID: "PHANTOM_3_PROFESSIONAL", Name: "Phantom 3 Professional", Manufacturer: "DJI", Category: "consumer",
+		MaxHorizontalSpeed: 16, MaxAscentSpeed: 5, MaxDescentSpeed: 3, MaxAltitude: maxAltitude,
+		MaxWaypoints: sdkMaxWaypoints, MinCornerRadius: 0.2, MaxCornerRadius: 100,
+		BatteryWh: 68, Power: endurance(68, 23), Weather: weather(10, 0, 40),
+		Cameras: []string{"PHANTOM_3"},
+	},
+	{
+		ID: "PHANTOM_3_ADVANCED", Name: "Phantom 3 Advanced", Manufacturer: "DJI", Category: "consumer",
+		MaxHorizontalSpeed: 16, MaxAscentSpeed: 5, MaxDescentSpeed: 3, MaxAltitude: maxAltitude,
+		MaxWaypoints: sdkMaxWaypoints, MinCornerRadius: 0.2, MaxCornerRadius: 100,
+		BatteryWh: 68, Power: endurance(68, 23), Weather: weather(10, 0, 40),
+		Cameras: []string{"PHANTOM_3"},
+	},
+	{
+		ID: "PHANTOM_3_STANDARD", Name: "Phantom 3 Standard", Manufacturer: "DJI", Category: "consumer",
+		MaxHorizontalSpeed: 16, MaxAscentSpeed: 5, MaxDescentSpeed: 3, MaxAltitude: maxAltitude,
+		MaxWaypoints: sdkMaxWaypoints, MinCornerRadius: 0.2, MaxCornerRadius: 100,
+		BatteryWh: 68, Power: endurance(68, 25), Weather: weather(10, 0, 40),
+		Cameras: []string{"PHANTOM_3"},
+	},
+	{
+		ID: "PHANTOM_3_4K", Name: "Phantom 3 4K", Manufacturer: "DJI", Category: "consumer",
+		MaxHorizontalSpeed: 16, MaxAscentSpeed: 5, MaxDescentSpeed: 3, MaxAltitude: maxAltitude,
+		MaxWaypoints: sdkMaxWaypoints, MinCornerRadius: 0.2, MaxCornerRadius: 100,
+		BatteryWh: 68, Power: endurance(68, 25), Weather: weather(10, 0, 40),
+		Cameras: []string{"PHANTOM_3"},
+	},
+	{
+		ID: "INSPIRE_2", Name: "Inspire 2", Manufacturer: "DJI", Category: "industrial",
+		MaxHorizontalSpeed: 26, MaxAscentSpeed: 6, MaxDescentSpeed: 9, MaxAltitude: maxAltitude,
+		MaxWaypoints: sdkMaxWaypoints, MinCornerRadius: 0.2, MaxCornerRadius: 100,
+		BatteryWh: 195.16, Power: endurance(195.16, 27), Weather: weather(10, -20, 40),
+		Cameras: []string{"ZENMUSE_X5S"},
+	},
+	{
+		ID: "INSPIRE_1_PRO", Name: "Inspire 1 Pro", Manufacturer: "DJI", Category: "industrial",
+		MaxHorizontalSpeed: 18, MaxAscentSpeed: 5, MaxDescentSpeed: 4, MaxAltitude: maxAltitude,
+		MaxWaypoints: sdkMaxWaypoints, MinCornerRadius: 0.2, MaxCornerRadius: 100,
+		BatteryWh: 129.96, Power: endurance(129.96, 15), Weather: weather(10, -10, 40),
+		Cameras: []string{"ZENMUSE_X5"},
+	},
+	{
+		ID: "INSPIRE_1", Name: "Inspire 1", Manufacturer: "DJI", Category: "industrial",
+		MaxHorizontalSpeed: 22, MaxAscentSpeed: 5, MaxDescentSpeed: 4, MaxAltitude: maxAltitude,
+		MaxWaypoints: sdkMaxWaypoints, MinCornerRadius: 0.2, MaxCornerRadius: 100,
+		BatteryWh: 99.9, Power: endurance(99.9, 18), Weather: weather(10, -10, 40),
+		Cameras: []string{"ZENMUSE_X3"},
+	},
+	{
+		ID: "M200_V2", Name: "Matrice 200 V2", Manufacturer: "DJI", Category: "industrial",
+		MaxHorizontalSpeed: 22.5, MaxAscentSpeed: 5, MaxDescentSpeed: 3, MaxAltitude: maxAltitude,
+		MaxWaypoints: sdkMaxWaypoints, MinCornerRadius: 0.2, MaxCornerRadius: 100,
+		BatteryWh: 349.2, Power: endurance(349.2, 38), Weather: sealed(12, -20, 50, ipx3Rain),
+		Cameras: []string{"ZENMUSE_X5S"},
+	},
+	{
+		ID: "M210_RTK_V2", Name: "Matrice 210 RTK V2", Manufacturer: "DJI", Category: "industrial",
+		MaxHorizontalSpeed: 22.5, MaxAscentSpeed: 5, MaxDescentSpeed: 3, MaxAltitude: maxAltitude,
+		MaxWaypoints: sdkMaxWaypoints, MinCornerRadius: 0.2, MaxCornerRadius: 100,
+		BatteryWh: 349.2, Power: endurance(349.2, 33), Weather: sealed(12, -20, 50, ipx3Rain),
+		Cameras: []string{"ZENMUSE_X5S"},
+	},
+	{
+		ID: "MATRICE_600_PRO", Name: "Matrice 600 Pro", Manufacturer: "DJI", Category: "industrial",
+		MaxHorizontalSpeed: 18, MaxAscentSpeed: 5, MaxDescentSpeed: 3, MaxAltitude: maxAltitude,
+		MaxWaypoints: sdkMaxWaypoints, MinCornerRadius: 0.2, MaxCornerRadius: 100,
+		BatteryWh: 779.76, Power: endurance(779.76, 38), Weather: weather(8, -10, 40),
+		Cameras: []string{"ZENMUSE_X5"},
+	},
+	{
+		ID: "MATRICE_600", Name: "Matrice 600", Manufacturer: "DJI", Category: "industrial",
+		MaxHorizontalSpeed: 18, MaxAscentSpeed: 5, MaxDescentSpeed: 3, MaxAltitude: maxAltitude,
+		MaxWaypoints: sdkMaxWaypoints, MinCornerRadius: 0.2, MaxCornerRadius: 100,
+		BatteryWh: 599.4, Power: endurance(599.4, 35), Weather: weather(8, -10, 40),
+		Cameras: []string{"ZENMUSE_X5"},
+	},
+	{
+		ID: "MATRICE_100", Name: "Matrice 100", Manufacturer: "DJI", Category: "industrial",
+		MaxHorizontalSpeed: 22, MaxAscentSpeed: 5, MaxDescentSpeed: 4, MaxAltitude: maxAltitude,
+		MaxWaypoints: sdkMaxWaypoints, MinCornerRadius: 0.2, MaxCornerRadius: 100,
+		BatteryWh: 99.9, Power: endurance(99.9, 22), Weather: weather(10, -10, 40),
+		Cameras: []string{"ZENMUSE_X3"},
+	},
+}
+
+// All returns every profile in the catalog
+func All() []Profile {
+	return append([]Profile{}, catalog...)
+}
+
+// Lookup returns the profile with the given drone type ID
+func Lookup(id string) (*Profile, bool) {
+	for i := range catalog {
+		if catalog[i].ID == id {
+			profile := catalog[i]
+			return &profile, true
+		}
+	}
+	return nil, false
+}
+
+// LookupWPML returns the first profile flying as the given WPML aircraft
+func LookupWPML(value, subValue int) (*Profile, bool) {
+	for i := range catalog {
+		enum := catalog[i].WPML
+		if enum != nil && enum.Value == value && enum.SubValue == subValue {
+			profile := catalog[i]
+			return &profile, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,143 @@
+package energy
+
+import (
+	"math"
+	"testing"
+
+	"drone-planner/server/drones"
+	"drone-planner/server/geometry"
+	"drone-planner/server/models"
+)
+
+// testProfile draws 360 W hovering and 450 W at its 10 m/s top speed
+var testProfile = drones.Profile{
+	ID: "TEST", Name: "test aircraft",
+	MaxHorizontalSpeed: 10, MaxAscentSpeed: 5, MaxDescentSpeed: 5,
+	BatteryWh: 100,
+	Power:     drones.PowerModel{HoverPower: 360, DragPower: 90, ClimbPower: 36},
+}
+
+var home = geometry.Point{Latitude: 47, Longitude: 8}
+
+// legEnergy is the energy of flying level between home and a point at full
+// speed, drawing 450 W (Wh)
+func legEnergy(lat, lng float64) float64 {
+	return 450 * geometry.Distance(home.Latitude, home.Longitude, lat, lng) / 10 / 3600
+}
+
+// shuttle flies between home and a point 0.01° north at full speed
+func shuttle(repeats int, actions ...models.WaypointAction) *models.WaypointMissionConfig {
+	return &models.WaypointMissionConfig{
+		AutoFlightSpeed: 10,
+		RepeatTimes:     repeats,
+		Waypoints: []models.Waypoint{
+			{Coordinate: models.Coordinate{Latitude: 47, Longitude: 8}, Actions: actions},
+			{Coordinate: models.Coordinate{Latitude: 47.01, Longitude: 8}},
+		},
+	}
+}
+
+func TestEstimateMission(t *testing.T) {
+	leg := legEnergy(47.01, 8)
+	tests := []struct {
+		name      string
+		config    *models.WaypointMissionConfig
+		threshold float64
+		// used is the energy of the whole flight (Wh)
+		used     float64
+		feasible bool
+		// crossing is the flown position first below the threshold, -1 for none
+		crossing int
+	}{
+		{"out and back", shuttle(1), 20, 2 * leg, true, -1},
+		{"hover at the start", shuttle(1, models.WaypointAction{ActionType: models.ActionHover, ActionParam: 10}), 20, 2*leg + 1, true, -1},
+		{"lands below the threshold", shuttle(3), 100 - 5.5*leg, 6 * leg, false, -1},
+		{"crosses the threshold", shuttle(3), 100 - 4.5*leg, 6 * leg, false, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profile := testProfile
+			estimate, err := EstimateMission(tt.config, &profile, Options{Home: home, Threshold: tt.threshold, BatteryAction: "GO_HOME"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if math.Abs(estimate.Energy.Total()-tt.used) > 1e-6 {
+				t.Errorf("energy = %g Wh, want %g", estimate.Energy.Total(), tt.used)
+			}
+			if want := 100 - tt.used; math.Abs(estimate.Remaining-want) > 1e-6 {
+				t.Errorf("remaining = %g%%, want %g", estimate.Remaining, want)
+			}
+			if estimate.Feasible != tt.feasible {
+				t.Errorf("feasible = %v, want %v", estimate.Feasible, tt.feasible)
+			}
+			switch {
+			case tt.crossing < 0 && estimate.ThresholdCrossing != nil:
+				t.Errorf("unexpected threshold crossing %+v", estimate.ThresholdCrossing)
+			case tt.crossing >= 0 && (estimate.ThresholdCrossing == nil || estimate.ThresholdCrossing.Index != tt.crossing || estimate.ThresholdCrossing.Action != "GO_HOME"):
+				t.Errorf("threshold crossing = %+v, want GO_HOME at %d", estimate.ThresholdCrossing, tt.crossing)
+			}
+			if got := len(estimate.Waypoints); got != 2*max(tt.config.RepeatTimes, 1) {
+				t.Errorf("got %d waypoint states", got)
+			}
+		})
+	}
+}
+
+func TestEstimateClimb(t *testing.T) {
+	// A 50 m climb and descent at 5 m/s around a hover over home
+	config := &models.WaypointMissionConfig{
+		AutoFlightSpeed: 10,
+		Waypoints: []models.Waypoint{
+			{Coordinate: models.Coordinate{Latitude: 47, Longitude: 8}, Altitude: 50, Actions: []models.WaypointAction{{ActionType: models.ActionHover, ActionParam: 10}}},
+			{Coordinate: models.Coordinate{Latitude: 47, Longitude: 8}, Altitude: 50},
+		},
+	}
+	profile := testProfile
+	estimate, err := EstimateMission(config, &profile, Options{Home: home})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Breakdown{Hover: 3, Climb: 0.5}
+	if got := estimate.Energy; math.Abs(got.Hover-want.Hover) > 1e-9 || math.Abs(got.Climb-want.Climb) > 1e-9 || math.Abs(got.Cruise) > 1e-9 {
+		t.Errorf("energy = %+v, want %+v", got, want)
+	}
+	if estimate.Duration != 30 {
+		t.Errorf("duration = %g s, want 30", estimate.Duration)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	leg := legEnergy(47.01, 8)
+	profile := testProfile
+	// Each sortie may use the energy of four and a half legs
+	sorties, err := Split(shuttle(3), &profile, Options{Home: home, Threshold: 100 - 4.5*leg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		start, end, resume int
+		used               float64
+	}{
+		{0, 4, 0, 4 * leg},
+		{4, 5, 0, 2 * leg},
+	}
+	if len(sorties) != len(want) {
+		t.Fatalf("got %d sorties, want %d", len(sorties), len(want))
+	}
+	for i, w := range want {
+		sortie := sorties[i]
+		if sortie.Number != i+1 || sortie.Start != w.start || sortie.End != w.end || sortie.ResumeWaypoint != w.resume {
+			t.Errorf("sortie %d = %d-%d resuming at %d, want %d-%d at %d", i+1, sortie.Start, sortie.End, sortie.ResumeWaypoint, w.start, w.end, w.resume)
+		}
+		if math.Abs(sortie.Energy.Total()-w.used) > 1e-6 {
+			t.Errorf("sortie %d uses %g Wh, want %g", i+1, sortie.Energy.Total(), w.used)
+		}
+		if len(sortie.Config.Waypoints) != w.end-w.start+1 || sortie.Config.FinishedAction != "GO_HOME" {
+			t.Errorf("sortie %d config has %d waypoints finishing with %s", i+1, len(sortie.Config.Waypoints), sortie.Config.FinishedAction)
+		}
+	}
+
+	if _, err := Split(shuttle(1), &profile, Options{Home: home, Threshold: 100 - leg}); err == nil {
+		t.Error("expected an error when a single leg and the return don't fit on a battery")
+	}
+}
@@ -0,0 +1,178 @@
+package energy
+
+import (
+	"fmt"
+
+	"drone-planner/server/drones"
+	"drone-planner/server/geometry"
+	"drone-planner/server/models"
+	"drone-planner/server/timeline"
+)
+
+// WaypointEnergy is the predicted battery state after the actions at a
+// waypoint. Percentages are of the full battery.
+type WaypointEnergy struct {
+	// Index is the position in the flown sequence, counting repeats
+	Index int `json:"index"`
+	// Waypoint is the index into the mission's waypoints
+	Waypoint  int     `json:"waypoint"`
+	Used      float64 `json:"used"`      // Wh since takeoff
+	Remaining float64 `json:"remaining"` // %
+	// ReturnEnergy is the energy needed to fly home and land from here (Wh)
+	ReturnEnergy float64 `json:"returnEnergy"`
+	// RemainingAfterReturn is the battery left when returning from here (%)
+	RemainingAfterReturn float64 `json:"remainingAfterReturn"`
+	BelowThreshold       bool    `json:"belowThreshold"`
+}
+
+// Crossing is the first waypoint reached with less battery than the threshold
+type Crossing struct {
+	Index     int     `json:"index"`
+	Waypoint  int     `json:"waypoint"`
+	Remaining float64 `json:"remaining"`
+	// Action is the mission's battery action, taken by the aircraft here
+	Action string `json:"action"`
+}
+
+// Estimate is the predicted energy use of a waypoint mission
+type Estimate struct {
+	Drone     string  `json:"drone"`
+	BatteryWh float64 `json:"batteryWh"`
+	Threshold float64 `json:"threshold"` // %
+
+	Steps     []Step           `json:"steps"`
+	Waypoints []WaypointEnergy `json:"waypoints"`
+
+	// Energy is the energy of the whole flight, including the return home (Wh)
+	Energy   Breakdown `json:"energy"`
+	Duration float64   `json:"duration"`
+	// Remaining is the battery left on landing (%)
+	Remaining float64 `json:"remaining"`
+
+	ThresholdCrossing *Crossing `json:"thresholdCrossing,omitempty"`
+	// Feasible reports whether the flight completes without crossing the threshold
+	Feasible bool `json:"feasible"`
+}
+
+// sequence is a waypoint mission flattened into the order it is flown
+type sequence struct {
+	points    []geometry.Point
+	waypoints []int // index into config.Waypoints for each point
+	segments  []geometry.Segment
+	actions   []float64 // action seconds at each point
+	speed     float64
+}
+
+// flatten expands a waypoint mission's repeats into a single sequence
+func flatten(config *models.WaypointMissionConfig) (*sequence, error) {
+	if len(config.Waypoints) < 2 {
+		return nil, fmt.Errorf("waypoint mission must have at least 2 waypoints")
+	}
+
+	points, legs := geometry.WaypointLegs(config)
+	repeats := config.RepeatTimes
+	if repeats < 1 {
+		repeats = 1
+	}
+
+	seq := &sequence{speed: config.AutoFlightSpeed}
+	var seqLegs []geometry.Leg
+	for r := 0; r < repeats; r++ {
+		for i, wp := range config.Waypoints {
+			leg := legs[i]
+			if i == len(config.Waypoints)-1 {
+				// The closing leg back to the first waypoint is flown straight
+				leg = geometry.Leg{}
+			}
+			seq.points = append(seq.points, points[i])
+			seq.waypoints = append(seq.waypoints, i)
+			seqLegs = append(seqLegs, leg)
+
+			seconds := 0.0
+			for _, action := range wp.Actions {
+				seconds += timeline.ActionDuration(action)
+			}
+			seq.actions = append(seq.actions, seconds)
+		}
+	}
+	seq.segments = geometry.Measure(seq.points, seqLegs, seq.speed).Segments
+	return seq, nil
+}
+
+// Options are the battery settings of an estimate
+type Options struct {
+	Home geometry.Point
+	// Threshold is the low battery level that triggers BatteryAction (%)
+	Threshold     float64
+	BatteryAction string
+}
+
+// EstimateMission predicts the battery used flying a waypoint mission from
+// home and back on the given aircraft
+func EstimateMission(config *models.WaypointMissionConfig, profile *drones.Profile, options Options) (*Estimate, error) {
+	seq, err := flatten(config)
+	if err != nil {
+		return nil, err
+	}
+	if profile.BatteryWh <= 0 {
+		return nil, fmt.Errorf("drone %s has no battery capacity", profile.ID)
+	}
+
+	estimate := &Estimate{
+		Drone:     profile.ID,
+		BatteryWh: profile.BatteryWh,
+		Threshold: options.Threshold,
+		Steps:     outbound(profile, options.Home, seq.points[0], 0, seq.speed),
+		Waypoints: []WaypointEnergy{},
+	}
+
+	for i := range seq.points {
+		if i > 0 {
+			estimate.Steps = append(estimate.Steps, segmentStep(profile, seq.segments[i-1]))
+		}
+		if seq.actions[i] > 0 {
+			estimate.Steps = append(estimate.Steps, Step{
+				Kind:     "action",
+				Index:    i,
+				Duration: seq.actions[i],
+				Energy:   hoverEnergy(profile, seq.actions[i]),
+			})
+		}
+
+		used := stepsEnergy(estimate.Steps)
+		returnEnergy := stepsEnergy(homeward(profile, seq.points[i], options.Home, seq.speed))
+		state := WaypointEnergy{
+			Index:                i,
+			Waypoint:             seq.waypoints[i],
+			Used:                 used,
+			Remaining:            percent(profile, used),
+			ReturnEnergy:         returnEnergy,
+			RemainingAfterReturn: percent(profile, used+returnEnergy),
+		}
+		state.BelowThreshold = state.Remaining < options.Threshold
+		if state.BelowThreshold && estimate.ThresholdCrossing == nil {
+			estimate.ThresholdCrossing = &Crossing{
+				Index:     i,
+				Waypoint:  state.Waypoint,
+				Remaining: state.Remaining,
+				Action:    options.BatteryAction,
+			}
+		}
+		estimate.Waypoints = append(estimate.Waypoints, state)
+	}
+
+	last := seq.points[len(seq.points)-1]
+	estimate.Steps = append(estimate.Steps, homeward(profile, last, options.Home, seq.speed)...)
+	for _, step := range estimate.Steps {
+		estimate.Energy.add(step.Energy)
+		estimate.Duration += step.Duration
+	}
+	estimate.Remaining = percent(profile, estimate.Energy.Total())
+	estimate.Feasible = estimate.ThresholdCrossing == nil && estimate.Remaining >= options.Threshold
+	return estimate, nil
+}
+
+// percent returns the battery left after using energy (Wh), as a percentage
+func percent(profile *drones.Profile, used float64) float64 {
+	return 100 * (profile.BatteryWh - used) / profile.BatteryWh
+}
@@ -0,0 +1,133 @@
+// Package energy estimates the battery a waypoint mission uses on a given
+// aircraft and splits missions that need more than one battery into sorties.
+package energy
+
+import (
+	"math"
+
+	"drone-planner/server/drones"
+	"drone-planner/server/geometry"
+)
+
+// Breakdown splits an energy (Wh) by what it is spent on: holding the
+// aircraft in the air, overcoming drag and gaining altitude
+type Breakdown struct {
+	Hover  float64 `json:"hover"`
+	Cruise float64 `json:"cruise"`
+	Climb  float64 `json:"climb"`
+}
+
+// Total returns the energy of all parts (Wh)
+func (b Breakdown) Total() float64 {
+	return b.Hover + b.Cruise + b.Climb
+}
+
+func (b *Breakdown) add(other Breakdown) {
+	b.Hover += other.Hover
+	b.Cruise += other.Cruise
+	b.Climb += other.Climb
+}
+
+// Step is one part of the flight with its energy cost
+type Step struct {
+	// Kind is "takeoff", "transit", "segment", "action", "return" or "landing"
+	Kind string `json:"kind"`
+	// Index is the position in the flown sequence the step ends at, -1 for home
+	Index    int       `json:"index"`
+	Duration float64   `json:"duration"` // s
+	Distance float64   `json:"distance"` // m
+	Energy   Breakdown `json:"energy"`
+}
+
+// hoverEnergy is the energy of holding position for a duration (s)
+func hoverEnergy(profile *drones.Profile, duration float64) Breakdown {
+	return Breakdown{Hover: profile.Power.HoverPower * duration / 3600}
+}
+
+// flightEnergy is the energy of flying at constant horizontal and vertical
+// speeds (m/s) for a duration (s)
+func flightEnergy(profile *drones.Profile, horizontalSpeed, verticalSpeed, duration float64) Breakdown {
+	hours := duration / 3600
+	total := profile.PowerDraw(horizontalSpeed, verticalSpeed) * hours
+	energy := hoverEnergy(profile, duration)
+	if verticalSpeed > 0 {
+		energy.Climb = profile.Power.ClimbPower * verticalSpeed * hours
+	}
+	energy.Cruise = total - energy.Hover - energy.Climb
+	return energy
+}
+
+// segmentStep costs a measured route segment
+func segmentStep(profile *drones.Profile, segment geometry.Segment) Step {
+	step := Step{Kind: "segment", Index: segment.To, Duration: segment.Duration, Distance: segment.Length}
+	if segment.Duration <= 0 || segment.Length <= 0 {
+		return step
+	}
+	horizontalSpeed := segment.Speed * segment.Horizontal / segment.Length
+	verticalSpeed := segment.Speed * segment.Climb / segment.Length
+	step.Energy = flightEnergy(profile, horizontalSpeed, verticalSpeed, segment.Duration)
+	return step
+}
+
+// verticalStep costs climbing (positive) or descending in place
+func verticalStep(profile *drones.Profile, kind string, index int, climb float64) Step {
+	speed := profile.MaxAscentSpeed
+	if climb < 0 {
+		speed = -profile.MaxDescentSpeed
+	}
+	if speed == 0 || climb == 0 {
+		return Step{Kind: kind, Index: index}
+	}
+	duration := climb / speed
+	return Step{
+		Kind:     kind,
+		Index:    index,
+		Duration: duration,
+		Distance: math.Abs(climb),
+		Energy:   flightEnergy(profile, 0, speed, duration),
+	}
+}
+
+// levelStep costs flying level between two points at a speed (m/s)
+func levelStep(profile *drones.Profile, kind string, index int, from, to geometry.Point, speed float64) Step {
+	distance := geometry.Distance(from.Latitude, from.Longitude, to.Latitude, to.Longitude)
+	if speed <= 0 {
+		speed = geometry.DefaultSpeed
+	}
+	if profile.MaxHorizontalSpeed > 0 && speed > profile.MaxHorizontalSpeed {
+		speed = profile.MaxHorizontalSpeed
+	}
+	duration := distance / speed
+	return Step{
+		Kind:     kind,
+		Index:    index,
+		Duration: duration,
+		Distance: distance,
+		Energy:   flightEnergy(profile, speed, 0, duration),
+	}
+}
+
+// outbound costs taking off at home and flying to a point at its altitude
+func outbound(profile *drones.Profile, home, to geometry.Point, index int, speed float64) []Step {
+	return []Step{
+		verticalStep(profile, "takeoff", -1, to.Altitude-home.Altitude),
+		levelStep(profile, "transit", index, geometry.Point{Latitude: home.Latitude, Longitude: home.Longitude, Altitude: to.Altitude}, to, speed),
+	}
+}
+
+// homeward costs flying back from a point to home at its altitude and landing
+func homeward(profile *drones.Profile, from, home geometry.Point, speed float64) []Step {
+	return []Step{
+		levelStep(profile, "return", -1, from, geometry.Point{Latitude: home.Latitude, Longitude: home.Longitude, Altitude: from.Altitude}, speed),
+		verticalStep(profile, "landing", -1, home.Altitude-from.Altitude),
+	}
+}
+
+// stepsEnergy sums the energy of steps (Wh)
+func stepsEnergy(steps []Step) float64 {
+	total := 0.0
+	for _, step := range steps {
+		total += step.Energy.Total()
+	}
+	return total
+}
@@ -0,0 +1,108 @@
+package energy
+
+import (
+	"fmt"
+
+	"drone-planner/server/drones"
+	"drone-planner/server/models"
+)
+
+// Sortie is one battery's worth of a split waypoint mission
+type Sortie struct {
+	Number int `json:"number"`
+	// Start and End are positions in the flown sequence, counting repeats
+	Start int `json:"start"`
+	End   int `json:"end"`
+	// ResumeWaypoint is the mission waypoint the sortie joins the route at
+	ResumeWaypoint int `json:"resumeWaypoint"`
+	// Outbound is the takeoff and transit to the resume waypoint
+	Outbound []Step `json:"outbound"`
+	// Return is the flight home and landing after the last waypoint
+	Return   []Step    `json:"return"`
+	Energy   Breakdown `json:"energy"`
+	Duration float64   `json:"duration"`
+	// Remaining is the battery left on landing (%)
+	Remaining float64 `json:"remaining"`
+	// Config is the sortie as a waypoint mission that returns home when done
+	Config models.WaypointMissionConfig `json:"config"`
+}
+
+// Split divides a waypoint mission into sorties that each land with at least
+// the threshold left. Every sortie after the first resumes at the waypoint
+// the previous one turned back from; actions there are not repeated.
+func Split(config *models.WaypointMissionConfig, profile *drones.Profile, options Options) ([]Sortie, error) {
+	seq, err := flatten(config)
+	if err != nil {
+		return nil, err
+	}
+	budget := profile.BatteryWh * (1 - options.Threshold/100)
+	if budget <= 0 {
+		return nil, fmt.Errorf("battery threshold leaves no usable energy")
+	}
+
+	sorties := []Sortie{}
+	for start := 0; start < len(seq.points)-1; {
+		sortie := Sortie{
+			Number:         len(sorties) + 1,
+			Start:          start,
+			ResumeWaypoint: seq.waypoints[start],
+			Outbound:       outbound(profile, options.Home, seq.points[start], start, seq.speed),
+		}
+		var steps []Step
+		if start == 0 && seq.actions[0] > 0 {
+			steps = append(steps, Step{Kind: "action", Index: 0, Duration: seq.actions[0], Energy: hoverEnergy(profile, seq.actions[0])})
+		}
+
+		used := stepsEnergy(sortie.Outbound) + stepsEnergy(steps)
+		end := start
+		for end < len(seq.points)-1 {
+			next := []Step{segmentStep(profile, seq.segments[end])}
+			if seq.actions[end+1] > 0 {
+				next = append(next, Step{Kind: "action", Index: end + 1, Duration: seq.actions[end+1], Energy: hoverEnergy(profile, seq.actions[end+1])})
+			}
+			cost := stepsEnergy(next)
+			returnEnergy := stepsEnergy(homeward(profile, seq.points[end+1], options.Home, seq.speed))
+			if used+cost+returnEnergy > budget {
+				break
+			}
+			used += cost
+			steps = append(steps, next...)
+			end++
+		}
+		if end == start {
+			return nil, fmt.Errorf("the %s cannot fly from waypoint %d to waypoint %d and return on one battery",
+				profile.Name, seq.waypoints[start]+1, seq.waypoints[start+1]+1)
+		}
+
+		sortie.End = end
+		sortie.Return = homeward(profile, seq.points[end], options.Home, seq.speed)
+		for _, part := range [][]Step{sortie.Outbound, steps, sortie.Return} {
+			for _, step := range part {
+				sortie.Energy.add(step.Energy)
+				sortie.Duration += step.Duration
+			}
+		}
+		sortie.Remaining = percent(profile, sortie.Energy.Total())
+		sortie.Config = sortieConfig(config, seq, start, end)
+		sorties = append(sorties, sortie)
+		start = end
+	}
+	return sorties, nil
+}
+
+// sortieConfig builds the waypoint mission flown by a sortie
+func sortieConfig(config *models.WaypointMissionConfig, seq *sequence, start, end int) models.WaypointMissionConfig {
+	sortie := *config
+	sortie.RepeatTimes = 0
+	sortie.FinishedAction = "GO_HOME"
+	sortie.Waypoints = make([]models.Waypoint, 0, end-start+1)
+	for i := start; i <= end; i++ {
+		wp := config.Waypoints[seq.waypoints[i]]
+		if i == start && start > 0 {
+			// Performed by the previous sortie before it turned back
+			wp.Actions = nil
+		}
+		sortie.Waypoints = append(sortie.Waypoints, wp)
+	}
+	return sortie
+}
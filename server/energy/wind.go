@@ -0,0 +1,212 @@
+package energy
+
+import (
+	"fmt"
+	"math"
+
+	"drone-planner/server/drones"
+	"drone-planner/server/geometry"
+	"drone-planner/server/models"
+	"drone-planner/server/weather"
+)
+
+// minGroundSpeed is the slowest ground speed a leg counts as flyable (m/s)
+const minGroundSpeed = 0.5
+
+// WindLeg is a leg of the route flown in wind. The aircraft holds its track
+// by crabbing into the crosswind and keeps the planned ground speed unless
+// that needs more than its maximum airspeed.
+type WindLeg struct {
+	// Kind is "transit", "segment" or "return"
+	Kind string `json:"kind"`
+	// Index is the position in the flown sequence the leg ends at, -1 for home
+	Index int `json:"index"`
+	// Waypoint is the mission waypoint the leg ends at, -1 for home
+	Waypoint int          `json:"waypoint"`
+	Track    float64      `json:"track"`    // degrees
+	Distance float64      `json:"distance"` // m, along the ground
+	Wind     weather.Wind `json:"wind"`
+	// Headwind is the wind against the track, negative for a tailwind (m/s)
+	Headwind float64 `json:"headwind"`
+	// Crosswind is the wind across the track, positive from the left (m/s)
+	Crosswind    float64 `json:"crosswind"`
+	PlannedSpeed float64 `json:"plannedSpeed"` // m/s over the ground
+	GroundSpeed  float64 `json:"groundSpeed"`
+	Airspeed     float64 `json:"airspeed"`
+	// CrabAngle is the heading minus the track (degrees)
+	CrabAngle    float64 `json:"crabAngle"`
+	Duration     float64 `json:"duration"`
+	CalmDuration float64 `json:"calmDuration"`
+	Energy       float64 `json:"energy"` // Wh
+	CalmEnergy   float64 `json:"calmEnergy"`
+	// Reachable is false when the wind stops the aircraft holding the track
+	Reachable bool `json:"reachable"`
+}
+
+// WindEstimate is the predicted duration and energy use of a waypoint
+// mission in wind, next to the same flight in calm air
+type WindEstimate struct {
+	Drone     string              `json:"drone"`
+	BatteryWh float64             `json:"batteryWh"`
+	Threshold float64             `json:"threshold"` // %
+	Wind      weather.WindProfile `json:"wind"`
+
+	Legs []WindLeg `json:"legs"`
+
+	// Duration and Energy include takeoff, actions, the return and landing
+	Duration     float64 `json:"duration"`
+	CalmDuration float64 `json:"calmDuration"`
+	Energy       float64 `json:"energy"` // Wh
+	CalmEnergy   float64 `json:"calmEnergy"`
+	// Remaining is the battery left on landing (%)
+	Remaining     float64 `json:"remaining"`
+	CalmRemaining float64 `json:"calmRemaining"`
+
+	// Feasible reports whether every leg can be flown and the aircraft lands
+	// with at least the threshold left
+	Feasible bool     `json:"feasible"`
+	Warnings []string `json:"warnings"`
+}
+
+// EstimateWind predicts the time and battery a waypoint mission takes in a
+// wind profile. Layer altitudes are relative to takeoff, like the waypoint
+// altitudes of config. Curved segments keep their length but are taken to
+// follow the track of their chord.
+func EstimateWind(config *models.WaypointMissionConfig, profile *drones.Profile, options Options, wind weather.WindProfile) (*WindEstimate, error) {
+	seq, err := flatten(config)
+	if err != nil {
+		return nil, err
+	}
+	if profile.BatteryWh <= 0 {
+		return nil, fmt.Errorf("drone %s has no battery capacity", profile.ID)
+	}
+
+	estimate := &WindEstimate{
+		Drone:     profile.ID,
+		BatteryWh: profile.BatteryWh,
+		Threshold: options.Threshold,
+		Wind:      wind,
+		Legs:      []WindLeg{},
+		Warnings:  []string{},
+	}
+	if estimate.Wind == nil {
+		estimate.Wind = weather.WindProfile{}
+	}
+	home := options.Home
+	first, last := seq.points[0], seq.points[len(seq.points)-1]
+	at := func(from, to geometry.Point) weather.Wind {
+		return wind.At((from.Altitude+to.Altitude)/2 - home.Altitude)
+	}
+	speed := seq.speed
+	if speed <= 0 {
+		speed = geometry.DefaultSpeed
+	}
+
+	estimate.hold(profile, verticalStep(profile, "takeoff", -1, first.Altitude-home.Altitude), wind.At((first.Altitude-home.Altitude)/2))
+	launch := geometry.Point{Latitude: home.Latitude, Longitude: home.Longitude, Altitude: first.Altitude}
+	estimate.fly(profile, windLeg(profile, "transit", 0, seq.waypoints[0], launch, first, geometry.Distance(home.Latitude, home.Longitude, first.Latitude, first.Longitude), 0, speed, at(launch, first)))
+	for i := range seq.points {
+		if i > 0 {
+			segment := seq.segments[i-1]
+			from, to := seq.points[i-1], seq.points[i]
+			estimate.fly(profile, windLeg(profile, "segment", i, seq.waypoints[i], from, to, segment.Horizontal, segment.Climb, segment.Speed, at(from, to)))
+		}
+		if seq.actions[i] > 0 {
+			estimate.hold(profile, Step{Kind: "action", Index: i, Duration: seq.actions[i], Energy: hoverEnergy(profile, seq.actions[i])}, wind.At(seq.points[i].Altitude-home.Altitude))
+		}
+	}
+	over := geometry.Point{Latitude: home.Latitude, Longitude: home.Longitude, Altitude: last.Altitude}
+	estimate.fly(profile, windLeg(profile, "return", -1, -1, last, over, geometry.Distance(last.Latitude, last.Longitude, home.Latitude, home.Longitude), 0, speed, at(last, over)))
+	estimate.hold(profile, verticalStep(profile, "landing", -1, home.Altitude-last.Altitude), wind.At((last.Altitude-home.Altitude)/2))
+
+	estimate.Remaining = percent(profile, estimate.Energy)
+	estimate.CalmRemaining = percent(profile, estimate.CalmEnergy)
+	estimate.Feasible = estimate.Remaining >= options.Threshold
+	for _, leg := range estimate.Legs {
+		estimate.Feasible = estimate.Feasible && leg.Reachable
+	}
+	if estimate.Remaining < options.Threshold && estimate.CalmRemaining >= options.Threshold {
+		estimate.Warnings = append(estimate.Warnings, fmt.Sprintf("the wind leaves %.0f%% on landing, below the %.0f%% threshold the flight meets in calm air",
+			estimate.Remaining, options.Threshold))
+	}
+	return estimate, nil
+}
+
+// fly adds a leg, warning when the wind slows or stops it
+func (e *WindEstimate) fly(profile *drones.Profile, leg WindLeg) {
+	e.Legs = append(e.Legs, leg)
+	e.Duration += leg.Duration
+	e.CalmDuration += leg.CalmDuration
+	e.Energy += leg.Energy
+	e.CalmEnergy += leg.CalmEnergy
+
+	name := fmt.Sprintf("%s to waypoint %d", leg.Kind, leg.Waypoint+1)
+	if leg.Waypoint < 0 {
+		name = leg.Kind + " home"
+	}
+	switch {
+	case !leg.Reachable:
+		e.Warnings = append(e.Warnings, fmt.Sprintf("%s: the %s cannot hold its track against %.1f m/s of wind", name, profile.Name, leg.Wind.Speed))
+	case leg.GroundSpeed < leg.PlannedSpeed-0.05:
+		e.Warnings = append(e.Warnings, fmt.Sprintf("%s: %.1f m/s of headwind exceeds the %.1f m/s margin between the planned speed and the %s's maximum; ground speed drops to %.1f m/s",
+			name, leg.Headwind, profile.MaxHorizontalSpeed-leg.PlannedSpeed, profile.Name, leg.GroundSpeed))
+	}
+}
+
+// hold adds a step flown in place, where the aircraft must fly as fast as
+// the wind to stay put
+func (e *WindEstimate) hold(profile *drones.Profile, step Step, wind weather.Wind) {
+	e.Duration += step.Duration
+	e.CalmDuration += step.Duration
+	e.Energy += step.Energy.Total() + flightEnergy(profile, wind.Speed, 0, step.Duration).Cruise
+	e.CalmEnergy += step.Energy.Total()
+}
+
+// windLeg flies a leg from one point to another, horizontal meters along the
+// ground, at a planned speed (m/s along the 3D path) in a wind
+func windLeg(profile *drones.Profile, kind string, index, waypoint int, from, to geometry.Point, horizontal, climb, speed float64, wind weather.Wind) WindLeg {
+	length := math.Hypot(horizontal, climb)
+	leg := WindLeg{Kind: kind, Index: index, Waypoint: waypoint, Distance: horizontal, Wind: wind, Reachable: true}
+	if length == 0 || speed <= 0 {
+		return leg
+	}
+	if profile.MaxHorizontalSpeed > 0 {
+		speed = math.Min(speed, profile.MaxHorizontalSpeed)
+	}
+	plannedSpeed := speed * horizontal / length
+	verticalSpeed := speed * climb / length
+	leg.PlannedSpeed = plannedSpeed
+	leg.CalmDuration = length / speed
+	leg.CalmEnergy = flightEnergy(profile, plannedSpeed, verticalSpeed, leg.CalmDuration).Total()
+	if horizontal == 0 {
+		leg.Airspeed = wind.Speed
+		leg.Duration = leg.CalmDuration
+		leg.Energy = flightEnergy(profile, wind.Speed, verticalSpeed, leg.Duration).Total()
+		return leg
+	}
+
+	leg.Track = geometry.Bearing(from.Latitude, from.Longitude, to.Latitude, to.Longitude)
+	track := leg.Track * math.Pi / 180
+	east, north := wind.Vector()
+	along := east*math.Sin(track) + north*math.Cos(track)
+	across := east*math.Cos(track) - north*math.Sin(track)
+	leg.Headwind, leg.Crosswind = -along, across
+
+	groundSpeed := plannedSpeed
+	airspeed := math.Hypot(groundSpeed-along, across)
+	if max := profile.MaxHorizontalSpeed; max > 0 && airspeed > max {
+		if math.Abs(across) >= max || along+math.Sqrt(max*max-across*across) < minGroundSpeed {
+			leg.Reachable = false
+			return leg
+		}
+		groundSpeed = along + math.Sqrt(max*max-across*across)
+		airspeed = max
+	}
+	leg.GroundSpeed = groundSpeed
+	leg.Airspeed = airspeed
+	leg.CrabAngle = math.Atan2(-across, groundSpeed-along) * 180 / math.Pi
+	leg.Duration = horizontal / groundSpeed
+	// Altitude changes along the track, so the climb slows with the aircraft
+	leg.Energy = flightEnergy(profile, airspeed, verticalSpeed*groundSpeed/plannedSpeed, leg.Duration).Total()
+	return leg
+}
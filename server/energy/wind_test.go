@@ -0,0 +1,91 @@
+package energy
+
+import (
+	"math"
+	"testing"
+
+	"drone-planner/server/weather"
+)
+
+func TestEstimateWind(t *testing.T) {
+	tests := []struct {
+		name string
+		wind weather.WindProfile
+		// The outbound leg flies due north at a planned 8 m/s
+		headwind, crosswind, groundSpeed, airspeed, crab float64
+		reachable                                        bool
+		warnings                                         int
+	}{
+		{"calm", nil, 0, 0, 8, 8, 0, true, 0},
+		{"headwind", weather.Constant(weather.Wind{Speed: 4, Direction: 0}), 4, 0, 6, 10, 0, true, 1},
+		{"tailwind", weather.Constant(weather.Wind{Speed: 4, Direction: 180}), -4, 0, 8, 4, 0, true, 1},
+		{"crosswind", weather.Constant(weather.Wind{Speed: 6, Direction: 90}), 0, -6, 8, 10, 36.8698976, true, 0},
+		{"crosswind at maximum speed", weather.Constant(weather.Wind{Speed: 10, Direction: 90}), 0, -10, 0, 0, 0, false, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := shuttle(1)
+			config.AutoFlightSpeed = 8
+			profile := testProfile
+			estimate, err := EstimateWind(config, &profile, Options{Home: home, Threshold: 20}, tt.wind)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(estimate.Legs) != 3 {
+				t.Fatalf("got %d legs, want transit, segment and return", len(estimate.Legs))
+			}
+			leg := estimate.Legs[1]
+			got := []float64{leg.Headwind, leg.Crosswind, leg.GroundSpeed, leg.Airspeed, leg.CrabAngle}
+			want := []float64{tt.headwind, tt.crosswind, tt.groundSpeed, tt.airspeed, tt.crab}
+			for i, name := range []string{"headwind", "crosswind", "ground speed", "airspeed", "crab angle"} {
+				if math.Abs(got[i]-want[i]) > 1e-6 {
+					t.Errorf("%s = %g, want %g", name, got[i], want[i])
+				}
+			}
+			if leg.Reachable != tt.reachable || estimate.Feasible != tt.reachable {
+				t.Errorf("reachable = %v and feasible = %v, want %v", leg.Reachable, estimate.Feasible, tt.reachable)
+			}
+			if len(estimate.Warnings) != tt.warnings {
+				t.Errorf("warnings = %q, want %d", estimate.Warnings, tt.warnings)
+			}
+			if !tt.reachable {
+				return
+			}
+			// Wind never shortens the flight: a tailwind is ridden at the
+			// planned ground speed, and holding against it still costs energy
+			if tt.wind == nil && (estimate.Duration != estimate.CalmDuration || estimate.Energy != estimate.CalmEnergy) {
+				t.Errorf("calm air estimate %g s, %g Wh differs from %g s, %g Wh", estimate.Duration, estimate.Energy, estimate.CalmDuration, estimate.CalmEnergy)
+			}
+			if estimate.Duration < estimate.CalmDuration-1e-9 || estimate.Energy < estimate.CalmEnergy-1e-9 {
+				t.Errorf("wind estimate %g s, %g Wh beats calm air %g s, %g Wh", estimate.Duration, estimate.Energy, estimate.CalmDuration, estimate.CalmEnergy)
+			}
+			if want := leg.Distance / tt.groundSpeed; math.Abs(leg.Duration-want) > 1e-6 {
+				t.Errorf("leg duration = %g s, want %g", leg.Duration, want)
+			}
+		})
+	}
+}
+
+func TestEstimateWindThreshold(t *testing.T) {
+	config := shuttle(1)
+	config.AutoFlightSpeed = 8
+	profile := testProfile
+	wind := weather.Constant(weather.Wind{Speed: 4, Direction: 0})
+	estimate, err := EstimateWind(config, &profile, Options{Home: home}, wind)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if estimate.Remaining >= estimate.CalmRemaining {
+		t.Fatalf("wind leaves %g%%, calm air %g%%", estimate.Remaining, estimate.CalmRemaining)
+	}
+
+	// A threshold met in calm air but not in the wind
+	threshold := (estimate.Remaining + estimate.CalmRemaining) / 2
+	estimate, err = EstimateWind(config, &profile, Options{Home: home, Threshold: threshold}, wind)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if estimate.Feasible || len(estimate.Warnings) != 2 {
+		t.Errorf("feasible = %v with warnings %q, want infeasible with a headwind and a threshold warning", estimate.Feasible, estimate.Warnings)
+	}
+}
@@ -0,0 +1,286 @@
+package generators
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"drone-planner/server/geometry"
+	"drone-planner/server/models"
+	"drone-planner/server/timeline"
+)
+
+// arcStep is the largest turn (degrees) between the points rounding the
+// outside of a corridor bend
+const arcStep = 15.0
+
+// Corridor describes a corridor mapped with parallel lines along a centerline
+type Corridor struct {
+	Centerline []models.Coordinate `json:"-"`
+	// Width is the width of the corridor across the centerline (m)
+	Width float64 `json:"width"`
+	// Lines is the number of parallel lines; when 0 it is the fewest that
+	// give the side overlap
+	Lines int `json:"lines"`
+	Mapping
+}
+
+// GenerateCorridor builds a waypoint mission flying parallel lines offset
+// from a centerline, back and forth, taking photos at the distance that
+// gives the front overlap. Lines keep their offset around bends: the outside
+// of a bend is rounded and the inside cut where the line would loop back.
+func GenerateCorridor(c Corridor) (*SurveyResult, error) {
+	errs := timeline.NewFieldErrors("")
+	if len(c.Centerline) < 2 {
+		errs.Add("centerline", "must have at least 2 points")
+	}
+	for i, p := range c.Centerline {
+		pointErrs := errs.At(fmt.Sprintf("centerline[%d]", i))
+		pointErrs.Between("latitude", p.Latitude, -90, 90)
+		pointErrs.Between("longitude", p.Longitude, -180, 180)
+	}
+	if c.Width <= 0 {
+		errs.Add("width", "must be greater than 0")
+	}
+	if c.Lines < 0 {
+		errs.Add("lines", "must not be negative")
+	}
+	c.Mapping.validate(errs)
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+
+	plane := geometry.NewPlane(centroid(c.Centerline))
+	var centerline [][2]float64
+	for _, p := range c.Centerline {
+		x, y := plane.Project(p.Latitude, p.Longitude)
+		if n := len(centerline); n > 0 && math.Hypot(x-centerline[n-1][0], y-centerline[n-1][1]) < 0.01 {
+			continue
+		}
+		centerline = append(centerline, [2]float64{x, y})
+	}
+	if len(centerline) < 2 {
+		return nil, fmt.Errorf("centerline has no length")
+	}
+
+	height, lineSpacing, triggerDistance := c.Mapping.grid()
+	speed := photoSpeed(c.Camera, triggerDistance, c.Speed, c.MaxSpeed)
+	lines := c.Lines
+	if lines == 0 {
+		lines = int(math.Max(1, math.Ceil(c.Width/lineSpacing)))
+	}
+	step := c.Width / float64(lines)
+
+	result := &SurveyResult{
+		Altitude:        height,
+		GSD:             c.Camera.GSD(height),
+		LineSpacing:     step,
+		TriggerDistance: triggerDistance,
+		Speed:           speed,
+	}
+	var waypoints []models.Waypoint
+	flown := 0
+	for k := 0; k < lines; k++ {
+		// Lines run from the left edge to the right, alternating direction
+		offset := (float64(lines-1)/2 - float64(k)) * step
+		path := offsetPath(centerline, offset)
+		if path == nil {
+			continue
+		}
+		flown++
+		if flown%2 == 0 {
+			for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+				path[i], path[j] = path[j], path[i]
+			}
+		}
+
+		length := 0.0
+		for i, p := range path {
+			next, from := i+1, i
+			if next == len(path) {
+				next, from = i, i-1
+			}
+			heading := normalizeHeading(math.Atan2(path[next][0]-path[from][0], path[next][1]-path[from][1]) * 180 / math.Pi)
+			wp := newWaypoint(plane, p, height, heading, nadirPitch)
+			if i < len(path)-1 {
+				wp.Actions = []models.WaypointAction{{ActionType: models.ActionPhotoInterval, ActionParam: triggerDistance}}
+				length += math.Hypot(path[i+1][0]-p[0], path[i+1][1]-p[1])
+			}
+			waypoints = append(waypoints, wp)
+		}
+		result.Photos += int(length/triggerDistance) + 1
+	}
+	if flown == 0 {
+		return nil, fmt.Errorf("centerline folds back closer than the corridor width")
+	}
+	result.Lines = flown
+	numberWaypoints(waypoints)
+
+	config := mappingConfig(waypoints, speed, c.MaxSpeed, c.AltitudeMode)
+	result.Distance = geometry.WaypointRoute(config).Distance
+	element, err := waypointElement(config)
+	if err != nil {
+		return nil, err
+	}
+	result.Element = *element
+	return result, nil
+}
+
+// offsetPath returns a polyline shifted offset meters to its left (right
+// when negative). Bends are rounded on the outside and the loops that form
+// on the inside of sharp bends are cut out. It returns nil when the line
+// would cross the polyline, where it folds back closer than the offset.
+func offsetPath(points [][2]float64, offset float64) [][2]float64 {
+	if offset == 0 {
+		return append([][2]float64{}, points...)
+	}
+
+	normals := make([][2]float64, len(points)-1)
+	for i := range normals {
+		dx, dy := points[i+1][0]-points[i][0], points[i+1][1]-points[i][1]
+		length := math.Hypot(dx, dy)
+		normals[i] = [2]float64{-dy / length, dx / length}
+	}
+	shift := func(p, n [2]float64) [2]float64 {
+		return [2]float64{p[0] + n[0]*offset, p[1] + n[1]*offset}
+	}
+
+	path := [][2]float64{shift(points[0], normals[0])}
+	for i := 1; i < len(points)-1; i++ {
+		before, after := normals[i-1], normals[i]
+		// A left turn has the left side inside the bend
+		turn := before[0]*after[1] - before[1]*after[0]
+		if turn*offset > 0 || math.Abs(turn) < 1e-9 && before[0]*after[0]+before[1]*after[1] > 0 {
+			// Inside the bend the shifted segments meet where they cross
+			if cross, ok := lineIntersection(shift(points[i-1], before), shift(points[i], before), shift(points[i], after), shift(points[i+1], after)); ok {
+				path = append(path, cross)
+			} else {
+				path = append(path, shift(points[i], before))
+			}
+			continue
+		}
+
+		// Outside the bend an arc around the vertex joins them
+		start := math.Atan2(before[1], before[0])
+		sweep := math.Atan2(after[1], after[0]) - start
+		if offset < 0 {
+			start += math.Pi
+		}
+		for sweep > math.Pi {
+			sweep -= 2 * math.Pi
+		}
+		for sweep < -math.Pi {
+			sweep += 2 * math.Pi
+		}
+		steps := int(math.Ceil(math.Abs(sweep) * 180 / math.Pi / arcStep))
+		if steps < 1 {
+			steps = 1
+		}
+		radius := math.Abs(offset)
+		for s := 0; s <= steps; s++ {
+			angle := start + sweep*float64(s)/float64(steps)
+			path = append(path, [2]float64{points[i][0] + radius*math.Cos(angle), points[i][1] + radius*math.Sin(angle)})
+		}
+	}
+	path = append(path, shift(points[len(points)-1], normals[len(normals)-1]))
+	path = removeLoops(path)
+	for i := 0; i+1 < len(path); i++ {
+		for j := 0; j+1 < len(points); j++ {
+			if _, ok := segmentIntersection(path[i], path[i+1], points[j], points[j+1]); ok {
+				return nil
+			}
+		}
+	}
+	return path
+}
+
+// removeLoops cuts out the parts of a polyline between two segments that
+// cross, joining them at the crossing
+func removeLoops(path [][2]float64) [][2]float64 {
+	for i := 0; i+1 < len(path); i++ {
+		for j := len(path) - 2; j > i+1; j-- {
+			if cross, ok := segmentIntersection(path[i], path[i+1], path[j], path[j+1]); ok {
+				path = append(append(path[:i+1], cross), path[j+1:]...)
+				break
+			}
+		}
+	}
+
+	// Cuts can leave a crossing on top of the point before it
+	trimmed := path[:1]
+	for _, p := range path[1:] {
+		last := trimmed[len(trimmed)-1]
+		if math.Hypot(p[0]-last[0], p[1]-last[1]) >= 0.01 {
+			trimmed = append(trimmed, p)
+		}
+	}
+	return trimmed
+}
+
+// lineIntersection returns where the infinite lines through a-b and c-d
+// cross, and false when they are parallel
+func lineIntersection(a, b, c, d [2]float64) ([2]float64, bool) {
+	t, _, ok := intersectionParams(a, b, c, d)
+	if !ok {
+		return [2]float64{}, false
+	}
+	return [2]float64{a[0] + t*(b[0]-a[0]), a[1] + t*(b[1]-a[1])}, true
+}
+
+// segmentIntersection returns where segments a-b and c-d cross
+func segmentIntersection(a, b, c, d [2]float64) ([2]float64, bool) {
+	t, u, ok := intersectionParams(a, b, c, d)
+	if !ok || t < 0 || t > 1 || u < 0 || u > 1 {
+		return [2]float64{}, false
+	}
+	return [2]float64{a[0] + t*(b[0]-a[0]), a[1] + t*(b[1]-a[1])}, true
+}
+
+// intersectionParams returns the positions along a-b and c-d (0 at a and c,
+// 1 at b and d) where the lines through them cross
+func intersectionParams(a, b, c, d [2]float64) (t, u float64, ok bool) {
+	rx, ry := b[0]-a[0], b[1]-a[1]
+	sx, sy := d[0]-c[0], d[1]-c[1]
+	denominator := rx*sy - ry*sx
+	if math.Abs(denominator) < 1e-12 {
+		return 0, 0, false
+	}
+	qx, qy := c[0]-a[0], c[1]-a[1]
+	return (qx*sy - qy*sx) / denominator, (qx*ry - qy*rx) / denominator, true
+}
+
+// ParseLineString reads the coordinates of a GeoJSON LineString given as a
+// geometry, a Feature or the first LineString of a FeatureCollection
+func ParseLineString(data []byte) ([]models.Coordinate, error) {
+	var object struct {
+		Type        string            `json:"type"`
+		Coordinates [][]float64       `json:"coordinates"`
+		Geometry    json.RawMessage   `json:"geometry"`
+		Features    []json.RawMessage `json:"features"`
+	}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, fmt.Errorf("invalid GeoJSON: %v", err)
+	}
+
+	switch object.Type {
+	case "LineString":
+		coordinates := make([]models.Coordinate, 0, len(object.Coordinates))
+		for _, position := range object.Coordinates {
+			if len(position) < 2 {
+				return nil, fmt.Errorf("invalid GeoJSON position")
+			}
+			coordinates = append(coordinates, models.Coordinate{Latitude: position[1], Longitude: position[0]})
+		}
+		return coordinates, nil
+	case "Feature":
+		return ParseLineString(object.Geometry)
+	case "FeatureCollection":
+		for _, feature := range object.Features {
+			if coordinates, err := ParseLineString(feature); err == nil {
+				return coordinates, nil
+			}
+		}
+		return nil, fmt.Errorf("no LineString feature found")
+	}
+	return nil, fmt.Errorf("expected a LineString, got %q", object.Type)
+}
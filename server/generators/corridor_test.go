@@ -0,0 +1,164 @@
+package generators
+
+import (
+	"math"
+	"testing"
+
+	"drone-planner/server/models"
+	"drone-planner/server/timeline"
+)
+
+func TestOffsetPath(t *testing.T) {
+	tests := []struct {
+		name       string
+		centerline [][2]float64
+		// dropped are the offsets whose line would cross the centerline
+		dropped []float64
+	}{
+		{"straight", [][2]float64{{0, 0}, {100, 0}}, nil},
+		{"right angle", [][2]float64{{0, 0}, {100, 0}, {100, 100}}, nil},
+		{"zigzag", [][2]float64{{0, 0}, {50, 40}, {100, 0}, {150, 40}}, nil},
+		{"hairpin", [][2]float64{{0, 0}, {100, 0}, {0, 30}}, nil},
+		{"tight hairpin", [][2]float64{{0, 0}, {100, 0}, {0, 8}}, nil},
+		{"U-turn", [][2]float64{{0, 0}, {100, 0}, {100, 60}, {0, 60}}, nil},
+		{"narrow U-turn", [][2]float64{{0, 0}, {100, 0}, {100, 10}, {0, 10}}, []float64{15}},
+		{"collinear", [][2]float64{{0, 0}, {50, 0}, {100, 0}}, nil},
+	}
+	// Four lines across a 40 m corridor
+	offsets := []float64{15, 5, -5, -15}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var paths [][][2]float64
+			var kept []float64
+			for _, offset := range offsets {
+				path := offsetPath(tt.centerline, offset)
+				dropped := false
+				for _, d := range tt.dropped {
+					dropped = dropped || d == offset
+				}
+				if path == nil {
+					if !dropped {
+						t.Errorf("line at %g m was dropped", offset)
+					}
+					continue
+				}
+				if dropped {
+					t.Errorf("line at %g m = %v, want it dropped", offset, path)
+				}
+				if len(path) < 2 {
+					t.Fatalf("line at %g m has %d points", offset, len(path))
+				}
+				if cross, ok := pathsCross(path, tt.centerline); ok {
+					t.Errorf("line at %g m crosses the centerline at %v", offset, cross)
+				}
+				for i := 0; i+1 < len(path); i++ {
+					for j := i + 2; j+1 < len(path); j++ {
+						if cross, ok := segmentIntersection(path[i], path[i+1], path[j], path[j+1]); ok {
+							t.Errorf("line at %g m crosses itself at %v between segments %d and %d", offset, cross, i, j)
+						}
+					}
+				}
+				paths = append(paths, path)
+				kept = append(kept, offset)
+			}
+			// No two lines cross
+			for k := 0; k < len(paths); k++ {
+				for l := k + 1; l < len(paths); l++ {
+					if cross, ok := pathsCross(paths[k], paths[l]); ok {
+						t.Errorf("lines at %g m and %g m cross at %v", kept[k], kept[l], cross)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestOffsetPathCorners(t *testing.T) {
+	corner := [][2]float64{{0, 0}, {100, 0}, {100, 100}}
+	// Inside the left turn the shifted segments meet at a point
+	if got := offsetPath(corner, 10); !samePath(got, [][2]float64{{0, 10}, {90, 10}, {90, 100}}) {
+		t.Errorf("inside line = %v", got)
+	}
+	// Outside it, a 90° arc in 15° steps rounds the corner
+	got := offsetPath(corner, -10)
+	if len(got) != 2+7 || !samePath([][2]float64{got[0], got[len(got)-1]}, [][2]float64{{0, -10}, {110, 100}}) {
+		t.Fatalf("outside line = %v", got)
+	}
+	for i, p := range got[1 : len(got)-1] {
+		if d := math.Hypot(p[0]-100, p[1]); math.Abs(d-10) > 1e-9 {
+			t.Errorf("arc point %d %v is %g m from the corner", i, p, d)
+		}
+	}
+}
+
+func TestGenerateCorridor(t *testing.T) {
+	// About 200 m east, then 200 m north
+	centerline := []models.Coordinate{
+		{Latitude: 47, Longitude: 8}, {Latitude: 47, Longitude: 8.00263}, {Latitude: 47.0018, Longitude: 8.00263},
+	}
+	result, err := GenerateCorridor(Corridor{
+		Centerline: centerline,
+		Width:      100,
+		Mapping:    Mapping{Camera: squareCamera, Altitude: 100, FrontOverlap: 50, SideOverlap: 50},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 50 m apart, two lines cover the width
+	if result.Lines != 2 || result.LineSpacing != 50 || result.TriggerDistance != 50 {
+		t.Errorf("%d lines %g m apart triggering every %g m, want 2, 50 and 50", result.Lines, result.LineSpacing, result.TriggerDistance)
+	}
+	config, err := timeline.Decode(&result.Element)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waypoints := config.(*models.WaypointMissionConfig).Waypoints
+	// The outer line rounds the corner; both end without photos
+	ends := 0
+	for i, wp := range waypoints {
+		if wp.Altitude != 100 || wp.GimbalPitch != nadirPitch {
+			t.Errorf("waypoint %d at %g m pitched %g°", i, wp.Altitude, wp.GimbalPitch)
+		}
+		if len(wp.Actions) == 0 {
+			ends++
+		} else if wp.Actions[0].ActionType != models.ActionPhotoInterval || wp.Actions[0].ActionParam != 50 {
+			t.Errorf("waypoint %d actions = %+v", i, wp.Actions)
+		}
+	}
+	if ends != 2 || len(waypoints[len(waypoints)-1].Actions) != 0 {
+		t.Errorf("%d waypoints end a line, want the last of each of 2", ends)
+	}
+	// The first line runs out east, the second returns south then west
+	if first, last := waypoints[0], waypoints[len(waypoints)-1]; math.Abs(first.Heading-90) > 0.5 || math.Abs(last.Heading+90) > 0.5 {
+		t.Errorf("headings %g° first and %g° last, want 90 and -90", first.Heading, last.Heading)
+	}
+
+	if _, err := GenerateCorridor(Corridor{Centerline: centerline[:1], Width: 100, Mapping: Mapping{Camera: squareCamera, Altitude: 100}}); err == nil {
+		t.Error("expected an error for a single point centerline")
+	}
+}
+
+// pathsCross returns where two polylines first cross
+func pathsCross(a, b [][2]float64) ([2]float64, bool) {
+	for i := 0; i+1 < len(a); i++ {
+		for j := 0; j+1 < len(b); j++ {
+			if cross, ok := segmentIntersection(a[i], a[i+1], b[j], b[j+1]); ok {
+				return cross, true
+			}
+		}
+	}
+	return [2]float64{}, false
+}
+
+// samePath reports whether two polylines have the same points
+func samePath(a, b [][2]float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if math.Hypot(a[i][0]-b[i][0], a[i][1]-b[i][1]) > 1e-9 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,313 @@
+package generators
+
+import (
+	"math"
+
+	"drone-planner/server/geometry"
+	"drone-planner/server/models"
+	"drone-planner/server/timeline"
+)
+
+const (
+	// defaultInspectionSpeed is the speed inspections are flown at when none
+	// is requested (m/s)
+	defaultInspectionSpeed = 5.0
+	// defaultPointSpacing is the distance between generated waypoints along
+	// an orbit or facade when none is requested (m)
+	defaultPointSpacing = 10.0
+	// minOrbitPoints keeps small orbits round
+	minOrbitPoints = 8
+)
+
+// Inspection holds the options shared by the inspection patterns
+type Inspection struct {
+	// Spacing is the distance between waypoints along the path (m)
+	Spacing float64 `json:"spacing"`
+	// Photo takes a photo at every waypoint
+	Photo        bool    `json:"photo"`
+	AltitudeMode string  `json:"altitudeMode"`
+	Speed        float64 `json:"speed"`
+	// MaxSpeed is the aircraft's top speed (m/s), 0 when unknown
+	MaxSpeed float64 `json:"-"`
+}
+
+// Orbit describes a circular orbit around a target
+type Orbit struct {
+	Target models.Target `json:"target"`
+	Radius float64       `json:"radius"`
+	// Altitude is the height of the orbit and TargetHeight the height of the
+	// point the camera aims at, in the same altitude mode (m)
+	Altitude     float64 `json:"altitude"`
+	TargetHeight float64 `json:"targetHeight"`
+	// StartBearing is the bearing from the target to the first waypoint
+	StartBearing float64 `json:"startBearing"`
+	// Direction is CLOCKWISE (the default) or COUNTER_CLOCKWISE
+	Direction string `json:"direction"`
+	Inspection
+}
+
+// VerticalOrbit describes stacked orbits around a tower, flown from the
+// lowest level up
+type VerticalOrbit struct {
+	Orbit
+	MinAltitude float64 `json:"minAltitude"`
+	MaxAltitude float64 `json:"maxAltitude"`
+	// LevelSpacing is the height between levels (m)
+	LevelSpacing float64 `json:"levelSpacing"`
+}
+
+// Facade describes a scan of every wall of a building footprint at a
+// standoff distance, one lap per level
+type Facade struct {
+	Footprint []models.Coordinate `json:"footprint"`
+	// Distance is the standoff from the walls (m)
+	Distance     float64 `json:"distance"`
+	MinAltitude  float64 `json:"minAltitude"`
+	MaxAltitude  float64 `json:"maxAltitude"`
+	LevelSpacing float64 `json:"levelSpacing"`
+	Inspection
+}
+
+// PatternResult is a generated inspection pattern
+type PatternResult struct {
+	Element   models.TimelineElement `json:"element"`
+	Waypoints int                    `json:"waypoints"`
+	Speed     float64                `json:"speed"`
+	// Distance is the length of the route (m)
+	Distance float64 `json:"distance"`
+}
+
+// GenerateOrbit builds a waypoint mission circling a target with the camera
+// pointed at it
+func GenerateOrbit(o Orbit) (*PatternResult, error) {
+	errs := timeline.NewFieldErrors("")
+	validateOrbit(errs, o)
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+
+	waypoints := orbitLevel(o, o.Altitude)
+	return inspectionResult(waypoints, []models.Target{o.Target}, o.Inspection)
+}
+
+// GenerateVerticalOrbit builds a waypoint mission circling a target at
+// evenly spaced levels, climbing to each level above the first waypoint
+func GenerateVerticalOrbit(v VerticalOrbit) (*PatternResult, error) {
+	errs := timeline.NewFieldErrors("")
+	v.Altitude = v.MinAltitude
+	validateOrbit(errs, v.Orbit)
+	validateLevels(errs, v.MinAltitude, v.MaxAltitude, v.LevelSpacing)
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+
+	var waypoints []models.Waypoint
+	for _, altitude := range levels(v.MinAltitude, v.MaxAltitude, v.LevelSpacing) {
+		waypoints = append(waypoints, orbitLevel(v.Orbit, altitude)...)
+	}
+	return inspectionResult(waypoints, []models.Target{v.Target}, v.Inspection)
+}
+
+// GenerateFacade builds a waypoint mission flying laps around a building
+// footprint at a standoff distance, facing the walls with the camera level
+func GenerateFacade(f Facade) (*PatternResult, error) {
+	errs := timeline.NewFieldErrors("")
+	validatePolygon(errs, "footprint", f.Footprint)
+	validateLevels(errs, f.MinAltitude, f.MaxAltitude, f.LevelSpacing)
+	validateInspection(errs, f.Inspection)
+	if f.Distance <= 0 {
+		errs.Add("distance", "must be greater than 0")
+	}
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+
+	plane := geometry.NewPlane(centroid(f.Footprint))
+	ring := make([][2]float64, 0, len(f.Footprint))
+	for _, c := range f.Footprint {
+		x, y := plane.Project(c.Latitude, c.Longitude)
+		ring = append(ring, [2]float64{x, y})
+	}
+	if first, last := ring[0], ring[len(ring)-1]; first == last {
+		ring = ring[:len(ring)-1]
+	}
+	if signedArea(ring) < 0 {
+		for i, j := 0, len(ring)-1; i < j; i, j = i+1, j-1 {
+			ring[i], ring[j] = ring[j], ring[i]
+		}
+	}
+
+	spacing := pointSpacing(f.Spacing)
+	var waypoints []models.Waypoint
+	for _, altitude := range levels(f.MinAltitude, f.MaxAltitude, f.LevelSpacing) {
+		for i := range ring {
+			a, b := ring[i], ring[(i+1)%len(ring)]
+			length := math.Hypot(b[0]-a[0], b[1]-a[1])
+			if length == 0 {
+				continue
+			}
+			// The ring is counter-clockwise, so the outward normal points
+			// to the right of each wall
+			nx, ny := (b[1]-a[1])/length, -(b[0]-a[0])/length
+			heading := normalizeHeading(math.Atan2(-nx, -ny) * 180 / math.Pi)
+
+			steps := int(math.Ceil(length / spacing))
+			for k := 0; k <= steps; k++ {
+				t := float64(k) / float64(steps)
+				p := [2]float64{
+					a[0] + t*(b[0]-a[0]) + nx*f.Distance,
+					a[1] + t*(b[1]-a[1]) + ny*f.Distance,
+				}
+				waypoints = append(waypoints, newWaypoint(plane, p, altitude, heading, 0))
+			}
+		}
+	}
+	return inspectionResult(waypoints, []models.Target{}, f.Inspection)
+}
+
+// orbitLevel returns a closed lap of waypoints around a target at one
+// altitude, each facing the target
+func orbitLevel(o Orbit, altitude float64) []models.Waypoint {
+	count := int(math.Ceil(2 * math.Pi * o.Radius / pointSpacing(o.Spacing)))
+	if count < minOrbitPoints {
+		count = minOrbitPoints
+	}
+	step := 360 / float64(count)
+	if o.Direction == "COUNTER_CLOCKWISE" {
+		step = -step
+	}
+	pitch := aimPitch(altitude-o.TargetHeight, o.Radius)
+
+	waypoints := make([]models.Waypoint, 0, count+1)
+	for i := 0; i <= count; i++ {
+		lat, lng := geometry.Destination(o.Target.Lat, o.Target.Lng, o.StartBearing+float64(i)*step, o.Radius)
+		heading := normalizeHeading(geometry.Bearing(lat, lng, o.Target.Lat, o.Target.Lng))
+		waypoints = append(waypoints, models.Waypoint{
+			Coordinate:  models.Coordinate{Latitude: lat, Longitude: lng},
+			Altitude:    altitude,
+			Heading:     heading,
+			GimbalPitch: pitch,
+			TurnMode:    turnMode(o.Direction),
+			Targets:     []models.Target{},
+			Actions:     []models.WaypointAction{},
+		})
+	}
+	return waypoints
+}
+
+// inspectionResult numbers waypoints, adds photo actions and wraps them in a
+// waypoint-mission element flown at the inspection speed
+func inspectionResult(waypoints []models.Waypoint, targets []models.Target, options Inspection) (*PatternResult, error) {
+	numberWaypoints(waypoints)
+	if options.Photo {
+		for i := range waypoints {
+			waypoints[i].Actions = []models.WaypointAction{{ActionType: models.ActionTakePhoto}}
+		}
+	}
+
+	speed := cruiseSpeed(options.Speed, options.MaxSpeed)
+	config := mappingConfig(waypoints, speed, options.MaxSpeed, options.AltitudeMode)
+	config.Targets = targets
+	element, err := waypointElement(config)
+	if err != nil {
+		return nil, err
+	}
+	return &PatternResult{
+		Element:   *element,
+		Waypoints: len(waypoints),
+		Speed:     speed,
+		Distance:  geometry.WaypointRoute(config).Distance,
+	}, nil
+}
+
+// validateOrbit checks an orbit's target and shape
+func validateOrbit(errs timeline.FieldErrors, o Orbit) {
+	targetErrs := errs.At("target")
+	targetErrs.Between("lat", o.Target.Lat, -90, 90)
+	targetErrs.Between("lng", o.Target.Lng, -180, 180)
+	if o.Radius <= 0 {
+		errs.Add("radius", "must be greater than 0")
+	}
+	errs.OneOf("direction", o.Direction, "CLOCKWISE", "COUNTER_CLOCKWISE")
+	validateInspection(errs, o.Inspection)
+}
+
+// validateLevels checks a range of levels
+func validateLevels(errs timeline.FieldErrors, minAltitude, maxAltitude, spacing float64) {
+	if maxAltitude < minAltitude {
+		errs.Add("maxAltitude", "must not be below minAltitude")
+	}
+	if spacing <= 0 && maxAltitude > minAltitude {
+		errs.Add("levelSpacing", "must be greater than 0")
+	}
+}
+
+// validateInspection checks the shared inspection options
+func validateInspection(errs timeline.FieldErrors, options Inspection) {
+	if options.Spacing < 0 {
+		errs.Add("spacing", "must not be negative")
+	}
+	if options.Speed < 0 {
+		errs.Add("speed", "must not be negative")
+	}
+	errs.OneOf("altitudeMode", options.AltitudeMode, models.AltitudeModes...)
+}
+
+// levels returns the altitudes from min to max, spacing apart, always
+// including both ends
+func levels(minAltitude, maxAltitude, spacing float64) []float64 {
+	if maxAltitude <= minAltitude {
+		return []float64{minAltitude}
+	}
+	count := int(math.Ceil((maxAltitude-minAltitude)/spacing - 1e-9))
+	altitudes := make([]float64, 0, count+1)
+	for i := 0; i <= count; i++ {
+		altitudes = append(altitudes, minAltitude+(maxAltitude-minAltitude)*float64(i)/float64(count))
+	}
+	return altitudes
+}
+
+// aimPitch returns the gimbal pitch looking at a point height meters below
+// and distance meters away, within the gimbal's range
+func aimPitch(height, distance float64) float64 {
+	pitch := -math.Atan2(height, distance) * 180 / math.Pi
+	return math.Max(-90, math.Min(30, pitch))
+}
+
+// cruiseSpeed returns the requested inspection speed capped by the
+// aircraft's top speed
+func cruiseSpeed(requested, maxSpeed float64) float64 {
+	speed := requested
+	if speed <= 0 {
+		speed = defaultInspectionSpeed
+	}
+	if maxSpeed > 0 {
+		speed = math.Min(speed, maxSpeed)
+	}
+	return speed
+}
+
+// pointSpacing returns the requested waypoint spacing or the default
+func pointSpacing(spacing float64) float64 {
+	if spacing <= 0 {
+		return defaultPointSpacing
+	}
+	return spacing
+}
+
+// turnMode returns the waypoint turn mode matching an orbit direction
+func turnMode(direction string) string {
+	if direction == "" {
+		return "CLOCKWISE"
+	}
+	return direction
+}
+
+// signedArea returns a ring's area, positive when it runs counter-clockwise
+func signedArea(ring [][2]float64) float64 {
+	area := 0.0
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		area += ring[j][0]*ring[i][1] - ring[i][0]*ring[j][1]
+	}
+	return area / 2
+}
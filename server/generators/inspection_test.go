@@ -0,0 +1,212 @@
+package generators
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"drone-planner/server/geometry"
+	"drone-planner/server/models"
+	"drone-planner/server/timeline"
+)
+
+// decodeWaypoints returns the waypoints of a generated pattern
+func decodeWaypoints(t *testing.T, result *PatternResult) []models.Waypoint {
+	t.Helper()
+	config, err := timeline.Decode(&result.Element)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waypoints := config.(*models.WaypointMissionConfig).Waypoints
+	if len(waypoints) != result.Waypoints {
+		t.Fatalf("result counts %d waypoints, the mission has %d", result.Waypoints, len(waypoints))
+	}
+	return waypoints
+}
+
+// headingDiff returns the smallest angle between two headings (degrees)
+func headingDiff(a, b float64) float64 {
+	d := math.Mod(math.Abs(a-b), 360)
+	return math.Min(d, 360-d)
+}
+
+// checkAim checks that a waypoint faces a point on the ground height meters
+// above takeoff with the camera tilted onto it
+func checkAim(t *testing.T, i int, wp models.Waypoint, lat, lng, height float64) {
+	t.Helper()
+	bearing := geometry.Bearing(wp.Coordinate.Latitude, wp.Coordinate.Longitude, lat, lng)
+	if headingDiff(wp.Heading, bearing) > 0.01 {
+		t.Errorf("waypoint %d heads %g°, the target is at %g°", i, wp.Heading, bearing)
+	}
+	distance := geometry.Distance(wp.Coordinate.Latitude, wp.Coordinate.Longitude, lat, lng)
+	pitch := -math.Atan2(wp.Altitude-height, distance) * 180 / math.Pi
+	pitch = math.Max(-90, math.Min(30, pitch))
+	if math.Abs(wp.GimbalPitch-pitch) > 0.1 {
+		t.Errorf("waypoint %d pitched %g°, the target is at %g°", i, wp.GimbalPitch, pitch)
+	}
+}
+
+func TestGenerateOrbit(t *testing.T) {
+	target := models.Target{ID: "mast", Lat: 47, Lng: 8}
+	tests := []struct {
+		name      string
+		direction string
+		// step is the change in bearing from the target between waypoints
+		step float64
+	}{
+		{"default", "", 360.0 / 19},
+		{"clockwise", "CLOCKWISE", 360.0 / 19},
+		{"counter-clockwise", "COUNTER_CLOCKWISE", -360.0 / 19},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := GenerateOrbit(Orbit{
+				Target: target, Radius: 30, Altitude: 50, TargetHeight: 20,
+				StartBearing: 90, Direction: tt.direction,
+				Inspection: Inspection{Photo: true},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// 188 m around at 10 m spacing, returning to the first waypoint
+			waypoints := decodeWaypoints(t, result)
+			if len(waypoints) != 20 {
+				t.Fatalf("got %d waypoints, want 20", len(waypoints))
+			}
+			for i, wp := range waypoints {
+				checkAim(t, i, wp, target.Lat, target.Lng, 20)
+				if math.Abs(wp.GimbalPitch+45) > 0.1 {
+					t.Errorf("waypoint %d pitched %g°, want -45", i, wp.GimbalPitch)
+				}
+				if d := geometry.Distance(target.Lat, target.Lng, wp.Coordinate.Latitude, wp.Coordinate.Longitude); math.Abs(d-30) > 0.2 {
+					t.Errorf("waypoint %d is %g m from the target, want 30", i, d)
+				}
+				bearing := geometry.Bearing(target.Lat, target.Lng, wp.Coordinate.Latitude, wp.Coordinate.Longitude)
+				if headingDiff(bearing, 90+float64(i)*tt.step) > 0.01 {
+					t.Errorf("waypoint %d is at %g° from the target, want %g", i, bearing, 90+float64(i)*tt.step)
+				}
+				if wp.TurnMode != turnMode(tt.direction) || len(wp.Actions) != 1 || wp.Actions[0].ActionType != models.ActionTakePhoto {
+					t.Errorf("waypoint %d turns %s with actions %+v", i, wp.TurnMode, wp.Actions)
+				}
+			}
+			if result.Speed != defaultInspectionSpeed {
+				t.Errorf("speed = %g, want %g", result.Speed, defaultInspectionSpeed)
+			}
+		})
+	}
+}
+
+func TestGenerateVerticalOrbit(t *testing.T) {
+	target := models.Target{Lat: 47, Lng: 8}
+	result, err := GenerateVerticalOrbit(VerticalOrbit{
+		Orbit:       Orbit{Target: target, Radius: 30, TargetHeight: 40, Inspection: Inspection{Spacing: 30}},
+		MinAltitude: 20, MaxAltitude: 60, LevelSpacing: 15,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Four evenly spaced levels of eight waypoints and the closing one
+	waypoints := decodeWaypoints(t, result)
+	if len(waypoints) != 4*9 {
+		t.Fatalf("got %d waypoints, want 36", len(waypoints))
+	}
+	for i, wp := range waypoints {
+		level := float64(i / 9)
+		if want := 20 + level*40/3; math.Abs(wp.Altitude-want) > 1e-9 {
+			t.Errorf("waypoint %d at %g m, want %g", i, wp.Altitude, want)
+		}
+		checkAim(t, i, wp, target.Lat, target.Lng, 40)
+	}
+	// The lowest level looks up at the gimbal's 30° limit
+	if waypoints[0].GimbalPitch != 30 || waypoints[35].GimbalPitch >= 0 {
+		t.Errorf("pitch %g° at the bottom and %g° at the top", waypoints[0].GimbalPitch, waypoints[35].GimbalPitch)
+	}
+}
+
+func TestGenerateFacade(t *testing.T) {
+	// A building about 20 m square
+	plane := geometry.NewPlane(geometry.Point{Latitude: 47, Longitude: 8})
+	corner := func(x, y float64) models.Coordinate {
+		lat, lng := plane.Unproject(x, y)
+		return models.Coordinate{Latitude: lat, Longitude: lng}
+	}
+	counterClockwise := []models.Coordinate{corner(-10, -10), corner(10, -10), corner(10, 10), corner(-10, 10)}
+	clockwise := []models.Coordinate{corner(-10, -10), corner(-10, 10), corner(10, 10), corner(10, -10), corner(-10, -10)}
+
+	for name, footprint := range map[string][]models.Coordinate{"counter-clockwise": counterClockwise, "clockwise and closed": clockwise} {
+		t.Run(name, func(t *testing.T) {
+			result, err := GenerateFacade(Facade{
+				Footprint: footprint, Distance: 8,
+				MinAltitude: 10, MaxAltitude: 30, LevelSpacing: 10,
+				Inspection: Inspection{Spacing: 8},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Three levels of four walls with four waypoints each
+			waypoints := decodeWaypoints(t, result)
+			if len(waypoints) != 3*4*4 {
+				t.Fatalf("got %d waypoints, want 48", len(waypoints))
+			}
+			for i, wp := range waypoints {
+				// The nearest point of the building is straight ahead, 8 m away
+				x, y := plane.Project(wp.Coordinate.Latitude, wp.Coordinate.Longitude)
+				nx, ny := math.Max(-10, math.Min(10, x)), math.Max(-10, math.Min(10, y))
+				if d := math.Hypot(x-nx, y-ny); math.Abs(d-8) > 0.01 {
+					t.Errorf("waypoint %d stands %g m off the wall, want 8", i, d)
+				}
+				facing := math.Atan2(nx-x, ny-y) * 180 / math.Pi
+				if headingDiff(wp.Heading, facing) > 0.1 {
+					t.Errorf("waypoint %d heads %g°, the wall is at %g°", i, wp.Heading, facing)
+				}
+				if wp.GimbalPitch != 0 {
+					t.Errorf("waypoint %d pitched %g°, want level", i, wp.GimbalPitch)
+				}
+				if want := 10 + float64(i/16)*10; wp.Altitude != want {
+					t.Errorf("waypoint %d at %g m, want %g", i, wp.Altitude, want)
+				}
+			}
+		})
+	}
+}
+
+func TestInspectionValidation(t *testing.T) {
+	target := models.Target{Lat: 47, Lng: 8}
+	square := []models.Coordinate{{Latitude: 47, Longitude: 8}, {Latitude: 47, Longitude: 8.001}, {Latitude: 47.001, Longitude: 8.001}}
+	tests := []struct {
+		name     string
+		generate func() (*PatternResult, error)
+		path     string
+	}{
+		{"orbit without a radius", func() (*PatternResult, error) {
+			return GenerateOrbit(Orbit{Target: target, Altitude: 50})
+		}, "radius"},
+		{"orbit direction", func() (*PatternResult, error) {
+			return GenerateOrbit(Orbit{Target: target, Radius: 30, Direction: "SIDEWAYS"})
+		}, "direction"},
+		{"orbit target", func() (*PatternResult, error) {
+			return GenerateOrbit(Orbit{Target: models.Target{Lat: 95}, Radius: 30})
+		}, "target.lat"},
+		{"inverted levels", func() (*PatternResult, error) {
+			return GenerateVerticalOrbit(VerticalOrbit{Orbit: Orbit{Target: target, Radius: 30}, MinAltitude: 60, MaxAltitude: 20, LevelSpacing: 10})
+		}, "maxAltitude"},
+		{"no level spacing", func() (*PatternResult, error) {
+			return GenerateVerticalOrbit(VerticalOrbit{Orbit: Orbit{Target: target, Radius: 30}, MinAltitude: 20, MaxAltitude: 60})
+		}, "levelSpacing"},
+		{"facade without a standoff", func() (*PatternResult, error) {
+			return GenerateFacade(Facade{Footprint: square, MinAltitude: 10, MaxAltitude: 10})
+		}, "distance"},
+		{"facade speed", func() (*PatternResult, error) {
+			return GenerateFacade(Facade{Footprint: square, Distance: 5, Inspection: Inspection{Speed: -1}})
+		}, "speed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.generate()
+			var validation *timeline.ValidationError
+			if !errors.As(err, &validation) || len(validation.Errors) != 1 || validation.Errors[0].Path != tt.path {
+				t.Fatalf("got error %v, want a validation error at %q", err, tt.path)
+			}
+		})
+	}
+}
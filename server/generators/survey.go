@@ -0,0 +1,356 @@
+// Package generators builds waypoint missions for mapping and inspection
+// patterns, so they don't have to be drawn by hand.
+package generators
+
+import (
+	"fmt"
+	"math"
+
+	"drone-planner/server/cameras"
+	"drone-planner/server/geometry"
+	"drone-planner/server/models"
+	"drone-planner/server/timeline"
+)
+
+const (
+	// defaultMaxSpeed caps generated speeds when no drone type is given (m/s)
+	defaultMaxSpeed = 15.0
+	// nadirPitch points the gimbal straight down
+	nadirPitch = -90.0
+)
+
+// Mapping holds the camera and overlap options shared by the mapping
+// patterns
+type Mapping struct {
+	Camera *cameras.Profile `json:"camera"`
+	// Altitude is the flight height above the ground (m); when 0 it is
+	// derived from GSD (cm/px)
+	Altitude float64 `json:"altitude"`
+	GSD      float64 `json:"gsd"`
+	// AltitudeMode is relative or agl; heights are taken as above the ground
+	// either way, so relative missions assume flat terrain
+	AltitudeMode string `json:"altitudeMode"`
+	// FrontOverlap and SideOverlap are percentages
+	FrontOverlap float64 `json:"frontOverlap"`
+	SideOverlap  float64 `json:"sideOverlap"`
+	// Speed is the requested ground speed (m/s); 0 flies as fast as the
+	// camera and MaxSpeed allow
+	Speed float64 `json:"speed"`
+	// MaxSpeed is the aircraft's top speed (m/s), 0 when unknown
+	MaxSpeed float64 `json:"-"`
+}
+
+// Survey describes a lawnmower survey over a polygon
+type Survey struct {
+	Polygon []models.Coordinate `json:"polygon"`
+	// Direction is the heading of the passes (degrees clockwise from north)
+	Direction float64 `json:"direction"`
+	// Margin extends the passes beyond the polygon on every side (m)
+	Margin float64 `json:"margin"`
+	// Crosshatch flies a second grid at right angles to the first
+	Crosshatch bool `json:"crosshatch"`
+	Mapping
+}
+
+// SurveyResult is a generated survey with the figures it was built from
+type SurveyResult struct {
+	Element models.TimelineElement `json:"element"`
+	// Altitude is the flight height (m) and GSD the resulting ground
+	// sampling distance (cm/px)
+	Altitude float64 `json:"altitude"`
+	GSD      float64 `json:"gsd"`
+	// LineSpacing is the distance between passes and TriggerDistance the
+	// distance between photos (m)
+	LineSpacing     float64 `json:"lineSpacing"`
+	TriggerDistance float64 `json:"triggerDistance"`
+	Speed           float64 `json:"speed"`
+	Lines           int     `json:"lines"`
+	Photos          int     `json:"photos"`
+	// Distance is the length of the route, including transits (m)
+	Distance float64 `json:"distance"`
+}
+
+// GenerateSurvey builds a waypoint mission flying serpentine passes over a
+// polygon, taking photos at the distance that gives the front overlap
+func GenerateSurvey(s Survey) (*SurveyResult, error) {
+	errs := timeline.NewFieldErrors("")
+	validatePolygon(errs, "polygon", s.Polygon)
+	s.Mapping.validate(errs)
+	if s.Margin < 0 {
+		errs.Add("margin", "must not be negative")
+	}
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+
+	height, lineSpacing, triggerDistance := s.Mapping.grid()
+	speed := photoSpeed(s.Camera, triggerDistance, s.Speed, s.MaxSpeed)
+
+	plane := geometry.NewPlane(centroid(s.Polygon))
+	ring := make([][2]float64, len(s.Polygon))
+	for i, c := range s.Polygon {
+		x, y := plane.Project(c.Latitude, c.Longitude)
+		ring[i] = [2]float64{x, y}
+	}
+
+	passes := lawnmower(ring, s.Direction, lineSpacing, s.Margin)
+	if len(passes) == 0 {
+		errs.Add("polygon", "polygon too narrow for line spacing of %.1f m", lineSpacing)
+		return nil, errs.Err()
+	}
+	lines := len(passes)
+	if s.Crosshatch {
+		cross := lawnmower(ring, s.Direction+90, lineSpacing, s.Margin)
+		passes = append(passes, continueFrom(passes[len(passes)-1][1], cross)...)
+	}
+
+	result := &SurveyResult{
+		Altitude:        height,
+		GSD:             s.Camera.GSD(height),
+		LineSpacing:     lineSpacing,
+		TriggerDistance: triggerDistance,
+		Speed:           speed,
+		Lines:           lines,
+	}
+	var waypoints []models.Waypoint
+	for _, pass := range passes {
+		heading := normalizeHeading(math.Atan2(pass[1][0]-pass[0][0], pass[1][1]-pass[0][1]) * 180 / math.Pi)
+		length := math.Hypot(pass[1][0]-pass[0][0], pass[1][1]-pass[0][1])
+		result.Photos += int(length/triggerDistance) + 1
+
+		start := newWaypoint(plane, pass[0], height, heading, nadirPitch)
+		start.Actions = []models.WaypointAction{{ActionType: models.ActionPhotoInterval, ActionParam: triggerDistance}}
+		waypoints = append(waypoints, start, newWaypoint(plane, pass[1], height, heading, nadirPitch))
+	}
+	numberWaypoints(waypoints)
+
+	config := mappingConfig(waypoints, speed, s.MaxSpeed, s.AltitudeMode)
+	result.Distance = geometry.WaypointRoute(config).Distance
+	element, err := waypointElement(config)
+	if err != nil {
+		return nil, err
+	}
+	result.Element = *element
+	return result, nil
+}
+
+// lawnmower returns the serpentine passes covering a polygon in plane
+// coordinates, flown along direction and spaced across it. Each pass spans
+// the polygon's full extent along its line, so concave areas are flown over
+// rather than split.
+func lawnmower(ring [][2]float64, direction, spacing, margin float64) [][2][2]float64 {
+	sin, cos := math.Sincos(direction * math.Pi / 180)
+	// u runs across the passes and v along them
+	toUV := func(p [2]float64) (float64, float64) {
+		return p[0]*cos - p[1]*sin, p[0]*sin + p[1]*cos
+	}
+	fromUV := func(u, v float64) [2]float64 {
+		return [2]float64{u*cos + v*sin, -u*sin + v*cos}
+	}
+
+	uv := make([][2]float64, len(ring))
+	minU, maxU := math.Inf(1), math.Inf(-1)
+	for i, p := range ring {
+		u, v := toUV(p)
+		uv[i] = [2]float64{u, v}
+		minU, maxU = math.Min(minU, u), math.Max(maxU, u)
+	}
+
+	width := maxU - minU + 2*margin
+	count := int(math.Ceil(width / spacing))
+	if count < 1 {
+		count = 1
+	}
+	first := (minU+maxU)/2 - float64(count-1)*spacing/2
+
+	passes := make([][2][2]float64, 0, count)
+	for k := 0; k < count; k++ {
+		u := first + float64(k)*spacing
+		// Lines in the margin take the extent of the nearest polygon edge
+		clamped := math.Max(minU+1e-6, math.Min(maxU-1e-6, u))
+		minV, maxV, ok := spanAt(uv, clamped)
+		if !ok {
+			continue
+		}
+		start, end := fromUV(u, minV-margin), fromUV(u, maxV+margin)
+		if k%2 == 1 {
+			start, end = end, start
+		}
+		passes = append(passes, [2][2]float64{start, end})
+	}
+	return passes
+}
+
+// spanAt returns the extent along v of a polygon's crossings with the line u
+func spanAt(uv [][2]float64, u float64) (float64, float64, bool) {
+	minV, maxV := math.Inf(1), math.Inf(-1)
+	for i, j := 0, len(uv)-1; i < len(uv); j, i = i, i+1 {
+		a, b := uv[j], uv[i]
+		if (a[0] > u) == (b[0] > u) {
+			continue
+		}
+		v := a[1] + (u-a[0])/(b[0]-a[0])*(b[1]-a[1])
+		minV, maxV = math.Min(minV, v), math.Max(maxV, v)
+	}
+	return minV, maxV, minV <= maxV
+}
+
+// continueFrom orders passes so the route starts at the end closest to from,
+// reversing their order and direction when that is shorter
+func continueFrom(from [2]float64, passes [][2][2]float64) [][2][2]float64 {
+	if len(passes) == 0 {
+		return passes
+	}
+	last := passes[len(passes)-1]
+	if math.Hypot(from[0]-passes[0][0][0], from[1]-passes[0][0][1]) <= math.Hypot(from[0]-last[1][0], from[1]-last[1][1]) {
+		return passes
+	}
+	reversed := make([][2][2]float64, len(passes))
+	for i, pass := range passes {
+		reversed[len(passes)-1-i] = [2][2]float64{pass[1], pass[0]}
+	}
+	return reversed
+}
+
+// validate checks the camera, height and overlap options
+func (m Mapping) validate(errs timeline.FieldErrors) {
+	validateCamera(errs, m.Camera)
+	if m.Altitude <= 0 && m.GSD <= 0 {
+		errs.Add("altitude", "altitude or gsd must be greater than 0")
+	}
+	errs.OneOf("altitudeMode", m.AltitudeMode, models.AltitudeRelative, models.AltitudeAGL)
+	errs.Between("frontOverlap", m.FrontOverlap, 0, 95)
+	errs.Between("sideOverlap", m.SideOverlap, 0, 95)
+	if m.Speed < 0 {
+		errs.Add("speed", "must not be negative")
+	}
+}
+
+// grid returns the flight height and the line and photo spacing giving the
+// requested overlaps (m)
+func (m Mapping) grid() (height, lineSpacing, triggerDistance float64) {
+	height = m.Altitude
+	if height <= 0 {
+		height = m.Camera.HeightForGSD(m.GSD)
+	}
+	lineSpacing, triggerDistance = m.Camera.Spacing(height, m.SideOverlap/100, m.FrontOverlap/100)
+	return height, lineSpacing, triggerDistance
+}
+
+// photoSpeed returns the ground speed for photos every triggerDistance
+// meters: the requested speed, capped by the camera's trigger rate and the
+// aircraft's top speed
+func photoSpeed(camera *cameras.Profile, triggerDistance, requested, maxSpeed float64) float64 {
+	if maxSpeed <= 0 {
+		maxSpeed = defaultMaxSpeed
+	}
+	speed := maxSpeed
+	if requested > 0 {
+		speed = math.Min(speed, requested)
+	}
+	if limit := camera.MaxSpeed(triggerDistance); limit > 0 {
+		speed = math.Min(speed, limit)
+	}
+	return speed
+}
+
+// validatePolygon checks an area's outline
+func validatePolygon(errs timeline.FieldErrors, path string, polygon []models.Coordinate) {
+	if len(polygon) < 3 {
+		errs.Add(path, "must have at least 3 points")
+	}
+	for i, c := range polygon {
+		pointErrs := errs.At(fmt.Sprintf("%s[%d]", path, i))
+		pointErrs.Between("latitude", c.Latitude, -90, 90)
+		pointErrs.Between("longitude", c.Longitude, -180, 180)
+	}
+}
+
+// validateCamera checks that a camera profile was given and is usable
+func validateCamera(errs timeline.FieldErrors, camera *cameras.Profile) {
+	if camera == nil {
+		errs.Add("camera", "a camera, cameraId or droneType with a camera is required")
+		return
+	}
+	if err := camera.Validate(); err != nil {
+		errs.Add("camera", "%v", err)
+	}
+}
+
+// centroid returns the mean of an outline's points
+func centroid(points []models.Coordinate) geometry.Point {
+	var lat, lng float64
+	for _, c := range points {
+		lat += c.Latitude
+		lng += c.Longitude
+	}
+	n := float64(len(points))
+	return geometry.Point{Latitude: lat / n, Longitude: lng / n}
+}
+
+// newWaypoint creates a waypoint at a plane position
+func newWaypoint(plane geometry.Plane, p [2]float64, altitude, heading, pitch float64) models.Waypoint {
+	lat, lng := plane.Unproject(p[0], p[1])
+	return models.Waypoint{
+		Coordinate:  models.Coordinate{Latitude: lat, Longitude: lng},
+		Altitude:    altitude,
+		Heading:     heading,
+		GimbalPitch: pitch,
+		TurnMode:    "CLOCKWISE",
+		Targets:     []models.Target{},
+		Actions:     []models.WaypointAction{},
+	}
+}
+
+// numberWaypoints gives waypoints sequential IDs from 1
+func numberWaypoints(waypoints []models.Waypoint) {
+	for i := range waypoints {
+		waypoints[i].ID = fmt.Sprint(i + 1)
+	}
+}
+
+// normalizeHeading wraps a heading into the -180..180 range waypoints use
+func normalizeHeading(heading float64) float64 {
+	heading = math.Mod(heading+180, 360)
+	if heading < 0 {
+		heading += 360
+	}
+	return heading - 180
+}
+
+// mappingConfig builds a waypoint mission flying waypoints at a constant
+// speed with the heading and gimbal pitch set on each waypoint
+func mappingConfig(waypoints []models.Waypoint, speed, maxSpeed float64, altitudeMode string) *models.WaypointMissionConfig {
+	if maxSpeed <= 0 {
+		maxSpeed = defaultMaxSpeed
+	}
+	return &models.WaypointMissionConfig{
+		AutoFlightSpeed:            speed,
+		MaxFlightSpeed:             math.Max(maxSpeed, speed),
+		FinishedAction:             "GO_HOME",
+		RepeatTimes:                1,
+		GlobalTurnMode:             "CLOCKWISE",
+		GimbalPitchRotationEnabled: true,
+		HeadingMode:                "USING_WAYPOINT_HEADING",
+		FlightPathMode:             "NORMAL",
+		AltitudeMode:               altitudeMode,
+		Targets:                    []models.Target{},
+		Waypoints:                  waypoints,
+	}
+}
+
+// waypointElement wraps a generated config in a validated waypoint-mission
+// timeline element
+func waypointElement(config *models.WaypointMissionConfig) (*models.TimelineElement, error) {
+	configMap, err := models.EncodeConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	mission := models.NewMission("", "")
+	mission.AddTimelineElement(models.ElementWaypointMission, configMap)
+	element := mission.TimelineElements[0]
+	if _, err := timeline.DecodeValid(&element); err != nil {
+		return nil, fmt.Errorf("generated mission is invalid: %v", err)
+	}
+	return &element, nil
+}
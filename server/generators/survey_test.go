@@ -0,0 +1,102 @@
+package generators
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"drone-planner/server/cameras"
+	"drone-planner/server/models"
+	"drone-planner/server/timeline"
+)
+
+// squareCamera covers a square as wide as its height above ground
+var squareCamera = &cameras.Profile{
+	ID: "TEST", SensorWidth: 10, SensorHeight: 10, FocalLength: 10,
+	ImageWidth: 1000, ImageHeight: 1000,
+}
+
+// rectangle is about 190 m east to west and 90 m north to south
+var rectangle = []models.Coordinate{
+	{Latitude: 46.9995953, Longitude: 7.9987473},
+	{Latitude: 46.9995953, Longitude: 8.0012527},
+	{Latitude: 47.0004047, Longitude: 8.0012527},
+	{Latitude: 47.0004047, Longitude: 7.9987473},
+}
+
+// line is a polygon with no width across north-south passes
+var line = []models.Coordinate{
+	{Latitude: 47, Longitude: 8}, {Latitude: 47.001, Longitude: 8}, {Latitude: 47.002, Longitude: 8},
+}
+
+func TestGenerateSurvey(t *testing.T) {
+	tests := []struct {
+		name       string
+		polygon    []models.Coordinate
+		direction  float64
+		crosshatch bool
+		// lines are the passes of the first grid, waypoints the whole route's
+		lines, waypoints int
+		// errPath is the field expected to fail, empty for a valid survey
+		errPath string
+	}{
+		{"north-south passes", rectangle, 0, false, 4, 8, ""},
+		{"east-west passes", rectangle, 90, false, 2, 4, ""},
+		{"crosshatch", rectangle, 0, true, 4, 12, ""},
+		{"two points", rectangle[:2], 0, false, 0, 0, "polygon"},
+		{"too narrow", line, 0, false, 0, 0, "polygon"},
+		{"too narrow crosshatch", line, 0, true, 0, 0, "polygon"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := GenerateSurvey(Survey{
+				Polygon:    tt.polygon,
+				Direction:  tt.direction,
+				Crosshatch: tt.crosshatch,
+				Mapping: Mapping{
+					Camera:       squareCamera,
+					Altitude:     100,
+					FrontOverlap: 50,
+					SideOverlap:  50,
+				},
+			})
+			if tt.errPath != "" {
+				var validation *timeline.ValidationError
+				if !errors.As(err, &validation) || validation.Errors[0].Path != tt.errPath {
+					t.Fatalf("got error %v, want a validation error at %q", err, tt.errPath)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if result.LineSpacing != 50 || result.TriggerDistance != 50 {
+				t.Errorf("spacing %g m and trigger distance %g m, want 50 m", result.LineSpacing, result.TriggerDistance)
+			}
+			if math.Abs(result.GSD-10) > 1e-9 {
+				t.Errorf("GSD = %g cm/px, want 10", result.GSD)
+			}
+			if result.Lines != tt.lines {
+				t.Errorf("got %d lines, want %d", result.Lines, tt.lines)
+			}
+			config, err := timeline.Decode(&result.Element)
+			if err != nil {
+				t.Fatal(err)
+			}
+			waypoints := config.(*models.WaypointMissionConfig).Waypoints
+			if len(waypoints) != tt.waypoints {
+				t.Fatalf("got %d waypoints, want %d", len(waypoints), tt.waypoints)
+			}
+			for i, wp := range waypoints {
+				if wp.Altitude != 100 || wp.GimbalPitch != nadirPitch {
+					t.Errorf("waypoint %d at %g m pitched %g°, want 100 m and nadir", i, wp.Altitude, wp.GimbalPitch)
+				}
+				// Passes start with distance-triggered photos
+				if i%2 == 0 && (len(wp.Actions) != 1 || wp.Actions[0].ActionType != models.ActionPhotoInterval || wp.Actions[0].ActionParam != 50) {
+					t.Errorf("pass start %d has actions %+v, want a photo every 50 m", i, wp.Actions)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+// Package geofence validates organization geofences and checks routes
+// against them.
+package geofence
+
+import (
+	"fmt"
+	"math"
+
+	"drone-planner/server/geometry"
+	"drone-planner/server/models"
+	"drone-planner/server/timeline"
+)
+
+// DefaultSpacing is the distance between the points checked along a segment (m)
+const DefaultSpacing = 10.0
+
+// Validate checks a geofence's fields, returning a *timeline.ValidationError
+// that lists each invalid field
+func Validate(fence *models.Geofence) error {
+	errs := timeline.NewFieldErrors("")
+	if fence.Name == "" {
+		errs.Add("name", "is required")
+	}
+	errs.OneOf("altitudeMode", fence.AltitudeMode, models.AltitudeModes...)
+	errs.OneOf("enforcement", fence.Enforcement, models.GeofenceReject, models.GeofenceWarn)
+	if len(fence.Zones) == 0 {
+		errs.Add("zones", "must contain at least one zone")
+	}
+	for i, zone := range fence.Zones {
+		zoneErrs := errs.At(fmt.Sprintf("zones[%d]", i))
+		if zone.Type == "" {
+			zoneErrs.Add("type", "is required")
+		}
+		zoneErrs.OneOf("type", zone.Type, models.GeofenceInclusion, models.GeofenceExclusion)
+		if len(zone.Polygon) < 3 {
+			zoneErrs.Add("polygon", "must have at least 3 points")
+		}
+		for j, point := range zone.Polygon {
+			pointErrs := zoneErrs.At(fmt.Sprintf("polygon[%d]", j))
+			pointErrs.Between("latitude", point.Latitude, -90, 90)
+			pointErrs.Between("longitude", point.Longitude, -180, 180)
+		}
+		if zone.MinAltitude != nil && zone.MaxAltitude != nil && *zone.MinAltitude > *zone.MaxAltitude {
+			zoneErrs.Add("minAltitude", "must not exceed maxAltitude")
+		}
+	}
+	return errs.Err()
+}
+
+// HasAltitudeLimits reports whether any zone of a fence limits altitudes
+func HasAltitudeLimits(fence *models.Geofence) bool {
+	for _, zone := range fence.Zones {
+		if zone.MinAltitude != nil || zone.MaxAltitude != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Violation is a waypoint, or a point sampled along the segment leaving it,
+// that breaks a fence
+type Violation struct {
+	Waypoint int `json:"waypoint"`
+	// Segment is set when the point lies between Waypoint and the next one
+	Segment   bool    `json:"segment"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Altitude  float64 `json:"altitude"`
+	Reason    string  `json:"reason"`
+}
+
+// Check tests a route against a fence. Point altitudes must be in the
+// fence's altitude mode; without checkAltitude the altitude limits are
+// ignored and exclusion zones forbid every altitude. Segments are sampled
+// every spacing meters with linearly interpolated altitudes, and each
+// waypoint and segment is reported at most once.
+func Check(fence *models.Geofence, points []geometry.Point, checkAltitude bool, spacing float64) []Violation {
+	if spacing <= 0 {
+		spacing = DefaultSpacing
+	}
+	polygons := make([]geometry.Polygon, len(fence.Zones))
+	for i, zone := range fence.Zones {
+		ring := make([]geometry.Point, len(zone.Polygon))
+		for j, c := range zone.Polygon {
+			ring[j] = geometry.Point{Latitude: c.Latitude, Longitude: c.Longitude}
+		}
+		polygons[i] = geometry.Polygon{Outer: ring}
+	}
+	z := zones{fence: fence, polygons: polygons, checkAltitude: checkAltitude}
+
+	var violations []Violation
+	for i, point := range points {
+		if reason := z.violation(point); reason != "" {
+			violations = append(violations, newViolation(i, false, point, reason))
+		}
+		if i == len(points)-1 {
+			continue
+		}
+
+		next := points[i+1]
+		distance := geometry.Distance(point.Latitude, point.Longitude, next.Latitude, next.Longitude)
+		samples := int(math.Ceil(distance / spacing))
+		for k := 1; k < samples; k++ {
+			t := float64(k) / float64(samples)
+			sample := geometry.Point{
+				Latitude:  point.Latitude + (next.Latitude-point.Latitude)*t,
+				Longitude: point.Longitude + (next.Longitude-point.Longitude)*t,
+				Altitude:  point.Altitude + (next.Altitude-point.Altitude)*t,
+			}
+			if reason := z.violation(sample); reason != "" {
+				violations = append(violations, newViolation(i, true, sample, reason))
+				break
+			}
+		}
+	}
+	return violations
+}
+
+func newViolation(waypoint int, segment bool, point geometry.Point, reason string) Violation {
+	return Violation{
+		Waypoint:  waypoint,
+		Segment:   segment,
+		Latitude:  point.Latitude,
+		Longitude: point.Longitude,
+		Altitude:  point.Altitude,
+		Reason:    reason,
+	}
+}
+
+// zones holds a fence's zones with their polygons
+type zones struct {
+	fence         *models.Geofence
+	polygons      []geometry.Polygon
+	checkAltitude bool
+}
+
+// violation returns why a point breaks the fence, or "" when it doesn't. A
+// point must lie inside one of the inclusion zones, within its altitude
+// limits, and outside the altitude band of every exclusion zone.
+func (z zones) violation(point geometry.Point) string {
+	hasInclusion, allowed := false, false
+	reason := ""
+	for i, zone := range z.fence.Zones {
+		if zone.Type != models.GeofenceInclusion {
+			continue
+		}
+		hasInclusion = true
+		if !z.polygons[i].Contains(point.Latitude, point.Longitude) {
+			continue
+		}
+		altitudeReason := z.altitudeViolation(zone, point.Altitude)
+		if altitudeReason == "" {
+			allowed = true
+			break
+		}
+		if reason == "" {
+			reason = altitudeReason
+		}
+	}
+	if hasInclusion && !allowed {
+		if reason == "" {
+			reason = "outside the inclusion zones"
+		}
+		return reason
+	}
+
+	for i, zone := range z.fence.Zones {
+		if zone.Type != models.GeofenceExclusion || !z.polygons[i].Contains(point.Latitude, point.Longitude) {
+			continue
+		}
+		// Exclusion limits bound the forbidden band rather than the allowed one
+		if !z.checkAltitude || z.altitudeViolation(zone, point.Altitude) == "" {
+			return fmt.Sprintf("inside exclusion zone %d", i+1)
+		}
+	}
+	return ""
+}
+
+// altitudeViolation returns why an altitude is outside a zone's limits
+func (z zones) altitudeViolation(zone models.GeofenceZone, altitude float64) string {
+	if !z.checkAltitude {
+		return ""
+	}
+	if zone.MaxAltitude != nil && altitude > *zone.MaxAltitude {
+		return fmt.Sprintf("above the %g m maximum altitude", *zone.MaxAltitude)
+	}
+	if zone.MinAltitude != nil && altitude < *zone.MinAltitude {
+		return fmt.Sprintf("below the %g m minimum altitude", *zone.MinAltitude)
+	}
+	return ""
+}
@@ -0,0 +1,128 @@
+package geofence
+
+import (
+	"errors"
+	"testing"
+
+	"drone-planner/server/geometry"
+	"drone-planner/server/models"
+	"drone-planner/server/timeline"
+)
+
+func float(value float64) *float64 {
+	return &value
+}
+
+// square returns a square zone between two corners
+func square(zoneType string, lat1, lng1, lat2, lng2 float64) models.GeofenceZone {
+	return models.GeofenceZone{Type: zoneType, Polygon: []models.Coordinate{
+		{Latitude: lat1, Longitude: lng1},
+		{Latitude: lat1, Longitude: lng2},
+		{Latitude: lat2, Longitude: lng2},
+		{Latitude: lat2, Longitude: lng1},
+	}}
+}
+
+// testFence allows flying up to 120 m over a square about 1.1 km across,
+// except above 50 m over a square of about 220 m in its middle
+func testFence() *models.Geofence {
+	inclusion := square(models.GeofenceInclusion, 47, 8, 47.01, 8.01)
+	inclusion.MaxAltitude = float(120)
+	exclusion := square(models.GeofenceExclusion, 47.004, 8.004, 47.006, 8.006)
+	exclusion.MinAltitude = float(50)
+	return &models.Geofence{Name: "site", Zones: []models.GeofenceZone{inclusion, exclusion}}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name          string
+		points        []geometry.Point
+		checkAltitude bool
+		want          []Violation
+	}{
+		{"inside", []geometry.Point{{Latitude: 47.001, Longitude: 8.001, Altitude: 100}, {Latitude: 47.002, Longitude: 8.009, Altitude: 100}}, true, nil},
+		{"waypoint outside", []geometry.Point{{Latitude: 47.001, Longitude: 8.001, Altitude: 30}, {Latitude: 47.02, Longitude: 8.001, Altitude: 30}}, true, []Violation{
+			{Waypoint: 0, Segment: true, Reason: "outside the inclusion zones"},
+			{Waypoint: 1, Reason: "outside the inclusion zones"},
+		}},
+		{"too high", []geometry.Point{{Latitude: 47.001, Longitude: 8.001, Altitude: 130}, {Latitude: 47.001, Longitude: 8.002, Altitude: 100}}, true, []Violation{
+			{Waypoint: 0, Reason: "above the 120 m maximum altitude"},
+			{Waypoint: 0, Segment: true, Reason: "above the 120 m maximum altitude"},
+		}},
+		{"too high without altitude checks", []geometry.Point{{Latitude: 47.001, Longitude: 8.001, Altitude: 130}, {Latitude: 47.001, Longitude: 8.002, Altitude: 100}}, false, nil},
+		{"crossing the exclusion band", []geometry.Point{{Latitude: 47.005, Longitude: 8.002, Altitude: 60}, {Latitude: 47.005, Longitude: 8.008, Altitude: 60}}, true, []Violation{
+			{Waypoint: 0, Segment: true, Reason: "inside exclusion zone 2"},
+		}},
+		{"under the exclusion band", []geometry.Point{{Latitude: 47.005, Longitude: 8.002, Altitude: 40}, {Latitude: 47.005, Longitude: 8.008, Altitude: 40}}, true, nil},
+		{"exclusion at any altitude without altitude checks", []geometry.Point{{Latitude: 47.005, Longitude: 8.002, Altitude: 40}, {Latitude: 47.005, Longitude: 8.008, Altitude: 40}}, false, []Violation{
+			{Waypoint: 0, Segment: true, Reason: "inside exclusion zone 2"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Check(testFence(), tt.points, tt.checkAltitude, DefaultSpacing)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got violations %+v, want %+v", got, tt.want)
+			}
+			for i, want := range tt.want {
+				if got[i].Waypoint != want.Waypoint || got[i].Segment != want.Segment || got[i].Reason != want.Reason {
+					t.Errorf("violation %d = %+v, want %+v", i, got[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckExclusionOnly(t *testing.T) {
+	fence := &models.Geofence{Name: "airport", Zones: []models.GeofenceZone{square(models.GeofenceExclusion, 47, 8, 47.01, 8.01)}}
+	outside := []geometry.Point{{Latitude: 46.99, Longitude: 7.99}, {Latitude: 46.99, Longitude: 8.02}}
+	if violations := Check(fence, outside, true, DefaultSpacing); len(violations) != 0 {
+		t.Errorf("route outside the exclusion zone got %+v", violations)
+	}
+	inside := []geometry.Point{{Latitude: 47.005, Longitude: 8.005}, {Latitude: 46.99, Longitude: 8.005}}
+	if violations := Check(fence, inside, true, DefaultSpacing); len(violations) != 2 || violations[0].Waypoint != 0 || violations[0].Segment {
+		t.Errorf("route starting in the exclusion zone got %+v", violations)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		edit  func(fence *models.Geofence)
+		paths []string
+	}{
+		{"valid", func(fence *models.Geofence) {}, nil},
+		{"no name", func(fence *models.Geofence) { fence.Name = "" }, []string{"name"}},
+		{"no zones", func(fence *models.Geofence) { fence.Zones = nil }, []string{"zones"}},
+		{"unknown enforcement", func(fence *models.Geofence) { fence.Enforcement = "ignore" }, []string{"enforcement"}},
+		{"unknown zone type", func(fence *models.Geofence) { fence.Zones[0].Type = "maybe" }, []string{"zones[0].type"}},
+		{"two point polygon", func(fence *models.Geofence) { fence.Zones[1].Polygon = fence.Zones[1].Polygon[:2] }, []string{"zones[1].polygon"}},
+		{"latitude out of range", func(fence *models.Geofence) { fence.Zones[0].Polygon[2].Latitude = 95 }, []string{"zones[0].polygon[2].latitude"}},
+		{"inverted altitude band", func(fence *models.Geofence) { fence.Zones[1].MaxAltitude = float(40) }, []string{"zones[1].minAltitude"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fence := testFence()
+			tt.edit(fence)
+			err := Validate(fence)
+			if tt.paths == nil {
+				if err != nil {
+					t.Fatalf("unexpected error %v", err)
+				}
+				return
+			}
+			var validation *timeline.ValidationError
+			if !errors.As(err, &validation) {
+				t.Fatalf("got error %v, want a validation error", err)
+			}
+			if len(validation.Errors) != len(tt.paths) {
+				t.Fatalf("got errors %+v, want %v", validation.Errors, tt.paths)
+			}
+			for i, path := range tt.paths {
+				if validation.Errors[i].Path != path {
+					t.Errorf("error %d at %q, want %q", i, validation.Errors[i].Path, path)
+				}
+			}
+		})
+	}
+}
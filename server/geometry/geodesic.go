@@ -0,0 +1,90 @@
+// Package geometry measures routes flown between waypoints: geodesic
+// distances on the WGS84 ellipsoid, climbs and descents, curved segments and
+// flight times.
+package geometry
+
+import "math"
+
+// WGS84 ellipsoid parameters
+const (
+	semiMajorAxis = 6378137.0
+	flattening    = 1 / 298.257223563
+	semiMinorAxis = semiMajorAxis * (1 - flattening)
+
+	// EarthRadius is the mean Earth radius (m) used for spherical approximations
+	EarthRadius = 6371008.8
+)
+
+const (
+	vincentyTolerance  = 1e-12
+	vincentyIterations = 200
+)
+
+// Distance returns the geodesic distance (m) between two points on the WGS84
+// ellipsoid using Vincenty's inverse formula. Nearly antipodal points, where
+// the iteration does not converge, fall back to the great-circle distance.
+func Distance(lat1, lng1, lat2, lng2 float64) float64 {
+	if lat1 == lat2 && lng1 == lng2 {
+		return 0
+	}
+
+	L := toRadians(lng2 - lng1)
+	U1 := math.Atan((1 - flattening) * math.Tan(toRadians(lat1)))
+	U2 := math.Atan((1 - flattening) * math.Tan(toRadians(lat2)))
+	sinU1, cosU1 := math.Sincos(U1)
+	sinU2, cosU2 := math.Sincos(U2)
+
+	lambda := L
+	var sinSigma, cosSigma, sigma, cos2Alpha, cos2SigmaM float64
+	converged := false
+	for i := 0; i < vincentyIterations; i++ {
+		sinLambda, cosLambda := math.Sincos(lambda)
+		sinSigma = math.Hypot(cosU2*sinLambda, cosU1*sinU2-sinU1*cosU2*cosLambda)
+		if sinSigma == 0 {
+			return 0
+		}
+		cosSigma = sinU1*sinU2 + cosU1*cosU2*cosLambda
+		sigma = math.Atan2(sinSigma, cosSigma)
+		sinAlpha := cosU1 * cosU2 * sinLambda / sinSigma
+		cos2Alpha = 1 - sinAlpha*sinAlpha
+		cos2SigmaM = 0
+		if cos2Alpha != 0 {
+			// Points on the equator have cos2Alpha == 0
+			cos2SigmaM = cosSigma - 2*sinU1*sinU2/cos2Alpha
+		}
+		C := flattening / 16 * cos2Alpha * (4 + flattening*(4-3*cos2Alpha))
+		previous := lambda
+		lambda = L + (1-C)*flattening*sinAlpha*
+			(sigma+C*sinSigma*(cos2SigmaM+C*cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)))
+		if math.Abs(lambda-previous) < vincentyTolerance {
+			converged = true
+			break
+		}
+	}
+	if !converged {
+		return Haversine(lat1, lng1, lat2, lng2)
+	}
+
+	uSquared := cos2Alpha * (semiMajorAxis*semiMajorAxis - semiMinorAxis*semiMinorAxis) / (semiMinorAxis * semiMinorAxis)
+	A := 1 + uSquared/16384*(4096+uSquared*(-768+uSquared*(320-175*uSquared)))
+	B := uSquared / 1024 * (256 + uSquared*(-128+uSquared*(74-47*uSquared)))
+	deltaSigma := B * sinSigma * (cos2SigmaM + B/4*(cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)-
+		B/6*cos2SigmaM*(-3+4*sinSigma*sinSigma)*(-3+4*cos2SigmaM*cos2SigmaM)))
+	return semiMinorAxis * A * (sigma - deltaSigma)
+}
+
+// Haversine returns the great-circle distance (m) between two points on a
+// sphere with the mean Earth radius
+func Haversine(lat1, lng1, lat2, lng2 float64) float64 {
+	phi1 := toRadians(lat1)
+	phi2 := toRadians(lat2)
+	dPhi := phi2 - phi1
+	dLambda := toRadians(lng2 - lng1)
+
+	h := math.Sin(dPhi/2)*math.Sin(dPhi/2) + math.Cos(phi1)*math.Cos(phi2)*math.Sin(dLambda/2)*math.Sin(dLambda/2)
+	return 2 * EarthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+func toRadians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
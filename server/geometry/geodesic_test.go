@@ -0,0 +1,80 @@
+package geometry
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		name                   string
+		lat1, lng1, lat2, lng2 float64
+		want                   float64
+	}{
+		{"same point", 47.3769, 8.5417, 47.3769, 8.5417, 0},
+		{"degree of the equator", 0, 0, 0, 1, 111319.491},
+		{"degree of the meridian at the equator", 0, 0, 1, 0, 110574.389},
+		{"quarter meridian", 0, 0, 90, 0, 10001965.729},
+		// Vincenty's 1975 example, Flinders Peak to Buninyong
+		{"Flinders Peak to Buninyong", -(37 + 57/60.0 + 3.72030/3600), 144 + 25/60.0 + 29.52440/3600,
+			-(37 + 39/60.0 + 10.15610/3600), 143 + 55/60.0 + 35.38390/3600, 54972.271},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Distance(tt.lat1, tt.lng1, tt.lat2, tt.lng2); math.Abs(got-tt.want) > 1e-3 {
+				t.Errorf("Distance = %.4f m, want %.3f", got, tt.want)
+			}
+			if got := Distance(tt.lat2, tt.lng2, tt.lat1, tt.lng1); math.Abs(got-tt.want) > 1e-3 {
+				t.Errorf("reverse Distance = %.4f m, want %.3f", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDistanceAntipodal(t *testing.T) {
+	// Vincenty's iteration does not converge here; the great-circle distance
+	// stands in for the 19936.3 km geodesic
+	got := Distance(0, 0, 0.5, 179.7)
+	if math.Abs(got-19936288.579)/19936288.579 > 0.005 {
+		t.Errorf("Distance = %.0f m, want about 19936289", got)
+	}
+}
+
+func TestCurveLength(t *testing.T) {
+	tests := []struct {
+		name          string
+		chord, offset float64
+	}{
+		{"straight", 100, 0},
+		{"gentle", 100, 5},
+		{"planner default", 80, defaultCurveTightness},
+		{"deep", 100, 60},
+		{"offset side", 100, -25},
+		{"no chord", 0, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := bezierLength(tt.chord, tt.offset)
+			if got := CurveLength(tt.chord, tt.offset); math.Abs(got-want) > 1e-6 {
+				t.Errorf("CurveLength(%g, %g) = %.6f, want %.6f", tt.chord, tt.offset, got, want)
+			}
+		})
+	}
+}
+
+// bezierLength sums the chords of a finely divided quadratic Bezier from
+// (0, 0) to (chord, 0) with its control point offset from the midpoint
+func bezierLength(chord, offset float64) float64 {
+	const steps = 100000
+	length := 0.0
+	x0, y0 := 0.0, 0.0
+	for i := 1; i <= steps; i++ {
+		t := float64(i) / steps
+		// With the control point at (chord/2, offset) the curve peaks at offset/2
+		x := chord * t
+		y := 2 * offset * t * (1 - t)
+		length += math.Hypot(x-x0, y-y0)
+		x0, y0 = x, y
+	}
+	return length
+}
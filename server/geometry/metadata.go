@@ -0,0 +1,106 @@
+package geometry
+
+import (
+	"math"
+	"strconv"
+
+	"drone-planner/server/models"
+)
+
+const (
+	// minCurveOffset is the corner radius up to which the planner draws straight segments (m)
+	minCurveOffset = 0.2
+	// defaultCurveTightness is the planner's curve offset for curved flight segments without a tightness (m)
+	defaultCurveTightness = 15
+)
+
+// WaypointRoute measures a waypoint mission, including repeats
+func WaypointRoute(config *models.WaypointMissionConfig) Route {
+	points, legs := WaypointLegs(config)
+	route := Measure(points, legs, config.AutoFlightSpeed)
+	return Repeat(route, points, config.AutoFlightSpeed, config.RepeatTimes)
+}
+
+// WaypointLegs converts a waypoint mission into points and legs. In CURVED
+// missions a waypoint's corner radius bows the segment leaving it.
+func WaypointLegs(config *models.WaypointMissionConfig) ([]Point, []Leg) {
+	points := make([]Point, 0, len(config.Waypoints))
+	legs := make([]Leg, 0, len(config.Waypoints))
+	for _, wp := range config.Waypoints {
+		points = append(points, Point{
+			Latitude:  wp.Coordinate.Latitude,
+			Longitude: wp.Coordinate.Longitude,
+			Altitude:  wp.Altitude,
+		})
+
+		leg := Leg{Speed: wp.Speed}
+		if config.FlightPathMode == "CURVED" && math.Abs(wp.CornerRadius) > minCurveOffset {
+			leg.CurveOffset = wp.CornerRadius
+		}
+		legs = append(legs, leg)
+	}
+	return points, legs
+}
+
+// HomePoint returns the mission's home on the ground, falling back to the
+// first waypoint
+func HomePoint(settings models.GlobalMissionSettings, config *models.WaypointMissionConfig) Point {
+	if settings.HomeLat != nil && settings.HomeLng != nil {
+		return Point{Latitude: *settings.HomeLat, Longitude: *settings.HomeLng}
+	}
+	first := config.Waypoints[0].Coordinate
+	return Point{Latitude: first.Latitude, Longitude: first.Longitude}
+}
+
+// FlightRoute measures a flight, including repeats
+func FlightRoute(flight *models.Flight) Route {
+	points, legs := FlightLegs(flight)
+	route := Measure(points, legs, flight.AutoFlightSpeed)
+	return Repeat(route, points, flight.AutoFlightSpeed, flight.RepeatTimes)
+}
+
+// FlightLegs converts a flight into points and legs. Segment speeds override
+// waypoint speeds and mark curved segments.
+func FlightLegs(flight *models.Flight) ([]Point, []Leg) {
+	segments := make(map[string]models.SegmentSpeed, len(flight.SegmentSpeeds))
+	for _, segment := range flight.SegmentSpeeds {
+		segments[strconv.FormatInt(segment.FromID, 10)] = segment
+	}
+
+	points := make([]Point, 0, len(flight.Waypoints))
+	legs := make([]Leg, 0, len(flight.Waypoints))
+	for _, wp := range flight.Waypoints {
+		points = append(points, Point{
+			Latitude:  wp.Coordinate.Latitude,
+			Longitude: wp.Coordinate.Longitude,
+			Altitude:  wp.Altitude,
+		})
+
+		leg := Leg{Speed: wp.Speed}
+		if segment, ok := segments[wp.ID]; ok {
+			if segment.Speed > 0 {
+				leg.Speed = segment.Speed
+			}
+			if segment.IsCurved {
+				leg.CurveOffset = float64(segment.CurveTightness)
+				if leg.CurveOffset == 0 {
+					leg.CurveOffset = defaultCurveTightness
+				}
+			}
+		}
+		legs = append(legs, leg)
+	}
+	return points, legs
+}
+
+// FlightMetadata computes a flight's metadata from its waypoints
+func FlightMetadata(flight *models.Flight) models.FlightMetadata {
+	route := FlightRoute(flight)
+	return models.FlightMetadata{
+		TotalWaypoints:    len(flight.Waypoints),
+		TotalDistance:     route.Distance / 1000,
+		TotalClimb:        route.Climb,
+		TotalDescent:      route.Descent,
+		EstimatedDuration: route.Duration,
+	}
+}
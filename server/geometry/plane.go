@@ -0,0 +1,55 @@
+package geometry
+
+import "math"
+
+// Plane projects positions onto a flat east/north plane (m) around an origin.
+// The equirectangular approximation stays within a meter over the few
+// kilometers a mission covers.
+type Plane struct {
+	origin       Point
+	metersPerLat float64
+	metersPerLng float64
+}
+
+// NewPlane returns the plane tangent at origin
+func NewPlane(origin Point) Plane {
+	metersPerDegree := EarthRadius * math.Pi / 180
+	return Plane{
+		origin:       origin,
+		metersPerLat: metersPerDegree,
+		metersPerLng: metersPerDegree * math.Cos(toRadians(origin.Latitude)),
+	}
+}
+
+// Project returns a position's offset east (x) and north (y) of the origin
+func (p Plane) Project(lat, lng float64) (x, y float64) {
+	return (lng - p.origin.Longitude) * p.metersPerLng, (lat - p.origin.Latitude) * p.metersPerLat
+}
+
+// Unproject returns the position at an offset east (x) and north (y) of the origin
+func (p Plane) Unproject(x, y float64) (lat, lng float64) {
+	return p.origin.Latitude + y/p.metersPerLat, p.origin.Longitude + x/p.metersPerLng
+}
+
+// Bearing returns the initial great-circle bearing from one position to
+// another (degrees clockwise from north, 0-360)
+func Bearing(lat1, lng1, lat2, lng2 float64) float64 {
+	phi1, phi2 := toRadians(lat1), toRadians(lat2)
+	dLambda := toRadians(lng2 - lng1)
+	y := math.Sin(dLambda) * math.Cos(phi2)
+	x := math.Cos(phi1)*math.Sin(phi2) - math.Sin(phi1)*math.Cos(phi2)*math.Cos(dLambda)
+	return math.Mod(math.Atan2(y, x)*180/math.Pi+360, 360)
+}
+
+// Destination returns the position a distance (m) away from another along a
+// great-circle bearing (degrees clockwise from north)
+func Destination(lat, lng, bearing, distance float64) (float64, float64) {
+	delta := distance / EarthRadius
+	theta := toRadians(bearing)
+	phi1, lambda1 := toRadians(lat), toRadians(lng)
+
+	phi2 := math.Asin(math.Sin(phi1)*math.Cos(delta) + math.Cos(phi1)*math.Sin(delta)*math.Cos(theta))
+	lambda2 := lambda1 + math.Atan2(math.Sin(theta)*math.Sin(delta)*math.Cos(phi1), math.Cos(delta)-math.Sin(phi1)*math.Sin(phi2))
+	lng2 := math.Mod(lambda2*180/math.Pi+540, 360) - 180
+	return phi2 * 180 / math.Pi, lng2
+}
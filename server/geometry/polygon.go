@@ -0,0 +1,130 @@
+package geometry
+
+import "math"
+
+// Bounds is a latitude/longitude bounding box (degrees)
+type Bounds struct {
+	MinLatitude, MinLongitude float64
+	MaxLatitude, MaxLongitude float64
+}
+
+// Intersects reports whether two bounding boxes overlap
+func (b Bounds) Intersects(other Bounds) bool {
+	return b.MinLatitude <= other.MaxLatitude && other.MinLatitude <= b.MaxLatitude &&
+		b.MinLongitude <= other.MaxLongitude && other.MinLongitude <= b.MaxLongitude
+}
+
+// SegmentBounds returns the bounding box of a straight segment
+func SegmentBounds(from, to Point) Bounds {
+	return Bounds{
+		MinLatitude:  math.Min(from.Latitude, to.Latitude),
+		MinLongitude: math.Min(from.Longitude, to.Longitude),
+		MaxLatitude:  math.Max(from.Latitude, to.Latitude),
+		MaxLongitude: math.Max(from.Longitude, to.Longitude),
+	}
+}
+
+// Polygon is an area bounded by an outer ring, minus its holes. Rings are
+// closed implicitly; the first point is not repeated at the end. Containment
+// treats latitude and longitude as plane coordinates, which is accurate for
+// the small areas drones fly in away from the poles and the antimeridian.
+type Polygon struct {
+	Outer []Point
+	Holes [][]Point
+}
+
+// Bounds returns the bounding box of the outer ring
+func (p Polygon) Bounds() Bounds {
+	b := Bounds{
+		MinLatitude: math.Inf(1), MinLongitude: math.Inf(1),
+		MaxLatitude: math.Inf(-1), MaxLongitude: math.Inf(-1),
+	}
+	for _, point := range p.Outer {
+		b.MinLatitude = math.Min(b.MinLatitude, point.Latitude)
+		b.MinLongitude = math.Min(b.MinLongitude, point.Longitude)
+		b.MaxLatitude = math.Max(b.MaxLatitude, point.Latitude)
+		b.MaxLongitude = math.Max(b.MaxLongitude, point.Longitude)
+	}
+	return b
+}
+
+// Contains reports whether a position lies inside the polygon
+func (p Polygon) Contains(lat, lng float64) bool {
+	if !ringContains(p.Outer, lat, lng) {
+		return false
+	}
+	for _, hole := range p.Holes {
+		if ringContains(hole, lat, lng) {
+			return false
+		}
+	}
+	return true
+}
+
+// IntersectsSegment reports whether a straight segment enters the polygon
+func (p Polygon) IntersectsSegment(from, to Point) bool {
+	if p.Contains(from.Latitude, from.Longitude) || p.Contains(to.Latitude, to.Longitude) {
+		return true
+	}
+	// With both ends outside, the segment enters the polygon only by crossing
+	// its boundary, which includes the edges of its holes
+	if ringCrosses(p.Outer, from, to) {
+		return true
+	}
+	for _, hole := range p.Holes {
+		if ringCrosses(hole, from, to) {
+			return true
+		}
+	}
+	return false
+}
+
+// ringContains tests a position against a ring with the even-odd rule
+func ringContains(ring []Point, lat, lng float64) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a.Latitude > lat) != (b.Latitude > lat) {
+			crossing := a.Longitude + (lat-a.Latitude)/(b.Latitude-a.Latitude)*(b.Longitude-a.Longitude)
+			if lng < crossing {
+				inside = !inside
+			}
+		}
+	}
+	return inside
+}
+
+// ringCrosses reports whether a segment crosses any edge of a ring
+func ringCrosses(ring []Point, from, to Point) bool {
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		if segmentsCross(from, to, ring[j], ring[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// segmentsCross reports whether segments ab and cd intersect, including
+// touching and collinear overlap
+func segmentsCross(a, b, c, d Point) bool {
+	d1 := orientation(c, d, a)
+	d2 := orientation(c, d, b)
+	d3 := orientation(a, b, c)
+	d4 := orientation(a, b, d)
+	if ((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) && ((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0)) {
+		return true
+	}
+	return (d1 == 0 && onSegment(c, d, a)) || (d2 == 0 && onSegment(c, d, b)) ||
+		(d3 == 0 && onSegment(a, b, c)) || (d4 == 0 && onSegment(a, b, d))
+}
+
+// orientation is the cross product of (b-a) and (c-a) in longitude/latitude
+func orientation(a, b, c Point) float64 {
+	return (b.Longitude-a.Longitude)*(c.Latitude-a.Latitude) - (b.Latitude-a.Latitude)*(c.Longitude-a.Longitude)
+}
+
+// onSegment reports whether p, collinear with ab, lies within its extent
+func onSegment(a, b, p Point) bool {
+	return math.Min(a.Longitude, b.Longitude) <= p.Longitude && p.Longitude <= math.Max(a.Longitude, b.Longitude) &&
+		math.Min(a.Latitude, b.Latitude) <= p.Latitude && p.Latitude <= math.Max(a.Latitude, b.Latitude)
+}
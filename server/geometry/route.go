@@ -0,0 +1,144 @@
+package geometry
+
+import "math"
+
+// DefaultSpeed is the flight speed used when neither a leg nor the route sets one (m/s)
+const DefaultSpeed = 10.0
+
+// Point is a waypoint position with an altitude (m)
+type Point struct {
+	Latitude  float64
+	Longitude float64
+	Altitude  float64
+}
+
+// Leg describes how the segment leaving a point is flown
+type Leg struct {
+	// Speed is the ground speed (m/s); zero uses the route speed
+	Speed float64
+	// CurveOffset bows the segment into a quadratic curve whose control point
+	// sits this far (m) to the side of the chord midpoint. Zero flies straight;
+	// the sign only selects the side.
+	CurveOffset float64
+}
+
+// Segment is a measured leg between two points
+type Segment struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+	// Horizontal is the length of the ground track (m)
+	Horizontal float64 `json:"horizontal"`
+	// Climb is the altitude change (m), negative when descending
+	Climb float64 `json:"climb"`
+	// Length is the 3D length of the flight path (m)
+	Length   float64 `json:"length"`
+	Speed    float64 `json:"speed"`
+	Duration float64 `json:"duration"`
+	Curved   bool    `json:"curved"`
+}
+
+// Route is a measured sequence of segments
+type Route struct {
+	Segments []Segment `json:"segments"`
+	Distance float64   `json:"distance"`
+	Climb    float64   `json:"climb"`
+	Descent  float64   `json:"descent"`
+	Duration float64   `json:"duration"`
+}
+
+// add appends a segment and updates the totals
+func (r *Route) add(segment Segment) {
+	r.Segments = append(r.Segments, segment)
+	r.Distance += segment.Length
+	r.Duration += segment.Duration
+	if segment.Climb > 0 {
+		r.Climb += segment.Climb
+	} else {
+		r.Descent -= segment.Climb
+	}
+}
+
+// Measure measures the route through points. legs[i] describes the segment
+// from points[i] to points[i+1]; points without a leg are flown straight at
+// the route speed.
+func Measure(points []Point, legs []Leg, speed float64) Route {
+	route := Route{Segments: []Segment{}}
+	for i := 1; i < len(points); i++ {
+		var leg Leg
+		if i-1 < len(legs) {
+			leg = legs[i-1]
+		}
+		segment := measureSegment(points[i-1], points[i], leg, speed)
+		segment.From, segment.To = i-1, i
+		route.add(segment)
+	}
+	return route
+}
+
+// Repeat returns a route flown the given number of times, returning straight
+// from the last point to the first between repetitions
+func Repeat(route Route, points []Point, speed float64, times int) Route {
+	if times <= 1 || len(points) < 2 {
+		return route
+	}
+
+	last := len(points) - 1
+	closing := measureSegment(points[last], points[0], Leg{}, speed)
+	closing.From, closing.To = last, 0
+
+	repeated := Route{Segments: []Segment{}}
+	for i := 0; i < times; i++ {
+		if i > 0 {
+			repeated.add(closing)
+		}
+		for _, segment := range route.Segments {
+			repeated.add(segment)
+		}
+	}
+	return repeated
+}
+
+// measureSegment measures a single leg. Altitude is assumed to change
+// linearly along the ground track.
+func measureSegment(from, to Point, leg Leg, speed float64) Segment {
+	horizontal := Distance(from.Latitude, from.Longitude, to.Latitude, to.Longitude)
+	curved := leg.CurveOffset != 0
+	if curved {
+		horizontal = CurveLength(horizontal, leg.CurveOffset)
+	}
+
+	climb := to.Altitude - from.Altitude
+	length := math.Hypot(horizontal, climb)
+
+	segmentSpeed := leg.Speed
+	if segmentSpeed <= 0 {
+		segmentSpeed = speed
+	}
+	if segmentSpeed <= 0 {
+		segmentSpeed = DefaultSpeed
+	}
+
+	return Segment{
+		Horizontal: horizontal,
+		Climb:      climb,
+		Length:     length,
+		Speed:      segmentSpeed,
+		Duration:   length / segmentSpeed,
+		Curved:     curved,
+	}
+}
+
+// CurveLength returns the length (m) of the quadratic Bezier curve drawn by
+// the planner between two points a chord apart, with its control point offset
+// sideways from the chord midpoint
+func CurveLength(chord, offset float64) float64 {
+	// B'(t) = (chord, 2*offset*(1-2t)); integrating |B'| gives a closed form
+	k := 2 * math.Abs(offset)
+	if k == 0 {
+		return chord
+	}
+	if chord == 0 {
+		return k / 2
+	}
+	return (math.Hypot(chord, k) + chord*chord/k*math.Asinh(k/chord)) / 2
+}
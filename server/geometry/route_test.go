@@ -0,0 +1,158 @@
+package geometry
+
+import (
+	"math"
+	"testing"
+
+	"drone-planner/server/models"
+)
+
+func TestMeasure(t *testing.T) {
+	// Three points 0.001° of latitude apart climbing 30 m and descending 10 m
+	points := []Point{
+		{Latitude: 47, Longitude: 8, Altitude: 20},
+		{Latitude: 47.001, Longitude: 8, Altitude: 50},
+		{Latitude: 47.002, Longitude: 8, Altitude: 40},
+	}
+	step := Distance(47, 8, 47.001, 8)
+	step2 := Distance(47.001, 8, 47.002, 8)
+	tests := []struct {
+		name  string
+		legs  []Leg
+		speed float64
+		// horizontal and speeds are per segment
+		horizontal []float64
+		speeds     []float64
+	}{
+		{"route speed", nil, 5, []float64{step, step2}, []float64{5, 5}},
+		{"default speed", nil, 0, []float64{step, step2}, []float64{DefaultSpeed, DefaultSpeed}},
+		{"leg speed", []Leg{{Speed: 2}, {}}, 5, []float64{step, step2}, []float64{2, 5}},
+		{"curved leg", []Leg{{CurveOffset: 20}}, 5, []float64{CurveLength(step, 20), step2}, []float64{5, 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route := Measure(points, tt.legs, tt.speed)
+			if len(route.Segments) != 2 {
+				t.Fatalf("got %d segments, want 2", len(route.Segments))
+			}
+			distance, duration := 0.0, 0.0
+			for i, segment := range route.Segments {
+				length := math.Hypot(tt.horizontal[i], points[i+1].Altitude-points[i].Altitude)
+				if segment.From != i || segment.To != i+1 {
+					t.Errorf("segment %d runs %d-%d", i, segment.From, segment.To)
+				}
+				if math.Abs(segment.Horizontal-tt.horizontal[i]) > 1e-9 || math.Abs(segment.Length-length) > 1e-9 {
+					t.Errorf("segment %d = %g m along the ground, %g m flown; want %g and %g", i, segment.Horizontal, segment.Length, tt.horizontal[i], length)
+				}
+				if segment.Speed != tt.speeds[i] || math.Abs(segment.Duration-length/tt.speeds[i]) > 1e-9 {
+					t.Errorf("segment %d flown at %g m/s for %g s, want %g m/s", i, segment.Speed, segment.Duration, tt.speeds[i])
+				}
+				if segment.Curved != (i < len(tt.legs) && tt.legs[i].CurveOffset != 0) {
+					t.Errorf("segment %d curved = %v", i, segment.Curved)
+				}
+				distance += length
+				duration += length / tt.speeds[i]
+			}
+			if route.Climb != 30 || route.Descent != 10 {
+				t.Errorf("climb %g m and descent %g m, want 30 and 10", route.Climb, route.Descent)
+			}
+			if math.Abs(route.Distance-distance) > 1e-9 || math.Abs(route.Duration-duration) > 1e-9 {
+				t.Errorf("route %g m in %g s, want %g m in %g s", route.Distance, route.Duration, distance, duration)
+			}
+		})
+	}
+}
+
+func TestRepeat(t *testing.T) {
+	points := []Point{{Latitude: 47, Longitude: 8}, {Latitude: 47.001, Longitude: 8}, {Latitude: 47.001, Longitude: 8.001, Altitude: 10}}
+	route := Measure(points, []Leg{{CurveOffset: 10}}, 5)
+	closing := Distance(47.001, 8.001, 47, 8)
+
+	for _, times := range []int{0, 1, 3} {
+		repeated := Repeat(route, points, 5, times)
+		n := max(times, 1)
+		if len(repeated.Segments) != 2*n+n-1 {
+			t.Errorf("%d times: got %d segments", times, len(repeated.Segments))
+			continue
+		}
+		want := float64(n)*route.Distance + float64(n-1)*math.Hypot(closing, 10)
+		if math.Abs(repeated.Distance-want) > 1e-9 {
+			t.Errorf("%d times: distance = %g m, want %g", times, repeated.Distance, want)
+		}
+		if repeated.Climb != float64(n)*10 || repeated.Descent != float64(n-1)*10 {
+			t.Errorf("%d times: climb %g m and descent %g m", times, repeated.Climb, repeated.Descent)
+		}
+		if times > 1 {
+			// The closing leg returns straight to the first point
+			if segment := repeated.Segments[2]; segment.From != 2 || segment.To != 0 || segment.Curved {
+				t.Errorf("closing segment = %+v", segment)
+			}
+		}
+	}
+}
+
+func TestWaypointLegs(t *testing.T) {
+	config := &models.WaypointMissionConfig{
+		Waypoints: []models.Waypoint{
+			{Coordinate: models.Coordinate{Latitude: 47, Longitude: 8}, Altitude: 30, Speed: 4, CornerRadius: 12},
+			{Coordinate: models.Coordinate{Latitude: 47.001, Longitude: 8}, Altitude: 40, CornerRadius: 0.1},
+			{Coordinate: models.Coordinate{Latitude: 47.002, Longitude: 8}, Altitude: 50},
+		},
+	}
+	tests := []struct {
+		mode    string
+		offsets []float64
+	}{
+		{"NORMAL", []float64{0, 0, 0}},
+		// Radii up to 0.2 m are drawn straight
+		{"CURVED", []float64{12, 0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			config.FlightPathMode = tt.mode
+			points, legs := WaypointLegs(config)
+			if len(points) != 3 || len(legs) != 3 {
+				t.Fatalf("got %d points and %d legs", len(points), len(legs))
+			}
+			for i, wp := range config.Waypoints {
+				if points[i] != (Point{Latitude: wp.Coordinate.Latitude, Longitude: wp.Coordinate.Longitude, Altitude: wp.Altitude}) {
+					t.Errorf("point %d = %+v", i, points[i])
+				}
+				if legs[i] != (Leg{Speed: wp.Speed, CurveOffset: tt.offsets[i]}) {
+					t.Errorf("leg %d = %+v, want speed %g and offset %g", i, legs[i], wp.Speed, tt.offsets[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFlightLegs(t *testing.T) {
+	flight := &models.Flight{
+		Waypoints: []models.Waypoint{
+			{ID: "1", Coordinate: models.Coordinate{Latitude: 47, Longitude: 8}, Speed: 4},
+			{ID: "2", Coordinate: models.Coordinate{Latitude: 47.001, Longitude: 8}, Speed: 6},
+			{ID: "3", Coordinate: models.Coordinate{Latitude: 47.002, Longitude: 8}},
+			{ID: "4", Coordinate: models.Coordinate{Latitude: 47.003, Longitude: 8}},
+		},
+		SegmentSpeeds: []models.SegmentSpeed{
+			{FromID: 1, ToID: 2, Speed: 8},
+			{FromID: 2, ToID: 3, IsCurved: true},
+			{FromID: 3, ToID: 4, Speed: 3, IsCurved: true, CurveTightness: 25},
+		},
+	}
+	want := []Leg{
+		{Speed: 8},
+		{Speed: 6, CurveOffset: defaultCurveTightness},
+		{Speed: 3, CurveOffset: 25},
+		{},
+	}
+	_, legs := FlightLegs(flight)
+	if len(legs) != len(want) {
+		t.Fatalf("got %d legs, want %d", len(legs), len(want))
+	}
+	for i := range want {
+		if legs[i] != want[i] {
+			t.Errorf("leg %d = %+v, want %+v", i, legs[i], want[i])
+		}
+	}
+}
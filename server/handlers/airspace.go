@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+
+	"drone-planner/server/airspace"
+	"drone-planner/server/altitude"
+	"drone-planner/server/models"
+)
+
+// AirspaceHandler checks missions against the airspace and no-fly zones in
+// AIRSPACE_DIR
+type AirspaceHandler struct {
+	missions  *MissionHandler
+	zones     *airspace.Index
+	altitudes *altitude.Converter
+}
+
+// NewAirspaceHandler creates an airspace handler, loading the zones once.
+// Without AIRSPACE_DIR the airspace endpoints respond with 503.
+func NewAirspaceHandler(missions *MissionHandler) *AirspaceHandler {
+	h := &AirspaceHandler{missions: missions, altitudes: sharedAltitudes()}
+	if dir := os.Getenv("AIRSPACE_DIR"); dir != "" {
+		zones, err := airspace.LoadDirectory(dir)
+		if err != nil {
+			log.Printf("Airspace data unavailable: %v", err)
+		} else {
+			log.Printf("Loaded %d airspace zones", zones.Len())
+			h.zones = zones
+		}
+	}
+	return h
+}
+
+// CheckMissionAirspace reports every waypoint and segment of a mission's
+// waypoint mission that enters an airspace zone, with the zone's floor,
+// ceiling and class. Heights that can't be converted to the reference of a
+// zone's limits are reported as unknown.
+func (h *AirspaceHandler) CheckMissionAirspace(w http.ResponseWriter, r *http.Request) {
+	if h.zones == nil {
+		http.Error(w, "Airspace data is not configured", http.StatusServiceUnavailable)
+		return
+	}
+	mission, ok := h.missions.findUserMission(w, r)
+	if !ok {
+		return
+	}
+	config, err := mission.DecodeWaypointMission()
+	if err != nil {
+		http.Error(w, "Mission has no usable waypoint mission: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	positions := make([]airspace.Position, len(config.Waypoints))
+	for i, wp := range config.Waypoints {
+		positions[i] = airspace.Position{Latitude: wp.Coordinate.Latitude, Longitude: wp.Coordinate.Longitude}
+	}
+	if msl, err := h.altitudes.Mission(mission.GlobalSettings, config, models.AltitudeMSL); err == nil {
+		for i, wp := range msl.Waypoints {
+			alt := wp.Altitude
+			positions[i].MSL = &alt
+		}
+	}
+	if agl, err := h.altitudes.Mission(mission.GlobalSettings, config, models.AltitudeAGL); err == nil {
+		for i, wp := range agl.Waypoints {
+			alt := wp.Altitude
+			positions[i].AGL = &alt
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.zones.Check(positions))
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"log"
+	"os"
+	"sync"
+
+	"drone-planner/server/altitude"
+	"drone-planner/server/terrain"
+)
+
+var (
+	altitudesOnce sync.Once
+	altitudes     *altitude.Converter
+)
+
+// sharedAltitudes returns the altitude converter used by exports and terrain
+// profiles, built on first use from the DEM tiles in TERRAIN_DIR and the
+// embedded EGM96 grid, or the one in GEOID_FILE. Conversions that need
+// missing data fail.
+func sharedAltitudes() *altitude.Converter {
+	altitudesOnce.Do(func() {
+		altitudes = &altitude.Converter{}
+		if dir := os.Getenv("TERRAIN_DIR"); dir != "" {
+			provider, err := terrain.NewDirectoryProvider(dir)
+			if err != nil {
+				log.Printf("Terrain data unavailable: %v", err)
+			} else {
+				altitudes.Terrain = provider
+			}
+		}
+		geoid, err := altitude.DefaultGeoid()
+		if path := os.Getenv("GEOID_FILE"); path != "" {
+			geoid, err = altitude.LoadGeoid(path)
+		}
+		if err != nil {
+			log.Printf("Geoid model unavailable: %v", err)
+		} else {
+			altitudes.Geoid = geoid
+		}
+	})
+	return altitudes
+}
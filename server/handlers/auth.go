@@ -193,6 +193,13 @@ func AuthMiddleware(next http.Handler) http.Handler {
 
 		// Add the user ID to the request context
 		ctx := context.WithValue(r.Context(), "userID", userID)
+
+		// Team membership is set by the backend in the user's app metadata
+		if appMetadata, ok := claims["app_metadata"].(map[string]interface{}); ok {
+			if teamID, ok := appMetadata["team_id"].(string); ok && teamID != "" {
+				ctx = context.WithValue(ctx, "teamID", teamID)
+			}
+		}
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"drone-planner/server/cameras"
+	"drone-planner/server/drones"
+	"drone-planner/server/timeline"
+)
+
+// CameraHandler serves the camera catalog and photogrammetry calculations
+type CameraHandler struct{}
+
+// NewCameraHandler creates a new camera handler
+func NewCameraHandler() *CameraHandler {
+	return &CameraHandler{}
+}
+
+// GetCameras lists every camera profile
+func (h *CameraHandler) GetCameras(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(cameras.All()); err != nil {
+		log.Printf("Error encoding cameras: %v", err)
+	}
+}
+
+// GetCamera returns a single camera profile
+func (h *CameraHandler) GetCamera(w http.ResponseWriter, r *http.Request) {
+	profile, ok := cameras.Lookup(mux.Vars(r)["id"])
+	if !ok {
+		http.Error(w, "Camera not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profile)
+}
+
+// Calculate converts between altitude, GSD, footprint, overlap, trigger
+// distance and speed for a camera, warning when the camera cannot keep up
+// with the planned speed
+func (h *CameraHandler) Calculate(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		cameras.CalcInput
+		droneRequest
+		cameraRequest
+		Camera *cameras.Profile `json:"camera"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	errs := timeline.NewFieldErrors("")
+	camera := resolveCamera(errs, req.Camera, req.CameraID, req.DroneType)
+	req.MaxSpeed = req.maxSpeed(errs)
+	if camera == nil {
+		errs.Add("camera", "a camera, cameraId or droneType with a camera is required")
+	}
+	if err := errs.Err(); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	calc, err := cameras.Calculate(camera, req.CalcInput)
+	if err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(calc); err != nil {
+		log.Printf("Error encoding photogrammetry calculation: %v", err)
+	}
+}
+
+// resolveCamera returns the inline camera profile, else the catalog camera
+// with cameraID, else the default camera of the drone type. Unknown IDs are
+// recorded as field errors; nil means no camera was named.
+func resolveCamera(errs timeline.FieldErrors, inline *cameras.Profile, cameraID, droneType string) *cameras.Profile {
+	if inline != nil {
+		return inline
+	}
+	if cameraID != "" {
+		profile, ok := cameras.Lookup(cameraID)
+		if !ok {
+			errs.Add("cameraId", "unknown camera %q", cameraID)
+		}
+		return profile
+	}
+	if drone, ok := drones.Lookup(droneType); ok && len(drone.Cameras) > 0 {
+		profile, _ := cameras.Lookup(drone.Cameras[0])
+		return profile
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"drone-planner/server/altitude"
+	"drone-planner/server/cameras"
+	"drone-planner/server/coverage"
+	"drone-planner/server/geometry"
+	"drone-planner/server/models"
+	"drone-planner/server/timeline"
+)
+
+// CoverageHandler analyzes the ground covered by a mission's photos
+type CoverageHandler struct {
+	missions  *MissionHandler
+	altitudes *altitude.Converter
+}
+
+// NewCoverageHandler creates a new coverage handler
+func NewCoverageHandler(missions *MissionHandler) *CoverageHandler {
+	return &CoverageHandler{missions: missions, altitudes: sharedAltitudes()}
+}
+
+// coverageReport is a mission's photo footprints and their coverage
+type coverageReport struct {
+	Camera     cameras.Profile             `json:"camera"`
+	Photos     int                         `json:"photos"`
+	Footprints *coverage.FeatureCollection `json:"footprints"`
+	Raster     *coverage.Raster            `json:"raster"`
+	Coverage   *coverage.Coverage          `json:"coverage,omitempty"`
+	Warnings   []string                    `json:"warnings"`
+}
+
+// GetMissionCoverage computes the ground footprint of every photo a mission
+// takes and, given an area of interest, the share of it covered by at least
+// minOverlap photos
+func (h *CoverageHandler) GetMissionCoverage(w http.ResponseWriter, r *http.Request) {
+	mission, ok := h.missions.findUserMission(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		cameraRequest
+		Camera     *cameras.Profile    `json:"camera"`
+		AOI        []models.Coordinate `json:"aoi"`
+		MinOverlap int                 `json:"minOverlap"`
+		CellSize   float64             `json:"cellSize"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	errs := timeline.NewFieldErrors("")
+	camera := resolveCamera(errs, req.Camera, req.CameraID, mission.GlobalSettings.DroneType)
+	if camera == nil {
+		errs.Add("camera", "a camera or cameraId is required when the mission's drone has no camera")
+	} else if err := camera.Validate(); err != nil {
+		errs.Add("camera", "%v", err)
+	}
+	if len(req.AOI) > 0 && len(req.AOI) < 3 {
+		errs.Add("aoi", "must have at least 3 points")
+	}
+	if req.MinOverlap < 0 {
+		errs.Add("minOverlap", "must not be negative")
+	}
+	if req.CellSize < 0 {
+		errs.Add("cellSize", "must not be negative")
+	}
+	if err := errs.Err(); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+	if req.MinOverlap == 0 {
+		req.MinOverlap = 1
+	}
+
+	// Footprints need heights above ground; without terrain the ground is
+	// taken to be level with home
+	report := coverageReport{Camera: *camera, Warnings: []string{}}
+	levelGround := false
+	heights := func(config *models.WaypointMissionConfig) (*models.WaypointMissionConfig, error) {
+		converted, err := h.altitudes.Mission(mission.GlobalSettings, config, models.AltitudeAGL)
+		if errors.Is(err, altitude.ErrNoTerrain) {
+			levelGround = true
+			return h.altitudes.Mission(mission.GlobalSettings, config, models.AltitudeRelative)
+		}
+		return converted, err
+	}
+	photos, err := coverage.MissionPhotos(mission, heights)
+	if err != nil {
+		http.Error(w, "Failed to list photos: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	if levelGround {
+		report.Warnings = append(report.Warnings, "terrain data is not configured; heights assume level ground at home")
+	}
+
+	footprints, collection := coverage.Footprints(photos, camera)
+	if skipped := len(photos) - len(footprints); skipped > 0 {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("%d photos cover no ground", skipped))
+	}
+	aoi := make([]geometry.Point, len(req.AOI))
+	for i, c := range req.AOI {
+		aoi[i] = geometry.Point{Latitude: c.Latitude, Longitude: c.Longitude}
+	}
+	report.Photos = len(photos)
+	report.Footprints = collection
+	report.Raster, report.Coverage = coverage.Analyze(footprints, aoi, req.CellSize, req.MinOverlap)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		log.Printf("Error encoding coverage: %v", err)
+	}
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"drone-planner/server/drones"
+)
+
+// DroneHandler serves the drone profile catalog
+type DroneHandler struct{}
+
+// NewDroneHandler creates a new drone handler
+func NewDroneHandler() *DroneHandler {
+	return &DroneHandler{}
+}
+
+// GetDrones lists every drone profile
+func (h *DroneHandler) GetDrones(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(drones.All()); err != nil {
+		log.Printf("Error encoding drones: %v", err)
+	}
+}
+
+// GetDrone returns a single drone profile
+func (h *DroneHandler) GetDrone(w http.ResponseWriter, r *http.Request) {
+	profile, ok := drones.Lookup(mux.Vars(r)["id"])
+	if !ok {
+		http.Error(w, "Drone not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profile)
+}
@@ -16,15 +16,18 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 
+	"drone-planner/server/geometry"
 	"drone-planner/server/models"
+	"drone-planner/server/timeline"
 )
 
 type FlightHandler struct {
 	collection *mongo.Collection
+	geofences  *GeofenceHandler
 }
 
-func NewFlightHandler(collection *mongo.Collection) *FlightHandler {
-	return &FlightHandler{collection: collection}
+func NewFlightHandler(collection *mongo.Collection, geofences *GeofenceHandler) *FlightHandler {
+	return &FlightHandler{collection: collection, geofences: geofences}
 }
 
 // CreateFlight handles the creation of a new flight plan
@@ -82,6 +85,11 @@ func (h *FlightHandler) CreateFlight(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	speedWarnings, warnings, ok := h.checkFlight(w, r, &flight)
+	if !ok {
+		return
+	}
+
 	// Set user ID and timestamps
 	flight.UserID = userID
 	now := time.Now()
@@ -107,7 +115,7 @@ func (h *FlightHandler) CreateFlight(w http.ResponseWriter, r *http.Request) {
 	// Return the created flight
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	if err := json.NewEncoder(w).Encode(flight.ToJSON()); err != nil {
+	if err := json.NewEncoder(w).Encode(withSpeedWarnings(withGeofenceWarnings(flight.ToJSON(), warnings), speedWarnings)); err != nil {
 		log.Printf("Error encoding response: %v", err)
 		http.Error(w, "Error encoding response", http.StatusInternalServerError)
 		return
@@ -257,20 +265,30 @@ func (h *FlightHandler) UpdateFlight(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	speedWarnings, warnings, ok := h.checkFlight(w, r, &flight)
+	if !ok {
+		return
+	}
+
 	filter := bson.M{
-		"_id":    id,
-		"userId": userID,
+		"_id":     id,
+		"user_id": userID,
 	}
 
 	update := bson.M{
 		"$set": bson.M{
-			"name":          flight.Name,
-			"waypoints":     flight.Waypoints,
-			"segmentSpeeds": flight.SegmentSpeeds,
-			"metadata":      flight.Metadata,
-			"updatedAt":     time.Now(),
+			"name":           flight.Name,
+			"waypoints":      flight.Waypoints,
+			"segment_speeds": flight.SegmentSpeeds,
+			"altitude_mode":  flight.AltitudeMode,
+			"metadata":       flight.Metadata,
+			"geofence_ids":   flight.GeofenceIDs,
+			"updated_at":     time.Now(),
 		},
 	}
+	if flight.DroneType != "" {
+		update["$set"].(bson.M)["drone_type"] = flight.DroneType
+	}
 
 	result, err := h.collection.UpdateOne(context.Background(), filter, update)
 	if err != nil {
@@ -291,7 +309,7 @@ func (h *FlightHandler) UpdateFlight(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(flight.ToJSON())
+	json.NewEncoder(w).Encode(withSpeedWarnings(withGeofenceWarnings(flight.ToJSON(), warnings), speedWarnings))
 }
 
 // DeleteFlight deletes a flight plan
@@ -338,3 +356,67 @@ func (h *FlightHandler) DeleteFlight(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Successfully deleted flight with ID: %s", flightID.Hex())
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// findUserFlight loads the flight identified by the URL's {id} for the
+// authenticated user, writing an error response and returning false on failure
+func (h *FlightHandler) findUserFlight(w http.ResponseWriter, r *http.Request) (*models.Flight, bool) {
+	return h.loadUserFlight(w, r, mux.Vars(r)["id"])
+}
+
+// loadUserFlight loads a flight by ID for the authenticated user, writing an
+// error response and returning false on failure
+func (h *FlightHandler) loadUserFlight(w http.ResponseWriter, r *http.Request, id string) (*models.Flight, bool) {
+	// Get user ID from context
+	userID, ok := r.Context().Value("userID").(string)
+	if !ok || userID == "" {
+		log.Printf("Auth error: userID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	flightID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		log.Printf("Invalid flight ID format: %v", err)
+		http.Error(w, "Invalid flight ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	var flight models.Flight
+	err = h.collection.FindOne(context.Background(), bson.M{
+		"_id":     flightID,
+		"user_id": userID,
+	}).Decode(&flight)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			http.Error(w, "Flight not found", http.StatusNotFound)
+			return nil, false
+		}
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Error retrieving flight", http.StatusInternalServerError)
+		return nil, false
+	}
+	return &flight, true
+}
+
+// checkFlight prepares a flight for saving: it lowers default speeds the
+// aircraft cannot fly when the client asks to, rejects values it cannot fly,
+// checks the route against the geofences that apply to it and computes the
+// metadata from the waypoints rather than trusting the client. It writes the
+// error response and returns false when the flight can't be saved.
+func (h *FlightHandler) checkFlight(w http.ResponseWriter, r *http.Request, flight *models.Flight) (speedWarnings, geofenceWarnings []timeline.FieldError, ok bool) {
+	if clampRequested(r) {
+		speedWarnings = timeline.ClampFlightSpeeds(flight)
+	}
+	if err := timeline.ValidateFlight(flight); err != nil {
+		writeValidationError(w, err)
+		return nil, nil, false
+	}
+
+	geofenceWarnings, ok = h.geofences.enforce(w, r, "geofenceIds", flight.GeofenceIDs, h.geofences.flightGeofenceRoutes(flight))
+	if !ok {
+		return nil, nil, false
+	}
+
+	flight.Metadata = geometry.FlightMetadata(flight)
+	return speedWarnings, geofenceWarnings, true
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"drone-planner/server/litchi"
+)
+
+// ExportFlightLitchi exports a flight as a Litchi CSV
+func (h *FlightHandler) ExportFlightLitchi(w http.ResponseWriter, r *http.Request) {
+	flight, ok := h.findUserFlight(w, r)
+	if !ok {
+		return
+	}
+	log.Printf("Exporting flight %s as Litchi CSV", flight.ID.Hex())
+
+	data, skipped, err := litchi.ExportFlight(flight, sharedAltitudes())
+	if err != nil {
+		log.Printf("Error exporting Litchi CSV: %v", err)
+		http.Error(w, "Failed to export flight: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	for _, note := range skipped {
+		log.Printf("Litchi export skipped %s", note)
+	}
+
+	writeAttachment(w, "text/csv", exportFilename(flight.Name, ".csv"), data)
+}
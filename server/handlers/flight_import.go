@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"drone-planner/server/litchi"
+)
+
+// ImportFlightLitchi creates a new flight from an uploaded Litchi CSV
+func (h *FlightHandler) ImportFlightLitchi(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, ok := r.Context().Value("userID").(string)
+	if !ok || userID == "" {
+		log.Printf("Auth error: userID not found in context")
+		http.Error(w, "Unauthorized: No user ID found in context", http.StatusUnauthorized)
+		return
+	}
+	log.Printf("Processing ImportFlightLitchi request for user: %s", userID)
+
+	data, name, ok := readUpload(w, r)
+	if !ok {
+		return
+	}
+
+	flight, report, err := litchi.ImportFlight(data)
+	if err != nil {
+		writeLitchiImportError(w, report, err)
+		return
+	}
+	if name == "" {
+		name = "Imported flight"
+	}
+
+	flight.Name = name
+
+	// Hold imported flights to the same checks as created ones
+	speedWarnings, warnings, ok := h.checkFlight(w, r, flight)
+	if !ok {
+		return
+	}
+
+	flight.UserID = userID
+	now := time.Now()
+	flight.CreatedAt = now
+	flight.UpdatedAt = now
+
+	// Insert into database
+	result, err := h.collection.InsertOne(context.Background(), flight)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Failed to save flight: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	flight.ID = result.InsertedID.(primitive.ObjectID)
+	log.Printf("Imported flight %s with %d waypoints", flight.ID.Hex(), report.Waypoints)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"flight": withSpeedWarnings(withGeofenceWarnings(flight.ToJSON(), warnings), speedWarnings),
+		"report": report,
+	})
+}
+
+// writeLitchiImportError reports a failed Litchi import, including the
+// per-row validation errors when there are any
+func writeLitchiImportError(w http.ResponseWriter, report *litchi.ImportReport, err error) {
+	log.Printf("Error importing Litchi CSV: %v", err)
+	if _, ok := err.(*litchi.InvalidRowsError); ok && report != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":  err.Error(),
+			"report": report,
+		})
+		return
+	}
+	http.Error(w, "Failed to import CSV: "+err.Error(), http.StatusUnprocessableEntity)
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"drone-planner/server/drones"
+	"drone-planner/server/generators"
+	"drone-planner/server/timeline"
+)
+
+// GeneratorHandler builds waypoint missions from survey and inspection patterns
+type GeneratorHandler struct {
+	flights *FlightHandler
+}
+
+// NewGeneratorHandler creates a new generator handler. Flights are loaded
+// through the flight handler so ownership checks stay in one place.
+func NewGeneratorHandler(flights *FlightHandler) *GeneratorHandler {
+	return &GeneratorHandler{flights: flights}
+}
+
+// droneRequest names the aircraft a pattern is flown with
+type droneRequest struct {
+	DroneType string `json:"droneType"`
+}
+
+// maxSpeed returns the aircraft's top speed, or 0 when no drone type was
+// given, recording an unknown type as a field error
+func (req droneRequest) maxSpeed(errs timeline.FieldErrors) float64 {
+	if req.DroneType == "" {
+		return 0
+	}
+	profile, ok := drones.Lookup(req.DroneType)
+	if !ok {
+		errs.Add("droneType", "unknown drone type %q", req.DroneType)
+		return 0
+	}
+	return profile.MaxHorizontalSpeed
+}
+
+// cameraRequest names a catalog camera. An inline camera profile takes
+// precedence over cameraId, which takes precedence over the drone's camera.
+type cameraRequest struct {
+	CameraID string `json:"cameraId"`
+}
+
+// GenerateSurvey builds a lawnmower survey over a polygon
+func (h *GeneratorHandler) GenerateSurvey(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		generators.Survey
+		droneRequest
+		cameraRequest
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	errs := timeline.NewFieldErrors("")
+	req.Camera = resolveCamera(errs, req.Camera, req.CameraID, req.DroneType)
+	req.MaxSpeed = req.maxSpeed(errs)
+	if err := errs.Err(); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	result, err := generators.GenerateSurvey(req.Survey)
+	writeGenerated(w, result, err)
+}
+
+// GenerateCorridor builds parallel mapping lines along a centerline, given
+// as a GeoJSON LineString or taken from a saved flight's waypoints
+func (h *GeneratorHandler) GenerateCorridor(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		generators.Corridor
+		droneRequest
+		cameraRequest
+		Centerline json.RawMessage `json:"centerline"`
+		FlightID   string          `json:"flightId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	errs := timeline.NewFieldErrors("")
+	switch {
+	case req.FlightID != "":
+		flight, ok := h.flights.loadUserFlight(w, r, req.FlightID)
+		if !ok {
+			return
+		}
+		for _, wp := range flight.Waypoints {
+			req.Corridor.Centerline = append(req.Corridor.Centerline, wp.Coordinate)
+		}
+	case len(req.Centerline) > 0:
+		centerline, err := generators.ParseLineString(req.Centerline)
+		if err != nil {
+			errs.Add("centerline", "%v", err)
+		}
+		req.Corridor.Centerline = centerline
+	default:
+		errs.Add("centerline", "a centerline or flightId is required")
+	}
+	req.Camera = resolveCamera(errs, req.Camera, req.CameraID, req.DroneType)
+	req.MaxSpeed = req.maxSpeed(errs)
+	if err := errs.Err(); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	result, err := generators.GenerateCorridor(req.Corridor)
+	writeGenerated(w, result, err)
+}
+
+// GenerateOrbit builds a circular orbit around a target
+func (h *GeneratorHandler) GenerateOrbit(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		generators.Orbit
+		droneRequest
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	errs := timeline.NewFieldErrors("")
+	req.MaxSpeed = req.maxSpeed(errs)
+	if err := errs.Err(); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+	result, err := generators.GenerateOrbit(req.Orbit)
+	writeGenerated(w, result, err)
+}
+
+// GenerateVerticalOrbit builds stacked orbits around a tower
+func (h *GeneratorHandler) GenerateVerticalOrbit(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		generators.VerticalOrbit
+		droneRequest
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	errs := timeline.NewFieldErrors("")
+	req.MaxSpeed = req.maxSpeed(errs)
+	if err := errs.Err(); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+	result, err := generators.GenerateVerticalOrbit(req.VerticalOrbit)
+	writeGenerated(w, result, err)
+}
+
+// GenerateFacade builds a facade scan around a building footprint
+func (h *GeneratorHandler) GenerateFacade(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		generators.Facade
+		droneRequest
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	errs := timeline.NewFieldErrors("")
+	req.MaxSpeed = req.maxSpeed(errs)
+	if err := errs.Err(); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+	result, err := generators.GenerateFacade(req.Facade)
+	writeGenerated(w, result, err)
+}
+
+// writeGenerated writes a generated pattern or its validation errors
+func writeGenerated(w http.ResponseWriter, result interface{}, err error) {
+	if err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Printf("Error encoding pattern: %v", err)
+	}
+}
@@ -0,0 +1,407 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"drone-planner/server/altitude"
+	"drone-planner/server/geofence"
+	"drone-planner/server/geometry"
+	"drone-planner/server/mavlink"
+	"drone-planner/server/models"
+	"drone-planner/server/timeline"
+)
+
+// GeofenceHandler stores geofences and enforces them on missions and flights
+type GeofenceHandler struct {
+	collection *mongo.Collection
+	altitudes  *altitude.Converter
+}
+
+func NewGeofenceHandler(collection *mongo.Collection) *GeofenceHandler {
+	return &GeofenceHandler{collection: collection, altitudes: sharedAltitudes()}
+}
+
+// teamFromContext returns the authenticated user's team, or "" without one
+func teamFromContext(r *http.Request) string {
+	teamID, _ := r.Context().Value("teamID").(string)
+	return teamID
+}
+
+// visibleFilter matches the geofences a user owns or shares through their team
+func visibleFilter(userID, teamID string) bson.M {
+	if teamID == "" {
+		return bson.M{"user_id": userID}
+	}
+	return bson.M{"$or": []bson.M{{"user_id": userID}, {"team_id": teamID}}}
+}
+
+// CreateGeofence handles the creation of a new geofence
+func (h *GeofenceHandler) CreateGeofence(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(string)
+	if !ok || userID == "" {
+		log.Printf("Auth error: userID not found in context")
+		http.Error(w, "Unauthorized: No user ID found in context", http.StatusUnauthorized)
+		return
+	}
+
+	var fence models.Geofence
+	if err := json.NewDecoder(r.Body).Decode(&fence); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := geofence.Validate(&fence); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+	if fence.TeamID != "" && fence.TeamID != teamFromContext(r) {
+		http.Error(w, "Geofences can only be shared with your own team", http.StatusForbidden)
+		return
+	}
+
+	fence.ID = primitive.NilObjectID
+	fence.UserID = userID
+	now := time.Now()
+	fence.CreatedAt = now
+	fence.UpdatedAt = now
+
+	result, err := h.collection.InsertOne(context.Background(), fence)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Failed to create geofence: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	fence.ID = result.InsertedID.(primitive.ObjectID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(fence.ToJSON())
+}
+
+// GetGeofences retrieves the geofences the user owns or shares through their team
+func (h *GeofenceHandler) GetGeofences(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(string)
+	if !ok || userID == "" {
+		log.Printf("Auth error: userID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	fences, err := h.visible(userID, teamFromContext(r))
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Failed to retrieve geofences: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	fencesJSON := make([]map[string]interface{}, len(fences))
+	for i := range fences {
+		fencesJSON[i] = fences[i].ToJSON()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(fencesJSON)
+}
+
+// GetGeofence retrieves a specific geofence
+func (h *GeofenceHandler) GetGeofence(w http.ResponseWriter, r *http.Request) {
+	fence, ok := h.findUserGeofence(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(fence.ToJSON())
+}
+
+// UpdateGeofence updates an existing geofence. Team members may edit the
+// fences shared with them.
+func (h *GeofenceHandler) UpdateGeofence(w http.ResponseWriter, r *http.Request) {
+	existing, ok := h.findUserGeofence(w, r)
+	if !ok {
+		return
+	}
+
+	var fence models.Geofence
+	if err := json.NewDecoder(r.Body).Decode(&fence); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := geofence.Validate(&fence); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+	if fence.TeamID != "" && fence.TeamID != existing.TeamID && fence.TeamID != teamFromContext(r) {
+		http.Error(w, "Geofences can only be shared with your own team", http.StatusForbidden)
+		return
+	}
+
+	fence.ID = existing.ID
+	fence.UserID = existing.UserID
+	fence.CreatedAt = existing.CreatedAt
+	fence.UpdatedAt = time.Now()
+	update := bson.M{
+		"$set": bson.M{
+			"team_id":       fence.TeamID,
+			"name":          fence.Name,
+			"zones":         fence.Zones,
+			"altitude_mode": fence.AltitudeMode,
+			"enforcement":   fence.Enforcement,
+			"auto_attach":   fence.AutoAttach,
+			"updated_at":    fence.UpdatedAt,
+		},
+	}
+	if _, err := h.collection.UpdateOne(context.Background(), bson.M{"_id": existing.ID}, update); err != nil {
+		http.Error(w, "Failed to update geofence: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(fence.ToJSON())
+}
+
+// DeleteGeofence deletes a geofence. Missions and flights that list it are
+// no longer checked against it.
+func (h *GeofenceHandler) DeleteGeofence(w http.ResponseWriter, r *http.Request) {
+	fence, ok := h.findUserGeofence(w, r)
+	if !ok {
+		return
+	}
+	if _, err := h.collection.DeleteOne(context.Background(), bson.M{"_id": fence.ID}); err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Failed to delete geofence: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ExportGeofenceArduPilot exports a geofence's zones as an ArduPilot fence file
+func (h *GeofenceHandler) ExportGeofenceArduPilot(w http.ResponseWriter, r *http.Request) {
+	fence, ok := h.findUserGeofence(w, r)
+	if !ok {
+		return
+	}
+	log.Printf("Exporting geofence %s as ArduPilot fence", fence.ID.Hex())
+
+	writeAttachment(w, "text/plain", exportFilename(fence.Name, ".fence.waypoints"), mavlink.FenceWPL(fencePolygons([]models.Geofence{*fence})))
+}
+
+// fencePolygons converts the zones of geofences to MAVLink fence polygons
+func fencePolygons(fences []models.Geofence) []mavlink.FencePolygon {
+	var polygons []mavlink.FencePolygon
+	for _, fence := range fences {
+		for _, zone := range fence.Zones {
+			polygon := mavlink.FencePolygon{Inclusion: zone.Type == models.GeofenceInclusion}
+			for _, point := range zone.Polygon {
+				polygon.Polygon = append(polygon.Polygon, [2]float64{point.Latitude, point.Longitude})
+			}
+			polygons = append(polygons, polygon)
+		}
+	}
+	return polygons
+}
+
+// findUserGeofence loads the geofence identified by the URL's {id} if the
+// authenticated user can see it, writing an error response and returning
+// false on failure
+func (h *GeofenceHandler) findUserGeofence(w http.ResponseWriter, r *http.Request) (*models.Geofence, bool) {
+	userID, ok := r.Context().Value("userID").(string)
+	if !ok || userID == "" {
+		log.Printf("Auth error: userID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	fenceID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid geofence ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	filter := visibleFilter(userID, teamFromContext(r))
+	filter["_id"] = fenceID
+	var fence models.Geofence
+	if err := h.collection.FindOne(context.Background(), filter).Decode(&fence); err != nil {
+		if err == mongo.ErrNoDocuments {
+			http.Error(w, "Geofence not found", http.StatusNotFound)
+			return nil, false
+		}
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Error retrieving geofence", http.StatusInternalServerError)
+		return nil, false
+	}
+	return &fence, true
+}
+
+// visible returns the geofences a user owns or shares through their team
+func (h *GeofenceHandler) visible(userID, teamID string) ([]models.Geofence, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+	cursor, err := h.collection.Find(context.Background(), visibleFilter(userID, teamID), opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+
+	var fences []models.Geofence
+	if err := cursor.All(context.Background(), &fences); err != nil {
+		return nil, err
+	}
+	return fences, nil
+}
+
+// attached returns the visible geofences that apply to a mission or flight:
+// those it lists and those attached automatically. It also returns the
+// indexes of listed IDs that match no visible fence.
+func (h *GeofenceHandler) attached(r *http.Request, ids []string) ([]models.Geofence, []int, error) {
+	userID, _ := r.Context().Value("userID").(string)
+	fences, err := h.visible(userID, teamFromContext(r))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	listed := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		listed[id] = true
+	}
+	found := make(map[string]bool, len(ids))
+	var applicable []models.Geofence
+	for _, fence := range fences {
+		id := fence.ID.Hex()
+		found[id] = true
+		if fence.AutoAttach || listed[id] {
+			applicable = append(applicable, fence)
+		}
+	}
+
+	var missing []int
+	for i, id := range ids {
+		if !found[id] {
+			missing = append(missing, i)
+		}
+	}
+	return applicable, missing, nil
+}
+
+// geofenceRoute is a route checked against geofences
+type geofenceRoute struct {
+	// path is the JSON path of the route's waypoints in the saved document
+	path string
+	// convert returns the route's points with altitudes in a mode, or as
+	// saved for an empty mode
+	convert func(mode string) ([]geometry.Point, error)
+}
+
+// enforce checks routes against the geofences attached through ids, listed
+// at idsPath. Violations of rejecting fences are written as a validation
+// error response and enforce returns false; violations of warning fences are
+// returned for the caller to report.
+func (h *GeofenceHandler) enforce(w http.ResponseWriter, r *http.Request, idsPath string, ids []string, routes []geofenceRoute) ([]timeline.FieldError, bool) {
+	fences, missing, err := h.attached(r, ids)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Failed to load geofences: "+err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+
+	rejected := timeline.NewFieldErrors("")
+	warned := timeline.NewFieldErrors("")
+	for _, i := range missing {
+		rejected.Add(fmt.Sprintf("%s[%d]", idsPath, i), "unknown geofence")
+	}
+
+	for i := range fences {
+		fence := &fences[i]
+		errs := rejected
+		if fence.NormalizeEnforcement() == models.GeofenceWarn {
+			errs = warned
+		}
+		checkAltitude := geofence.HasAltitudeLimits(fence)
+
+		for _, route := range routes {
+			points, err := route.convert(models.NormalizeAltitudeMode(fence.AltitudeMode))
+			if err != nil {
+				if checkAltitude {
+					errs.Add(route.path, "geofence %q: altitudes can't be checked: %v", fence.Name, err)
+					continue
+				}
+				// Without altitude limits the original altitudes are good enough
+				if points, err = route.convert(""); err != nil {
+					continue
+				}
+			}
+
+			for _, v := range geofence.Check(fence, points, checkAltitude, geofence.DefaultSpacing) {
+				message := fmt.Sprintf("geofence %q: %s", fence.Name, v.Reason)
+				if v.Segment {
+					message = fmt.Sprintf("geofence %q: %s on the way to the next waypoint", fence.Name, v.Reason)
+				}
+				errs.Add(fmt.Sprintf("%s[%d]", route.path, v.Waypoint), "%s", message)
+			}
+		}
+	}
+
+	if err := rejected.Err(); err != nil {
+		writeValidationError(w, err)
+		return nil, false
+	}
+	if err := warned.Err(); err != nil {
+		return err.(*timeline.ValidationError).Errors, true
+	}
+	return nil, true
+}
+
+// missionGeofenceRoutes returns the waypoint missions of a mission as routes
+func (h *GeofenceHandler) missionGeofenceRoutes(mission *models.Mission) []geofenceRoute {
+	var routes []geofenceRoute
+	for i := range mission.TimelineElements {
+		element := &mission.TimelineElements[i]
+		if element.Type != models.ElementWaypointMission {
+			continue
+		}
+		var config models.WaypointMissionConfig
+		if err := element.DecodeConfig(&config); err != nil || len(config.Waypoints) == 0 {
+			continue
+		}
+		routes = append(routes, geofenceRoute{
+			path: fmt.Sprintf("timelineElements[%d].config.waypoints", i),
+			convert: func(mode string) ([]geometry.Point, error) {
+				converted := &config
+				if mode != "" {
+					var err error
+					if converted, err = h.altitudes.Mission(mission.GlobalSettings, &config, mode); err != nil {
+						return nil, err
+					}
+				}
+				points, _ := geometry.WaypointLegs(converted)
+				return points, nil
+			},
+		})
+	}
+	return routes
+}
+
+// flightGeofenceRoutes returns a flight's waypoints as a route
+func (h *GeofenceHandler) flightGeofenceRoutes(flight *models.Flight) []geofenceRoute {
+	return []geofenceRoute{{
+		path: "waypoints",
+		convert: func(mode string) ([]geometry.Point, error) {
+			converted := flight
+			if mode != "" {
+				var err error
+				if converted, err = h.altitudes.Flight(flight, mode); err != nil {
+					return nil, err
+				}
+			}
+			points, _ := geometry.FlightLegs(converted)
+			return points, nil
+		},
+	}}
+}
@@ -16,14 +16,16 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"drone-planner/server/models"
+	"drone-planner/server/timeline"
 )
 
 type MissionHandler struct {
 	collection *mongo.Collection
+	geofences  *GeofenceHandler
 }
 
-func NewMissionHandler(collection *mongo.Collection) *MissionHandler {
-	return &MissionHandler{collection: collection}
+func NewMissionHandler(collection *mongo.Collection, geofences *GeofenceHandler) *MissionHandler {
+	return &MissionHandler{collection: collection, geofences: geofences}
 }
 
 // CreateMission handles the creation of a new mission
@@ -66,36 +68,11 @@ func (h *MissionHandler) CreateMission(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate timeline elements
-	if len(mission.TimelineElements) == 0 {
-		log.Printf("Validation error: No timeline elements")
-		http.Error(w, "At least one timeline element is required", http.StatusBadRequest)
+	speedWarnings, warnings, ok := h.checkMission(w, r, &mission)
+	if !ok {
 		return
 	}
 
-	// Validate that there's at least one waypoint mission
-	hasWaypointMission := false
-	for _, element := range mission.TimelineElements {
-		if element.Type == "waypoint-mission" {
-			hasWaypointMission = true
-			// Validate waypoint mission has waypoints
-			if config, ok := element.Config["waypoints"]; ok {
-				if waypoints, ok := config.([]interface{}); ok {
-					if len(waypoints) < 2 {
-						log.Printf("Validation error: Waypoint mission must have at least 2 waypoints")
-						http.Error(w, "Waypoint mission must have at least 2 waypoints", http.StatusBadRequest)
-						return
-					}
-				}
-			}
-		}
-	}
-
-	if !hasWaypointMission {
-		log.Printf("Validation error: No waypoint mission found")
-		http.Error(w, "At least one waypoint mission is required", http.StatusBadRequest)
-		return
-	}
 	mission.UserID = userID
 	now := time.Now()
 	mission.CreatedAt = now
@@ -114,7 +91,7 @@ func (h *MissionHandler) CreateMission(w http.ResponseWriter, r *http.Request) {
 	// Return the created mission
 	mission.ID = result.InsertedID.(primitive.ObjectID)
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(mission.ToJSON())
+	json.NewEncoder(w).Encode(withSpeedWarnings(withGeofenceWarnings(mission.ToJSON(), warnings), speedWarnings))
 }
 
 // GetMissions retrieves all missions for the authenticated user
@@ -225,6 +202,11 @@ func (h *MissionHandler) UpdateMission(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	speedWarnings, warnings, ok := h.checkMission(w, r, &mission)
+	if !ok {
+		return
+	}
+
 	filter := bson.M{
 		"_id":     id,
 		"user_id": userID,
@@ -232,11 +214,11 @@ func (h *MissionHandler) UpdateMission(w http.ResponseWriter, r *http.Request) {
 
 	update := bson.M{
 		"$set": bson.M{
-			"name":             mission.Name,
-			"timelineElements": mission.TimelineElements,
-			"globalSettings":   mission.GlobalSettings,
-			"metadata":         mission.Metadata,
-			"updatedAt":        time.Now(),
+			"name":              mission.Name,
+			"timeline_elements": mission.TimelineElements,
+			"global_settings":   mission.GlobalSettings,
+			"metadata":          mission.Metadata,
+			"updated_at":        time.Now(),
 		},
 	}
 
@@ -252,7 +234,7 @@ func (h *MissionHandler) UpdateMission(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(mission.ToJSON())
+	json.NewEncoder(w).Encode(withSpeedWarnings(withGeofenceWarnings(mission.ToJSON(), warnings), speedWarnings))
 }
 
 // DeleteMission deletes a mission
@@ -294,3 +276,108 @@ func (h *MissionHandler) DeleteMission(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// checkMission prepares a mission for saving: it lowers default speeds the
+// aircraft cannot fly when the client asks to, validates every timeline
+// element against its type, computes the metadata from the timeline rather
+// than trusting the client and checks the route against the geofences that
+// apply to it. It writes the error response and returns false when the
+// mission can't be saved.
+func (h *MissionHandler) checkMission(w http.ResponseWriter, r *http.Request, mission *models.Mission) (speedWarnings, geofenceWarnings []timeline.FieldError, ok bool) {
+	if clampRequested(r) {
+		speedWarnings = timeline.ClampSpeeds(mission)
+	}
+	if err := timeline.Validate(mission); err != nil {
+		writeValidationError(w, err)
+		return nil, nil, false
+	}
+
+	metadata, err := timeline.Metadata(mission)
+	if err != nil {
+		log.Printf("Validation error: %v", err)
+		http.Error(w, "Invalid timeline element: "+err.Error(), http.StatusBadRequest)
+		return nil, nil, false
+	}
+	mission.Metadata = metadata
+
+	geofenceWarnings, ok = h.geofences.enforce(w, r, "globalSettings.geofenceIds", mission.GlobalSettings.GeofenceIDs, h.geofences.missionGeofenceRoutes(mission))
+	return speedWarnings, geofenceWarnings, ok
+}
+
+// writeValidationError responds with the field errors of a failed validation
+func writeValidationError(w http.ResponseWriter, err error) {
+	log.Printf("Validation error: %v", err)
+	validationErr, ok := err.(*timeline.ValidationError)
+	if !ok {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":  validationErr.Error(),
+		"errors": validationErr.Errors,
+	})
+}
+
+// withGeofenceWarnings adds the violations of warning geofences to a saved
+// mission or flight response
+func withGeofenceWarnings(response map[string]interface{}, warnings []timeline.FieldError) map[string]interface{} {
+	if len(warnings) > 0 {
+		response["geofenceWarnings"] = warnings
+	}
+	return response
+}
+
+// clampRequested reports whether a client sending its default speeds asked
+// for those above the aircraft's maximum to be lowered rather than rejected,
+// with clampSpeeds=true
+func clampRequested(r *http.Request) bool {
+	return r.URL.Query().Get("clampSpeeds") == "true"
+}
+
+// withSpeedWarnings adds the speeds lowered to the aircraft's limit to a
+// saved mission or flight response
+func withSpeedWarnings(response map[string]interface{}, warnings []timeline.FieldError) map[string]interface{} {
+	if len(warnings) > 0 {
+		response["speedWarnings"] = warnings
+	}
+	return response
+}
+
+// findUserMission loads the mission identified by the URL's {id} for the
+// authenticated user, writing an error response and returning false on failure
+func (h *MissionHandler) findUserMission(w http.ResponseWriter, r *http.Request) (*models.Mission, bool) {
+	// Get user ID from context
+	userID, ok := r.Context().Value("userID").(string)
+	if !ok || userID == "" {
+		log.Printf("Auth error: userID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	// Get mission ID from URL
+	vars := mux.Vars(r)
+	missionID, err := primitive.ObjectIDFromHex(vars["id"])
+	if err != nil {
+		log.Printf("Invalid mission ID format: %v", err)
+		http.Error(w, "Invalid mission ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	var mission models.Mission
+	err = h.collection.FindOne(context.Background(), bson.M{
+		"_id":     missionID,
+		"user_id": userID,
+	}).Decode(&mission)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			http.Error(w, "Mission not found", http.StatusNotFound)
+			return nil, false
+		}
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Error retrieving mission", http.StatusInternalServerError)
+		return nil, false
+	}
+	return &mission, true
+}
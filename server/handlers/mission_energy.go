@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"drone-planner/server/drones"
+	"drone-planner/server/energy"
+	"drone-planner/server/geometry"
+	"drone-planner/server/models"
+)
+
+// energyInputs resolves the waypoint mission, aircraft and battery settings
+// of an energy request. The "drone" and "threshold" query parameters
+// override the mission's drone type and battery threshold.
+func energyInputs(w http.ResponseWriter, r *http.Request, mission *models.Mission) (*models.WaypointMissionConfig, *drones.Profile, energy.Options, bool) {
+	config, err := mission.DecodeWaypointMission()
+	if err != nil {
+		http.Error(w, "Mission has no usable waypoint mission: "+err.Error(), http.StatusUnprocessableEntity)
+		return nil, nil, energy.Options{}, false
+	}
+	if len(config.Waypoints) < 2 {
+		http.Error(w, "Waypoint mission must have at least 2 waypoints", http.StatusUnprocessableEntity)
+		return nil, nil, energy.Options{}, false
+	}
+
+	droneType := r.URL.Query().Get("drone")
+	if droneType == "" {
+		droneType = mission.GlobalSettings.DroneType
+	}
+	if droneType == "" {
+		http.Error(w, "Mission has no drone type; pass ?drone= to choose one", http.StatusBadRequest)
+		return nil, nil, energy.Options{}, false
+	}
+	profile, ok := drones.Lookup(droneType)
+	if !ok {
+		http.Error(w, "Unknown drone type: "+droneType, http.StatusBadRequest)
+		return nil, nil, energy.Options{}, false
+	}
+
+	options := energy.Options{
+		Home:          geometry.HomePoint(mission.GlobalSettings, config),
+		Threshold:     float64(mission.GlobalSettings.BatteryThreshold),
+		BatteryAction: mission.GlobalSettings.BatteryAction,
+	}
+	if value := r.URL.Query().Get("threshold"); value != "" {
+		threshold, err := strconv.ParseFloat(value, 64)
+		if err != nil || threshold < 0 || threshold >= 100 {
+			http.Error(w, "threshold must be a percentage below 100", http.StatusBadRequest)
+			return nil, nil, energy.Options{}, false
+		}
+		options.Threshold = threshold
+	}
+	return config, profile, options, true
+}
+
+// GetMissionEnergy predicts the battery use of a mission's waypoint mission
+func (h *MissionHandler) GetMissionEnergy(w http.ResponseWriter, r *http.Request) {
+	mission, ok := h.findUserMission(w, r)
+	if !ok {
+		return
+	}
+	config, profile, options, ok := energyInputs(w, r, mission)
+	if !ok {
+		return
+	}
+
+	estimate, err := energy.EstimateMission(config, profile, options)
+	if err != nil {
+		log.Printf("Error estimating energy: %v", err)
+		http.Error(w, "Failed to estimate energy: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(estimate)
+}
+
+// SplitMissionSorties splits a mission's waypoint mission into sorties that
+// each fit on one battery
+func (h *MissionHandler) SplitMissionSorties(w http.ResponseWriter, r *http.Request) {
+	mission, ok := h.findUserMission(w, r)
+	if !ok {
+		return
+	}
+	config, profile, options, ok := energyInputs(w, r, mission)
+	if !ok {
+		return
+	}
+
+	sorties, err := energy.Split(config, profile, options)
+	if err != nil {
+		log.Printf("Error splitting mission %s: %v", mission.ID.Hex(), err)
+		http.Error(w, "Failed to split mission: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"drone":     profile.ID,
+		"threshold": options.Threshold,
+		"sorties":   sorties,
+	})
+}
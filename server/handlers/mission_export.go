@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"drone-planner/server/czml"
+	"drone-planner/server/litchi"
+	"drone-planner/server/mavlink"
+	"drone-planner/server/models"
+	"drone-planner/server/timeline"
+	"drone-planner/server/wpml"
+)
+
+// unsafeFilenameChars matches characters that should not appear in a download filename
+var unsafeFilenameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// exportFilename builds a download filename from a mission name
+func exportFilename(name, extension string) string {
+	base := strings.Trim(unsafeFilenameChars.ReplaceAllString(name, "_"), "_")
+	if base == "" {
+		base = "mission"
+	}
+	return base + extension
+}
+
+// writeAttachment sends an export as a file download
+func writeAttachment(w http.ResponseWriter, contentType, filename string, data []byte) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	if _, err := w.Write(data); err != nil {
+		log.Printf("Error writing export: %v", err)
+	}
+}
+
+// ExportMissionKMZ exports a mission as a DJI WPML KMZ package
+func (h *MissionHandler) ExportMissionKMZ(w http.ResponseWriter, r *http.Request) {
+	mission, ok := h.findUserMission(w, r)
+	if !ok {
+		return
+	}
+	log.Printf("Exporting mission %s as KMZ", mission.ID.Hex())
+
+	data, err := wpml.Export(mission, sharedAltitudes())
+	if err != nil {
+		log.Printf("Error exporting KMZ: %v", err)
+		http.Error(w, "Failed to export mission: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	writeAttachment(w, "application/vnd.google-earth.kmz", exportFilename(mission.Name, ".kmz"), data)
+}
+
+// ExportMissionLitchi exports a mission's waypoint mission as a Litchi CSV
+func (h *MissionHandler) ExportMissionLitchi(w http.ResponseWriter, r *http.Request) {
+	mission, ok := h.findUserMission(w, r)
+	if !ok {
+		return
+	}
+	log.Printf("Exporting mission %s as Litchi CSV", mission.ID.Hex())
+
+	data, skipped, err := litchi.ExportMission(mission, sharedAltitudes())
+	if err != nil {
+		log.Printf("Error exporting Litchi CSV: %v", err)
+		http.Error(w, "Failed to export mission: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	for _, note := range skipped {
+		log.Printf("Litchi export skipped %s", note)
+	}
+
+	writeAttachment(w, "text/csv", exportFilename(mission.Name, ".csv"), data)
+}
+
+// firmwareFromQuery reads the target autopilot from the "firmware" query parameter
+func firmwareFromQuery(r *http.Request) int {
+	if strings.EqualFold(r.URL.Query().Get("firmware"), "px4") {
+		return mavlink.AutopilotPX4
+	}
+	return mavlink.AutopilotArduPilot
+}
+
+// buildMAVLinkMission compiles a mission to MAVLink items, writing an error response on failure
+func buildMAVLinkMission(w http.ResponseWriter, mission *models.Mission) (*mavlink.Mission, bool) {
+	compiled, err := mavlink.Build(mission, sharedAltitudes())
+	if err != nil {
+		log.Printf("Error compiling MAVLink mission: %v", err)
+		http.Error(w, "Failed to export mission: "+err.Error(), http.StatusUnprocessableEntity)
+		return nil, false
+	}
+	for _, note := range compiled.Skipped {
+		log.Printf("MAVLink export skipped %s", note)
+	}
+	return compiled, true
+}
+
+// ExportMissionQGC exports a mission as a QGroundControl .plan file
+func (h *MissionHandler) ExportMissionQGC(w http.ResponseWriter, r *http.Request) {
+	mission, ok := h.findUserMission(w, r)
+	if !ok {
+		return
+	}
+	log.Printf("Exporting mission %s as QGroundControl plan", mission.ID.Hex())
+
+	compiled, ok := buildMAVLinkMission(w, mission)
+	if !ok {
+		return
+	}
+	// The plan's geofence carries the fences that apply to the mission
+	fences, _, err := h.geofences.attached(r, mission.GlobalSettings.GeofenceIDs)
+	if err != nil {
+		log.Printf("Error loading geofences: %v", err)
+		http.Error(w, "Failed to load geofences: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	data, err := compiled.Plan(mavlink.PlanOptions{Firmware: firmwareFromQuery(r), Fences: fencePolygons(fences)})
+	if err != nil {
+		log.Printf("Error encoding plan: %v", err)
+		http.Error(w, "Failed to export mission: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeAttachment(w, "application/json", exportFilename(mission.Name, ".plan"), data)
+}
+
+// ExportMissionWPL exports a mission as a MAVLink WPL 110 waypoint file
+func (h *MissionHandler) ExportMissionWPL(w http.ResponseWriter, r *http.Request) {
+	mission, ok := h.findUserMission(w, r)
+	if !ok {
+		return
+	}
+	log.Printf("Exporting mission %s as WPL", mission.ID.Hex())
+
+	compiled, ok := buildMAVLinkMission(w, mission)
+	if !ok {
+		return
+	}
+
+	writeAttachment(w, "text/plain", exportFilename(mission.Name, ".waypoints"), compiled.WPL())
+}
+
+// ExportMissionCZML exports a mission's simulated flight as a CZML document
+// for Cesium playback. ?rate=, ?drone= and ?cameraId= override the sampling
+// rate, the aircraft limits and the camera drawn as the frustum.
+func (h *MissionHandler) ExportMissionCZML(w http.ResponseWriter, r *http.Request) {
+	mission, ok := h.findUserMission(w, r)
+	if !ok {
+		return
+	}
+	log.Printf("Exporting mission %s as CZML", mission.ID.Hex())
+
+	query := r.URL.Query()
+	droneType := mission.GlobalSettings.DroneType
+	if drone := query.Get("drone"); drone != "" {
+		droneType = drone
+	}
+	options, ok := simulationOptions(w, query, droneType)
+	if !ok {
+		return
+	}
+	if options.Rate == 0 {
+		options.Rate = czml.DefaultRate
+	}
+	errs := timeline.NewFieldErrors("")
+	camera := resolveCamera(errs, nil, query.Get("cameraId"), droneType)
+	if err := errs.Err(); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	data, notes, err := czml.Export(mission, sharedAltitudes(), camera, options)
+	if err != nil {
+		log.Printf("Error exporting CZML: %v", err)
+		http.Error(w, "Failed to export mission: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	for _, note := range notes {
+		log.Printf("CZML export: %s", note)
+	}
+
+	writeAttachment(w, "application/json", exportFilename(mission.Name, ".czml"), data)
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"drone-planner/server/litchi"
+	"drone-planner/server/models"
+	"drone-planner/server/wpml"
+)
+
+// maxImportSize limits the size of uploaded mission files (bytes)
+const maxImportSize = 32 << 20
+
+// readUpload reads the "file" field of a multipart upload, returning its
+// contents and a mission name taken from the "name" field or the filename
+func readUpload(w http.ResponseWriter, r *http.Request) ([]byte, string, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	if err := r.ParseMultipartForm(maxImportSize); err != nil {
+		log.Printf("Error parsing multipart form: %v", err)
+		http.Error(w, "Invalid multipart upload: "+err.Error(), http.StatusBadRequest)
+		return nil, "", false
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		log.Printf("Missing upload file: %v", err)
+		http.Error(w, "A file field is required", http.StatusBadRequest)
+		return nil, "", false
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		log.Printf("Error reading upload: %v", err)
+		http.Error(w, "Error reading uploaded file", http.StatusBadRequest)
+		return nil, "", false
+	}
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" {
+		name = strings.TrimSuffix(header.Filename, filepath.Ext(header.Filename))
+	}
+	return data, name, true
+}
+
+// ImportMissionKMZ creates a new mission from an uploaded DJI WPML KMZ file
+func (h *MissionHandler) ImportMissionKMZ(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, ok := r.Context().Value("userID").(string)
+	if !ok || userID == "" {
+		log.Printf("Auth error: userID not found in context")
+		http.Error(w, "Unauthorized: No user ID found in context", http.StatusUnauthorized)
+		return
+	}
+	log.Printf("Processing ImportMissionKMZ request for user: %s", userID)
+
+	data, name, ok := readUpload(w, r)
+	if !ok {
+		return
+	}
+
+	mission, report, err := wpml.Import(data)
+	if err != nil {
+		log.Printf("Error importing KMZ: %v", err)
+		http.Error(w, "Failed to import KMZ: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	log.Printf("Parsed KMZ with %d waypoints (%d unmapped items)", report.Waypoints, len(report.Unmapped))
+
+	h.insertImportedMission(w, r, mission, name, userID, report)
+}
+
+// ImportMissionLitchi creates a new mission from an uploaded Litchi CSV
+func (h *MissionHandler) ImportMissionLitchi(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, ok := r.Context().Value("userID").(string)
+	if !ok || userID == "" {
+		log.Printf("Auth error: userID not found in context")
+		http.Error(w, "Unauthorized: No user ID found in context", http.StatusUnauthorized)
+		return
+	}
+	log.Printf("Processing ImportMissionLitchi request for user: %s", userID)
+
+	data, name, ok := readUpload(w, r)
+	if !ok {
+		return
+	}
+
+	mission, report, err := litchi.ImportMission(data)
+	if err != nil {
+		writeLitchiImportError(w, report, err)
+		return
+	}
+
+	h.insertImportedMission(w, r, mission, name, userID, report)
+}
+
+// insertImportedMission checks an imported mission like a created one, stores
+// it and responds with it and its import report
+func (h *MissionHandler) insertImportedMission(w http.ResponseWriter, r *http.Request, mission *models.Mission, name, userID string, report interface{}) {
+	if name == "" {
+		name = "Imported mission"
+	}
+	mission.Name = name
+
+	speedWarnings, warnings, ok := h.checkMission(w, r, mission)
+	if !ok {
+		return
+	}
+
+	mission.UserID = userID
+	now := time.Now()
+	mission.CreatedAt = now
+	mission.UpdatedAt = now
+	mission.Date = now
+
+	// Insert mission into database
+	result, err := h.collection.InsertOne(context.Background(), mission)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Failed to create mission: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	mission.ID = result.InsertedID.(primitive.ObjectID)
+	log.Printf("Imported mission %s", mission.ID.Hex())
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"mission": withSpeedWarnings(withGeofenceWarnings(mission.ToJSON(), warnings), speedWarnings),
+		"report":  report,
+	})
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+
+	"drone-planner/server/altitude"
+	"drone-planner/server/drones"
+	"drone-planner/server/geometry"
+	"drone-planner/server/models"
+	"drone-planner/server/simulator"
+	"drone-planner/server/timeline"
+)
+
+// SimulatorHandler serves simulated trajectories of missions and flights
+type SimulatorHandler struct {
+	missions  *MissionHandler
+	flights   *FlightHandler
+	altitudes *altitude.Converter
+}
+
+// NewSimulatorHandler creates a new simulator handler
+func NewSimulatorHandler(missions *MissionHandler, flights *FlightHandler) *SimulatorHandler {
+	return &SimulatorHandler{missions: missions, flights: flights, altitudes: sharedAltitudes()}
+}
+
+// GetMissionTrajectory simulates a mission's timeline and returns its
+// trajectory sampled ?rate= times per second. Aircraft limits come from
+// ?drone= or the mission's drone type.
+func (h *SimulatorHandler) GetMissionTrajectory(w http.ResponseWriter, r *http.Request) {
+	mission, ok := h.missions.findUserMission(w, r)
+	if !ok {
+		return
+	}
+	options, ok := simulationOptions(w, r.URL.Query(), mission.GlobalSettings.DroneType)
+	if !ok {
+		return
+	}
+
+	relative := func(config *models.WaypointMissionConfig) (*models.WaypointMissionConfig, error) {
+		return h.altitudes.Mission(mission.GlobalSettings, config, models.AltitudeRelative)
+	}
+	trajectory, err := simulator.SimulateMission(mission, relative, options)
+	writeTrajectory(w, trajectory, err)
+}
+
+// GetFlightTrajectory simulates a saved flight like GetMissionTrajectory
+func (h *SimulatorHandler) GetFlightTrajectory(w http.ResponseWriter, r *http.Request) {
+	flight, ok := h.flights.findUserFlight(w, r)
+	if !ok {
+		return
+	}
+	options, ok := simulationOptions(w, r.URL.Query(), flight.DroneType)
+	if !ok {
+		return
+	}
+
+	converted, err := h.altitudes.Flight(flight, models.AltitudeRelative)
+	if err != nil {
+		http.Error(w, "Failed to convert altitudes: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	trajectory, err := simulator.SimulateFlight(converted, options)
+	writeTrajectory(w, trajectory, err)
+}
+
+// DryRunMission simulates a mission with failures injected and returns how
+// its failsafe settings respond: the resulting trajectory, the action taken
+// for each failure and findings such as a return home the battery cannot
+// complete or that passes too close to the terrain.
+func (h *SimulatorHandler) DryRunMission(w http.ResponseWriter, r *http.Request) {
+	mission, ok := h.missions.findUserMission(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Failures    []simulator.Failure `json:"failures"`
+		RTHAltitude float64             `json:"rthAltitude"`
+		RTHSpeed    float64             `json:"rthSpeed"`
+		Drone       string              `json:"drone"`
+		Rate        float64             `json:"rate"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	errs := timeline.NewFieldErrors("")
+	if len(req.Failures) == 0 {
+		errs.Add("failures", "at least one failure is required")
+	}
+	for i, failure := range req.Failures {
+		failureErrs := errs.At(fmt.Sprintf("failures[%d]", i))
+		if failure.Type == "" {
+			failureErrs.Add("type", "is required")
+		}
+		failureErrs.OneOf("type", failure.Type, simulator.FailureTypes...)
+		if failure.Time == nil && failure.Type != simulator.FailureLowBattery {
+			failureErrs.Add("time", "is required")
+		} else if failure.Time != nil && *failure.Time < 0 {
+			failureErrs.Add("time", "must not be negative")
+		}
+		if failure.Type == simulator.FailureGPSDegraded && failure.Duration <= 0 {
+			failureErrs.Add("duration", "must be positive")
+		}
+	}
+	if req.RTHAltitude < 0 {
+		errs.Add("rthAltitude", "must not be negative")
+	}
+	if req.RTHSpeed < 0 {
+		errs.Add("rthSpeed", "must not be negative")
+	}
+	errs.Between("rate", req.Rate, 0, simulator.MaxRate)
+	if err := errs.Err(); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	settings := mission.GlobalSettings
+	droneType := settings.DroneType
+	if req.Drone != "" {
+		droneType = req.Drone
+	}
+	options := simulator.DroneOptions(droneType)
+	options.Rate = req.Rate
+	scenario := simulator.Scenario{
+		Failures:         req.Failures,
+		SignalLostAction: settings.SignalLostAction,
+		BatteryAction:    settings.BatteryAction,
+		BatteryThreshold: float64(settings.BatteryThreshold),
+		RTHAltitude:      req.RTHAltitude,
+		RTHSpeed:         req.RTHSpeed,
+		Clearance: func(home geometry.Point, lat, lng, alt float64) (float64, error) {
+			return h.altitudes.Convert(home, lat, lng, alt, models.AltitudeRelative, models.AltitudeAGL)
+		},
+	}
+	if profile, ok := drones.Lookup(droneType); ok {
+		scenario.Profile = profile
+	}
+
+	relative := func(config *models.WaypointMissionConfig) (*models.WaypointMissionConfig, error) {
+		return h.altitudes.Mission(settings, config, models.AltitudeRelative)
+	}
+	result, err := simulator.DryRun(mission, relative, options, scenario)
+	if err != nil {
+		http.Error(w, "Failed to simulate: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Printf("Error encoding dry run: %v", err)
+	}
+}
+
+// simulationOptions reads the drone and sampling rate of a simulation
+func simulationOptions(w http.ResponseWriter, query url.Values, droneType string) (simulator.Options, bool) {
+	if drone := query.Get("drone"); drone != "" {
+		droneType = drone
+	}
+	options := simulator.DroneOptions(droneType)
+	rate, ok := floatQuery(w, query.Get("rate"), 0)
+	if !ok {
+		return options, false
+	}
+	if rate < 0 || rate > simulator.MaxRate {
+		http.Error(w, "rate must be between 0 and 50 samples per second", http.StatusBadRequest)
+		return options, false
+	}
+	options.Rate = rate
+	return options, true
+}
+
+// writeTrajectory responds with a simulated trajectory or the error that
+// prevented it
+func writeTrajectory(w http.ResponseWriter, trajectory *simulator.Trajectory, err error) {
+	if err != nil {
+		http.Error(w, "Failed to simulate: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(trajectory); err != nil {
+		log.Printf("Error encoding trajectory: %v", err)
+	}
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"drone-planner/server/altitude"
+	"drone-planner/server/models"
+	"drone-planner/server/simulator"
+	"drone-planner/server/sun"
+)
+
+// glareAngle is how close to the sun the camera may point before the
+// photos risk flare (degrees)
+const glareAngle = 30.0
+
+// SunHandler serves daylight and sun positions for missions
+type SunHandler struct {
+	missions  *MissionHandler
+	altitudes *altitude.Converter
+}
+
+// NewSunHandler creates a new sun handler
+func NewSunHandler(missions *MissionHandler) *SunHandler {
+	return &SunHandler{missions: missions, altitudes: sharedAltitudes()}
+}
+
+// waypointSun is the sun at a waypoint when the aircraft reaches it
+type waypointSun struct {
+	Element     int       `json:"element"`
+	Waypoint    int       `json:"waypoint"`
+	Time        time.Time `json:"time"`
+	Latitude    float64   `json:"latitude"`
+	Longitude   float64   `json:"longitude"`
+	Heading     float64   `json:"heading"`
+	GimbalPitch float64   `json:"gimbalPitch"`
+	Azimuth     float64   `json:"azimuth"`
+	Elevation   float64   `json:"elevation"`
+	// SunAngle is the angle between the camera and the sun (degrees)
+	SunAngle             float64 `json:"sunAngle"`
+	IntoSun              bool    `json:"intoSun"`
+	OutsideCivilTwilight bool    `json:"outsideCivilTwilight"`
+}
+
+// sunReport is the daylight of a mission's day and the sun along its route
+type sunReport struct {
+	Latitude  float64       `json:"latitude"`
+	Longitude float64       `json:"longitude"`
+	Start     time.Time     `json:"start"`
+	End       time.Time     `json:"end"`
+	Day       sun.Day       `json:"day"`
+	Waypoints []waypointSun `json:"waypoints"`
+	Warnings  []string      `json:"warnings"`
+}
+
+// GetMissionSun returns sunrise, sunset, civil twilight and the golden and
+// blue hours at a mission's home on its date, or at ?time=, with the sun's
+// position at each waypoint when the simulated flight reaches it. It warns
+// when the flight runs outside civil twilight or the camera faces the sun.
+func (h *SunHandler) GetMissionSun(w http.ResponseWriter, r *http.Request) {
+	mission, ok := h.missions.findUserMission(w, r)
+	if !ok {
+		return
+	}
+	start, ok := missionDate(w, r, mission)
+	if !ok {
+		return
+	}
+	options, ok := simulationOptions(w, r.URL.Query(), mission.GlobalSettings.DroneType)
+	if !ok {
+		return
+	}
+
+	relative := func(config *models.WaypointMissionConfig) (*models.WaypointMissionConfig, error) {
+		return h.altitudes.Mission(mission.GlobalSettings, config, models.AltitudeRelative)
+	}
+	trajectory, err := simulator.SimulateMission(mission, relative, options)
+	if err != nil {
+		http.Error(w, "Failed to simulate: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	home := trajectory.Home
+	end := start.Add(time.Duration(trajectory.Duration * float64(time.Second)))
+	report := sunReport{
+		Latitude:  home.Latitude,
+		Longitude: home.Longitude,
+		Start:     start,
+		End:       end,
+		Day:       sun.DayAt(start, home.Latitude, home.Longitude),
+		Waypoints: []waypointSun{},
+		Warnings:  []string{},
+	}
+
+	window := civilWindow(report.Day)
+	for _, at := range []struct {
+		what string
+		time time.Time
+	}{{"starts", start}, {"ends", end}} {
+		if _, elevation := sun.Position(at.time, home.Latitude, home.Longitude); elevation < sun.CivilElevation {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("the mission %s at %s, outside civil twilight (%s)", at.what, clockTime(at.time), window))
+		}
+	}
+
+	dark := 0
+	for _, event := range trajectory.Events {
+		if event.Type != simulator.EventWaypoint {
+			continue
+		}
+		index := int(math.Round(event.Time * trajectory.Rate))
+		if index >= len(trajectory.Samples) {
+			index = len(trajectory.Samples) - 1
+		}
+		sample := trajectory.Samples[index]
+		arrival := start.Add(time.Duration(event.Time * float64(time.Second)))
+		azimuth, elevation := sun.Position(arrival, sample.Latitude, sample.Longitude)
+
+		point := waypointSun{
+			Element:     event.Element,
+			Waypoint:    event.Waypoint,
+			Time:        arrival,
+			Latitude:    sample.Latitude,
+			Longitude:   sample.Longitude,
+			Heading:     math.Mod(sample.Heading+360, 360),
+			GimbalPitch: sample.GimbalPitch,
+			Azimuth:     azimuth,
+			Elevation:   elevation,
+			SunAngle:    sun.Separation(azimuth, elevation, sample.Heading, sample.GimbalPitch),
+		}
+		point.IntoSun = elevation > 0 && point.SunAngle < glareAngle
+		point.OutsideCivilTwilight = elevation < sun.CivilElevation
+		if point.IntoSun {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("waypoint %d: the camera points %.0f° from the sun", event.Waypoint, point.SunAngle))
+		}
+		if point.OutsideCivilTwilight {
+			dark++
+		}
+		report.Waypoints = append(report.Waypoints, point)
+	}
+	if dark > 0 {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("%d of %d waypoints are reached outside civil twilight (%s)", dark, len(report.Waypoints), window))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// civilWindow describes when a day's civil twilight allows flying
+func civilWindow(day sun.Day) string {
+	switch {
+	case day.CivilDawn != nil && day.CivilDusk != nil:
+		return fmt.Sprintf("%s to %s", clockTime(*day.CivilDawn), clockTime(*day.CivilDusk))
+	case day.CivilDawn != nil:
+		return "from " + clockTime(*day.CivilDawn)
+	case day.CivilDusk != nil:
+		return "until " + clockTime(*day.CivilDusk)
+	case day.NoonElevation < sun.CivilElevation:
+		return "the sun stays more than 6° below the horizon all day"
+	}
+	return "all day"
+}
+
+// clockTime formats a time of day in UTC
+func clockTime(t time.Time) string {
+	return t.UTC().Format("15:04 MST")
+}
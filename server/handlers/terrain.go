@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"drone-planner/server/altitude"
+	"drone-planner/server/geometry"
+	"drone-planner/server/models"
+	"drone-planner/server/terrain"
+)
+
+const (
+	// defaultMinClearance is the height above ground flagged as too low (m)
+	defaultMinClearance = 30.0
+	// defaultFollowTolerance is how far terrain-following may drift from the requested height (m)
+	defaultFollowTolerance = 5.0
+)
+
+// TerrainHandler serves terrain profiles from the DEM tiles in TERRAIN_DIR
+type TerrainHandler struct {
+	missions  *MissionHandler
+	altitudes *altitude.Converter
+}
+
+// NewTerrainHandler creates a terrain handler. Without TERRAIN_DIR the
+// terrain endpoints respond with 503.
+func NewTerrainHandler(missions *MissionHandler) *TerrainHandler {
+	return &TerrainHandler{missions: missions, altitudes: sharedAltitudes()}
+}
+
+// terrainFollowing is a mission rewritten to follow the terrain
+type terrainFollowing struct {
+	Height   float64                       `json:"height"`
+	Inserted int                           `json:"inserted"`
+	Config   *models.WaypointMissionConfig `json:"config"`
+	Profile  *terrain.Profile              `json:"profile"`
+}
+
+// GetMissionTerrain returns the terrain profile under a mission's waypoint
+// mission and the segments lower than ?clearance= meters above ground. With
+// ?follow= it also rewrites the waypoints to fly that height above ground.
+func (h *TerrainHandler) GetMissionTerrain(w http.ResponseWriter, r *http.Request) {
+	provider := h.altitudes.Terrain
+	if provider == nil {
+		http.Error(w, "Terrain data is not configured", http.StatusServiceUnavailable)
+		return
+	}
+	mission, ok := h.missions.findUserMission(w, r)
+	if !ok {
+		return
+	}
+	config, err := mission.DecodeWaypointMission()
+	if err != nil {
+		http.Error(w, "Mission has no usable waypoint mission: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	query := r.URL.Query()
+	clearance, ok := floatQuery(w, query.Get("clearance"), defaultMinClearance)
+	if !ok {
+		return
+	}
+	spacing, ok := floatQuery(w, query.Get("spacing"), terrain.DefaultSpacing)
+	if !ok {
+		return
+	}
+	if spacing < 1 {
+		http.Error(w, "spacing must be at least 1 meter", http.StatusBadRequest)
+		return
+	}
+
+	// Profiles are measured from the ground at home
+	config, err = h.altitudes.Mission(mission.GlobalSettings, config, models.AltitudeRelative)
+	if err != nil {
+		http.Error(w, "Failed to convert altitudes: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	home := geometry.HomePoint(mission.GlobalSettings, config)
+	points, _ := geometry.WaypointLegs(config)
+	profile, err := terrain.BuildProfile(provider, home, points, clearance, spacing)
+	if err != nil {
+		log.Printf("Error building terrain profile: %v", err)
+		http.Error(w, "Failed to build terrain profile: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	response := map[string]interface{}{"profile": profile}
+
+	if query.Get("follow") != "" {
+		height, ok := floatQuery(w, query.Get("follow"), 0)
+		if !ok {
+			return
+		}
+		tolerance, ok := floatQuery(w, query.Get("tolerance"), defaultFollowTolerance)
+		if !ok {
+			return
+		}
+
+		followed, inserted, err := terrain.Follow(provider, home, config, height, tolerance, spacing)
+		if err != nil {
+			log.Printf("Error following terrain: %v", err)
+			http.Error(w, "Failed to follow terrain: "+err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		followedPoints, _ := geometry.WaypointLegs(followed)
+		followedProfile, err := terrain.BuildProfile(provider, home, followedPoints, clearance, spacing)
+		if err != nil {
+			http.Error(w, "Failed to build terrain profile: "+err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		response["terrainFollowing"] = terrainFollowing{
+			Height:   height,
+			Inserted: inserted,
+			Config:   followed,
+			Profile:  followedProfile,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// floatQuery parses an optional numeric query parameter
+func floatQuery(w http.ResponseWriter, value string, fallback float64) (float64, bool) {
+	if value == "" {
+		return fallback, true
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		http.Error(w, "Invalid number: "+value, http.StatusBadRequest)
+		return 0, false
+	}
+	return parsed, true
+}
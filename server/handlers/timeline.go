@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"drone-planner/server/timeline"
+)
+
+// GetElementTypes lists the registered timeline element types with their config schemas
+func (h *MissionHandler) GetElementTypes(w http.ResponseWriter, r *http.Request) {
+	types := timeline.Types()
+	schemas := make([]timeline.Schema, 0, len(types))
+	for _, elementType := range types {
+		schemas = append(schemas, elementType.Schema())
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(schemas); err != nil {
+		log.Printf("Error encoding element types: %v", err)
+	}
+}
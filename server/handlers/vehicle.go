@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"drone-planner/server/mavlink"
+)
+
+const (
+	// vehicleDialTimeout bounds opening the link to a vehicle
+	vehicleDialTimeout = 10 * time.Second
+	// vehicleJobTimeout bounds a whole upload or comparison
+	vehicleJobTimeout = 2 * time.Minute
+	// vehicleJobRetention is how long finished jobs can still be queried
+	vehicleJobRetention = time.Hour
+)
+
+// Vehicle job operations and statuses
+const (
+	vehicleOperationUpload  = "upload"
+	vehicleOperationCompare = "compare"
+
+	vehicleStatusConnecting  = "connecting"
+	vehicleStatusUploading   = "uploading"
+	vehicleStatusDownloading = "downloading"
+	vehicleStatusCompleted   = "completed"
+	vehicleStatusFailed      = "failed"
+)
+
+// VehicleJob tracks a mission upload to, or comparison with, a vehicle
+type VehicleJob struct {
+	ID          string               `json:"id"`
+	UserID      string               `json:"-"`
+	MissionID   string               `json:"missionId"`
+	Operation   string               `json:"operation"`
+	Connection  string               `json:"connection"`
+	Verify      bool                 `json:"verify,omitempty"`
+	Status      string               `json:"status"`
+	Done        int                  `json:"done"`
+	Total       int                  `json:"total"`
+	Vehicle     *mavlink.Vehicle     `json:"vehicle,omitempty"`
+	InSync      *bool                `json:"inSync,omitempty"`
+	Differences []mavlink.Difference `json:"differences,omitempty"`
+	Skipped     []string             `json:"skipped,omitempty"`
+	Error       string               `json:"error,omitempty"`
+	StartedAt   time.Time            `json:"startedAt"`
+	FinishedAt  *time.Time           `json:"finishedAt,omitempty"`
+}
+
+// finished reports whether the job has stopped running
+func (j *VehicleJob) finished() bool {
+	return j.Status == vehicleStatusCompleted || j.Status == vehicleStatusFailed
+}
+
+// vehicleRequest is the body of upload and compare requests
+type vehicleRequest struct {
+	Connection string `json:"connection"`
+	// Verify downloads the mission again after an upload and compares it
+	Verify bool `json:"verify"`
+}
+
+// VehicleHandler transfers missions to and from vehicles over MAVLink. Jobs
+// run in the background; clients poll GetVehicleJob for progress.
+type VehicleHandler struct {
+	missions *MissionHandler
+	// connections lists the links users may open, from MAVLINK_CONNECTIONS
+	connections map[string]bool
+	jobs        map[string]*VehicleJob
+	mutex       sync.RWMutex
+}
+
+// NewVehicleHandler creates a new vehicle handler. MAVLINK_CONNECTIONS is a
+// comma-separated list of permitted connection addresses, for example
+// "tcp://127.0.0.1:5760,serial:///dev/ttyACM0?baud=115200".
+func NewVehicleHandler(missions *MissionHandler) *VehicleHandler {
+	connections := make(map[string]bool)
+	for _, address := range strings.Split(os.Getenv("MAVLINK_CONNECTIONS"), ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			connections[address] = true
+		}
+	}
+	return &VehicleHandler{
+		missions:    missions,
+		connections: connections,
+		jobs:        make(map[string]*VehicleJob),
+	}
+}
+
+// UploadMission starts uploading a mission to a vehicle
+func (h *VehicleHandler) UploadMission(w http.ResponseWriter, r *http.Request) {
+	h.startJob(w, r, vehicleOperationUpload)
+}
+
+// CompareMission starts downloading a vehicle's mission and comparing it with a stored mission
+func (h *VehicleHandler) CompareMission(w http.ResponseWriter, r *http.Request) {
+	h.startJob(w, r, vehicleOperationCompare)
+}
+
+// GetVehicleJob returns the progress of an upload or comparison
+func (h *VehicleHandler) GetVehicleJob(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(string)
+	if !ok || userID == "" {
+		log.Printf("Auth error: userID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	h.mutex.RLock()
+	job, exists := h.jobs[mux.Vars(r)["jobId"]]
+	var snapshot VehicleJob
+	if exists {
+		snapshot = *job
+	}
+	h.mutex.RUnlock()
+
+	if !exists || snapshot.UserID != userID {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(snapshot)
+}
+
+// startJob validates the request and starts a background job for the mission
+func (h *VehicleHandler) startJob(w http.ResponseWriter, r *http.Request, operation string) {
+	var req vehicleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("Error decoding vehicle request: %v", err)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(h.connections) == 0 {
+		http.Error(w, "No vehicle connections are configured", http.StatusServiceUnavailable)
+		return
+	}
+	if !h.connections[req.Connection] {
+		log.Printf("Rejected vehicle connection %q", req.Connection)
+		http.Error(w, "Connection is not permitted", http.StatusForbidden)
+		return
+	}
+
+	mission, ok := h.missions.findUserMission(w, r)
+	if !ok {
+		return
+	}
+	compiled, ok := buildMAVLinkMission(w, mission)
+	if !ok {
+		return
+	}
+
+	job := &VehicleJob{
+		ID:         primitive.NewObjectID().Hex(),
+		UserID:     mission.UserID,
+		MissionID:  mission.ID.Hex(),
+		Operation:  operation,
+		Connection: req.Connection,
+		Verify:     req.Verify && operation == vehicleOperationUpload,
+		Status:     vehicleStatusConnecting,
+		Skipped:    compiled.Skipped,
+		StartedAt:  time.Now(),
+	}
+
+	h.mutex.Lock()
+	for id, existing := range h.jobs {
+		if existing.finished() && time.Since(*existing.FinishedAt) > vehicleJobRetention {
+			delete(h.jobs, id)
+			continue
+		}
+		if !existing.finished() && existing.Connection == job.Connection {
+			h.mutex.Unlock()
+			http.Error(w, "Another transfer is using this connection", http.StatusConflict)
+			return
+		}
+	}
+	h.jobs[job.ID] = job
+	snapshot := *job
+	h.mutex.Unlock()
+
+	log.Printf("Starting vehicle %s of mission %s over %s (job %s)", operation, job.MissionID, job.Connection, job.ID)
+	go h.run(job, compiled)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(snapshot)
+}
+
+// run performs a job and records its outcome
+func (h *VehicleHandler) run(job *VehicleJob, mission *mavlink.Mission) {
+	ctx, cancel := context.WithTimeout(context.Background(), vehicleJobTimeout)
+	defer cancel()
+
+	err := h.transfer(ctx, job, mission)
+	h.update(job, func(j *VehicleJob) {
+		now := time.Now()
+		j.FinishedAt = &now
+		if err != nil {
+			j.Status = vehicleStatusFailed
+			j.Error = err.Error()
+			return
+		}
+		j.Status = vehicleStatusCompleted
+	})
+
+	if err != nil {
+		log.Printf("Vehicle job %s failed: %v", job.ID, err)
+		return
+	}
+	log.Printf("Vehicle job %s completed", job.ID)
+}
+
+// transfer connects to the vehicle, uploads the mission if requested and
+// downloads it back for comparison
+func (h *VehicleHandler) transfer(ctx context.Context, job *VehicleJob, mission *mavlink.Mission) error {
+	conn, err := mavlink.Dial(job.Connection, vehicleDialTimeout)
+	if err != nil {
+		return err
+	}
+	client := mavlink.NewClient(conn)
+	defer client.Close()
+
+	vehicle, err := client.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	h.update(job, func(j *VehicleJob) { j.Vehicle = &vehicle })
+
+	items := mission.VehicleItems(vehicle.Autopilot)
+	if job.Operation == vehicleOperationUpload {
+		h.update(job, func(j *VehicleJob) {
+			j.Status = vehicleStatusUploading
+			j.Total = len(items)
+		})
+		if err := client.UploadMission(ctx, items, h.progress(job)); err != nil {
+			return err
+		}
+		if !job.Verify {
+			return nil
+		}
+	}
+
+	h.update(job, func(j *VehicleJob) {
+		j.Status = vehicleStatusDownloading
+		j.Done, j.Total = 0, 0
+	})
+	actual, err := client.DownloadMission(ctx, h.progress(job))
+	if err != nil {
+		return err
+	}
+
+	differences := mavlink.CompareItems(items, actual, vehicle.Autopilot)
+	inSync := len(differences) == 0
+	h.update(job, func(j *VehicleJob) {
+		j.Differences = differences
+		j.InSync = &inSync
+	})
+	return nil
+}
+
+// progress returns a callback recording transfer progress on the job
+func (h *VehicleHandler) progress(job *VehicleJob) mavlink.ProgressFunc {
+	return func(done, total int) {
+		h.update(job, func(j *VehicleJob) {
+			j.Done = done
+			j.Total = total
+		})
+	}
+}
+
+// update modifies a job while holding the handler's lock
+func (h *VehicleHandler) update(job *VehicleJob, change func(*VehicleJob)) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	change(job)
+}
@@ -0,0 +1,279 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"time"
+
+	"drone-planner/server/altitude"
+	"drone-planner/server/drones"
+	"drone-planner/server/energy"
+	"drone-planner/server/geometry"
+	"drone-planner/server/models"
+	"drone-planner/server/timeline"
+	"drone-planner/server/weather"
+)
+
+// WeatherHandler estimates and assesses missions in the forecast weather from
+// WEATHER_FILE or WEATHER_URL
+type WeatherHandler struct {
+	missions  *MissionHandler
+	altitudes *altitude.Converter
+	provider  weather.Provider
+}
+
+// NewWeatherHandler creates a weather handler. Without a weather provider,
+// requests must give the wind themselves.
+func NewWeatherHandler(missions *MissionHandler) *WeatherHandler {
+	h := &WeatherHandler{missions: missions, altitudes: sharedAltitudes()}
+	if path := os.Getenv("WEATHER_FILE"); path != "" {
+		provider, err := weather.NewFileProvider(path)
+		if err != nil {
+			log.Printf("Weather data unavailable: %v", err)
+		} else {
+			h.provider = provider
+		}
+	} else if url := os.Getenv("WEATHER_URL"); url != "" {
+		h.provider = weather.NewHTTPProvider(url)
+	}
+	return h
+}
+
+// windReport is a wind estimate with where its wind came from
+type windReport struct {
+	*energy.WindEstimate
+	// Source is "request" or "forecast"
+	Source       string     `json:"source"`
+	ForecastTime *time.Time `json:"forecastTime,omitempty"`
+}
+
+// EstimateMissionWind predicts the ground speeds, crab angles, durations and
+// battery use of a mission's waypoint mission in wind. The body gives a
+// constant "wind" or altitude "layers"; without either the forecast for
+// "time", or else the mission's date, is used. The "drone" and "threshold"
+// query parameters work as for the energy estimate.
+func (h *WeatherHandler) EstimateMissionWind(w http.ResponseWriter, r *http.Request) {
+	mission, ok := h.missions.findUserMission(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Wind   *weather.Wind       `json:"wind"`
+		Layers weather.WindProfile `json:"layers"`
+		Time   *time.Time          `json:"time"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	errs := timeline.NewFieldErrors("")
+	if req.Wind != nil && len(req.Layers) > 0 {
+		errs.Add("wind", "give either a wind or layers, not both")
+	}
+	if req.Wind != nil {
+		validateWind(errs.At("wind"), *req.Wind)
+	}
+	for i, layer := range req.Layers {
+		validateWind(errs.At(fmt.Sprintf("layers[%d]", i)), layer.Wind)
+	}
+	if err := errs.Err(); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	config, profile, options, ok := energyInputs(w, r, mission)
+	if !ok {
+		return
+	}
+	relative, err := h.altitudes.Mission(mission.GlobalSettings, config, models.AltitudeRelative)
+	if err != nil {
+		http.Error(w, "Failed to convert altitudes: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	report := windReport{Source: "request"}
+	wind := req.Layers
+	if req.Wind != nil {
+		wind = weather.Constant(*req.Wind)
+	}
+	if req.Wind == nil && len(req.Layers) == 0 {
+		if h.provider == nil {
+			http.Error(w, "Weather data is not configured; give the wind in the request", http.StatusServiceUnavailable)
+			return
+		}
+		at := missionTime(mission, req.Time)
+		hour, err := forecastHour(h.provider, options.Home.Latitude, options.Home.Longitude, at, at)
+		if err != nil {
+			log.Printf("Error fetching forecast: %v", err)
+			http.Error(w, "Failed to fetch forecast: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+		wind = hour.Wind
+		report.Source = "forecast"
+		report.ForecastTime = &hour.Time
+	}
+
+	report.WindEstimate, err = energy.EstimateWind(relative, profile, options, wind)
+	if err != nil {
+		log.Printf("Error estimating wind: %v", err)
+		http.Error(w, "Failed to estimate wind: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// weatherAssessment is the go/no-go verdict of a mission with the forecast
+// and thresholds it was reached from
+type weatherAssessment struct {
+	weather.Assessment
+	Start       time.Time          `json:"start"`
+	End         time.Time          `json:"end"`
+	MaxAltitude float64            `json:"maxAltitude"`
+	Thresholds  weather.Thresholds `json:"thresholds"`
+	Hours       []weather.Hour     `json:"hours"`
+}
+
+// AssessMissionWeather rates the forecast weather for a mission at its date,
+// or at ?time=, as go, caution or no-go with the reasons. Limits come from
+// the mission's drone type or ?drone=.
+func (h *WeatherHandler) AssessMissionWeather(w http.ResponseWriter, r *http.Request) {
+	if h.provider == nil {
+		http.Error(w, "Weather data is not configured", http.StatusServiceUnavailable)
+		return
+	}
+	mission, ok := h.missions.findUserMission(w, r)
+	if !ok {
+		return
+	}
+
+	start, ok := missionDate(w, r, mission)
+	if !ok {
+		return
+	}
+	droneType := mission.GlobalSettings.DroneType
+	if drone := r.URL.Query().Get("drone"); drone != "" {
+		droneType = drone
+	}
+	profile, _ := drones.Lookup(droneType)
+
+	// The place and height of the flight come from the waypoint mission
+	var config *models.WaypointMissionConfig
+	maxAltitude := 0.0
+	if decoded, err := mission.DecodeWaypointMission(); err == nil && len(decoded.Waypoints) > 0 {
+		config = decoded
+		if relative, err := h.altitudes.Mission(mission.GlobalSettings, decoded, models.AltitudeRelative); err == nil {
+			decoded = relative
+		}
+		for _, wp := range decoded.Waypoints {
+			maxAltitude = math.Max(maxAltitude, wp.Altitude)
+		}
+	}
+	settings := mission.GlobalSettings
+	if config == nil && (settings.HomeLat == nil || settings.HomeLng == nil) {
+		http.Error(w, "Mission has no home point or waypoints to forecast for", http.StatusUnprocessableEntity)
+		return
+	}
+	if config == nil {
+		config = &models.WaypointMissionConfig{}
+	}
+	home := geometry.HomePoint(settings, config)
+
+	end := start.Add(time.Duration(mission.Metadata.EstimatedDuration * float64(time.Second)))
+	forecast, err := h.provider.Forecast(home.Latitude, home.Longitude, start, end)
+	if err == nil && len(forecast.Hours) == 0 {
+		err = weather.ErrNoForecast
+	}
+	if err != nil {
+		log.Printf("Error fetching forecast: %v", err)
+		http.Error(w, "Failed to fetch forecast: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	hours := flightHours(forecast, start, end)
+	if len(hours) == 0 {
+		hour, err := forecast.At(start)
+		if err != nil {
+			http.Error(w, "Failed to fetch forecast: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+		hours = []weather.Hour{*hour}
+	}
+
+	thresholds := weather.DroneThresholds(profile)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(weatherAssessment{
+		Assessment:  weather.Assess(hours, thresholds, maxAltitude),
+		Start:       start,
+		End:         end,
+		MaxAltitude: maxAltitude,
+		Thresholds:  thresholds,
+		Hours:       hours,
+	})
+}
+
+// flightHours returns the forecast hours that overlap a flight, each hour
+// standing for the half hour either side of it
+func flightHours(forecast *weather.Forecast, start, end time.Time) []weather.Hour {
+	hours := []weather.Hour{}
+	for _, hour := range forecast.Hours {
+		if !hour.Time.Before(start.Add(-30*time.Minute)) && !hour.Time.After(end.Add(30*time.Minute)) {
+			hours = append(hours, hour)
+		}
+	}
+	return hours
+}
+
+// validateWind checks a wind's speed and direction
+func validateWind(errs timeline.FieldErrors, wind weather.Wind) {
+	if wind.Speed < 0 {
+		errs.Add("speed", "must not be negative")
+	}
+	errs.Between("direction", wind.Direction, 0, 360)
+}
+
+// missionDate returns when a mission flies: ?time= or else its date
+func missionDate(w http.ResponseWriter, r *http.Request, mission *models.Mission) (time.Time, bool) {
+	start := mission.Date
+	if value := r.URL.Query().Get("time"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			http.Error(w, "time must be an RFC 3339 timestamp", http.StatusBadRequest)
+			return start, false
+		}
+		start = parsed
+	}
+	if start.IsZero() {
+		http.Error(w, "Mission has no date; pass ?time= to choose one", http.StatusUnprocessableEntity)
+		return start, false
+	}
+	return start, true
+}
+
+// missionTime returns when a mission flies: the requested time, else the
+// mission's date, else now
+func missionTime(mission *models.Mission, requested *time.Time) time.Time {
+	switch {
+	case requested != nil:
+		return *requested
+	case !mission.Date.IsZero():
+		return mission.Date
+	}
+	return time.Now()
+}
+
+// forecastHour returns the forecast hour nearest a time at a place
+func forecastHour(provider weather.Provider, lat, lng float64, from, to time.Time) (*weather.Hour, error) {
+	forecast, err := provider.Forecast(lat, lng, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return forecast.At(from)
+}
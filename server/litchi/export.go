@@ -0,0 +1,225 @@
+package litchi
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"math"
+	"strconv"
+
+	"drone-planner/server/altitude"
+	"drone-planner/server/models"
+)
+
+// ExportFlight writes a flight as a Litchi CSV. Flight-level actions are
+// executed at every waypoint, after the waypoint's own actions. Altitudes
+// Litchi cannot fly are converted to relative altitudes.
+func ExportFlight(flight *models.Flight, altitudes *altitude.Converter) ([]byte, []string, error) {
+	if len(flight.Waypoints) < 2 {
+		return nil, nil, fmt.Errorf("flight must have at least 2 waypoints")
+	}
+	flight, err := altitudes.Flight(flight, altitude.Target(flight.AltitudeMode, altitudeModes...))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	speeds := make(map[string]float64, len(flight.SegmentSpeeds))
+	for _, segment := range flight.SegmentSpeeds {
+		speeds[strconv.FormatInt(segment.FromID, 10)] = segment.Speed
+	}
+
+	r := route{
+		FlightPathMode: flight.FlightpathMode,
+		HeadingMode:    "USING_WAYPOINT_HEADING",
+		AltitudeMode:   flight.AltitudeMode,
+	}
+	for _, wp := range flight.Waypoints {
+		if speed, ok := speeds[wp.ID]; ok {
+			wp.Speed = speed
+		}
+		actions := append([]models.WaypointAction{}, wp.Actions...)
+		for _, action := range flight.Actions {
+			actions = append(actions, models.WaypointAction{ActionType: action.ActionType, ActionParam: action.ActionParam})
+		}
+		wp.Actions = actions
+		if wp.GimbalPitch != 0 {
+			r.GimbalPitchRotationEnabled = true
+		}
+		r.Waypoints = append(r.Waypoints, wp)
+	}
+	return writeRoute(r)
+}
+
+// ExportMission writes the mission's waypoint mission as a Litchi CSV.
+// Altitudes Litchi cannot fly are converted to relative altitudes.
+func ExportMission(mission *models.Mission, altitudes *altitude.Converter) ([]byte, []string, error) {
+	config, err := mission.DecodeWaypointMission()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(config.Waypoints) < 2 {
+		return nil, nil, fmt.Errorf("waypoint mission must have at least 2 waypoints")
+	}
+	config, err = altitudes.Mission(mission.GlobalSettings, config, altitude.Target(config.AltitudeMode, altitudeModes...))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return writeRoute(route{
+		Waypoints:                  config.Waypoints,
+		Targets:                    config.Targets,
+		FlightPathMode:             config.FlightPathMode,
+		HeadingMode:                config.HeadingMode,
+		GimbalPitchRotationEnabled: config.GimbalPitchRotationEnabled,
+		AltitudeMode:               config.AltitudeMode,
+	})
+}
+
+// writeRoute encodes a route as CSV, returning notes about anything that was skipped
+func writeRoute(r route) ([]byte, []string, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(columns()); err != nil {
+		return nil, nil, fmt.Errorf("failed to write CSV header: %v", err)
+	}
+
+	var skipped []string
+	for i, wp := range r.Waypoints {
+		record, notes, err := waypointRecord(i, wp, r)
+		if err != nil {
+			return nil, nil, err
+		}
+		skipped = append(skipped, notes...)
+		if err := w.Write(record); err != nil {
+			return nil, nil, fmt.Errorf("failed to write waypoint %d: %v", i+1, err)
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, nil, fmt.Errorf("failed to write CSV: %v", err)
+	}
+	return buf.Bytes(), skipped, nil
+}
+
+// waypointRecord builds the CSV row for a waypoint
+func waypointRecord(index int, wp models.Waypoint, r route) ([]string, []string, error) {
+	endpoint := index == 0 || index == len(r.Waypoints)-1
+
+	curveSize := 0.0
+	if r.FlightPathMode == "CURVED" && !endpoint {
+		curveSize = math.Abs(wp.CornerRadius)
+	}
+	rotationDir := 0
+	if wp.TurnMode == "COUNTER_CLOCKWISE" {
+		rotationDir = 1
+	}
+
+	poi, hasPoi := waypointPoi(wp, r)
+	gimbalMode := gimbalDisabled
+	switch {
+	case r.GimbalPitchRotationEnabled:
+		gimbalMode = gimbalInterpolate
+	case hasPoi:
+		gimbalMode = gimbalFocusPOI
+	}
+
+	record := []string{
+		formatFloat(wp.Coordinate.Latitude),
+		formatFloat(wp.Coordinate.Longitude),
+		formatFloat(wp.Altitude),
+		formatFloat(wrap360(wp.Heading)),
+		formatFloat(curveSize),
+		strconv.Itoa(rotationDir),
+		strconv.Itoa(gimbalMode),
+		formatFloat(wp.GimbalPitch),
+	}
+
+	var skipped []string
+	written := 0
+	photoDistance := -1.0
+	for _, action := range wp.Actions {
+		// Litchi takes interval photos from a column rather than an action
+		if models.NormalizeActionType(action.ActionType) == models.ActionPhotoInterval {
+			photoDistance = action.ActionParam
+			continue
+		}
+		code, param, ok := litchiAction(action)
+		if !ok {
+			skipped = append(skipped, fmt.Sprintf("waypoint %d: action %q has no Litchi equivalent", index+1, action.ActionType))
+			continue
+		}
+		if written == maxActions {
+			return nil, nil, fmt.Errorf("waypoint %d has more than %d actions", index+1, maxActions)
+		}
+		record = append(record, strconv.Itoa(code), formatFloat(param))
+		written++
+	}
+	for ; written < maxActions; written++ {
+		record = append(record, strconv.Itoa(actionNone), "0")
+	}
+
+	poiLat, poiLng := 0.0, 0.0
+	if hasPoi {
+		poiLat, poiLng = poi.Lat, poi.Lng
+	}
+	altitudeMode := altitudeRelative
+	if r.AltitudeMode == models.AltitudeAGL {
+		altitudeMode = altitudeAGL
+	}
+	record = append(record,
+		strconv.Itoa(altitudeMode),
+		formatFloat(math.Max(wp.Speed, 0)),
+		formatFloat(poiLat),
+		formatFloat(poiLng),
+		"0",
+		strconv.Itoa(altitudeRelative),
+		"-1",
+		formatFloat(photoDistance),
+	)
+	return record, skipped, nil
+}
+
+// waypointPoi returns the POI a waypoint faces, if any
+func waypointPoi(wp models.Waypoint, r route) (models.Target, bool) {
+	if len(wp.Targets) > 0 {
+		return wp.Targets[0], true
+	}
+	if r.HeadingMode == "TOWARD_POINT_OF_INTEREST" && len(r.Targets) > 0 {
+		return r.Targets[0], true
+	}
+	return models.Target{}, false
+}
+
+// litchiAction maps a waypoint action to a Litchi action code and parameter
+func litchiAction(action models.WaypointAction) (int, float64, bool) {
+	switch models.NormalizeActionType(action.ActionType) {
+	case models.ActionHover:
+		// Litchi stays are in milliseconds
+		return actionStay, math.Round(action.ActionParam * 1000), true
+	case models.ActionTakePhoto:
+		return actionTakePhoto, 0, true
+	case models.ActionStartRecording:
+		return actionStartRecording, 0, true
+	case models.ActionStopRecording:
+		return actionStopRecording, 0, true
+	case models.ActionRotateAircraft:
+		return actionRotateAircraft, wrap360(action.ActionParam), true
+	case models.ActionRotateGimbal:
+		return actionTiltCamera, action.ActionParam, true
+	}
+	return 0, 0, false
+}
+
+// wrap360 wraps a heading into Litchi's 0..360 range
+func wrap360(heading float64) float64 {
+	heading = math.Mod(heading, 360)
+	if heading < 0 {
+		heading += 360
+	}
+	return heading
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
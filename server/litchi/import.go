@@ -0,0 +1,408 @@
+package litchi
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"drone-planner/server/geometry"
+	"drone-planner/server/models"
+	"drone-planner/server/timeline"
+)
+
+const (
+	// defaultCruiseSpeed is used as the auto flight speed since Litchi CSVs do not carry one (m/s)
+	defaultCruiseSpeed = 10.0
+	// maxLitchiSpeed is the fastest waypoint speed Litchi accepts (m/s)
+	maxLitchiSpeed = 15.0
+)
+
+// ImportFlight parses a Litchi CSV into a new flight. Actions shared by every
+// waypoint are stored as flight-level actions.
+func ImportFlight(data []byte) (*models.Flight, *ImportReport, error) {
+	r, report, err := readRoute(data)
+	if err != nil {
+		return nil, report, err
+	}
+
+	now := time.Now()
+	flight := &models.Flight{
+		Date:            now,
+		Waypoints:       r.Waypoints,
+		SegmentSpeeds:   []models.SegmentSpeed{},
+		MissionType:     "waypoint",
+		MaxFlightSpeed:  maxLitchiSpeed,
+		AutoFlightSpeed: defaultCruiseSpeed,
+		FinishedAction:  "NO_ACTION",
+		FlightpathMode:  r.FlightPathMode,
+		RepeatTimes:     1,
+		AltitudeMode:    r.AltitudeMode,
+		TurnMode:        r.Waypoints[0].TurnMode,
+		Actions:         []models.Action{},
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+
+	for i := 0; i < len(r.Waypoints)-1; i++ {
+		flight.SegmentSpeeds = append(flight.SegmentSpeeds, models.SegmentSpeed{
+			FromID:   int64(i + 1),
+			ToID:     int64(i + 2),
+			Speed:    r.Waypoints[i].Speed,
+			IsCurved: r.FlightPathMode == "CURVED" && r.Waypoints[i].CornerRadius > 0,
+		})
+	}
+
+	if shared := sharedActions(r.Waypoints); len(shared) > 0 {
+		for _, action := range shared {
+			flight.Actions = append(flight.Actions, models.Action{ActionType: action.ActionType, ActionParam: action.ActionParam})
+		}
+		for i := range flight.Waypoints {
+			flight.Waypoints[i].Actions = []models.WaypointAction{}
+		}
+	}
+	flight.Metadata = geometry.FlightMetadata(flight)
+	return flight, report, nil
+}
+
+// ImportMission parses a Litchi CSV into a new mission with a single waypoint-mission element
+func ImportMission(data []byte) (*models.Mission, *ImportReport, error) {
+	r, report, err := readRoute(data)
+	if err != nil {
+		return nil, report, err
+	}
+
+	config := models.WaypointMissionConfig{
+		AutoFlightSpeed:            defaultCruiseSpeed,
+		MaxFlightSpeed:             maxLitchiSpeed,
+		FinishedAction:             "NO_ACTION",
+		RepeatTimes:                1,
+		GlobalTurnMode:             "CLOCKWISE",
+		GimbalPitchRotationEnabled: r.GimbalPitchRotationEnabled,
+		HeadingMode:                r.HeadingMode,
+		FlightPathMode:             r.FlightPathMode,
+		AltitudeMode:               r.AltitudeMode,
+		Targets:                    r.Targets,
+		Waypoints:                  r.Waypoints,
+	}
+	if r.Waypoints[0].TurnMode != "" {
+		config.GlobalTurnMode = r.Waypoints[0].TurnMode
+	}
+
+	configMap, err := models.EncodeConfig(config)
+	if err != nil {
+		return nil, report, err
+	}
+
+	mission := models.NewMission("", "")
+	mission.AddTimelineElement(models.ElementWaypointMission, configMap)
+	mission.Metadata, err = timeline.Metadata(mission)
+	if err != nil {
+		return nil, report, err
+	}
+	return mission, report, nil
+}
+
+// readRoute parses and validates every row of a Litchi CSV
+func readRoute(data []byte) (*route, *ImportReport, error) {
+	report := &ImportReport{Errors: []RowIssue{}, Warnings: []RowIssue{}}
+
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, report, fmt.Errorf("failed to read CSV header: %v", err)
+	}
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"latitude", "longitude", "altitude(m)"} {
+		if _, ok := index[required]; !ok {
+			return nil, report, fmt.Errorf("CSV is missing the %q column", required)
+		}
+	}
+
+	r := &route{
+		Targets:        []models.Target{},
+		Waypoints:      []models.Waypoint{},
+		FlightPathMode: "NORMAL",
+		HeadingMode:    "USING_WAYPOINT_HEADING",
+	}
+	targets := map[[2]float64]models.Target{}
+	allPoi := true
+	// Rows with above-ground altitudes, by row number
+	var aboveGround []int
+
+	for rowNumber := 2; ; rowNumber++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			report.errorf(rowNumber, "", "malformed row: %v", err)
+			continue
+		}
+		if isBlank(record) {
+			continue
+		}
+
+		row := rowReader{record: record, index: index, number: rowNumber, report: report}
+		wp, poi, hasPoi, curved, gimbal := row.waypoint(len(r.Waypoints))
+		if !row.ok {
+			continue
+		}
+
+		if hasPoi {
+			key := [2]float64{poi.Lat, poi.Lng}
+			target, seen := targets[key]
+			if !seen {
+				n := len(r.Targets) + 1
+				target = models.Target{ID: fmt.Sprintf("poi-%d", n), Name: fmt.Sprintf("POI %d", n), Lat: poi.Lat, Lng: poi.Lng}
+				targets[key] = target
+				r.Targets = append(r.Targets, target)
+			}
+			wp.Targets = []models.Target{target}
+		} else {
+			allPoi = false
+		}
+		if curved {
+			r.FlightPathMode = "CURVED"
+		}
+		if gimbal {
+			r.GimbalPitchRotationEnabled = true
+		}
+		if row.aboveGround {
+			aboveGround = append(aboveGround, rowNumber)
+		}
+		r.Waypoints = append(r.Waypoints, wp)
+	}
+
+	if len(report.Errors) > 0 {
+		return nil, report, &InvalidRowsError{Count: len(report.Errors)}
+	}
+	if len(r.Waypoints) < 2 {
+		return nil, report, fmt.Errorf("at least 2 waypoints are required (found %d)", len(r.Waypoints))
+	}
+	if allPoi {
+		r.HeadingMode = "TOWARD_POINT_OF_INTEREST"
+	}
+	// A route has a single altitude mode, so mixed routes keep the rows as
+	// relative altitudes
+	r.AltitudeMode = models.AltitudeRelative
+	if len(aboveGround) == len(r.Waypoints) {
+		r.AltitudeMode = models.AltitudeAGL
+	} else {
+		for _, row := range aboveGround {
+			report.warnf(row, "altitudemode", "above-ground altitude imported as relative to takeoff")
+		}
+	}
+	report.Waypoints = len(r.Waypoints)
+	return r, report, nil
+}
+
+// rowReader reads typed values from a single CSV record, recording
+// validation problems against its row number
+type rowReader struct {
+	record []string
+	index  map[string]int
+	number int
+	report *ImportReport
+	ok     bool
+	// aboveGround is set when the row's altitude is above ground level
+	aboveGround bool
+}
+
+// waypoint converts the row into a waypoint, returning its POI and whether it
+// uses a curved turn or an explicit gimbal pitch
+func (r *rowReader) waypoint(position int) (models.Waypoint, models.Target, bool, bool, bool) {
+	r.ok = true
+
+	lat := r.float("latitude", 0, -90, 90)
+	lng := r.float("longitude", 0, -180, 180)
+	if r.ok && lat == 0 && lng == 0 {
+		r.fail("latitude", "coordinates 0,0 are not a valid waypoint")
+	}
+	altitude := r.float("altitude(m)", 0, -200, 500)
+	heading := r.float("heading(deg)", 0, -360, 360)
+	curveSize := r.float("curvesize(m)", 0, 0, math.Inf(1))
+	rotationDir := r.int("rotationdir", 0, 0, 1)
+	gimbalMode := r.int("gimbalmode", gimbalDisabled, gimbalDisabled, gimbalInterpolate)
+	gimbalPitch := r.float("gimbalpitchangle", 0, -90, 30)
+	altitudeMode := r.int("altitudemode", altitudeRelative, altitudeRelative, altitudeAGL)
+	speed := r.float("speed(m/s)", 0, 0, math.Inf(1))
+	poiLat := r.float("poi_latitude", 0, -90, 90)
+	poiLng := r.float("poi_longitude", 0, -180, 180)
+	photoTime := r.float("photo_timeinterval", -1, math.Inf(-1), math.Inf(1))
+	photoDist := r.float("photo_distinterval", -1, math.Inf(-1), math.Inf(1))
+
+	r.aboveGround = altitudeMode == altitudeAGL
+	if speed > maxLitchiSpeed {
+		r.report.warnf(r.number, "speed(m/s)", "speed %.1f m/s exceeds Litchi's %.0f m/s limit", speed, maxLitchiSpeed)
+	}
+	if photoTime > 0 {
+		r.report.warnf(r.number, "photo_timeinterval", "timed interval photos are not supported and were skipped")
+	}
+
+	actions := []models.WaypointAction{}
+	if photoDist > 0 {
+		actions = append(actions, models.WaypointAction{ActionType: models.ActionPhotoInterval, ActionParam: photoDist})
+	}
+	for i := 1; i <= maxActions; i++ {
+		typeColumn := fmt.Sprintf("actiontype%d", i)
+		paramColumn := fmt.Sprintf("actionparam%d", i)
+		code := r.int(typeColumn, actionNone, actionNone, actionTiltCamera)
+		param := r.float(paramColumn, 0, math.Inf(-1), math.Inf(1))
+		if code == actionNone {
+			continue
+		}
+		action, err := plannerAction(code, param)
+		if err != nil {
+			r.fail(paramColumn, "%v", err)
+			continue
+		}
+		actions = append(actions, action)
+	}
+
+	turnMode := "CLOCKWISE"
+	if rotationDir == 1 {
+		turnMode = "COUNTER_CLOCKWISE"
+	}
+	wp := models.Waypoint{
+		ID:           strconv.Itoa(position + 1),
+		Coordinate:   models.Coordinate{Latitude: lat, Longitude: lng},
+		Altitude:     altitude,
+		Heading:      wrap180(heading),
+		GimbalPitch:  gimbalPitch,
+		Speed:        speed,
+		CornerRadius: curveSize,
+		TurnMode:     turnMode,
+		Targets:      []models.Target{},
+		Actions:      actions,
+	}
+
+	hasPoi := poiLat != 0 || poiLng != 0
+	poi := models.Target{Lat: poiLat, Lng: poiLng}
+	return wp, poi, hasPoi, curveSize > 0, gimbalMode == gimbalInterpolate
+}
+
+// value returns the raw value of a column, or "" when the column is absent
+func (r *rowReader) value(column string) string {
+	i, ok := r.index[column]
+	if !ok || i >= len(r.record) {
+		return ""
+	}
+	return strings.TrimSpace(r.record[i])
+}
+
+// float parses a numeric column, using fallback when it is empty
+func (r *rowReader) float(column string, fallback, min, max float64) float64 {
+	raw := r.value(column)
+	if raw == "" {
+		return fallback
+	}
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		r.fail(column, "%q is not a number", raw)
+		return fallback
+	}
+	if value < min || value > max {
+		r.fail(column, "%s is outside the allowed range %s..%s", raw, formatFloat(min), formatFloat(max))
+		return fallback
+	}
+	return value
+}
+
+// int parses an integer enum column, using fallback when it is empty
+func (r *rowReader) int(column string, fallback, min, max int) int {
+	raw := r.value(column)
+	if raw == "" {
+		return fallback
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		r.fail(column, "%q is not an integer", raw)
+		return fallback
+	}
+	if value < min || value > max {
+		r.fail(column, "%d is not a supported value (%d..%d)", value, min, max)
+		return fallback
+	}
+	return value
+}
+
+func (r *rowReader) fail(column, format string, args ...interface{}) {
+	r.ok = false
+	r.report.errorf(r.number, column, format, args...)
+}
+
+// plannerAction maps a Litchi action code and parameter to a waypoint action
+func plannerAction(code int, param float64) (models.WaypointAction, error) {
+	switch code {
+	case actionStay:
+		if param < 0 || param > 32000 {
+			return models.WaypointAction{}, fmt.Errorf("stay time must be between 0 and 32000 ms")
+		}
+		return models.WaypointAction{ActionType: models.ActionHover, ActionParam: param / 1000}, nil
+	case actionTakePhoto:
+		return models.WaypointAction{ActionType: models.ActionTakePhoto}, nil
+	case actionStartRecording:
+		return models.WaypointAction{ActionType: models.ActionStartRecording}, nil
+	case actionStopRecording:
+		return models.WaypointAction{ActionType: models.ActionStopRecording}, nil
+	case actionRotateAircraft:
+		if param < -360 || param > 360 {
+			return models.WaypointAction{}, fmt.Errorf("aircraft heading must be between -360 and 360 degrees")
+		}
+		return models.WaypointAction{ActionType: models.ActionRotateAircraft, ActionParam: wrap180(param)}, nil
+	case actionTiltCamera:
+		if param < -90 || param > 30 {
+			return models.WaypointAction{}, fmt.Errorf("camera tilt must be between -90 and 30 degrees")
+		}
+		return models.WaypointAction{ActionType: models.ActionRotateGimbal, ActionParam: param}, nil
+	}
+	return models.WaypointAction{}, fmt.Errorf("unsupported action type %d", code)
+}
+
+// sharedActions returns the action list when every waypoint has the same non-empty actions
+func sharedActions(waypoints []models.Waypoint) []models.WaypointAction {
+	first := waypoints[0].Actions
+	if len(first) == 0 {
+		return nil
+	}
+	for _, wp := range waypoints[1:] {
+		if len(wp.Actions) != len(first) {
+			return nil
+		}
+		for i, action := range wp.Actions {
+			if action != first[i] {
+				return nil
+			}
+		}
+	}
+	return first
+}
+
+// wrap180 wraps a heading into the planner's -180..180 range
+func wrap180(heading float64) float64 {
+	heading = wrap360(heading)
+	if heading > 180 {
+		heading -= 360
+	}
+	return heading
+}
+
+func isBlank(record []string) bool {
+	for _, value := range record {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,99 @@
+// Package litchi reads and writes Litchi Mission Hub CSV waypoint files for
+// both flights and the waypoint-mission element of missions.
+package litchi
+
+import (
+	"fmt"
+
+	"drone-planner/server/models"
+)
+
+// maxActions is the number of action columns in a Litchi CSV row
+const maxActions = 15
+
+// Litchi action type codes
+const (
+	actionNone           = -1
+	actionStay           = 0
+	actionTakePhoto      = 1
+	actionStartRecording = 2
+	actionStopRecording  = 3
+	actionRotateAircraft = 4
+	actionTiltCamera     = 5
+)
+
+// Litchi gimbal modes
+const (
+	gimbalDisabled    = 0
+	gimbalFocusPOI    = 1
+	gimbalInterpolate = 2
+)
+
+// Litchi altitude modes
+const (
+	altitudeRelative = 0
+	altitudeAGL      = 1
+)
+
+// altitudeModes are the planner altitude modes Litchi can fly, the first
+// being the one other modes are converted to
+var altitudeModes = []string{models.AltitudeRelative, models.AltitudeAGL}
+
+// columns returns the Litchi CSV header in file order
+func columns() []string {
+	header := []string{
+		"latitude", "longitude", "altitude(m)", "heading(deg)", "curvesize(m)",
+		"rotationdir", "gimbalmode", "gimbalpitchangle",
+	}
+	for i := 1; i <= maxActions; i++ {
+		header = append(header, fmt.Sprintf("actiontype%d", i), fmt.Sprintf("actionparam%d", i))
+	}
+	return append(header,
+		"altitudemode", "speed(m/s)", "poi_latitude", "poi_longitude", "poi_altitude(m)",
+		"poi_altitudemode", "photo_timeinterval", "photo_distinterval",
+	)
+}
+
+// route is the planner-neutral form of a Litchi mission shared by flights and missions
+type route struct {
+	Waypoints                  []models.Waypoint
+	Targets                    []models.Target
+	FlightPathMode             string
+	HeadingMode                string
+	GimbalPitchRotationEnabled bool
+	// AltitudeMode is models.AltitudeRelative or models.AltitudeAGL
+	AltitudeMode string
+}
+
+// RowIssue is a problem found in a single CSV row. Row numbers are 1-based and
+// count the header, so they match what a spreadsheet shows.
+type RowIssue struct {
+	Row     int    `json:"row"`
+	Column  string `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+// ImportReport describes the outcome of a CSV import
+type ImportReport struct {
+	Waypoints int        `json:"waypoints"`
+	Errors    []RowIssue `json:"errors"`
+	Warnings  []RowIssue `json:"warnings"`
+}
+
+func (r *ImportReport) errorf(row int, column, format string, args ...interface{}) {
+	r.Errors = append(r.Errors, RowIssue{Row: row, Column: column, Message: fmt.Sprintf(format, args...)})
+}
+
+func (r *ImportReport) warnf(row int, column, format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, RowIssue{Row: row, Column: column, Message: fmt.Sprintf(format, args...)})
+}
+
+// InvalidRowsError is returned when one or more rows fail validation; the
+// details are in the import report
+type InvalidRowsError struct {
+	Count int
+}
+
+func (e *InvalidRowsError) Error() string {
+	return fmt.Sprintf("CSV contains %d invalid values", e.Count)
+}
@@ -0,0 +1,209 @@
+package litchi
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"drone-planner/server/altitude"
+	"drone-planner/server/models"
+)
+
+// testWaypoints is a three waypoint route whose first waypoint carries actions
+func testWaypoints(actions []models.WaypointAction) []models.Waypoint {
+	return []models.Waypoint{
+		{Coordinate: models.Coordinate{Latitude: 47.3769, Longitude: 8.5417}, Altitude: 40, Heading: -90, GimbalPitch: -30, Speed: 6, Actions: actions},
+		{Coordinate: models.Coordinate{Latitude: 47.3779, Longitude: 8.5427}, Altitude: 55, Heading: 45, GimbalPitch: -45, Speed: 8, CornerRadius: 5},
+		{Coordinate: models.Coordinate{Latitude: 47.3789, Longitude: 8.5417}, Altitude: 70, Heading: 180, GimbalPitch: -60},
+	}
+}
+
+func TestMissionRoundTrip(t *testing.T) {
+	tests := []struct {
+		name           string
+		actions        []models.WaypointAction
+		altitudeMode   string
+		flightPathMode string
+	}{
+		{"no actions", nil, models.AltitudeRelative, "NORMAL"},
+		{"camera actions", []models.WaypointAction{
+			{ActionType: models.ActionTakePhoto},
+			{ActionType: models.ActionStartRecording},
+			{ActionType: models.ActionStopRecording},
+		}, models.AltitudeRelative, "NORMAL"},
+		{"hover, turn and tilt", []models.WaypointAction{
+			{ActionType: models.ActionHover, ActionParam: 2.5},
+			{ActionType: models.ActionRotateAircraft, ActionParam: -90},
+			{ActionType: models.ActionRotateGimbal, ActionParam: -45},
+		}, models.AltitudeRelative, "NORMAL"},
+		{"photo interval", []models.WaypointAction{{ActionType: models.ActionPhotoInterval, ActionParam: 20}}, models.AltitudeRelative, "NORMAL"},
+		{"above ground", nil, models.AltitudeAGL, "NORMAL"},
+		{"curved", nil, models.AltitudeRelative, "CURVED"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := testWaypoints(tt.actions)
+			config, err := models.EncodeConfig(models.WaypointMissionConfig{
+				AutoFlightSpeed:            8,
+				MaxFlightSpeed:             12,
+				HeadingMode:                "USING_WAYPOINT_HEADING",
+				FlightPathMode:             tt.flightPathMode,
+				GimbalPitchRotationEnabled: true,
+				AltitudeMode:               tt.altitudeMode,
+				Waypoints:                  want,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			mission := models.NewMission("", "")
+			mission.AddTimelineElement(models.ElementWaypointMission, config)
+
+			data, skipped, err := ExportMission(mission, &altitude.Converter{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(skipped) > 0 {
+				t.Errorf("skipped on export: %v", skipped)
+			}
+			imported, report, err := ImportMission(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(report.Errors) > 0 || len(report.Warnings) > 0 {
+				t.Errorf("import reported %+v", report)
+			}
+			got, err := imported.DecodeWaypointMission()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got.AltitudeMode != tt.altitudeMode || got.FlightPathMode != tt.flightPathMode || !got.GimbalPitchRotationEnabled {
+				t.Errorf("got altitude mode %q, path mode %q and gimbal rotation %v", got.AltitudeMode, got.FlightPathMode, got.GimbalPitchRotationEnabled)
+			}
+			if len(got.Waypoints) != len(want) {
+				t.Fatalf("got %d waypoints, want %d", len(got.Waypoints), len(want))
+			}
+			for i, wp := range got.Waypoints {
+				w := want[i]
+				if wp.Coordinate != w.Coordinate || wp.Altitude != w.Altitude || wp.Heading != w.Heading || wp.GimbalPitch != w.GimbalPitch || wp.Speed != w.Speed {
+					t.Errorf("waypoint %d = %+v, want %+v", i, wp, w)
+				}
+				// Only curved routes keep corners, and never at their ends
+				radius := 0.0
+				if tt.flightPathMode == "CURVED" && i == 1 {
+					radius = w.CornerRadius
+				}
+				if wp.CornerRadius != radius {
+					t.Errorf("waypoint %d corner radius = %g, want %g", i, wp.CornerRadius, radius)
+				}
+				if len(wp.Actions) != len(w.Actions) {
+					t.Errorf("waypoint %d actions = %+v, want %+v", i, wp.Actions, w.Actions)
+				}
+			}
+			for _, action := range tt.actions {
+				if !hasAction(got.Waypoints[0].Actions, action) {
+					t.Errorf("first waypoint lost %+v: got %+v", action, got.Waypoints[0].Actions)
+				}
+			}
+		})
+	}
+}
+
+func TestFlightRoundTrip(t *testing.T) {
+	flight := &models.Flight{
+		Waypoints: testWaypoints(nil),
+		SegmentSpeeds: []models.SegmentSpeed{
+			{FromID: 1, ToID: 2, Speed: 6},
+			{FromID: 2, ToID: 3, Speed: 8},
+		},
+		FlightpathMode: "NORMAL",
+		AltitudeMode:   models.AltitudeRelative,
+		Actions:        []models.Action{{ActionType: models.ActionTakePhoto}},
+	}
+	for i := range flight.Waypoints {
+		flight.Waypoints[i].ID = strconv.Itoa(i + 1)
+	}
+
+	data, _, err := ExportFlight(flight, &altitude.Converter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported, _, err := ImportFlight(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(imported.Actions) != 1 || imported.Actions[0].ActionType != models.ActionTakePhoto {
+		t.Errorf("flight actions = %+v, want a photo at every waypoint", imported.Actions)
+	}
+	for i, wp := range imported.Waypoints {
+		if len(wp.Actions) != 0 {
+			t.Errorf("waypoint %d kept the flight-level actions %+v", i, wp.Actions)
+		}
+	}
+	if len(imported.SegmentSpeeds) != 2 || imported.SegmentSpeeds[0].Speed != 6 || imported.SegmentSpeeds[1].Speed != 8 {
+		t.Errorf("segment speeds = %+v, want 6 and 8 m/s", imported.SegmentSpeeds)
+	}
+}
+
+func TestImportInvalidRows(t *testing.T) {
+	header := strings.Join(columns(), ",")
+	tests := []struct {
+		name   string
+		rows   []string
+		column string
+	}{
+		{"latitude out of range", []string{"91,8.5,40", "47,8.5,40"}, "latitude"},
+		{"null island", []string{"0,0,40", "47,8.5,40"}, "latitude"},
+		{"not a number", []string{"47,8.5,high", "47,8.6,40"}, "altitude(m)"},
+		{"unknown action", []string{"47,8.5,40,0,0,0,0,0,9,0", "47,8.6,40"}, "actiontype1"},
+		{"camera tilt out of range", []string{"47,8.5,40,0,0,0,0,0,5,45", "47,8.6,40"}, "actionparam1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := header + "\n" + strings.Join(tt.rows, "\n") + "\n"
+			_, report, err := ImportMission([]byte(data))
+			var invalid *InvalidRowsError
+			if !errors.As(err, &invalid) {
+				t.Fatalf("got error %v, want an InvalidRowsError", err)
+			}
+			if len(report.Errors) == 0 || report.Errors[0].Row != 2 || report.Errors[0].Column != tt.column {
+				t.Errorf("errors = %+v, want row 2 column %q", report.Errors, tt.column)
+			}
+		})
+	}
+}
+
+// hasAction reports whether actions include one with the same type and parameter
+func hasAction(actions []models.WaypointAction, want models.WaypointAction) bool {
+	for _, action := range actions {
+		if action.ActionType == want.ActionType && action.ActionParam == want.ActionParam {
+			return true
+		}
+	}
+	return false
+}
+
+func TestImportFlightCurves(t *testing.T) {
+	flight := &models.Flight{
+		Waypoints:      testWaypoints(nil),
+		FlightpathMode: "CURVED",
+		AltitudeMode:   models.AltitudeRelative,
+	}
+	for i := range flight.Waypoints {
+		flight.Waypoints[i].ID = strconv.Itoa(i + 1)
+	}
+	data, _, err := ExportFlight(flight, &altitude.Converter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported, _, err := ImportFlight(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only the segment leaving the rounded second waypoint curves, as in
+	// geometry.WaypointLegs
+	if len(imported.SegmentSpeeds) != 2 || imported.SegmentSpeeds[0].IsCurved || !imported.SegmentSpeeds[1].IsCurved {
+		t.Errorf("segments = %+v, want the second curved", imported.SegmentSpeeds)
+	}
+}
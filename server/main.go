@@ -101,6 +101,7 @@ func main() {
 	db := client.Database("drone_planner")
 	flightsCollection := db.Collection("flights")
 	missionsCollection := db.Collection("missions")  // Add missions collection
+	geofencesCollection := db.Collection("geofences")
 	log.Println("Database and collection initialized")
 
 	// Create router
@@ -108,9 +109,20 @@ func main() {
 	log.Println("Router created")
 
 	// Create handlers
-	flightHandler := handlers.NewFlightHandler(flightsCollection)
+	geofenceHandler := handlers.NewGeofenceHandler(geofencesCollection)
+	flightHandler := handlers.NewFlightHandler(flightsCollection, geofenceHandler)
 	timezoneHandler := handlers.NewTimezoneHandler()
-	missionHandler := handlers.NewMissionHandler(missionsCollection)
+	missionHandler := handlers.NewMissionHandler(missionsCollection, geofenceHandler)
+	vehicleHandler := handlers.NewVehicleHandler(missionHandler)
+	droneHandler := handlers.NewDroneHandler()
+	terrainHandler := handlers.NewTerrainHandler(missionHandler)
+	airspaceHandler := handlers.NewAirspaceHandler(missionHandler)
+	generatorHandler := handlers.NewGeneratorHandler(flightHandler)
+	cameraHandler := handlers.NewCameraHandler()
+	coverageHandler := handlers.NewCoverageHandler(missionHandler)
+	simulatorHandler := handlers.NewSimulatorHandler(missionHandler, flightHandler)
+	weatherHandler := handlers.NewWeatherHandler(missionHandler)
+	sunHandler := handlers.NewSunHandler(missionHandler)
 	log.Println("Handlers initialized")
 
 	
@@ -142,16 +154,66 @@ func main() {
 	// Flight routes
 	api.HandleFunc("/flights", flightHandler.CreateFlight).Methods("POST")
 	api.HandleFunc("/flights", flightHandler.GetFlights).Methods("GET")
+	api.HandleFunc("/flights/import/litchi", flightHandler.ImportFlightLitchi).Methods("POST")
 	api.HandleFunc("/flights/{id}", flightHandler.GetFlight).Methods("GET")
 	api.HandleFunc("/flights/{id}", flightHandler.UpdateFlight).Methods("PUT")
 	api.HandleFunc("/flights/{id}", flightHandler.DeleteFlight).Methods("DELETE")
+	api.HandleFunc("/flights/{id}/export/litchi", flightHandler.ExportFlightLitchi).Methods("GET")
+	api.HandleFunc("/flights/{id}/trajectory", simulatorHandler.GetFlightTrajectory).Methods("GET")
 
 	// Mission routes (new)
 	api.HandleFunc("/missions", missionHandler.CreateMission).Methods("POST")
 	api.HandleFunc("/missions", missionHandler.GetMissions).Methods("GET")
+	api.HandleFunc("/missions/import/kmz", missionHandler.ImportMissionKMZ).Methods("POST")
+	api.HandleFunc("/missions/import/litchi", missionHandler.ImportMissionLitchi).Methods("POST")
 	api.HandleFunc("/missions/{id}", missionHandler.GetMission).Methods("GET")
 	api.HandleFunc("/missions/{id}", missionHandler.UpdateMission).Methods("PUT")
 	api.HandleFunc("/missions/{id}", missionHandler.DeleteMission).Methods("DELETE")
+	api.HandleFunc("/missions/{id}/export/kmz", missionHandler.ExportMissionKMZ).Methods("GET")
+	api.HandleFunc("/missions/{id}/export/litchi", missionHandler.ExportMissionLitchi).Methods("GET")
+	api.HandleFunc("/missions/{id}/export/qgc", missionHandler.ExportMissionQGC).Methods("GET")
+	api.HandleFunc("/missions/{id}/export/wpl", missionHandler.ExportMissionWPL).Methods("GET")
+	api.HandleFunc("/missions/{id}/export/czml", missionHandler.ExportMissionCZML).Methods("GET")
+	api.HandleFunc("/missions/{id}/energy", missionHandler.GetMissionEnergy).Methods("GET")
+	api.HandleFunc("/missions/{id}/wind", weatherHandler.EstimateMissionWind).Methods("POST")
+	api.HandleFunc("/missions/{id}/weather", weatherHandler.AssessMissionWeather).Methods("GET")
+	api.HandleFunc("/missions/{id}/sun", sunHandler.GetMissionSun).Methods("GET")
+	api.HandleFunc("/missions/{id}/sorties", missionHandler.SplitMissionSorties).Methods("GET")
+	api.HandleFunc("/missions/{id}/terrain", terrainHandler.GetMissionTerrain).Methods("GET")
+	api.HandleFunc("/missions/{id}/airspace-check", airspaceHandler.CheckMissionAirspace).Methods("POST")
+	api.HandleFunc("/missions/{id}/coverage", coverageHandler.GetMissionCoverage).Methods("POST")
+	api.HandleFunc("/missions/{id}/trajectory", simulatorHandler.GetMissionTrajectory).Methods("GET")
+	api.HandleFunc("/missions/{id}/dry-run", simulatorHandler.DryRunMission).Methods("POST")
+	api.HandleFunc("/timeline/element-types", missionHandler.GetElementTypes).Methods("GET")
+
+	// Vehicle routes
+	api.HandleFunc("/missions/{id}/vehicle/upload", vehicleHandler.UploadMission).Methods("POST")
+	api.HandleFunc("/missions/{id}/vehicle/compare", vehicleHandler.CompareMission).Methods("POST")
+	api.HandleFunc("/vehicle/jobs/{jobId}", vehicleHandler.GetVehicleJob).Methods("GET")
+
+	// Geofence routes
+	api.HandleFunc("/geofences", geofenceHandler.CreateGeofence).Methods("POST")
+	api.HandleFunc("/geofences", geofenceHandler.GetGeofences).Methods("GET")
+	api.HandleFunc("/geofences/{id}", geofenceHandler.GetGeofence).Methods("GET")
+	api.HandleFunc("/geofences/{id}", geofenceHandler.UpdateGeofence).Methods("PUT")
+	api.HandleFunc("/geofences/{id}", geofenceHandler.DeleteGeofence).Methods("DELETE")
+	api.HandleFunc("/geofences/{id}/export/ardupilot", geofenceHandler.ExportGeofenceArduPilot).Methods("GET")
+
+	// Drone profile routes
+	api.HandleFunc("/drones", droneHandler.GetDrones).Methods("GET")
+	api.HandleFunc("/drones/{id}", droneHandler.GetDrone).Methods("GET")
+
+	// Camera profile and photogrammetry routes
+	api.HandleFunc("/cameras", cameraHandler.GetCameras).Methods("GET")
+	api.HandleFunc("/cameras/{id}", cameraHandler.GetCamera).Methods("GET")
+	api.HandleFunc("/photogrammetry/calc", cameraHandler.Calculate).Methods("POST")
+
+	// Pattern generator routes
+	api.HandleFunc("/generators/survey", generatorHandler.GenerateSurvey).Methods("POST")
+	api.HandleFunc("/generators/corridor", generatorHandler.GenerateCorridor).Methods("POST")
+	api.HandleFunc("/generators/orbit", generatorHandler.GenerateOrbit).Methods("POST")
+	api.HandleFunc("/generators/vertical-orbit", generatorHandler.GenerateVerticalOrbit).Methods("POST")
+	api.HandleFunc("/generators/facade", generatorHandler.GenerateFacade).Methods("POST")
 
 	// Add auth middleware to API routes
 	api.Use(handlers.AuthMiddleware)
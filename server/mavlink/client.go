@@ -0,0 +1,307 @@
+package mavlink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Ground station identity used for outgoing messages
+const (
+	gcsSystemID = 255
+	// gcsComponentID is MAV_COMP_ID_MISSIONPLANNER
+	gcsComponentID = 190
+)
+
+const (
+	heartbeatTimeout  = 5 * time.Second
+	heartbeatInterval = time.Second
+	// retryTimeout is how long to wait for a reply before resending a message
+	retryTimeout = 1500 * time.Millisecond
+	maxRetries   = 5
+)
+
+// ErrTimeout is returned when the vehicle stops answering
+var ErrTimeout = errors.New("timed out waiting for the vehicle")
+
+// Vehicle identifies the flight controller at the other end of a link
+type Vehicle struct {
+	SystemID    uint8 `json:"systemId"`
+	ComponentID uint8 `json:"componentId"`
+	Autopilot   int   `json:"autopilot"`
+}
+
+// ProgressFunc is called as mission items are transferred
+type ProgressFunc func(done, total int)
+
+// Client speaks the MAVLink v2 mission protocol with a single vehicle
+type Client struct {
+	conn    io.ReadWriteCloser
+	frames  chan Frame
+	done    chan struct{}
+	readErr error
+	vehicle Vehicle
+
+	writeMu sync.Mutex
+	seq     uint8
+
+	closeOnce sync.Once
+}
+
+// NewClient starts reading frames from a link opened with Dial
+func NewClient(conn io.ReadWriteCloser) *Client {
+	c := &Client{
+		conn:   conn,
+		frames: make(chan Frame, 64),
+		done:   make(chan struct{}),
+	}
+	go c.readLoop()
+	return c
+}
+
+// Close stops the client and closes the link
+func (c *Client) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.done)
+		err = c.conn.Close()
+	})
+	return err
+}
+
+// Connect starts sending ground station heartbeats and waits for a flight
+// controller heartbeat to identify the vehicle
+func (c *Client) Connect(ctx context.Context) (Vehicle, error) {
+	go c.heartbeatLoop()
+
+	f, err := c.next(ctx, heartbeatTimeout, func(f Frame) bool {
+		if f.MessageID != msgHeartbeat {
+			return false
+		}
+		hb := decodeHeartbeat(f.Payload)
+		return hb.Type != mavTypeGCS && hb.Autopilot != autopilotInvalid
+	})
+	if err == ErrTimeout {
+		return Vehicle{}, fmt.Errorf("no heartbeat received from a flight controller")
+	}
+	if err != nil {
+		return Vehicle{}, err
+	}
+
+	c.vehicle = Vehicle{
+		SystemID:    f.SystemID,
+		ComponentID: f.ComponentID,
+		Autopilot:   int(decodeHeartbeat(f.Payload).Autopilot),
+	}
+	return c.vehicle, nil
+}
+
+// UploadMission replaces the vehicle's mission with items using the
+// MISSION_COUNT / MISSION_REQUEST_INT / MISSION_ITEM_INT handshake. The
+// vehicle drives the transfer by requesting each item.
+func (c *Client) UploadMission(ctx context.Context, items []MissionItem, progress ProgressFunc) error {
+	total := len(items)
+	lastID, last := uint32(msgMissionCount), encodeMissionCount(total, c.vehicle)
+	if err := c.send(lastID, last); err != nil {
+		return err
+	}
+
+	sent, retries := 0, 0
+	for {
+		f, err := c.next(ctx, retryTimeout, c.replyMatcher(msgMissionRequestInt, msgMissionRequest, msgMissionAck))
+		if err == ErrTimeout && retries < maxRetries {
+			retries++
+			if err := c.send(lastID, last); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("upload stopped after %d of %d items: %v", sent, total, err)
+		}
+		retries = 0
+
+		if f.MessageID == msgMissionAck {
+			result, missionType := decodeMissionAck(f.Payload)
+			if missionType != missionTypeMission {
+				continue
+			}
+			if result != missionAccepted {
+				return &MissionResultError{Result: result}
+			}
+			if sent < total {
+				return fmt.Errorf("vehicle accepted the mission after only %d of %d items", sent, total)
+			}
+			return nil
+		}
+
+		seq, missionType := decodeMissionRequest(f.Payload)
+		if missionType != missionTypeMission {
+			continue
+		}
+		if seq >= total {
+			return fmt.Errorf("vehicle requested item %d of a %d item mission", seq, total)
+		}
+		lastID, last = msgMissionItemInt, encodeMissionItemInt(seq, items[seq], c.vehicle)
+		if err := c.send(lastID, last); err != nil {
+			return err
+		}
+		if seq+1 > sent {
+			sent = seq + 1
+			if progress != nil {
+				progress(sent, total)
+			}
+		}
+	}
+}
+
+// DownloadMission reads the mission currently stored on the vehicle
+func (c *Client) DownloadMission(ctx context.Context, progress ProgressFunc) ([]MissionItem, error) {
+	f, err := c.exchange(ctx, msgMissionRequestList, encodeMissionRequestList(c.vehicle),
+		c.replyMatcher(msgMissionCount, msgMissionAck))
+	if err != nil {
+		return nil, err
+	}
+	total := decodeMissionCount(f.Payload)
+
+	items := make([]MissionItem, total)
+	for seq := 0; seq < total; seq++ {
+		matchItem := c.replyMatcher(msgMissionItemInt, msgMissionAck)
+		f, err := c.exchange(ctx, msgMissionRequestInt, encodeMissionRequestInt(seq, c.vehicle), func(f Frame) bool {
+			if !matchItem(f) {
+				return false
+			}
+			if f.MessageID == msgMissionItemInt {
+				itemSeq, _ := decodeMissionItemInt(f.Payload)
+				return itemSeq == seq
+			}
+			return true
+		})
+		if err != nil {
+			return nil, fmt.Errorf("download stopped after %d of %d items: %v", seq, total, err)
+		}
+		_, items[seq] = decodeMissionItemInt(f.Payload)
+		if progress != nil {
+			progress(seq+1, total)
+		}
+	}
+
+	if err := c.send(msgMissionAck, encodeMissionAck(missionAccepted, c.vehicle)); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// exchange sends a message until the vehicle replies with a matching frame.
+// A MISSION_ACK reply is returned as an error.
+func (c *Client) exchange(ctx context.Context, messageID uint32, payload []byte, match func(Frame) bool) (Frame, error) {
+	for attempt := 0; ; attempt++ {
+		if err := c.send(messageID, payload); err != nil {
+			return Frame{}, err
+		}
+		f, err := c.next(ctx, retryTimeout, match)
+		if err == ErrTimeout && attempt < maxRetries {
+			continue
+		}
+		if err != nil {
+			return Frame{}, err
+		}
+		if f.MessageID == msgMissionAck {
+			result, _ := decodeMissionAck(f.Payload)
+			return Frame{}, &MissionResultError{Result: result}
+		}
+		return f, nil
+	}
+}
+
+// replyMatcher matches frames from the connected vehicle with one of the given message IDs
+func (c *Client) replyMatcher(messageIDs ...uint32) func(Frame) bool {
+	return func(f Frame) bool {
+		if f.SystemID != c.vehicle.SystemID || f.ComponentID != c.vehicle.ComponentID {
+			return false
+		}
+		for _, id := range messageIDs {
+			if f.MessageID == id {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// next returns the next frame accepted by match, discarding others
+func (c *Client) next(ctx context.Context, timeout time.Duration, match func(Frame) bool) (Frame, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return Frame{}, ctx.Err()
+		case <-timer.C:
+			return Frame{}, ErrTimeout
+		case f, ok := <-c.frames:
+			if !ok {
+				return Frame{}, fmt.Errorf("link closed: %v", c.readErr)
+			}
+			if match(f) {
+				return f, nil
+			}
+		}
+	}
+}
+
+// send writes a message as a MAVLink v2 frame
+func (c *Client) send(messageID uint32, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	frame := encodeFrame(c.seq, gcsSystemID, gcsComponentID, messageID, payload)
+	c.seq++
+	if _, err := c.conn.Write(frame); err != nil {
+		return fmt.Errorf("failed to send message %d: %v", messageID, err)
+	}
+	return nil
+}
+
+// readLoop parses incoming frames until the link fails or the client is closed
+func (c *Client) readLoop() {
+	defer close(c.frames)
+
+	var p parser
+	buf := make([]byte, maxFrameLength*4)
+	for {
+		n, err := c.conn.Read(buf)
+		if err != nil {
+			c.readErr = err
+			return
+		}
+		for _, f := range p.push(buf[:n]) {
+			select {
+			case c.frames <- f:
+			case <-c.done:
+				return
+			}
+		}
+	}
+}
+
+// heartbeatLoop announces the ground station once a second. Send errors are
+// ignored: a UDP listener cannot reply until the vehicle has spoken first.
+func (c *Client) heartbeatLoop() {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+
+	payload := heartbeat{Type: mavTypeGCS, Autopilot: autopilotInvalid}.encode()
+	for {
+		c.send(msgHeartbeat, payload)
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,53 @@
+// Package mavlink compiles missions into MAVLink mission items for ArduPilot
+// and PX4 vehicles and serializes them as QGroundControl .plan and WPL files.
+package mavlink
+
+// MAV_CMD values used by mission items
+const (
+	CmdNavWaypoint       = 16
+	CmdNavLoiterTurns    = 18
+	CmdNavLoiterTime     = 19
+	CmdNavReturnToLaunch = 20
+	CmdNavLand           = 21
+	CmdNavTakeoff        = 22
+	CmdConditionYaw      = 115
+	CmdDoJump            = 177
+	CmdDoChangeSpeed     = 178
+	CmdDoSetROILocation  = 195
+	CmdDoSetROINone      = 197
+	CmdDoMountControl    = 205
+	CmdDoSetCamTriggDist = 206
+	CmdSetCameraZoom     = 531
+	CmdImageStartCapture = 2000
+	CmdVideoStartCapture = 2500
+	CmdVideoStopCapture  = 2501
+
+	CmdNavFencePolygonVertexInclusion = 5001
+	CmdNavFencePolygonVertexExclusion = 5002
+
+	// cmdNavLast is MAV_CMD_NAV_LAST; lower command IDs are navigation commands
+	cmdNavLast = 95
+)
+
+// MAV_FRAME values used by mission items
+const (
+	FrameGlobal            = 0
+	FrameMission           = 2
+	FrameGlobalRelativeAlt = 3
+	FrameGlobalTerrainAlt  = 10
+)
+
+// MAV_AUTOPILOT values identifying the target firmware
+const (
+	AutopilotArduPilot = 3
+	AutopilotPX4       = 12
+)
+
+const (
+	// mountModeMAVLinkTargeting is MAV_MOUNT_MODE_MAVLINK_TARGETING for DO_MOUNT_CONTROL
+	mountModeMAVLinkTargeting = 2
+	// zoomTypeFocalLength is ZOOM_TYPE_FOCAL_LENGTH for SET_CAMERA_ZOOM
+	zoomTypeFocalLength = 3
+	// speedTypeGround is the DO_CHANGE_SPEED ground speed type
+	speedTypeGround = 1
+)
@@ -0,0 +1,87 @@
+package mavlink
+
+import (
+	"fmt"
+	"math"
+)
+
+// Comparison tolerances matching the precision vehicles store items with
+const (
+	coordinateTolerance = 1.5e-7
+	altitudeTolerance   = 0.01
+	paramTolerance      = 1e-4
+)
+
+// Difference is a mismatch between the planned and the stored mission. Seq is
+// -1 for differences that concern the whole mission.
+type Difference struct {
+	Seq      int    `json:"seq"`
+	Field    string `json:"field"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+// CompareItems compares the items uploaded to a vehicle with the items read
+// back from it. ArduPilot's home item is skipped since the vehicle replaces it
+// with its own home. Unset (NaN) params are not compared, and positions are
+// only compared for positional items.
+func CompareItems(expected, actual []MissionItem, autopilot int) []Difference {
+	var diffs []Difference
+	if len(expected) != len(actual) {
+		diffs = append(diffs, Difference{
+			Seq:      -1,
+			Field:    "count",
+			Expected: fmt.Sprint(len(expected)),
+			Actual:   fmt.Sprint(len(actual)),
+		})
+	}
+
+	start := 0
+	if autopilot != AutopilotPX4 {
+		start = 1
+	}
+	for seq := start; seq < len(expected) && seq < len(actual); seq++ {
+		want, got := expected[seq], actual[seq]
+		add := func(field string, expected, actual interface{}) {
+			diffs = append(diffs, Difference{
+				Seq:      seq,
+				Field:    field,
+				Expected: fmt.Sprint(expected),
+				Actual:   fmt.Sprint(actual),
+			})
+		}
+
+		if want.Command != got.Command {
+			add("command", want.Command, got.Command)
+			continue
+		}
+		if want.IsNav() && want.Frame != got.Frame {
+			add("frame", want.Frame, got.Frame)
+		}
+		for i, value := range want.Params {
+			if math.IsNaN(value) {
+				continue
+			}
+			if !within(value, got.Params[i], paramTolerance*math.Max(1, math.Abs(value))) {
+				add(fmt.Sprintf("param%d", i+1), value, got.Params[i])
+			}
+		}
+		if want.Frame == FrameMission {
+			continue
+		}
+		if !within(want.Latitude, got.Latitude, coordinateTolerance) {
+			add("latitude", want.Latitude, got.Latitude)
+		}
+		if !within(want.Longitude, got.Longitude, coordinateTolerance) {
+			add("longitude", want.Longitude, got.Longitude)
+		}
+		if !within(want.Altitude, got.Altitude, altitudeTolerance) {
+			add("altitude", want.Altitude, got.Altitude)
+		}
+	}
+	return diffs
+}
+
+func within(a, b, tolerance float64) bool {
+	return math.Abs(a-b) <= tolerance
+}
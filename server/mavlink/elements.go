@@ -0,0 +1,142 @@
+package mavlink
+
+import (
+	"math"
+	"sync"
+
+	"drone-planner/server/models"
+	"drone-planner/server/timeline"
+)
+
+// ElementHook appends the mission items for one timeline element. config is
+// the typed config registered for the element type in the timeline package.
+type ElementHook func(b *Builder, config interface{}) error
+
+var (
+	hooksMutex   sync.RWMutex
+	elementHooks = map[string]ElementHook{
+		models.ElementWaypointMission: waypointMissionHook,
+		models.ElementRecordVideo:     recordVideoHook,
+		models.ElementShootPhoto:      shootPhotoHook,
+		models.ElementChangeHeading:   changeHeadingHook,
+		models.ElementHover:           hoverHook,
+		models.ElementRotateGimbal:    rotateGimbalHook,
+		models.ElementCameraZoom:      cameraZoomHook,
+		models.ElementPanorama:        panoramaHook,
+		models.ElementHotpointOrbit:   hotpointOrbitHook,
+	}
+)
+
+// RegisterElement sets the hook that compiles a timeline element type,
+// replacing any existing hook
+func RegisterElement(elementType string, hook ElementHook) {
+	hooksMutex.Lock()
+	defer hooksMutex.Unlock()
+	elementHooks[elementType] = hook
+}
+
+// addElement compiles a timeline element with its registered hook. Elements
+// without a hook are recorded as skipped.
+func (b *Builder) addElement(element *models.TimelineElement) error {
+	hooksMutex.RLock()
+	hook, ok := elementHooks[element.Type]
+	hooksMutex.RUnlock()
+	if !ok {
+		b.Skipf("timeline element %q", element.Type)
+		return nil
+	}
+
+	config, err := timeline.Decode(element)
+	if err != nil {
+		return err
+	}
+	return hook(b, config)
+}
+
+// waypointMissionHook appends the waypoints in the altitude mode chosen by Build
+func waypointMissionHook(b *Builder, config interface{}) error {
+	converted, err := b.altitudes.Mission(b.settings, config.(*models.WaypointMissionConfig), b.altitudeMode)
+	if err != nil {
+		return err
+	}
+	b.addWaypointMission(converted, b.mission.CruiseSpeed)
+	return nil
+}
+
+func recordVideoHook(b *Builder, config interface{}) error {
+	b.Command(CmdVideoStartCapture, [4]float64{0, 0, math.NaN(), math.NaN()})
+	b.recording = true
+	return nil
+}
+
+func shootPhotoHook(b *Builder, config interface{}) error {
+	b.addPhoto(*config.(*models.TakePhotoActionConfig))
+	return nil
+}
+
+func changeHeadingHook(b *Builder, config interface{}) error {
+	heading := config.(*models.ChangeHeadingActionConfig)
+	b.Command(CmdConditionYaw, [4]float64{wrap360(heading.Angle), heading.AngularVelocity, 0, 0})
+	return nil
+}
+
+// hoverHook holds at the last navigation target
+func hoverHook(b *Builder, config interface{}) error {
+	hover := config.(*models.HoverActionConfig)
+	b.PositionalFrame(b.positionFrame, CmdNavLoiterTime, [4]float64{hover.Duration, 0, 0, math.NaN()}, b.position.Latitude, b.position.Longitude, b.position.Altitude)
+	return nil
+}
+
+func rotateGimbalHook(b *Builder, config interface{}) error {
+	gimbal := config.(*models.RotateGimbalActionConfig)
+	if gimbal.Yaw == nil {
+		b.mountPitch(gimbal.Pitch)
+		return nil
+	}
+	b.Command(CmdDoMountControl, [4]float64{gimbal.Pitch, 0, *gimbal.Yaw, math.NaN()})
+	b.mission.Items[len(b.mission.Items)-1].Altitude = mountModeMAVLinkTargeting
+	return nil
+}
+
+func cameraZoomHook(b *Builder, config interface{}) error {
+	zoom := config.(*models.CameraZoomActionConfig)
+	b.Command(CmdSetCameraZoom, [4]float64{zoomTypeFocalLength, zoom.FocalLength, math.NaN(), math.NaN()})
+	return nil
+}
+
+// panoramaHook takes a photo, then yaws by one step, until the sweep is covered
+func panoramaHook(b *Builder, config interface{}) error {
+	panorama := config.(*models.PanoramaActionConfig)
+	step, _ := timeline.PanoramaSteps(panorama)
+
+	b.mountPitch(panorama.GimbalPitch)
+	for i := 0; i < panorama.PhotoCount; i++ {
+		b.Command(CmdImageStartCapture, [4]float64{0, 0, 1, 0})
+		if i < panorama.PhotoCount-1 {
+			// Clockwise, relative to the current heading
+			b.Command(CmdConditionYaw, [4]float64{step, 0, 1, 1})
+		}
+	}
+	return nil
+}
+
+// hotpointOrbitHook circles the hotpoint facing it, then restores the cruise
+// speed. Vehicles fly to the circle's edge before starting the turns.
+func hotpointOrbitHook(b *Builder, config interface{}) error {
+	orbit := config.(*models.HotpointOrbitConfig)
+	center := orbit.Center
+
+	b.Command(CmdDoChangeSpeed, [4]float64{speedTypeGround, timeline.OrbitSpeed(orbit), -1, 0})
+	b.Positional(CmdDoSetROILocation, [4]float64{0, math.NaN(), math.NaN(), math.NaN()}, center.Latitude, center.Longitude, 0)
+
+	// A negative radius circles counter-clockwise
+	radius := orbit.Radius
+	if !orbit.Clockwise {
+		radius = -radius
+	}
+	b.Positional(CmdNavLoiterTurns, [4]float64{orbit.Laps, 0, radius, math.NaN()}, center.Latitude, center.Longitude, orbit.Altitude)
+
+	b.Command(CmdDoSetROINone, [4]float64{0, math.NaN(), math.NaN(), math.NaN()})
+	b.Command(CmdDoChangeSpeed, [4]float64{speedTypeGround, b.mission.CruiseSpeed, -1, 0})
+	return nil
+}
@@ -0,0 +1,33 @@
+package mavlink
+
+import "bytes"
+
+// FenceWPL serializes fence polygons as a "QGC WPL 110" fence file, the format
+// ArduPilot ground stations load and save fences in. Each vertex is a fence
+// item whose first parameter is the vertex count of its polygon. Altitude
+// limits are vehicle parameters (FENCE_ALT_MAX, FENCE_ALT_MIN) rather than
+// fence items, so they are not included.
+func FenceWPL(fences []FencePolygon) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("QGC WPL 110\n")
+
+	seq := 0
+	for _, fence := range fences {
+		command := CmdNavFencePolygonVertexExclusion
+		if fence.Inclusion {
+			command = CmdNavFencePolygonVertexInclusion
+		}
+		for _, vertex := range fence.Polygon {
+			writeWPLItem(&buf, seq, false, MissionItem{
+				Command:      command,
+				Frame:        FrameGlobal,
+				Params:       [4]float64{float64(len(fence.Polygon))},
+				Latitude:     vertex[0],
+				Longitude:    vertex[1],
+				AutoContinue: true,
+			})
+			seq++
+		}
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,154 @@
+package mavlink
+
+// MAVLink frame markers and sizes
+const (
+	stxV1          = 0xFE
+	stxV2          = 0xFD
+	headerLenV1    = 6
+	headerLenV2    = 10
+	checksumLen    = 2
+	signatureLen   = 13
+	flagSigned     = 0x01
+	maxFrameLength = headerLenV2 + 255 + checksumLen + signatureLen
+)
+
+// Frame is a decoded MAVLink message with a verified checksum
+type Frame struct {
+	SystemID    uint8
+	ComponentID uint8
+	MessageID   uint32
+	Payload     []byte
+}
+
+// crcAccumulate adds a byte to a CRC-16/MCRF4XX checksum
+func crcAccumulate(b byte, crc uint16) uint16 {
+	tmp := b ^ byte(crc&0xff)
+	tmp ^= tmp << 4
+	return (crc >> 8) ^ (uint16(tmp) << 8) ^ (uint16(tmp) << 3) ^ (uint16(tmp) >> 4)
+}
+
+// checksum computes the frame checksum over data followed by the message's CRC extra byte
+func checksum(data []byte, crcExtra byte) uint16 {
+	crc := uint16(0xffff)
+	for _, b := range data {
+		crc = crcAccumulate(b, crc)
+	}
+	return crcAccumulate(crcExtra, crc)
+}
+
+// encodeFrame builds a MAVLink v2 frame. Trailing zero bytes are truncated as
+// the v2 protocol requires.
+func encodeFrame(seq, systemID, componentID uint8, messageID uint32, payload []byte) []byte {
+	length := len(payload)
+	for length > 1 && payload[length-1] == 0 {
+		length--
+	}
+
+	frame := make([]byte, 0, headerLenV2+length+checksumLen)
+	frame = append(frame,
+		stxV2, byte(length), 0, 0, seq, systemID, componentID,
+		byte(messageID), byte(messageID>>8), byte(messageID>>16),
+	)
+	frame = append(frame, payload[:length]...)
+
+	crc := checksum(frame[1:], crcExtras[messageID])
+	return append(frame, byte(crc), byte(crc>>8))
+}
+
+// parser extracts frames from a byte stream. Frames for messages without a
+// known CRC extra cannot be verified and are skipped.
+type parser struct {
+	buf []byte
+}
+
+// push appends received bytes and returns every complete, valid frame
+func (p *parser) push(data []byte) []Frame {
+	p.buf = append(p.buf, data...)
+
+	var frames []Frame
+	for {
+		start := -1
+		for i, b := range p.buf {
+			if b == stxV2 || b == stxV1 {
+				start = i
+				break
+			}
+		}
+		if start < 0 {
+			p.buf = p.buf[:0]
+			return frames
+		}
+		p.buf = p.buf[start:]
+
+		frame, size, ok := p.decode()
+		if size == 0 {
+			// Incomplete frame; wait for more data
+			return frames
+		}
+		if !ok {
+			// Not a valid frame at this position; resynchronise on the next marker
+			p.buf = p.buf[1:]
+			continue
+		}
+		if frame != nil {
+			frames = append(frames, *frame)
+		}
+		p.buf = p.buf[size:]
+	}
+}
+
+// decode decodes the frame at the start of the buffer. It returns a zero size
+// when more data is needed, and a nil frame for well-formed unknown messages.
+func (p *parser) decode() (*Frame, int, bool) {
+	buf := p.buf
+	if len(buf) < 2 {
+		return nil, 0, true
+	}
+	payloadLen := int(buf[1])
+
+	var headerLen, size int
+	var messageID uint32
+	var systemID, componentID uint8
+	if buf[0] == stxV2 {
+		headerLen = headerLenV2
+		if len(buf) < headerLen {
+			return nil, 0, true
+		}
+		size = headerLen + payloadLen + checksumLen
+		if buf[2]&flagSigned != 0 {
+			size += signatureLen
+		}
+		systemID, componentID = buf[5], buf[6]
+		messageID = uint32(buf[7]) | uint32(buf[8])<<8 | uint32(buf[9])<<16
+	} else {
+		headerLen = headerLenV1
+		if len(buf) < headerLen {
+			return nil, 0, true
+		}
+		size = headerLen + payloadLen + checksumLen
+		systemID, componentID = buf[3], buf[4]
+		messageID = uint32(buf[5])
+	}
+	if len(buf) < size {
+		return nil, 0, true
+	}
+
+	crcExtra, known := crcExtras[messageID]
+	if !known {
+		return nil, size, true
+	}
+	crcOffset := headerLen + payloadLen
+	expected := uint16(buf[crcOffset]) | uint16(buf[crcOffset+1])<<8
+	if checksum(buf[1:crcOffset], crcExtra) != expected {
+		return nil, 0, false
+	}
+
+	payload := make([]byte, payloadLen)
+	copy(payload, buf[headerLen:crcOffset])
+	return &Frame{
+		SystemID:    systemID,
+		ComponentID: componentID,
+		MessageID:   messageID,
+		Payload:     payload,
+	}, size, true
+}
@@ -0,0 +1,203 @@
+package mavlink
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// MAVLink message IDs used by the mission protocol
+const (
+	msgHeartbeat          = 0
+	msgMissionRequest     = 40
+	msgMissionRequestList = 43
+	msgMissionCount       = 44
+	msgMissionAck         = 47
+	msgMissionRequestInt  = 51
+	msgMissionItemInt     = 73
+)
+
+// crcExtras holds the CRC_EXTRA seed of each supported message
+var crcExtras = map[uint32]byte{
+	msgHeartbeat:          50,
+	msgMissionRequest:     230,
+	msgMissionRequestList: 132,
+	msgMissionCount:       221,
+	msgMissionAck:         153,
+	msgMissionRequestInt:  196,
+	msgMissionItemInt:     38,
+}
+
+// Payload lengths including the mission_type extension
+const (
+	lenHeartbeat          = 9
+	lenMissionRequest     = 5
+	lenMissionRequestList = 3
+	lenMissionCount       = 5
+	lenMissionAck         = 4
+	lenMissionItemInt     = 38
+)
+
+const (
+	// missionTypeMission is MAV_MISSION_TYPE_MISSION
+	missionTypeMission = 0
+	// mavTypeGCS is MAV_TYPE_GCS
+	mavTypeGCS = 6
+	// autopilotInvalid is MAV_AUTOPILOT_INVALID, sent by components that are not flight controllers
+	autopilotInvalid = 8
+	// missionAccepted is MAV_MISSION_ACCEPTED
+	missionAccepted = 0
+)
+
+// missionResults names MAV_MISSION_RESULT values
+var missionResults = map[uint8]string{
+	0:  "accepted",
+	1:  "generic error",
+	2:  "unsupported coordinate frame",
+	3:  "unsupported command",
+	4:  "mission storage full",
+	5:  "invalid mission item",
+	6:  "invalid param1",
+	7:  "invalid param2",
+	8:  "invalid param3",
+	9:  "invalid param4",
+	10: "invalid param5 (x)",
+	11: "invalid param6 (y)",
+	12: "invalid param7 (z)",
+	13: "item received out of sequence",
+	14: "denied",
+	15: "operation cancelled",
+}
+
+// MissionResultError is returned when the vehicle rejects a mission transfer
+type MissionResultError struct {
+	Result uint8
+}
+
+func (e *MissionResultError) Error() string {
+	if name, ok := missionResults[e.Result]; ok {
+		return fmt.Sprintf("vehicle rejected mission: %s", name)
+	}
+	return fmt.Sprintf("vehicle rejected mission: result %d", e.Result)
+}
+
+// heartbeat is the HEARTBEAT message
+type heartbeat struct {
+	Type      uint8
+	Autopilot uint8
+}
+
+func (m heartbeat) encode() []byte {
+	payload := make([]byte, lenHeartbeat)
+	payload[4] = m.Type
+	payload[5] = m.Autopilot
+	// MAV_STATE_ACTIVE and the MAVLink protocol version
+	payload[7] = 4
+	payload[8] = 3
+	return payload
+}
+
+func decodeHeartbeat(payload []byte) heartbeat {
+	payload = padded(payload, lenHeartbeat)
+	return heartbeat{Type: payload[4], Autopilot: payload[5]}
+}
+
+// encodeMissionCount encodes MISSION_COUNT
+func encodeMissionCount(count int, target Vehicle) []byte {
+	payload := make([]byte, lenMissionCount)
+	binary.LittleEndian.PutUint16(payload[0:], uint16(count))
+	payload[2] = target.SystemID
+	payload[3] = target.ComponentID
+	payload[4] = missionTypeMission
+	return payload
+}
+
+func decodeMissionCount(payload []byte) int {
+	payload = padded(payload, lenMissionCount)
+	return int(binary.LittleEndian.Uint16(payload[0:]))
+}
+
+// encodeMissionRequestList encodes MISSION_REQUEST_LIST
+func encodeMissionRequestList(target Vehicle) []byte {
+	return []byte{target.SystemID, target.ComponentID, missionTypeMission}
+}
+
+// encodeMissionRequestInt encodes MISSION_REQUEST_INT
+func encodeMissionRequestInt(seq int, target Vehicle) []byte {
+	payload := make([]byte, lenMissionRequest)
+	binary.LittleEndian.PutUint16(payload[0:], uint16(seq))
+	payload[2] = target.SystemID
+	payload[3] = target.ComponentID
+	payload[4] = missionTypeMission
+	return payload
+}
+
+// decodeMissionRequest decodes the sequence number of MISSION_REQUEST or
+// MISSION_REQUEST_INT, which share a layout
+func decodeMissionRequest(payload []byte) (int, uint8) {
+	payload = padded(payload, lenMissionRequest)
+	return int(binary.LittleEndian.Uint16(payload[0:])), payload[4]
+}
+
+// encodeMissionAck encodes MISSION_ACK
+func encodeMissionAck(result uint8, target Vehicle) []byte {
+	return []byte{target.SystemID, target.ComponentID, result, missionTypeMission}
+}
+
+func decodeMissionAck(payload []byte) (uint8, uint8) {
+	payload = padded(payload, lenMissionAck)
+	return payload[2], payload[3]
+}
+
+// encodeMissionItemInt encodes MISSION_ITEM_INT. NaN params are sent as NaN,
+// which MAVLink defines as "leave unchanged".
+func encodeMissionItemInt(seq int, item MissionItem, target Vehicle) []byte {
+	payload := make([]byte, lenMissionItemInt)
+	for i, value := range item.Params {
+		binary.LittleEndian.PutUint32(payload[i*4:], math.Float32bits(float32(value)))
+	}
+	binary.LittleEndian.PutUint32(payload[16:], uint32(int32(math.Round(item.Latitude*1e7))))
+	binary.LittleEndian.PutUint32(payload[20:], uint32(int32(math.Round(item.Longitude*1e7))))
+	binary.LittleEndian.PutUint32(payload[24:], math.Float32bits(float32(item.Altitude)))
+	binary.LittleEndian.PutUint16(payload[28:], uint16(seq))
+	binary.LittleEndian.PutUint16(payload[30:], uint16(item.Command))
+	payload[32] = target.SystemID
+	payload[33] = target.ComponentID
+	payload[34] = uint8(item.Frame)
+	if seq == 0 {
+		payload[35] = 1
+	}
+	if item.AutoContinue {
+		payload[36] = 1
+	}
+	payload[37] = missionTypeMission
+	return payload
+}
+
+// decodeMissionItemInt decodes MISSION_ITEM_INT into its sequence number and item
+func decodeMissionItemInt(payload []byte) (int, MissionItem) {
+	payload = padded(payload, lenMissionItemInt)
+
+	var item MissionItem
+	for i := range item.Params {
+		item.Params[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(payload[i*4:])))
+	}
+	item.Latitude = float64(int32(binary.LittleEndian.Uint32(payload[16:]))) / 1e7
+	item.Longitude = float64(int32(binary.LittleEndian.Uint32(payload[20:]))) / 1e7
+	item.Altitude = float64(math.Float32frombits(binary.LittleEndian.Uint32(payload[24:])))
+	seq := int(binary.LittleEndian.Uint16(payload[28:]))
+	item.Command = int(binary.LittleEndian.Uint16(payload[30:]))
+	item.Frame = int(payload[34])
+	item.AutoContinue = payload[36] != 0
+	return seq, item
+}
+
+// padded restores the trailing zero bytes MAVLink v2 truncates from payloads
+func padded(payload []byte, length int) []byte {
+	if len(payload) >= length {
+		return payload
+	}
+	full := make([]byte, length)
+	copy(full, payload)
+	return full
+}
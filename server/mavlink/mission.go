@@ -0,0 +1,367 @@
+package mavlink
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"drone-planner/server/altitude"
+	"drone-planner/server/geometry"
+	"drone-planner/server/models"
+)
+
+// defaultSpeed is used when a waypoint mission has no auto flight speed (m/s)
+const defaultSpeed = 10.0
+
+// altitudeFrames maps the planner altitude modes a vehicle can fly to the
+// frame of their mission items
+var altitudeFrames = map[string]int{
+	models.AltitudeMSL:      FrameGlobal,
+	models.AltitudeRelative: FrameGlobalRelativeAlt,
+	models.AltitudeAGL:      FrameGlobalTerrainAlt,
+}
+
+// altitudeModes are the modes in altitudeFrames; ellipsoid heights are
+// converted to the first
+var altitudeModes = []string{models.AltitudeMSL, models.AltitudeRelative, models.AltitudeAGL}
+
+// MissionItem is a single MAVLink mission item. Params set to NaN are left
+// unchanged by the vehicle.
+type MissionItem struct {
+	Command      int
+	Frame        int
+	Params       [4]float64
+	Latitude     float64
+	Longitude    float64
+	Altitude     float64
+	AutoContinue bool
+}
+
+// IsNav reports whether the item is a navigation command (MAV_CMD_NAV_*)
+func (i MissionItem) IsNav() bool {
+	return i.Command < cmdNavLast
+}
+
+// Position is a geographic position with an altitude (m)
+type Position struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Altitude  float64 `json:"altitude"`
+}
+
+// Mission is a mission compiled to MAVLink mission items. Home is not part of
+// Items; serializers and uploaders add it where the target expects it.
+type Mission struct {
+	// Home is on the ground, with its altitude above sea level when known
+	Home Position
+	// AltitudeFrame is the frame of the waypoint altitudes
+	AltitudeFrame int
+	Items         []MissionItem
+	CruiseSpeed   float64
+	HoverSpeed    float64
+	// Skipped lists parts of the mission that have no MAVLink equivalent
+	Skipped []string
+}
+
+// homeItem returns the home position as the sequence 0 item ArduPilot expects
+func (m *Mission) homeItem() MissionItem {
+	return MissionItem{
+		Command:      CmdNavWaypoint,
+		Frame:        FrameGlobal,
+		Latitude:     m.Home.Latitude,
+		Longitude:    m.Home.Longitude,
+		Altitude:     m.Home.Altitude,
+		AutoContinue: true,
+	}
+}
+
+// VehicleItems returns the items in the order they are stored on a vehicle
+// running the given autopilot. ArduPilot reserves sequence 0 for home; PX4
+// starts with the first mission item, so its DO_JUMP targets, which Items
+// number counting home as 0, move down by one.
+func (m *Mission) VehicleItems(autopilot int) []MissionItem {
+	if autopilot != AutopilotPX4 {
+		return append([]MissionItem{m.homeItem()}, m.Items...)
+	}
+	items := append([]MissionItem{}, m.Items...)
+	for i := range items {
+		if items[i].Command == CmdDoJump {
+			items[i].Params[0]--
+		}
+	}
+	return items
+}
+
+// Build compiles a mission's timeline into MAVLink mission items. Waypoints
+// keep their altitude mode where a frame exists for it; ellipsoid heights are
+// converted to heights above sea level with altitudes.
+func Build(mission *models.Mission, altitudes *altitude.Converter) (*Mission, error) {
+	config, err := mission.DecodeWaypointMission()
+	if err != nil {
+		return nil, err
+	}
+	if len(config.Waypoints) < 2 {
+		return nil, fmt.Errorf("waypoint mission must have at least 2 waypoints")
+	}
+	mode := altitude.Target(config.AltitudeMode, altitudeModes...)
+	config, err = altitudes.Mission(mission.GlobalSettings, config, mode)
+	if err != nil {
+		return nil, err
+	}
+
+	speed := config.AutoFlightSpeed
+	if speed <= 0 {
+		speed = defaultSpeed
+	}
+
+	b := &Builder{
+		mission: &Mission{
+			Home:          homePosition(mission.GlobalSettings, config),
+			AltitudeFrame: altitudeFrames[mode],
+			CruiseSpeed:   speed,
+			HoverSpeed:    speed,
+		},
+		altitudes:    altitudes,
+		settings:     mission.GlobalSettings,
+		altitudeMode: mode,
+	}
+
+	// Home items are above sea level, which needs terrain data
+	home := geometry.Point{Latitude: b.mission.Home.Latitude, Longitude: b.mission.Home.Longitude}
+	if ground, err := altitudes.Convert(home, home.Latitude, home.Longitude, 0, models.AltitudeRelative, models.AltitudeMSL); err == nil {
+		b.mission.Home.Altitude = ground
+	}
+
+	first := config.Waypoints[0]
+	b.PositionalFrame(b.mission.AltitudeFrame, CmdNavTakeoff, [4]float64{0, 0, 0, math.NaN()}, b.mission.Home.Latitude, b.mission.Home.Longitude, first.Altitude)
+
+	elements := append([]models.TimelineElement{}, mission.TimelineElements...)
+	sort.SliceStable(elements, func(i, j int) bool { return elements[i].Order < elements[j].Order })
+
+	for i := range elements {
+		if err := b.addElement(&elements[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	if b.recording {
+		b.Command(CmdVideoStopCapture, [4]float64{0, math.NaN(), math.NaN(), math.NaN()})
+	}
+	b.addFinishAction(config)
+	return b.mission, nil
+}
+
+// Builder accumulates mission items while a mission is compiled. Element
+// hooks use it to append the items for their timeline element.
+type Builder struct {
+	mission *Mission
+	// position is the last navigation target, where the vehicle is expected
+	// to be, and positionFrame the frame of its altitude
+	position      Position
+	positionFrame int
+	// recording is set once video recording has started
+	recording bool
+	// triggering is set while the camera takes photos by distance
+	triggering bool
+
+	// Waypoint missions are converted to altitudeMode with altitudes
+	altitudes    *altitude.Converter
+	settings     models.GlobalMissionSettings
+	altitudeMode string
+}
+
+// Positional appends an item located at a position with an altitude relative to home
+func (b *Builder) Positional(command int, params [4]float64, lat, lng, alt float64) {
+	b.PositionalFrame(FrameGlobalRelativeAlt, command, params, lat, lng, alt)
+}
+
+// PositionalFrame appends an item located at a position with an altitude in a MAV_FRAME
+func (b *Builder) PositionalFrame(frame, command int, params [4]float64, lat, lng, alt float64) {
+	item := MissionItem{
+		Command:      command,
+		Frame:        frame,
+		Params:       params,
+		Latitude:     lat,
+		Longitude:    lng,
+		Altitude:     alt,
+		AutoContinue: true,
+	}
+	b.mission.Items = append(b.mission.Items, item)
+	if item.IsNav() {
+		b.position = Position{Latitude: lat, Longitude: lng, Altitude: alt}
+		b.positionFrame = frame
+	}
+}
+
+// Command appends a non-positional (DO_ or CONDITION_) item
+func (b *Builder) Command(command int, params [4]float64) {
+	b.mission.Items = append(b.mission.Items, MissionItem{
+		Command:      command,
+		Frame:        FrameMission,
+		Params:       params,
+		AutoContinue: true,
+	})
+}
+
+// Skipf records a part of the mission that could not be compiled
+func (b *Builder) Skipf(format string, args ...interface{}) {
+	b.mission.Skipped = append(b.mission.Skipped, fmt.Sprintf(format, args...))
+}
+
+// addWaypointMission appends the waypoints of a waypoint mission and their actions
+func (b *Builder) addWaypointMission(config *models.WaypointMissionConfig, speed float64) {
+	// Repeats jump back here so the speed and ROI are re-applied
+	startIndex := len(b.mission.Items)
+	b.Command(CmdDoChangeSpeed, [4]float64{speedTypeGround, speed, -1, 0})
+	currentSpeed := speed
+
+	facingPoi := config.HeadingMode == "TOWARD_POINT_OF_INTEREST"
+	var currentRoi *models.Target
+
+	for i, wp := range config.Waypoints {
+		if facingPoi {
+			if target, ok := waypointTarget(wp, config); ok && (currentRoi == nil || *currentRoi != target) {
+				b.Positional(CmdDoSetROILocation, [4]float64{0, math.NaN(), math.NaN(), math.NaN()}, target.Lat, target.Lng, 0)
+				currentRoi = &target
+			}
+		}
+
+		yaw := math.NaN()
+		if config.HeadingMode == "USING_WAYPOINT_HEADING" {
+			yaw = wrap360(wp.Heading)
+		}
+		passRadius := 0.0
+		if config.FlightPathMode == "CURVED" {
+			passRadius = math.Abs(wp.CornerRadius)
+		}
+		b.PositionalFrame(b.mission.AltitudeFrame, CmdNavWaypoint, [4]float64{0, 0, passRadius, yaw}, wp.Coordinate.Latitude, wp.Coordinate.Longitude, wp.Altitude)
+
+		if config.GimbalPitchRotationEnabled {
+			b.mountPitch(wp.GimbalPitch)
+		}
+		// Distance triggering only covers the leg it was started on
+		if b.triggering {
+			b.stopTriggering()
+		}
+		for _, action := range wp.Actions {
+			b.addWaypointAction(i, action, wp)
+		}
+
+		// DJI waypoint speeds apply to the leg leaving the waypoint
+		legSpeed := wp.Speed
+		if legSpeed <= 0 {
+			legSpeed = speed
+		}
+		if i < len(config.Waypoints)-1 && legSpeed != currentSpeed {
+			b.Command(CmdDoChangeSpeed, [4]float64{speedTypeGround, legSpeed, -1, 0})
+			currentSpeed = legSpeed
+		}
+	}
+
+	if b.triggering {
+		b.stopTriggering()
+	}
+	if currentRoi != nil {
+		b.Command(CmdDoSetROINone, [4]float64{0, math.NaN(), math.NaN(), math.NaN()})
+	}
+	if config.RepeatTimes > 1 {
+		// DO_JUMP targets are 1-based sequence numbers counting home as 0
+		b.Command(CmdDoJump, [4]float64{float64(startIndex + 1), float64(config.RepeatTimes - 1), math.NaN(), math.NaN()})
+	}
+}
+
+// addWaypointAction translates a waypoint action into mission items
+func (b *Builder) addWaypointAction(index int, action models.WaypointAction, wp models.Waypoint) {
+	switch models.NormalizeActionType(action.ActionType) {
+	case models.ActionTakePhoto:
+		b.Command(CmdImageStartCapture, [4]float64{0, 0, 1, 0})
+	case models.ActionStartRecording:
+		b.Command(CmdVideoStartCapture, [4]float64{0, 0, math.NaN(), math.NaN()})
+	case models.ActionStopRecording:
+		b.Command(CmdVideoStopCapture, [4]float64{0, math.NaN(), math.NaN(), math.NaN()})
+	case models.ActionRotateGimbal:
+		b.mountPitch(action.ActionParam)
+	case models.ActionRotateAircraft:
+		direction := 1.0
+		if wp.TurnMode == "COUNTER_CLOCKWISE" {
+			direction = -1
+		}
+		b.Command(CmdConditionYaw, [4]float64{wrap360(action.ActionParam), 0, direction, 0})
+	case models.ActionHover:
+		b.PositionalFrame(b.mission.AltitudeFrame, CmdNavLoiterTime, [4]float64{action.ActionParam, 0, 0, math.NaN()}, wp.Coordinate.Latitude, wp.Coordinate.Longitude, wp.Altitude)
+	case models.ActionZoom:
+		b.Command(CmdSetCameraZoom, [4]float64{zoomTypeFocalLength, action.ActionParam, math.NaN(), math.NaN()})
+	case models.ActionPhotoInterval:
+		// Trigger once immediately, then every ActionParam meters
+		b.Command(CmdDoSetCamTriggDist, [4]float64{action.ActionParam, 0, 1, math.NaN()})
+		b.triggering = true
+	default:
+		b.Skipf("waypoint %d: action %q", index+1, action.ActionType)
+	}
+}
+
+// addPhoto appends a timeline photo element as an image capture command
+func (b *Builder) addPhoto(photo models.TakePhotoActionConfig) {
+	if photo.PhotoType == "interval" && photo.PhotoCount != nil && photo.TimeInterval != nil {
+		b.Command(CmdImageStartCapture, [4]float64{0, float64(*photo.TimeInterval), float64(*photo.PhotoCount), 0})
+		return
+	}
+	b.Command(CmdImageStartCapture, [4]float64{0, 0, 1, 0})
+}
+
+// stopTriggering ends distance-triggered photos
+func (b *Builder) stopTriggering() {
+	b.Command(CmdDoSetCamTriggDist, [4]float64{0, 0, 0, math.NaN()})
+	b.triggering = false
+}
+
+// mountPitch points the gimbal to an absolute pitch
+func (b *Builder) mountPitch(pitch float64) {
+	b.Command(CmdDoMountControl, [4]float64{pitch, 0, 0, math.NaN()})
+	// DO_MOUNT_CONTROL carries the mount mode in param 7 (the altitude field)
+	b.mission.Items[len(b.mission.Items)-1].Altitude = mountModeMAVLinkTargeting
+}
+
+// addFinishAction appends the item matching the mission's finished action
+func (b *Builder) addFinishAction(config *models.WaypointMissionConfig) {
+	last := config.Waypoints[len(config.Waypoints)-1]
+	switch config.FinishedAction {
+	case "GO_HOME":
+		b.Command(CmdNavReturnToLaunch, [4]float64{0, 0, 0, 0})
+	case "LAND", "AUTO_LAND":
+		b.Positional(CmdNavLand, [4]float64{0, 0, 0, math.NaN()}, last.Coordinate.Latitude, last.Coordinate.Longitude, 0)
+	case "GO_TO_FIRST_WAYPOINT", "GO_FIRST_WAYPOINT":
+		first := config.Waypoints[0]
+		b.PositionalFrame(b.mission.AltitudeFrame, CmdNavWaypoint, [4]float64{0, 0, 0, math.NaN()}, first.Coordinate.Latitude, first.Coordinate.Longitude, first.Altitude)
+	}
+	// NO_ACTION and HOVER leave the vehicle holding position at the last waypoint
+}
+
+// homePosition returns the mission's home point, falling back to the first waypoint
+func homePosition(settings models.GlobalMissionSettings, config *models.WaypointMissionConfig) Position {
+	if settings.HomeLat != nil && settings.HomeLng != nil {
+		return Position{Latitude: *settings.HomeLat, Longitude: *settings.HomeLng}
+	}
+	first := config.Waypoints[0].Coordinate
+	return Position{Latitude: first.Latitude, Longitude: first.Longitude}
+}
+
+// waypointTarget returns the POI a waypoint should face, preferring its own targets
+func waypointTarget(wp models.Waypoint, config *models.WaypointMissionConfig) (models.Target, bool) {
+	if len(wp.Targets) > 0 {
+		return wp.Targets[0], true
+	}
+	if len(config.Targets) > 0 {
+		return config.Targets[0], true
+	}
+	return models.Target{}, false
+}
+
+// wrap360 wraps a heading into the 0..360 range MAVLink yaw parameters use
+func wrap360(heading float64) float64 {
+	heading = math.Mod(heading, 360)
+	if heading < 0 {
+		heading += 360
+	}
+	return heading
+}
@@ -0,0 +1,192 @@
+package mavlink
+
+import (
+	"context"
+	"math"
+	"net"
+	"testing"
+	"time"
+
+	"drone-planner/server/altitude"
+	"drone-planner/server/models"
+)
+
+// fakeVehicle answers the mission protocol on one end of a link, storing
+// uploaded items the way a flight controller does
+type fakeVehicle struct {
+	conn      net.Conn
+	autopilot uint8
+	items     []MissionItem
+	seq       uint8
+}
+
+var gcs = Vehicle{SystemID: gcsSystemID, ComponentID: gcsComponentID}
+
+func (v *fakeVehicle) send(messageID uint32, payload []byte) {
+	v.conn.Write(encodeFrame(v.seq, 1, 1, messageID, payload))
+	v.seq++
+}
+
+func (v *fakeVehicle) run() {
+	v.send(msgHeartbeat, heartbeat{Type: 2, Autopilot: v.autopilot}.encode())
+
+	var p parser
+	buf := make([]byte, maxFrameLength*4)
+	for {
+		n, err := v.conn.Read(buf)
+		if err != nil {
+			return
+		}
+		for _, f := range p.push(buf[:n]) {
+			switch f.MessageID {
+			case msgMissionCount:
+				v.items = make([]MissionItem, decodeMissionCount(f.Payload))
+				v.send(msgMissionRequestInt, encodeMissionRequestInt(0, gcs))
+			case msgMissionItemInt:
+				seq, item := decodeMissionItemInt(f.Payload)
+				v.items[seq] = item
+				if seq+1 < len(v.items) {
+					v.send(msgMissionRequestInt, encodeMissionRequestInt(seq+1, gcs))
+				} else {
+					v.send(msgMissionAck, encodeMissionAck(missionAccepted, gcs))
+				}
+			case msgMissionRequestList:
+				v.send(msgMissionCount, encodeMissionCount(len(v.items), gcs))
+			case msgMissionRequestInt:
+				seq, _ := decodeMissionRequest(f.Payload)
+				v.send(msgMissionItemInt, encodeMissionItemInt(seq, v.items[seq], gcs))
+			}
+		}
+	}
+}
+
+// repeatedMission is a three waypoint mission flown twice
+func repeatedMission(t *testing.T) *Mission {
+	t.Helper()
+	config, err := models.EncodeConfig(models.WaypointMissionConfig{
+		AutoFlightSpeed: 8,
+		MaxFlightSpeed:  12,
+		RepeatTimes:     2,
+		AltitudeMode:    models.AltitudeRelative,
+		Waypoints: []models.Waypoint{
+			{Coordinate: models.Coordinate{Latitude: 47.3769, Longitude: 8.5417}, Altitude: 40},
+			{Coordinate: models.Coordinate{Latitude: 47.3779, Longitude: 8.5427}, Altitude: 50, Speed: 5},
+			{Coordinate: models.Coordinate{Latitude: 47.3789, Longitude: 8.5417}, Altitude: 60},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mission, err := Build(&models.Mission{
+		TimelineElements: []models.TimelineElement{{Type: models.ElementWaypointMission, Config: config}},
+	}, &altitude.Converter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mission
+}
+
+func TestMissionRoundTrip(t *testing.T) {
+	tests := []struct {
+		name      string
+		autopilot int
+		// offset is the sequence number of the first mission item
+		offset int
+	}{
+		{"ArduPilot", AutopilotArduPilot, 1},
+		{"PX4", AutopilotPX4, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mission := repeatedMission(t)
+			items := mission.VehicleItems(tt.autopilot)
+			if len(items) != len(mission.Items)+tt.offset {
+				t.Fatalf("got %d vehicle items for %d mission items", len(items), len(mission.Items))
+			}
+
+			local, remote := net.Pipe()
+			vehicle := &fakeVehicle{conn: remote, autopilot: uint8(tt.autopilot)}
+			go vehicle.run()
+			client := NewClient(local)
+			defer client.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			connected, err := client.Connect(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if connected.Autopilot != tt.autopilot {
+				t.Fatalf("connected to autopilot %d, want %d", connected.Autopilot, tt.autopilot)
+			}
+			if err := client.UploadMission(ctx, items, nil); err != nil {
+				t.Fatal(err)
+			}
+			stored, err := client.DownloadMission(ctx, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diffs := CompareItems(items, stored, tt.autopilot); len(diffs) > 0 {
+				t.Fatalf("stored mission differs: %+v", diffs)
+			}
+
+			// The jump must land on the speed reset before the first waypoint
+			jumps := 0
+			for _, item := range stored {
+				if item.Command != CmdDoJump {
+					continue
+				}
+				jumps++
+				target := int(item.Params[0])
+				if target < 0 || target+1 >= len(stored) {
+					t.Fatalf("DO_JUMP target %d is outside the %d item mission", target, len(stored))
+				}
+				if reset := stored[target]; reset.Command != CmdDoChangeSpeed || reset.Params[1] != 8 {
+					t.Errorf("DO_JUMP target %d is %+v, want the 8 m/s speed reset", target, reset)
+				}
+				first := stored[target+1]
+				if first.Command != CmdNavWaypoint || math.Abs(first.Latitude-47.3769) > coordinateTolerance || math.Abs(first.Longitude-8.5417) > coordinateTolerance {
+					t.Errorf("item %d after the DO_JUMP target is %+v, want the first waypoint", target+1, first)
+				}
+				if item.Params[1] != 1 {
+					t.Errorf("DO_JUMP repeats %g times, want 1", item.Params[1])
+				}
+			}
+			if jumps != 1 {
+				t.Errorf("got %d DO_JUMP items, want 1", jumps)
+			}
+		})
+	}
+}
+
+func TestMissionItemEncoding(t *testing.T) {
+	tests := []struct {
+		name string
+		seq  int
+		item MissionItem
+	}{
+		{"waypoint", 3, MissionItem{Command: CmdNavWaypoint, Frame: FrameGlobalRelativeAlt, Params: [4]float64{0, 0, 2, math.NaN()}, Latitude: 47.3769123, Longitude: -8.5417456, Altitude: 42.5, AutoContinue: true}},
+		{"command", 0, MissionItem{Command: CmdDoChangeSpeed, Frame: FrameMission, Params: [4]float64{1, 5.5, -1, 0}}},
+		{"southern hemisphere", 65535, MissionItem{Command: CmdNavTakeoff, Frame: FrameGlobal, Params: [4]float64{0, 0, 0, math.NaN()}, Latitude: -33.8688, Longitude: 151.2093, Altitude: 120}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frame := encodeFrame(0, gcsSystemID, gcsComponentID, msgMissionItemInt, encodeMissionItemInt(tt.seq, tt.item, Vehicle{SystemID: 1, ComponentID: 1}))
+			var p parser
+			frames := p.push(frame)
+			if len(frames) != 1 {
+				t.Fatalf("parsed %d frames, want 1", len(frames))
+			}
+			seq, item := decodeMissionItemInt(frames[0].Payload)
+			if seq != tt.seq {
+				t.Errorf("seq = %d, want %d", seq, tt.seq)
+			}
+			if diffs := CompareItems([]MissionItem{tt.item}, []MissionItem{item}, AutopilotPX4); len(diffs) > 0 {
+				t.Errorf("decoded item differs: %+v", diffs)
+			}
+			if item.AutoContinue != tt.item.AutoContinue {
+				t.Errorf("autoContinue = %v, want %v", item.AutoContinue, tt.item.AutoContinue)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package mavlink
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// vehicleTypeQuadrotor is MAV_TYPE_QUADROTOR
+const vehicleTypeQuadrotor = 2
+
+// planAltitudeModes maps item frames to QGroundControl altitude modes
+// (relative, AMSL and terrain frame)
+var planAltitudeModes = map[int]int{
+	FrameGlobalRelativeAlt: 1,
+	FrameGlobal:            2,
+	FrameGlobalTerrainAlt:  4,
+}
+
+// FencePolygon is a geofence polygon of [lat, lng] vertices
+type FencePolygon struct {
+	Inclusion bool
+	Polygon   [][2]float64
+}
+
+// PlanOptions selects the target firmware and the safety data written with a .plan file
+type PlanOptions struct {
+	Firmware    int
+	Fences      []FencePolygon
+	RallyPoints []Position
+}
+
+// planFile mirrors the QGroundControl .plan JSON layout
+type planFile struct {
+	FileType      string        `json:"fileType"`
+	GeoFence      planGeoFence  `json:"geoFence"`
+	GroundStation string        `json:"groundStation"`
+	Mission       planMission   `json:"mission"`
+	RallyPoints   planRallyList `json:"rallyPoints"`
+	Version       int           `json:"version"`
+}
+
+type planGeoFence struct {
+	Circles  []interface{} `json:"circles"`
+	Polygons []planPolygon `json:"polygons"`
+	Version  int           `json:"version"`
+}
+
+type planPolygon struct {
+	Inclusion bool         `json:"inclusion"`
+	Polygon   [][2]float64 `json:"polygon"`
+	Version   int          `json:"version"`
+}
+
+type planMission struct {
+	CruiseSpeed            float64    `json:"cruiseSpeed"`
+	FirmwareType           int        `json:"firmwareType"`
+	GlobalPlanAltitudeMode int        `json:"globalPlanAltitudeMode"`
+	HoverSpeed             float64    `json:"hoverSpeed"`
+	Items                  []planItem `json:"items"`
+	PlannedHomePosition    [3]float64 `json:"plannedHomePosition"`
+	VehicleType            int        `json:"vehicleType"`
+	Version                int        `json:"version"`
+}
+
+type planItem struct {
+	AMSLAltAboveTerrain interface{}   `json:"AMSLAltAboveTerrain,omitempty"`
+	Altitude            *float64      `json:"Altitude,omitempty"`
+	AltitudeMode        *int          `json:"AltitudeMode,omitempty"`
+	AutoContinue        bool          `json:"autoContinue"`
+	Command             int           `json:"command"`
+	DoJumpID            int           `json:"doJumpId"`
+	Frame               int           `json:"frame"`
+	Params              []interface{} `json:"params"`
+	Type                string        `json:"type"`
+}
+
+type planRallyList struct {
+	Points  [][3]float64 `json:"points"`
+	Version int          `json:"version"`
+}
+
+// Plan serializes the mission as a QGroundControl .plan file
+func (m *Mission) Plan(options PlanOptions) ([]byte, error) {
+	firmware := options.Firmware
+	if firmware == 0 {
+		firmware = AutopilotArduPilot
+	}
+
+	plan := planFile{
+		FileType: "Plan",
+		GeoFence: planGeoFence{
+			Circles:  []interface{}{},
+			Polygons: []planPolygon{},
+			Version:  2,
+		},
+		GroundStation: "QGroundControl",
+		Mission: planMission{
+			CruiseSpeed:            m.CruiseSpeed,
+			FirmwareType:           firmware,
+			GlobalPlanAltitudeMode: planAltitudeModes[m.AltitudeFrame],
+			HoverSpeed:             m.HoverSpeed,
+			Items:                  []planItem{},
+			PlannedHomePosition:    [3]float64{m.Home.Latitude, m.Home.Longitude, m.Home.Altitude},
+			VehicleType:            vehicleTypeQuadrotor,
+			Version:                2,
+		},
+		RallyPoints: planRallyList{
+			Points:  [][3]float64{},
+			Version: 2,
+		},
+		Version: 1,
+	}
+
+	for i, item := range m.Items {
+		entry := planItem{
+			AutoContinue: item.AutoContinue,
+			Command:      item.Command,
+			DoJumpID:     i + 1,
+			Frame:        item.Frame,
+			Params: []interface{}{
+				planParam(item.Params[0]),
+				planParam(item.Params[1]),
+				planParam(item.Params[2]),
+				planParam(item.Params[3]),
+				item.Latitude,
+				item.Longitude,
+				item.Altitude,
+			},
+			Type: "SimpleItem",
+		}
+		if altitudeMode, ok := planAltitudeModes[item.Frame]; ok {
+			altitude := item.Altitude
+			entry.Altitude = &altitude
+			entry.AltitudeMode = &altitudeMode
+		}
+		plan.Mission.Items = append(plan.Mission.Items, entry)
+	}
+
+	for _, fence := range options.Fences {
+		plan.GeoFence.Polygons = append(plan.GeoFence.Polygons, planPolygon{
+			Inclusion: fence.Inclusion,
+			Polygon:   fence.Polygon,
+			Version:   1,
+		})
+	}
+	for _, point := range options.RallyPoints {
+		plan.RallyPoints.Points = append(plan.RallyPoints.Points, [3]float64{point.Latitude, point.Longitude, point.Altitude})
+	}
+
+	data, err := json.MarshalIndent(plan, "", "    ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode plan: %v", err)
+	}
+	return data, nil
+}
+
+// planParam encodes unset (NaN) parameters as null, as QGroundControl does
+func planParam(value float64) interface{} {
+	if math.IsNaN(value) {
+		return nil
+	}
+	return value
+}
@@ -0,0 +1,131 @@
+package mavlink
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestPlanExport(t *testing.T) {
+	fence := FencePolygon{Inclusion: true, Polygon: [][2]float64{{47.37, 8.53}, {47.39, 8.53}, {47.39, 8.55}}}
+	rally := Position{Latitude: 47.377, Longitude: 8.541, Altitude: 30}
+	tests := []struct {
+		name     string
+		firmware int
+		want     int
+	}{
+		{"default", 0, AutopilotArduPilot},
+		{"ArduPilot", AutopilotArduPilot, AutopilotArduPilot},
+		{"PX4", AutopilotPX4, AutopilotPX4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mission := repeatedMission(t)
+			data, err := mission.Plan(PlanOptions{Firmware: tt.firmware, Fences: []FencePolygon{fence}, RallyPoints: []Position{rally}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var plan planFile
+			if err := json.Unmarshal(data, &plan); err != nil {
+				t.Fatal(err)
+			}
+			if plan.FileType != "Plan" || plan.Mission.FirmwareType != tt.want {
+				t.Errorf("file type %q for firmware %d, want a Plan for %d", plan.FileType, plan.Mission.FirmwareType, tt.want)
+			}
+			if len(plan.GeoFence.Polygons) != 1 || !plan.GeoFence.Polygons[0].Inclusion || len(plan.GeoFence.Polygons[0].Polygon) != 3 {
+				t.Errorf("geofence = %+v, want the inclusion polygon", plan.GeoFence)
+			}
+			if len(plan.RallyPoints.Points) != 1 || plan.RallyPoints.Points[0] != [3]float64{rally.Latitude, rally.Longitude, rally.Altitude} {
+				t.Errorf("rally points = %v", plan.RallyPoints.Points)
+			}
+
+			items := make([]MissionItem, len(plan.Mission.Items))
+			ids := map[int]int{}
+			for i, entry := range plan.Mission.Items {
+				item, err := decodePlanItem(entry)
+				if err != nil {
+					t.Fatalf("item %d: %v", i, err)
+				}
+				items[i] = item
+				ids[entry.DoJumpID] = i
+			}
+			if diffs := CompareItems(mission.Items, items, AutopilotPX4); len(diffs) > 0 {
+				t.Fatalf("plan items differ: %+v", diffs)
+			}
+
+			// QGroundControl jumps to doJumpId, which must be the speed reset
+			for _, item := range items {
+				if item.Command != CmdDoJump {
+					continue
+				}
+				target, ok := ids[int(item.Params[0])]
+				if !ok {
+					t.Fatalf("DO_JUMP targets unknown doJumpId %g", item.Params[0])
+				}
+				if items[target].Command != CmdDoChangeSpeed {
+					t.Errorf("DO_JUMP lands on %+v, want the speed reset", items[target])
+				}
+			}
+		})
+	}
+}
+
+// decodePlanItem converts a .plan SimpleItem back to a mission item
+func decodePlanItem(entry planItem) (MissionItem, error) {
+	if entry.Type != "SimpleItem" || len(entry.Params) != 7 {
+		return MissionItem{}, fmt.Errorf("unexpected %s with %d params", entry.Type, len(entry.Params))
+	}
+	var values [7]float64
+	for i, param := range entry.Params {
+		switch v := param.(type) {
+		case nil:
+			values[i] = math.NaN()
+		case float64:
+			values[i] = v
+		default:
+			return MissionItem{}, fmt.Errorf("param %d is %T", i+1, param)
+		}
+	}
+	return MissionItem{
+		Command:      entry.Command,
+		Frame:        entry.Frame,
+		Params:       [4]float64{values[0], values[1], values[2], values[3]},
+		Latitude:     values[4],
+		Longitude:    values[5],
+		Altitude:     values[6],
+		AutoContinue: entry.AutoContinue,
+	}, nil
+}
+
+func TestWPLExport(t *testing.T) {
+	mission := repeatedMission(t)
+	scanner := bufio.NewScanner(bytes.NewReader(mission.WPL()))
+	if !scanner.Scan() || scanner.Text() != "QGC WPL 110" {
+		t.Fatalf("header = %q, want QGC WPL 110", scanner.Text())
+	}
+
+	var items []MissionItem
+	for scanner.Scan() {
+		var seq, current, autoContinue int
+		var item MissionItem
+		if _, err := fmt.Sscanf(scanner.Text(), "%d\t%d\t%d\t%d\t%g\t%g\t%g\t%g\t%g\t%g\t%g\t%d",
+			&seq, &current, &item.Frame, &item.Command,
+			&item.Params[0], &item.Params[1], &item.Params[2], &item.Params[3],
+			&item.Latitude, &item.Longitude, &item.Altitude, &autoContinue); err != nil {
+			t.Fatalf("line %d: %v", len(items)+2, err)
+		}
+		if seq != len(items) || (current == 1) != (seq == 0) {
+			t.Errorf("line %d has seq %d current %d", len(items)+2, seq, current)
+		}
+		item.AutoContinue = autoContinue == 1
+		items = append(items, item)
+	}
+
+	// Like ArduPilot, the file numbers home as item 0
+	if diffs := CompareItems(mission.VehicleItems(AutopilotArduPilot), items, AutopilotPX4); len(diffs) > 0 {
+		t.Errorf("WPL items differ: %+v", diffs)
+	}
+}
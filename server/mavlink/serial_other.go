@@ -0,0 +1,13 @@
+//go:build !linux
+
+package mavlink
+
+import (
+	"fmt"
+	"io"
+)
+
+// openSerial is only implemented on Linux
+func openSerial(path string, baud int) (io.ReadWriteCloser, error) {
+	return nil, fmt.Errorf("serial links are not supported on this platform")
+}
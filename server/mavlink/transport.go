@@ -0,0 +1,93 @@
+package mavlink
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaultBaudRate is used for serial links without a baud query parameter
+const defaultBaudRate = 57600
+
+// Dial opens a link to a vehicle. Supported addresses:
+//
+//	tcp://host:port                 TCP client, e.g. ArduPilot SITL on tcp://127.0.0.1:5760
+//	udp://host:port                 UDP, sending to the vehicle at host:port
+//	udpin://host:port               UDP, listening for the vehicle on host:port
+//	serial:///dev/ttyUSB0?baud=57600
+func Dial(address string, timeout time.Duration) (io.ReadWriteCloser, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid connection address: %v", err)
+	}
+
+	switch u.Scheme {
+	case "tcp":
+		conn, err := net.DialTimeout("tcp", u.Host, timeout)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to %s: %v", u.Host, err)
+		}
+		return conn, nil
+	case "udp":
+		conn, err := net.DialTimeout("udp", u.Host, timeout)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to %s: %v", u.Host, err)
+		}
+		return conn, nil
+	case "udpin":
+		addr, err := net.ResolveUDPAddr("udp", u.Host)
+		if err != nil {
+			return nil, fmt.Errorf("invalid UDP address %s: %v", u.Host, err)
+		}
+		conn, err := net.ListenUDP("udp", addr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen on %s: %v", u.Host, err)
+		}
+		return &udpListener{conn: conn}, nil
+	case "serial":
+		baud := defaultBaudRate
+		if value := u.Query().Get("baud"); value != "" {
+			baud, err = strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid baud rate %q", value)
+			}
+		}
+		return openSerial(u.Path, baud)
+	}
+	return nil, fmt.Errorf("unsupported connection scheme %q", u.Scheme)
+}
+
+// udpListener is a UDP link that replies to whichever peer last sent a datagram
+type udpListener struct {
+	conn *net.UDPConn
+	mu   sync.Mutex
+	peer *net.UDPAddr
+}
+
+func (l *udpListener) Read(p []byte) (int, error) {
+	n, addr, err := l.conn.ReadFromUDP(p)
+	if err == nil {
+		l.mu.Lock()
+		l.peer = addr
+		l.mu.Unlock()
+	}
+	return n, err
+}
+
+func (l *udpListener) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	peer := l.peer
+	l.mu.Unlock()
+	if peer == nil {
+		return 0, fmt.Errorf("no vehicle has connected yet")
+	}
+	return l.conn.WriteToUDP(p, peer)
+}
+
+func (l *udpListener) Close() error {
+	return l.conn.Close()
+}
@@ -0,0 +1,54 @@
+package mavlink
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+)
+
+// WPL serializes the mission as a "QGC WPL 110" text file. Item 0 is the home
+// position, as expected by ArduPilot ground stations.
+func (m *Mission) WPL() []byte {
+	var buf bytes.Buffer
+	buf.WriteString("QGC WPL 110\n")
+
+	writeWPLItem(&buf, 0, true, m.homeItem())
+	for i, item := range m.Items {
+		writeWPLItem(&buf, i+1, false, item)
+	}
+	return buf.Bytes()
+}
+
+// writeWPLItem writes one tab-separated WPL line
+func writeWPLItem(buf *bytes.Buffer, seq int, current bool, item MissionItem) {
+	fmt.Fprintf(buf, "%d\t%d\t%d\t%d\t%s\t%s\t%s\t%s\t%.8f\t%.8f\t%.6f\t%d\n",
+		seq,
+		boolInt(current),
+		item.Frame,
+		item.Command,
+		wplParam(item.Params[0]),
+		wplParam(item.Params[1]),
+		wplParam(item.Params[2]),
+		wplParam(item.Params[3]),
+		item.Latitude,
+		item.Longitude,
+		item.Altitude,
+		boolInt(item.AutoContinue),
+	)
+}
+
+// wplParam formats a parameter, writing unset (NaN) values as 0 since not all
+// ground stations parse "nan"
+func wplParam(value float64) string {
+	if math.IsNaN(value) {
+		value = 0
+	}
+	return fmt.Sprintf("%.6f", value)
+}
+
+func boolInt(value bool) int {
+	if value {
+		return 1
+	}
+	return 0
+}
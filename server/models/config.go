@@ -0,0 +1,147 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Waypoint action types (matching the actuator names used by the client)
+const (
+	ActionTakePhoto      = "takePhoto"
+	ActionStartRecording = "startRecording"
+	ActionStopRecording  = "stopRecording"
+	ActionRotateGimbal   = "rotateGimbal"
+	ActionRotateAircraft = "rotateAircraft"
+	ActionHover          = "hover"
+	ActionFocus          = "focus"
+	ActionZoom           = "zoom"
+	// ActionPhotoInterval takes a photo every ActionParam meters along the
+	// leg leaving the waypoint
+	ActionPhotoInterval = "photoInterval"
+	// ActionCustom is a client-defined action whose ActionParam is stored as
+	// given; exporters skip it
+	ActionCustom = "custom"
+)
+
+// Altitude reference modes of waypoint altitudes
+const (
+	AltitudeRelative  = "relative"  // Above the takeoff point
+	AltitudeAGL       = "agl"       // Above the ground under the waypoint
+	AltitudeMSL       = "msl"       // Above mean sea level (EGM96 geoid)
+	AltitudeEllipsoid = "ellipsoid" // Above the WGS84 ellipsoid
+)
+
+// AltitudeModes lists every altitude reference mode
+var AltitudeModes = []string{AltitudeRelative, AltitudeAGL, AltitudeMSL, AltitudeEllipsoid}
+
+// actionTypeAliases maps alternative spellings to the canonical action types
+var actionTypeAliases = map[string]string{
+	"TAKE_PHOTO":      ActionTakePhoto,
+	"START_RECORD":    ActionStartRecording,
+	"startRecord":     ActionStartRecording,
+	"STOP_RECORD":     ActionStopRecording,
+	"stopRecord":      ActionStopRecording,
+	"GIMBAL_PITCH":    ActionRotateGimbal,
+	"gimbalRotate":    ActionRotateGimbal,
+	"ROTATE_AIRCRAFT": ActionRotateAircraft,
+	"rotateYaw":       ActionRotateAircraft,
+	"STAY":            ActionHover,
+	"stay":            ActionHover,
+	"HOVER":           ActionHover,
+	"FOCUS":           ActionFocus,
+	"ZOOM":            ActionZoom,
+}
+
+// NormalizeActionType returns the canonical name for a waypoint action type
+func NormalizeActionType(actionType string) string {
+	if canonical, ok := actionTypeAliases[actionType]; ok {
+		return canonical
+	}
+	return actionType
+}
+
+// NormalizeAltitudeMode returns the altitude reference mode, treating an empty
+// mode as relative to takeoff
+func NormalizeAltitudeMode(mode string) string {
+	if mode == "" {
+		return AltitudeRelative
+	}
+	return mode
+}
+
+// DecodeConfig decodes the element's flexible config into a typed config struct
+func (e *TimelineElement) DecodeConfig(out interface{}) error {
+	if err := e.UnmarshalConfig(out); err != nil {
+		return fmt.Errorf("failed to decode %s config: %v", e.Type, err)
+	}
+	return nil
+}
+
+// UnmarshalConfig round-trips the config through JSON into out, returning
+// the JSON error unwrapped so callers can inspect it
+func (e *TimelineElement) UnmarshalConfig(out interface{}) error {
+	data, err := json.Marshal(normalizeConfigValue(e.Config))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// EncodeConfig converts a typed config struct into the flexible map stored on a timeline element
+func EncodeConfig(config interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode config: %v", err)
+	}
+	var out map[string]interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("failed to convert config: %v", err)
+	}
+	return out, nil
+}
+
+// DecodeWaypointMission returns the typed config of the mission's waypoint mission element
+func (m *Mission) DecodeWaypointMission() (*WaypointMissionConfig, error) {
+	element := m.GetWaypointMission()
+	if element == nil {
+		return nil, fmt.Errorf("mission has no waypoint mission")
+	}
+	var config WaypointMissionConfig
+	if err := element.DecodeConfig(&config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// normalizeConfigValue converts BSON container types (as returned by MongoDB)
+// into plain maps and slices so they can be round-tripped through JSON
+func normalizeConfigValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case primitive.D:
+		out := make(map[string]interface{}, len(v))
+		for _, elem := range v {
+			out[elem.Key] = normalizeConfigValue(elem.Value)
+		}
+		return out
+	case primitive.M:
+		return normalizeConfigValue(map[string]interface{}(v))
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, elem := range v {
+			out[key] = normalizeConfigValue(elem)
+		}
+		return out
+	case primitive.A:
+		return normalizeConfigValue([]interface{}(v))
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, elem := range v {
+			out[i] = normalizeConfigValue(elem)
+		}
+		return out
+	default:
+		return v
+	}
+}
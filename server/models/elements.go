@@ -0,0 +1,14 @@
+package models
+
+// Timeline element types
+const (
+	ElementWaypointMission = "waypoint-mission"
+	ElementRecordVideo     = "record-video"
+	ElementShootPhoto      = "shoot-photo"
+	ElementChangeHeading   = "change-heading"
+	ElementHover           = "hover"
+	ElementRotateGimbal    = "rotate-gimbal"
+	ElementCameraZoom      = "camera-zoom"
+	ElementPanorama        = "panorama"
+	ElementHotpointOrbit   = "hotpoint-orbit"
+)
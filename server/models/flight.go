@@ -23,6 +23,9 @@ type Flight struct {
 	FlightpathMode  string             `bson:"flightpath_mode" json:"flightpathMode"`
 	RepeatTimes     int                `bson:"repeat_times" json:"repeatTimes"`
 	TurnMode        string             `bson:"turn_mode" json:"turnMode"`
+	DroneType       string             `bson:"drone_type" json:"droneType"`
+	AltitudeMode    string             `bson:"altitude_mode" json:"altitudeMode"`
+	GeofenceIDs     []string           `bson:"geofence_ids" json:"geofenceIds"`
 	Actions         []Action           `bson:"actions" json:"actions"`
 	CreatedAt       time.Time          `bson:"created_at" json:"createdAt"`
 	UpdatedAt       time.Time          `bson:"updated_at" json:"updatedAt"`
@@ -39,10 +42,13 @@ type Action struct {
 	ActionParam float64 `json:"actionParam" bson:"actionParam"`
 }
 
-// FlightMetadata contains calculated flight information
+// FlightMetadata contains calculated flight information. Distances are in
+// kilometers, climbs in meters and durations in seconds.
 type FlightMetadata struct {
 	TotalWaypoints    int     `json:"totalWaypoints" bson:"totalWaypoints"`
 	TotalDistance     float64 `json:"totalDistance" bson:"totalDistance"`
+	TotalClimb        float64 `json:"totalClimb" bson:"totalClimb"`
+	TotalDescent      float64 `json:"totalDescent" bson:"totalDescent"`
 	EstimatedDuration float64 `json:"estimatedDuration" bson:"estimatedDuration"`
 }
 
@@ -77,6 +83,9 @@ func (f *Flight) ToJSON() map[string]interface{} {
 		"flightpathMode":  f.FlightpathMode,
 		"repeatTimes":     f.RepeatTimes,
 		"turnMode":        f.TurnMode,
+		"droneType":       f.DroneType,
+		"altitudeMode":    f.AltitudeMode,
+		"geofenceIds":     f.GeofenceIDs,
 		"actions":         f.Actions,
 	}
 }
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Geofence zone types
+const (
+	GeofenceInclusion = "inclusion" // Routes must stay inside
+	GeofenceExclusion = "exclusion" // Routes must stay outside
+)
+
+// Geofence enforcement, applied when a saved mission or flight violates a fence
+const (
+	GeofenceReject = "reject" // Refuse to save
+	GeofenceWarn   = "warn"   // Save and report the violations
+)
+
+// Geofence is a set of zones that missions and flights must respect, owned
+// by a user and optionally shared with their team
+type Geofence struct {
+	ID     primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID string             `bson:"user_id" json:"userId"`
+	// TeamID shares the fence with every member of a team when set
+	TeamID string         `bson:"team_id" json:"teamId"`
+	Name   string         `bson:"name" json:"name"`
+	Zones  []GeofenceZone `bson:"zones" json:"zones"`
+	// AltitudeMode is the reference of the zone altitude limits, one of the
+	// Altitude* constants; empty means relative to takeoff
+	AltitudeMode string `bson:"altitude_mode" json:"altitudeMode"`
+	// Enforcement is GeofenceReject or GeofenceWarn; empty means reject
+	Enforcement string `bson:"enforcement" json:"enforcement"`
+	// AutoAttach applies the fence to every mission and flight of the users
+	// who can see it, not only those that list it
+	AutoAttach bool      `bson:"auto_attach" json:"autoAttach"`
+	CreatedAt  time.Time `bson:"created_at" json:"createdAt"`
+	UpdatedAt  time.Time `bson:"updated_at" json:"updatedAt"`
+}
+
+// GeofenceZone is a polygon with optional altitude limits (m). Inclusion
+// zones bound the allowed altitudes inside them; exclusion zones only forbid
+// the altitudes between their limits.
+type GeofenceZone struct {
+	Type        string       `bson:"type" json:"type"`
+	Polygon     []Coordinate `bson:"polygon" json:"polygon"`
+	MinAltitude *float64     `bson:"min_altitude" json:"minAltitude"`
+	MaxAltitude *float64     `bson:"max_altitude" json:"maxAltitude"`
+}
+
+// NormalizeEnforcement returns the fence's enforcement, treating an empty one
+// as reject
+func (g *Geofence) NormalizeEnforcement() string {
+	if g.Enforcement == "" {
+		return GeofenceReject
+	}
+	return g.Enforcement
+}
+
+// ToJSON returns a map representation of the geofence suitable for JSON
+func (g *Geofence) ToJSON() map[string]interface{} {
+	return map[string]interface{}{
+		"id":           g.ID.Hex(),
+		"userId":       g.UserID,
+		"teamId":       g.TeamID,
+		"name":         g.Name,
+		"zones":        g.Zones,
+		"altitudeMode": g.AltitudeMode,
+		"enforcement":  g.NormalizeEnforcement(),
+		"autoAttach":   g.AutoAttach,
+		"createdAt":    g.CreatedAt,
+		"updatedAt":    g.UpdatedAt,
+	}
+}
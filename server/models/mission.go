@@ -30,7 +30,7 @@ type Mission struct {
 // TimelineElement represents a single element in the mission timeline
 type TimelineElement struct {
 	ID     string                 `bson:"id" json:"id"`
-	Type   string                 `bson:"type" json:"type"` // "waypoint-mission", "record-video", "shoot-photo", "change-heading"
+	Type   string                 `bson:"type" json:"type"` // One of the Element* constants
 	Order  int                    `bson:"order" json:"order"`
 	Config map[string]interface{} `bson:"config" json:"config"` // Flexible config based on type
 }
@@ -48,6 +48,9 @@ type GlobalMissionSettings struct {
 
 	// Drone type
 	DroneType string `bson:"drone_type" json:"droneType"`
+
+	// Geofences the mission must respect, in addition to auto-attached ones
+	GeofenceIDs []string `bson:"geofence_ids" json:"geofenceIds"`
 }
 
 // WaypointMissionConfig represents the configuration for a waypoint mission timeline element
@@ -61,6 +64,9 @@ type WaypointMissionConfig struct {
 	GimbalPitchRotationEnabled bool    `bson:"gimbal_pitch_rotation_enabled" json:"gimbalPitchRotationEnabled"`
 	HeadingMode                string  `bson:"heading_mode" json:"headingMode"`
 	FlightPathMode             string  `bson:"flight_path_mode" json:"flightPathMode"`
+	// AltitudeMode is the reference of the waypoint altitudes, one of the
+	// Altitude* constants; empty means relative to takeoff
+	AltitudeMode string `bson:"altitude_mode" json:"altitudeMode"`
 
 	// Targets/POIs
 	Targets []Target `bson:"targets" json:"targets"`
@@ -114,6 +120,40 @@ type ChangeHeadingActionConfig struct {
 	AngularVelocity float64 `bson:"angular_velocity" json:"angularVelocity"` // Degrees per second
 }
 
+// HoverActionConfig represents configuration for hover timeline action
+type HoverActionConfig struct {
+	Duration float64 `bson:"duration" json:"duration"` // Seconds to hold position
+}
+
+// RotateGimbalActionConfig represents configuration for rotate gimbal timeline action
+type RotateGimbalActionConfig struct {
+	Pitch      float64  `bson:"pitch" json:"pitch"`            // -90 to 30 degrees
+	Yaw        *float64 `bson:"yaw" json:"yaw"`                // -180 to 180 degrees relative to the aircraft, optional
+	RotateTime float64  `bson:"rotate_time" json:"rotateTime"` // Seconds, 0 rotates as fast as possible
+}
+
+// CameraZoomActionConfig represents configuration for camera zoom timeline action
+type CameraZoomActionConfig struct {
+	FocalLength float64 `bson:"focal_length" json:"focalLength"` // 35mm equivalent focal length in mm
+}
+
+// PanoramaActionConfig represents configuration for panorama timeline action
+type PanoramaActionConfig struct {
+	PhotoCount  int     `bson:"photo_count" json:"photoCount"`   // Photos around the sweep (2-36)
+	SweepAngle  float64 `bson:"sweep_angle" json:"sweepAngle"`   // Degrees covered, 360 for a full circle
+	GimbalPitch float64 `bson:"gimbal_pitch" json:"gimbalPitch"` // -90 to 30 degrees
+}
+
+// HotpointOrbitConfig represents configuration for hotpoint orbit timeline element
+type HotpointOrbitConfig struct {
+	Center    Coordinate `bson:"center" json:"center"`
+	Radius    float64    `bson:"radius" json:"radius"`     // Meters (5-500)
+	Altitude  float64    `bson:"altitude" json:"altitude"` // Meters relative to takeoff
+	Speed     float64    `bson:"speed" json:"speed"`       // Meters per second
+	Laps      float64    `bson:"laps" json:"laps"`         // Turns around the center
+	Clockwise bool       `bson:"clockwise" json:"clockwise"`
+}
+
 // Supporting types
 
 // Coordinate represents a 2D position
@@ -130,12 +170,15 @@ type Target struct {
 	Lng  float64 `bson:"lng" json:"lng"`
 }
 
-// MissionMetadata contains calculated mission information
+// MissionMetadata contains calculated mission information. Distances are in
+// kilometers, climbs in meters and durations in seconds.
 type MissionMetadata struct {
 	TotalTimelineElements int     `bson:"total_timeline_elements" json:"totalTimelineElements"`
 	HasWaypointMission    bool    `bson:"has_waypoint_mission" json:"hasWaypointMission"`
 	TotalWaypoints        int     `bson:"total_waypoints" json:"totalWaypoints"`
 	TotalDistance         float64 `bson:"total_distance" json:"totalDistance"`
+	TotalClimb            float64 `bson:"total_climb" json:"totalClimb"`
+	TotalDescent          float64 `bson:"total_descent" json:"totalDescent"`
 	EstimatedDuration     float64 `bson:"estimated_duration" json:"estimatedDuration"`
 }
 
@@ -183,9 +226,9 @@ func (m *Mission) AddTimelineElement(elementType string, config map[string]inter
 
 // GetWaypointMission returns the waypoint mission element if it exists
 func (m *Mission) GetWaypointMission() *TimelineElement {
-	for _, element := range m.TimelineElements {
-		if element.Type == "waypoint-mission" {
-			return &element
+	for i := range m.TimelineElements {
+		if m.TimelineElements[i].Type == ElementWaypointMission {
+			return &m.TimelineElements[i]
 		}
 	}
 	return nil
@@ -0,0 +1,547 @@
+package simulator
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"drone-planner/server/drones"
+	"drone-planner/server/geometry"
+	"drone-planner/server/models"
+)
+
+// Failure types
+const (
+	FailureSignalLost  = "signalLost"
+	FailureLowBattery  = "lowBattery"
+	FailureGPSDegraded = "gpsDegraded"
+)
+
+// FailureTypes lists every failure a dry run can inject
+var FailureTypes = []string{FailureSignalLost, FailureLowBattery, FailureGPSDegraded}
+
+// Failsafe actions
+const (
+	ActionContinue   = "continue"
+	ActionHover      = "hover"
+	ActionLand       = "land"
+	ActionReturnHome = "returnHome"
+	ActionPause      = "pause" // GPS loss: hold until it returns, then resume
+	ActionNone       = "none"  // the aircraft is not flying or already failsafe
+)
+
+// Events of dry runs
+const (
+	EventSignalLost  = "signalLost"
+	EventLowBattery  = "lowBattery"
+	EventGPSLost     = "gpsLost"
+	EventGPSRestored = "gpsRestored"
+	EventReturnHome  = "returnHome"
+)
+
+// Finding kinds
+const (
+	FindingRTHUnreachable  = "rthUnreachable"
+	FindingBatteryDepleted = "batteryDepleted"
+	FindingTerrain         = "terrain"
+	FindingGPSDelay        = "gpsDelay"
+	FindingNoEnergyModel   = "noEnergyModel"
+	FindingNoTerrain       = "noTerrain"
+)
+
+const (
+	// minRTHAltitude is the lowest return-to-home altitude computed (m)
+	minRTHAltitude = 20.0
+	// minRTHClearance is the height above terrain a return home must keep (m)
+	minRTHClearance = 20.0
+	// rthSampleSpacing is the distance between terrain checks on the way
+	// home (m)
+	rthSampleSpacing = 25.0
+	// batteryStep is the integration step of the battery drain (s)
+	batteryStep = 1.0
+	// emptyLevel is the battery level (%) taken as empty, so that hovers
+	// lasting exactly until the battery runs out are flagged
+	emptyLevel = 0.1
+	// defaultHoverLimit bounds failsafe hovers when the drone's battery is
+	// unknown (s)
+	defaultHoverLimit = 600.0
+)
+
+// Failure is a failure injected into a dry run
+type Failure struct {
+	Type string `json:"type"`
+	// Time is when the failure occurs (s after the start). Low battery
+	// without a time occurs when the battery reaches the threshold.
+	Time *float64 `json:"time"`
+	// Duration is the length of a GPS-degraded window (s)
+	Duration float64 `json:"duration"`
+}
+
+// Scenario is a dry run's failures and the aircraft's failsafe settings
+type Scenario struct {
+	Failures         []Failure
+	SignalLostAction string
+	BatteryAction    string
+	// BatteryThreshold is the battery level (%) that triggers BatteryAction
+	BatteryThreshold float64
+	// RTHAltitude is the aircraft's return-to-home altitude (m relative to
+	// takeoff); zero computes one that clears the terrain on the way home
+	RTHAltitude float64
+	// RTHSpeed is the return-to-home speed (m/s); zero uses the default
+	RTHSpeed float64
+	// Profile powers the battery checks; nil skips them
+	Profile *drones.Profile
+	// Clearance returns the height above ground of an altitude relative to
+	// takeoff at home; nil skips the terrain checks
+	Clearance func(home geometry.Point, lat, lng, altitude float64) (float64, error)
+}
+
+// Response is what the aircraft does about a failure
+type Response struct {
+	Failure string  `json:"failure"`
+	Time    float64 `json:"time"`
+	Action  string  `json:"action"`
+	// Start is when the action begins, later than Time when the aircraft
+	// must wait for GPS
+	Start       float64 `json:"start"`
+	RTHAltitude float64 `json:"rthAltitude,omitempty"`
+	Note        string  `json:"note,omitempty"`
+}
+
+// Finding is a problem a dry run reveals
+type Finding struct {
+	Kind    string  `json:"kind"`
+	Time    float64 `json:"time"`
+	Message string  `json:"message"`
+}
+
+// DryRunResult is the flight a mission makes with failures injected
+type DryRunResult struct {
+	Trajectory *Trajectory `json:"trajectory"`
+	Responses  []Response  `json:"responses"`
+	Findings   []Finding   `json:"findings"`
+	// Remaining is the battery left at the end (%), nil without a profile
+	Remaining *float64 `json:"remaining,omitempty"`
+}
+
+// dryRun is the state of a dry run
+type dryRun struct {
+	*sim
+	scenario Scenario
+	result   *DryRunResult
+	// failsafe is the action in progress, empty while flying the mission
+	failsafe string
+	// windows are the GPS-degraded intervals
+	windows   [][2]float64
+	rthStart  float64
+	noTerrain bool
+}
+
+// DryRun flies a mission like SimulateMission with failures injected. Each
+// failure stops the aircraft where it is and triggers the failsafe action:
+// signal loss and low battery hover, land or return home at a computed
+// altitude, GPS loss pauses until GPS returns. The battery is drained along
+// the way to trigger the low battery action and flag returns that cannot
+// make it home.
+func DryRun(mission *models.Mission, relative AltitudeFunc, options Options, scenario Scenario) (*DryRunResult, error) {
+	s, err := missionSim(mission, relative, options)
+	if err != nil {
+		return nil, err
+	}
+	if scenario.Profile != nil && scenario.Profile.BatteryWh <= 0 {
+		scenario.Profile = nil
+	}
+	d := &dryRun{sim: s, scenario: scenario, result: &DryRunResult{Responses: []Response{}, Findings: []Finding{}}}
+
+	failures := append([]Failure{}, scenario.Failures...)
+	sort.SliceStable(failures, func(i, j int) bool {
+		return failures[i].Time != nil && (failures[j].Time == nil || *failures[i].Time < *failures[j].Time)
+	})
+	batteryInjected := false
+	for _, failure := range failures {
+		if failure.Time == nil {
+			continue
+		}
+		if failure.Type == FailureLowBattery {
+			batteryInjected = true
+		}
+		d.inject(failure.Type, *failure.Time, failure.Duration)
+	}
+
+	if scenario.Profile == nil {
+		d.find(FindingNoEnergyModel, 0, "the drone type has no battery profile; battery levels are not checked")
+	} else {
+		if !batteryInjected && scenario.BatteryThreshold > 0 {
+			if _, crossing, ok := d.drain(scenario.BatteryThreshold); ok {
+				d.inject(FailureLowBattery, crossing, 0)
+			}
+		}
+		d.checkBattery()
+	}
+
+	trajectory, err := s.trajectory()
+	if err != nil {
+		return nil, err
+	}
+	d.result.Trajectory = trajectory
+	sort.SliceStable(d.result.Findings, func(i, j int) bool { return d.result.Findings[i].Time < d.result.Findings[j].Time })
+	return d.result, nil
+}
+
+// inject makes a failure happen at a time
+func (d *dryRun) inject(kind string, t, duration float64) {
+	response := Response{Failure: kind, Time: t, Start: t, Action: ActionNone}
+	defer func() { d.result.Responses = append(d.result.Responses, response) }()
+
+	k := 0
+	at, _ := d.stateAt(t, &k)
+	if t >= d.time || at.altitude <= 0 && at.speed == 0 && at.verticalSpeed == 0 {
+		response.Note = "the aircraft is on the ground"
+		return
+	}
+
+	if kind == FailureGPSDegraded {
+		response.Action = ActionPause
+		d.pause(t, duration)
+		return
+	}
+
+	event, action := EventSignalLost, failsafeAction(d.scenario.SignalLostAction)
+	if kind == FailureLowBattery {
+		event, action = EventLowBattery, failsafeAction(d.scenario.BatteryAction)
+	}
+	if d.failsafe == ActionReturnHome || d.failsafe == ActionLand {
+		d.eventAt(t, event, 0)
+		response.Note = "the aircraft is already flying its " + d.failsafe + " failsafe"
+		return
+	}
+	response.Action = action
+	if action == ActionContinue {
+		d.eventAt(t, event, 0)
+		return
+	}
+
+	// Failsafes need GPS to navigate, so they wait for a degraded window to end
+	start := t
+	for _, window := range d.windows {
+		if start >= window[0] && start < window[1] {
+			start = window[1]
+		}
+	}
+	if start >= d.time {
+		response.Action = ActionNone
+		response.Note = "the flight ends before GPS returns"
+		return
+	}
+	if start > t {
+		response.Start = start
+		d.find(FindingGPSDelay, t, fmt.Sprintf("the %s failsafe waits %.0f s for GPS to return", action, start-t))
+	}
+
+	d.cut(start)
+	d.eventAt(t, event, 0)
+	d.brake()
+	d.airborne = true
+	d.failsafe = action
+	d.element = 0
+	switch action {
+	case ActionHover:
+		d.wait(d.hoverLimit())
+	case ActionLand:
+		d.land()
+	case ActionReturnHome:
+		response.RTHAltitude = d.returnHome()
+	}
+}
+
+// failsafeAction maps a mission's signal-lost or battery action to the
+// failsafe flown; unset actions return home
+func failsafeAction(action string) string {
+	switch action {
+	case "continue":
+		return ActionContinue
+	case "hover", "stop_timeline":
+		return ActionHover
+	case "landing", "land", "LAND":
+		return ActionLand
+	}
+	return ActionReturnHome
+}
+
+// pause holds the aircraft while GPS is degraded, then flies back to where
+// it stopped and resumes the rest of the flight
+func (d *dryRun) pause(t, duration float64) {
+	d.windows = append(d.windows, [2]float64{t, t + duration})
+	rest, events := d.cut(t)
+	stopped := d.current
+	d.eventAt(t, EventGPSLost, 0)
+	d.brake()
+	d.wait(t + duration - d.time)
+	d.event(EventGPSRestored, 0)
+	d.flyTo(stopped.x, stopped.y, stopped.altitude, 0)
+	d.turnTo(stopped.heading, 0)
+	d.resume(rest, events)
+}
+
+// returnHome climbs to the return altitude, flies home and lands, returning
+// the altitude flown
+func (d *dryRun) returnHome() float64 {
+	d.rthStart = d.time
+	altitude := d.rthAltitude()
+	d.event(EventReturnHome, 0)
+	if altitude > d.current.altitude {
+		d.climbTo(altitude)
+	}
+	d.flyTo(0, 0, d.current.altitude, d.scenario.RTHSpeed)
+	d.land()
+	return altitude
+}
+
+// rthAltitude returns the altitude of a return home from the current
+// position. A set RTH altitude is flown as is and flagged when it passes too
+// close to the terrain; otherwise the altitude clears the highest ground on
+// the way home.
+func (d *dryRun) rthAltitude() float64 {
+	ground, ok := d.highestGround()
+	if d.scenario.RTHAltitude > 0 {
+		altitude := math.Max(d.current.altitude, d.scenario.RTHAltitude)
+		if ok && altitude-ground < minRTHClearance {
+			d.find(FindingTerrain, d.time, fmt.Sprintf("returning home at %.0f m passes %.0f m above terrain", altitude, altitude-ground))
+		}
+		return altitude
+	}
+	altitude := math.Max(d.current.altitude, minRTHAltitude)
+	if ok {
+		altitude = math.Max(altitude, ground+minRTHClearance)
+	}
+	return altitude
+}
+
+// highestGround returns the highest ground (m relative to takeoff) on the
+// straight way home
+func (d *dryRun) highestGround() (float64, bool) {
+	if d.scenario.Clearance == nil {
+		return 0, false
+	}
+	x, y := d.current.x, d.current.y
+	steps := int(math.Ceil(math.Hypot(x, y) / rthSampleSpacing))
+	highest := math.Inf(-1)
+	for i := 0; i <= steps; i++ {
+		f := 1.0
+		if steps > 0 {
+			f = float64(i) / float64(steps)
+		}
+		lat, lng := d.plane.Unproject(x*(1-f), y*(1-f))
+		clearance, err := d.scenario.Clearance(d.home, lat, lng, d.current.altitude)
+		if err != nil {
+			if !d.noTerrain {
+				d.noTerrain = true
+				d.find(FindingNoTerrain, d.time, "terrain is not checked on the way home: "+err.Error())
+			}
+			return 0, false
+		}
+		highest = math.Max(highest, d.current.altitude-clearance)
+	}
+	return highest, true
+}
+
+// hoverLimit returns how long a failsafe hover can last: until the battery
+// is empty, or defaultHoverLimit without a profile
+func (d *dryRun) hoverLimit() float64 {
+	profile := d.scenario.Profile
+	if profile == nil || profile.Power.HoverPower <= 0 {
+		return defaultHoverLimit
+	}
+	used, _, _ := d.drain(0)
+	return math.Max(0, profile.BatteryWh-used) / profile.Power.HoverPower * 3600
+}
+
+// checkBattery flags the flight running out of battery
+func (d *dryRun) checkBattery() {
+	profile := d.scenario.Profile
+	used, depleted, empty := d.drain(emptyLevel)
+	remaining := math.Max(0, 100*(profile.BatteryWh-used)/profile.BatteryWh)
+	d.result.Remaining = &remaining
+	if !empty {
+		return
+	}
+
+	k := 0
+	at, _ := d.stateAt(depleted, &k)
+	if d.failsafe == ActionReturnHome && depleted >= d.rthStart {
+		d.find(FindingRTHUnreachable, depleted, fmt.Sprintf("the battery runs out returning home, %.0f m from home at %.0f m", math.Hypot(at.x, at.y), at.altitude))
+		return
+	}
+	d.find(FindingBatteryDepleted, depleted, fmt.Sprintf("the battery runs out %.0f m from home at %.0f m", math.Hypot(at.x, at.y), at.altitude))
+}
+
+// drain integrates the battery used over the flight so far, returning the
+// energy used (Wh) and the first time the battery falls below level (%)
+func (d *dryRun) drain(level float64) (used, crossing float64, crossed bool) {
+	profile := d.scenario.Profile
+	k := 0
+	for t := 0.0; t < d.time; t += batteryStep {
+		at, _ := d.stateAt(t, &k)
+		if at.altitude <= 0 && at.speed == 0 && at.verticalSpeed == 0 {
+			continue
+		}
+		used += profile.PowerDraw(at.speed, at.verticalSpeed) * math.Min(batteryStep, d.time-t) / 3600
+		if !crossed && 100*(profile.BatteryWh-used)/profile.BatteryWh < level {
+			crossing, crossed = t, true
+		}
+	}
+	return used, crossing, crossed
+}
+
+// find records a finding
+func (d *dryRun) find(kind string, t float64, message string) {
+	d.result.Findings = append(d.result.Findings, Finding{Kind: kind, Time: t, Message: message})
+}
+
+// truncated is a move stopped part way
+type truncated struct {
+	move
+	end float64
+}
+
+func (t truncated) duration() float64 { return t.end }
+
+func (t truncated) distance() float64 { return flownDistance(t.move, t.end) }
+
+// flownDistance returns the length flown in the first t seconds of a move
+func flownDistance(m move, t float64) float64 {
+	switch m := m.(type) {
+	case leg:
+		d, _ := m.profile.at(t)
+		return d
+	case orbit:
+		return m.speed * math.Min(t, m.duration())
+	case hold:
+		return math.Abs(m.at(t).altitude - m.from.altitude)
+	case truncated:
+		return flownDistance(m.move, math.Min(t, m.end))
+	}
+	return 0
+}
+
+// remainder returns the rest of a move after t seconds, flown from a stop
+func remainder(m move, t, acceleration float64) move {
+	switch m := m.(type) {
+	case hold:
+		return hold{from: m.at(t), heading: m.heading, pitch: m.pitch, altitude: m.altitude, seconds: m.seconds - t}
+	case orbit:
+		f := t / m.duration()
+		m.start += m.sweep * f
+		m.sweep *= 1 - f
+		return m
+	case leg:
+		flown, _ := m.profile.at(t)
+		rest := m.profile.length - flown
+		if m.profile.length > 0 {
+			m.skip += (1 - m.skip) * flown / m.profile.length
+		}
+		exit := math.Min(m.profile.exit, math.Sqrt(2*acceleration*rest))
+		m.profile = newProfile(rest, 0, math.Max(m.profile.peak, exit), exit, acceleration)
+		return m
+	case truncated:
+		return remainder(m.move, t, acceleration)
+	}
+	return m
+}
+
+// cut ends the simulation at time t, leaving the aircraft in the state it
+// had then, and returns the moves and events after it. The move flown at t
+// is split and the rest of it resumed from a stop. Event times are made
+// relative to t.
+func (s *sim) cut(t float64) ([]span, []Event) {
+	k := 0
+	s.current, s.element = s.stateAt(t, &k)
+	var kept, rest []span
+	s.distance = 0
+	for _, sp := range s.spans {
+		end := sp.start + sp.move.duration()
+		switch {
+		case end <= t:
+			kept = append(kept, sp)
+			s.distance += sp.move.distance()
+		case sp.start >= t:
+			sp.start -= t
+			rest = append(rest, sp)
+		default:
+			elapsed := t - sp.start
+			part := truncated{move: sp.move, end: elapsed}
+			kept = append(kept, span{move: part, start: sp.start, element: sp.element})
+			s.distance += part.distance()
+			rest = append(rest, span{move: remainder(sp.move, elapsed, s.options.Acceleration), element: sp.element})
+		}
+	}
+
+	var events, later []Event
+	for _, e := range s.events {
+		if e.Time > t {
+			e.Time -= t
+			later = append(later, e)
+		} else {
+			events = append(events, e)
+		}
+	}
+	s.spans, s.events = kept, events
+	if t < s.time {
+		s.time = t
+	}
+	return rest, later
+}
+
+// resume appends moves and events cut off by cut. The first move may be
+// shorter or longer than its original, so events are moved with the move
+// they fall in.
+func (s *sim) resume(rest []span, events []Event) {
+	type shift struct{ from, to, start float64 }
+	shifts := make([]shift, 0, len(rest))
+	for i, sp := range rest {
+		end := sp.start + sp.move.duration()
+		if i == 0 && len(rest) > 1 {
+			end = rest[1].start
+		}
+		shifts = append(shifts, shift{from: sp.start, to: end, start: s.time})
+		element := s.element
+		s.element = sp.element
+		s.add(sp.move)
+		s.element = element
+	}
+	for _, e := range events {
+		time := s.time
+		for _, sh := range shifts {
+			if e.Time < sh.to {
+				time = sh.start + math.Max(0, e.Time-sh.from)
+				break
+			}
+		}
+		e.Time = time
+		s.events = append(s.events, e)
+	}
+	if len(rest) > 0 {
+		s.element = rest[len(rest)-1].element
+	}
+}
+
+// brake stops the aircraft along its track from the speed it was flying
+func (s *sim) brake() {
+	from := s.current
+	if from.speed < 0.1 {
+		s.current.speed, s.current.verticalSpeed = 0, 0
+		return
+	}
+	length := from.speed * from.speed / (2 * s.options.Acceleration)
+	track := from.track * math.Pi / 180
+	to := [2]float64{from.x + length*math.Sin(track), from.y + length*math.Cos(track)}
+	s.add(leg{
+		path:         straightPath([2]float64{from.x, from.y}, to),
+		fromAltitude: from.altitude,
+		toAltitude:   from.altitude,
+		fromPitch:    from.gimbalPitch,
+		toPitch:      from.gimbalPitch,
+		heading:      holdHeading(from.heading),
+		profile:      newProfile(length, from.speed, from.speed, 0, s.options.Acceleration),
+	})
+}
@@ -0,0 +1,177 @@
+package simulator
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"drone-planner/server/drones"
+	"drone-planner/server/geometry"
+	"drone-planner/server/models"
+)
+
+func TestDryRun(t *testing.T) {
+	// A 1000 m line east at 20 m. At 50 s the aircraft cruises 405 m out at
+	// 10 m/s and brakes to a stop 430 m out 5 s later; flying home then takes
+	// a 6 s turn, 48 s back and 20/3 s to land.
+	mission := testMission(t, models.WaypointMissionConfig{
+		AutoFlightSpeed: 10,
+		Waypoints:       []models.Waypoint{waypointAt(0, 0, 20), waypointAt(1000, 0, 20)},
+	})
+	at := func(seconds float64) *float64 { return &seconds }
+	// battery draws 0.1 Wh every second in the air
+	battery := func(wh float64) *drones.Profile {
+		return &drones.Profile{BatteryWh: wh, Power: drones.PowerModel{HoverPower: 360}}
+	}
+	// ridge is 50 m high ground up to 300 m east of home
+	ridge := func(home geometry.Point, lat, lng, altitude float64) (float64, error) {
+		if x, _ := testPlane.Project(lat, lng); x < 300 {
+			return altitude - 50, nil
+		}
+		return altitude, nil
+	}
+	noTerrain := func(home geometry.Point, lat, lng, altitude float64) (float64, error) {
+		return 0, fmt.Errorf("no elevation data")
+	}
+	home := 6 + 48 + 20.0/3
+
+	type event struct {
+		kind string
+		time float64
+	}
+	tests := []struct {
+		name     string
+		scenario Scenario
+		duration float64
+		// responses are the actions and when they start
+		responses []Response
+		findings  []string
+		events    []event
+	}{
+		{"signal lost", Scenario{Failures: []Failure{{Type: FailureSignalLost, Time: at(50)}}},
+			55 + home,
+			[]Response{{Failure: FailureSignalLost, Time: 50, Start: 50, Action: ActionReturnHome, RTHAltitude: 20}},
+			[]string{FindingNoEnergyModel},
+			[]event{{EventSignalLost, 50}, {EventReturnHome, 55}, {EventLand, 109}}},
+		{"signal lost with an RTH altitude", Scenario{Failures: []Failure{{Type: FailureSignalLost, Time: at(50)}}, RTHAltitude: 60},
+			// The climb to 60 m takes 8 s and the landing 20 s
+			55 + 8 + 6 + 48 + 20,
+			[]Response{{Failure: FailureSignalLost, Time: 50, Start: 50, Action: ActionReturnHome, RTHAltitude: 60}},
+			[]string{FindingNoEnergyModel},
+			[]event{{EventSignalLost, 50}, {EventReturnHome, 55}, {EventLand, 117}}},
+		{"signal lost hovers", Scenario{Failures: []Failure{{Type: FailureSignalLost, Time: at(50)}}, SignalLostAction: "hover"},
+			55 + defaultHoverLimit,
+			[]Response{{Failure: FailureSignalLost, Time: 50, Start: 50, Action: ActionHover}},
+			[]string{FindingNoEnergyModel},
+			[]event{{EventSignalLost, 50}}},
+		{"signal lost lands", Scenario{Failures: []Failure{{Type: FailureSignalLost, Time: at(50)}}, SignalLostAction: "land"},
+			55 + 20.0/3,
+			[]Response{{Failure: FailureSignalLost, Time: 50, Start: 50, Action: ActionLand}},
+			[]string{FindingNoEnergyModel},
+			[]event{{EventSignalLost, 50}, {EventLand, 55}}},
+		{"signal lost continues", Scenario{Failures: []Failure{{Type: FailureSignalLost, Time: at(50)}}, SignalLostAction: "continue"},
+			112,
+			[]Response{{Failure: FailureSignalLost, Time: 50, Start: 50, Action: ActionContinue}},
+			[]string{FindingNoEnergyModel},
+			[]event{{EventSignalLost, 50}}},
+		{"on the ground", Scenario{Failures: []Failure{{Type: FailureSignalLost, Time: at(500)}}},
+			112,
+			[]Response{{Failure: FailureSignalLost, Time: 500, Start: 500, Action: ActionNone}},
+			[]string{FindingNoEnergyModel},
+			nil},
+		{"battery threshold", Scenario{BatteryThreshold: 70, Profile: battery(20)},
+			// 30% of 20 Wh is gone after 60 s, 505 m out
+			65 + 6 + 58 + 20.0/3,
+			[]Response{{Failure: FailureLowBattery, Time: 60, Start: 60, Action: ActionReturnHome, RTHAltitude: 20}},
+			nil,
+			[]event{{EventLowBattery, 60}, {EventReturnHome, 65}, {EventLand, 129}}},
+		{"cannot reach home", Scenario{BatteryThreshold: 50, Profile: battery(12)},
+			65 + 6 + 58 + 20.0/3,
+			[]Response{{Failure: FailureLowBattery, Time: 60, Start: 60, Action: ActionReturnHome, RTHAltitude: 20}},
+			[]string{FindingRTHUnreachable},
+			[]event{{EventLowBattery, 60}, {EventReturnHome, 65}, {EventLand, 129}}},
+		{"battery runs out hovering", Scenario{Failures: []Failure{{Type: FailureSignalLost, Time: at(50)}}, SignalLostAction: "hover", Profile: battery(20)},
+			200,
+			[]Response{{Failure: FailureSignalLost, Time: 50, Start: 50, Action: ActionHover}},
+			[]string{FindingBatteryDepleted},
+			[]event{{EventSignalLost, 50}}},
+		{"GPS degraded", Scenario{Failures: []Failure{{Type: FailureGPSDegraded, Time: at(50), Duration: 20}}},
+			// Back 25 m to where it stopped between two 6 s turns, then the
+			// remaining 595 m from a stop
+			70 + 6 + 2*math.Sqrt(12.5) + 6 + 64.5,
+			[]Response{{Failure: FailureGPSDegraded, Time: 50, Start: 50, Action: ActionPause}},
+			[]string{FindingNoEnergyModel},
+			[]event{{EventGPSLost, 50}, {EventGPSRestored, 70}}},
+		{"signal lost without GPS", Scenario{Failures: []Failure{
+			{Type: FailureGPSDegraded, Time: at(50), Duration: 20},
+			{Type: FailureSignalLost, Time: at(60)},
+		}},
+			70 + home,
+			[]Response{
+				{Failure: FailureGPSDegraded, Time: 50, Start: 50, Action: ActionPause},
+				{Failure: FailureSignalLost, Time: 60, Start: 70, Action: ActionReturnHome, RTHAltitude: 20},
+			},
+			[]string{FindingNoEnergyModel, FindingGPSDelay},
+			[]event{{EventGPSLost, 50}, {EventSignalLost, 60}, {EventGPSRestored, 70}, {EventReturnHome, 70}, {EventLand, 124}}},
+		{"RTH altitude over terrain", Scenario{Failures: []Failure{{Type: FailureSignalLost, Time: at(50)}}, Clearance: ridge},
+			// The climb to 70 m takes 10 s and the landing 70/3 s
+			55 + 10 + 6 + 48 + 70.0/3,
+			[]Response{{Failure: FailureSignalLost, Time: 50, Start: 50, Action: ActionReturnHome, RTHAltitude: 70}},
+			[]string{FindingNoEnergyModel},
+			[]event{{EventSignalLost, 50}, {EventReturnHome, 55}, {EventLand, 119}}},
+		{"RTH altitude too low for the terrain", Scenario{Failures: []Failure{{Type: FailureSignalLost, Time: at(50)}}, Clearance: ridge, RTHAltitude: 40},
+			55 + 4 + 6 + 48 + 40.0/3,
+			[]Response{{Failure: FailureSignalLost, Time: 50, Start: 50, Action: ActionReturnHome, RTHAltitude: 40}},
+			[]string{FindingNoEnergyModel, FindingTerrain},
+			[]event{{EventSignalLost, 50}, {EventReturnHome, 55}, {EventLand, 113}}},
+		{"no terrain data", Scenario{Failures: []Failure{{Type: FailureSignalLost, Time: at(50)}}, Clearance: noTerrain},
+			55 + home,
+			[]Response{{Failure: FailureSignalLost, Time: 50, Start: 50, Action: ActionReturnHome, RTHAltitude: 20}},
+			[]string{FindingNoEnergyModel, FindingNoTerrain},
+			[]event{{EventSignalLost, 50}, {EventReturnHome, 55}, {EventLand, 109}}},
+	}
+	failsafeEvents := map[string]bool{
+		EventSignalLost: true, EventLowBattery: true, EventGPSLost: true, EventGPSRestored: true, EventReturnHome: true, EventLand: true,
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := DryRun(mission, relativeAltitudes, Options{}, tt.scenario)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d := result.Trajectory.Duration; math.Abs(d-tt.duration) > 1e-6 {
+				t.Errorf("duration = %g s, want %g", d, tt.duration)
+			}
+			if len(result.Responses) != len(tt.responses) {
+				t.Fatalf("responses %+v, want %+v", result.Responses, tt.responses)
+			}
+			for i, want := range tt.responses {
+				got := result.Responses[i]
+				got.Note = ""
+				if got.Failure != want.Failure || got.Action != want.Action || math.Abs(got.Time-want.Time) > 1e-9 ||
+					math.Abs(got.Start-want.Start) > 1e-9 || math.Abs(got.RTHAltitude-want.RTHAltitude) > 1e-9 {
+					t.Errorf("response %d = %+v, want %+v", i, got, want)
+				}
+			}
+			var findings []string
+			for _, f := range result.Findings {
+				findings = append(findings, f.Kind)
+			}
+			if fmt.Sprint(findings) != fmt.Sprint(tt.findings) {
+				t.Errorf("findings %+v, want %v", result.Findings, tt.findings)
+			}
+			var events []event
+			for _, e := range result.Trajectory.Events {
+				if failsafeEvents[e.Type] {
+					events = append(events, event{e.Type, math.Round(e.Time*1000) / 1000})
+				}
+			}
+			if fmt.Sprint(events) != fmt.Sprint(tt.events) {
+				t.Errorf("events %v, want %v", events, tt.events)
+			}
+			if (tt.scenario.Profile == nil) != (result.Remaining == nil) {
+				t.Errorf("remaining = %v with profile %v", result.Remaining, tt.scenario.Profile)
+			}
+		})
+	}
+}
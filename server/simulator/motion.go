@@ -0,0 +1,336 @@
+package simulator
+
+import (
+	"math"
+)
+
+// bezierSteps is the resolution of the arc-length table of curved paths
+const bezierSteps = 32
+
+// state is the aircraft's state at an instant. Positions are plane
+// coordinates (m east and north of home) and altitudes relative to takeoff.
+type state struct {
+	x, y, altitude float64
+	heading        float64
+	gimbalPitch    float64
+	// speed is the ground speed and verticalSpeed the climb rate (m/s)
+	speed         float64
+	verticalSpeed float64
+	// track is the direction of travel (degrees clockwise from north)
+	track float64
+}
+
+// move is one piece of a trajectory whose state is known at every instant
+type move interface {
+	duration() float64
+	// at returns the state t seconds into the move
+	at(t float64) state
+	// distance returns the length flown (m)
+	distance() float64
+}
+
+// hold keeps the aircraft over one spot while it turns, tilts the gimbal or
+// climbs, each changing linearly over the hold
+type hold struct {
+	from     state
+	heading  float64
+	pitch    float64
+	altitude float64
+	seconds  float64
+}
+
+func (h hold) duration() float64 { return h.seconds }
+
+func (h hold) distance() float64 { return math.Abs(h.altitude - h.from.altitude) }
+
+func (h hold) at(t float64) state {
+	f := 1.0
+	if h.seconds > 0 {
+		f = math.Min(1, t/h.seconds)
+	}
+	s := h.from
+	s.heading = lerpAngle(h.from.heading, h.heading, f)
+	s.gimbalPitch = h.from.gimbalPitch + (h.pitch-h.from.gimbalPitch)*f
+	s.altitude = h.from.altitude + (h.altitude-h.from.altitude)*f
+	s.speed, s.verticalSpeed = 0, 0
+	if h.seconds > 0 && f < 1 {
+		s.verticalSpeed = (h.altitude - h.from.altitude) / h.seconds
+	}
+	return s
+}
+
+// headingFunc returns the heading at a point of a leg: f is the fraction of
+// the leg flown, (x, y) the position and track the direction of travel
+type headingFunc func(f, x, y, track float64) float64
+
+// interpolateHeading turns evenly from one heading to another over a leg
+func interpolateHeading(from, to float64) headingFunc {
+	return func(f, x, y, track float64) float64 { return lerpAngle(from, to, f) }
+}
+
+// holdHeading keeps one heading over a leg
+func holdHeading(heading float64) headingFunc {
+	return func(f, x, y, track float64) float64 { return heading }
+}
+
+// travelHeading faces the direction of travel
+func travelHeading(f, x, y, track float64) float64 { return track }
+
+// towardHeading faces a point of interest
+func towardHeading(px, py float64) headingFunc {
+	return func(f, x, y, track float64) float64 {
+		if math.Hypot(px-x, py-y) < 0.01 {
+			return track
+		}
+		return bearing(x, y, px, py)
+	}
+}
+
+// leg flies a path with a trapezoidal speed profile, changing altitude
+// evenly along the ground track
+type leg struct {
+	path         path
+	fromAltitude float64
+	toAltitude   float64
+	fromPitch    float64
+	toPitch      float64
+	heading      headingFunc
+	profile      profile
+	// skip is the fraction of the path flown before the leg starts, for legs
+	// resumed part way
+	skip float64
+}
+
+func (l leg) duration() float64 { return l.profile.duration() }
+
+func (l leg) distance() float64 { return l.profile.length }
+
+func (l leg) at(t float64) state {
+	s, v := l.profile.at(t)
+	f := 1.0
+	if l.profile.length > 0 {
+		f = l.skip + (1-l.skip)*math.Min(1, s/l.profile.length)
+	}
+	x, y, track := l.path.point(f)
+	climb := l.toAltitude - l.fromAltitude
+	result := state{
+		x: x, y: y,
+		altitude:    l.fromAltitude + climb*f,
+		heading:     normalizeHeading(l.heading(f, x, y, track)),
+		gimbalPitch: l.fromPitch + (l.toPitch-l.fromPitch)*f,
+		track:       track,
+	}
+	if length := math.Hypot(l.path.length(), climb); length > 0 {
+		result.speed = v * l.path.length() / length
+		result.verticalSpeed = v * climb / length
+	}
+	return result
+}
+
+// orbit circles a center at a constant speed facing it
+type orbit struct {
+	cx, cy   float64
+	radius   float64
+	altitude float64
+	pitch    float64
+	// start is the angle (radians, counter-clockwise from east) of the
+	// starting point and sweep the signed angle flown
+	start, sweep float64
+	speed        float64
+}
+
+func (o orbit) duration() float64 { return math.Abs(o.sweep) * o.radius / o.speed }
+
+func (o orbit) distance() float64 { return math.Abs(o.sweep) * o.radius }
+
+func (o orbit) at(t float64) state {
+	f := 1.0
+	if d := o.duration(); d > 0 {
+		f = math.Min(1, t/d)
+	}
+	angle := o.start + o.sweep*f
+	x, y := o.cx+o.radius*math.Cos(angle), o.cy+o.radius*math.Sin(angle)
+	direction := math.Copysign(1, o.sweep)
+	return state{
+		x: x, y: y, altitude: o.altitude,
+		heading:     normalizeHeading(bearing(x, y, o.cx, o.cy)),
+		gimbalPitch: o.pitch,
+		speed:       o.speed,
+		track:       normalizeHeading(math.Atan2(-math.Sin(angle)*direction, math.Cos(angle)*direction) * 180 / math.Pi),
+	}
+}
+
+// path is a horizontal track in plane coordinates
+type path struct {
+	from, to [2]float64
+	// control is the quadratic Bezier control point of curved paths
+	control *[2]float64
+	// table holds the curve's cumulative length at bezierSteps even steps of
+	// its parameter
+	table []float64
+}
+
+// straightPath returns the straight track between two points
+func straightPath(from, to [2]float64) path {
+	return path{from: from, to: to}
+}
+
+// curvedPath returns the planner's curve between two points, bowed by a
+// control point offset (m) to the right of the chord midpoint, or to the left
+// when negative
+func curvedPath(from, to [2]float64, offset float64) path {
+	dx, dy := to[0]-from[0], to[1]-from[1]
+	chord := math.Hypot(dx, dy)
+	if chord == 0 || offset == 0 {
+		return straightPath(from, to)
+	}
+	control := [2]float64{(from[0]+to[0])/2 + dy/chord*offset, (from[1]+to[1])/2 - dx/chord*offset}
+	p := path{from: from, to: to, control: &control, table: make([]float64, bezierSteps+1)}
+	px, py := from[0], from[1]
+	for i := 1; i <= bezierSteps; i++ {
+		x, y := p.bezier(float64(i) / bezierSteps)
+		p.table[i] = p.table[i-1] + math.Hypot(x-px, y-py)
+		px, py = x, y
+	}
+	return p
+}
+
+// length returns the track's length (m)
+func (p path) length() float64 {
+	if p.control == nil {
+		return math.Hypot(p.to[0]-p.from[0], p.to[1]-p.from[1])
+	}
+	return p.table[bezierSteps]
+}
+
+// point returns the position a fraction f of the way along the track and
+// the direction of travel there
+func (p path) point(f float64) (x, y, track float64) {
+	if p.control == nil {
+		x = p.from[0] + (p.to[0]-p.from[0])*f
+		y = p.from[1] + (p.to[1]-p.from[1])*f
+		return x, y, bearing(p.from[0], p.from[1], p.to[0], p.to[1])
+	}
+
+	// Find the curve parameter at the fraction of the arc length
+	target := f * p.table[bezierSteps]
+	i := 1
+	for i < bezierSteps && p.table[i] < target {
+		i++
+	}
+	span := p.table[i] - p.table[i-1]
+	t := float64(i-1) / bezierSteps
+	if span > 0 {
+		t += (target - p.table[i-1]) / span / bezierSteps
+	}
+	x, y = p.bezier(t)
+	c := *p.control
+	tx := 2*(1-t)*(c[0]-p.from[0]) + 2*t*(p.to[0]-c[0])
+	ty := 2*(1-t)*(c[1]-p.from[1]) + 2*t*(p.to[1]-c[1])
+	return x, y, normalizeHeading(math.Atan2(tx, ty) * 180 / math.Pi)
+}
+
+// bezier returns the curve's point at parameter t
+func (p path) bezier(t float64) (float64, float64) {
+	c := *p.control
+	a, b, d := (1-t)*(1-t), 2*(1-t)*t, t*t
+	return a*p.from[0] + b*c[0] + d*p.to[0], a*p.from[1] + b*c[1] + d*p.to[1]
+}
+
+// startTrack and endTrack return the direction of travel at the ends
+func (p path) startTrack() float64 {
+	_, _, track := p.point(0)
+	return track
+}
+
+func (p path) endTrack() float64 {
+	_, _, track := p.point(1)
+	return track
+}
+
+// profile is a trapezoidal speed profile over a distance: accelerating from
+// the entry speed, cruising, then slowing to the exit speed
+type profile struct {
+	length                           float64
+	entry, peak, exit                float64
+	acceleration                     float64
+	accelTime, cruiseTime, decelTime float64
+}
+
+// newProfile plans a length flown from entry to exit speed, cruising at up
+// to cruise. Entry and exit must be reachable from each other over length.
+func newProfile(length, entry, cruise, exit, acceleration float64) profile {
+	p := profile{length: length, entry: entry, exit: exit, acceleration: acceleration}
+	if length <= 0 {
+		return p
+	}
+	p.peak = math.Min(cruise, math.Sqrt((2*acceleration*length+entry*entry+exit*exit)/2))
+	p.peak = math.Max(p.peak, math.Max(entry, exit))
+	accelDistance := (p.peak*p.peak - entry*entry) / (2 * acceleration)
+	decelDistance := (p.peak*p.peak - exit*exit) / (2 * acceleration)
+	p.accelTime = (p.peak - entry) / acceleration
+	p.decelTime = (p.peak - exit) / acceleration
+	if p.peak > 0 {
+		p.cruiseTime = math.Max(0, length-accelDistance-decelDistance) / p.peak
+	}
+	return p
+}
+
+func (p profile) duration() float64 { return p.accelTime + p.cruiseTime + p.decelTime }
+
+// at returns the distance flown and the speed t seconds in
+func (p profile) at(t float64) (float64, float64) {
+	a := p.acceleration
+	switch {
+	case t <= 0:
+		return 0, p.entry
+	case t < p.accelTime:
+		return p.entry*t + a*t*t/2, p.entry + a*t
+	}
+	accelDistance := p.entry*p.accelTime + a*p.accelTime*p.accelTime/2
+	t -= p.accelTime
+	if t < p.cruiseTime {
+		return accelDistance + p.peak*t, p.peak
+	}
+	cruiseDistance := p.peak * p.cruiseTime
+	t -= p.cruiseTime
+	if t >= p.decelTime {
+		return p.length, p.exit
+	}
+	return math.Min(p.length, accelDistance+cruiseDistance+p.peak*t-a*t*t/2), p.peak - a*t
+}
+
+// bearing returns the heading (degrees clockwise from north) from one plane
+// point to another
+func bearing(x1, y1, x2, y2 float64) float64 {
+	return math.Atan2(x2-x1, y2-y1) * 180 / math.Pi
+}
+
+// lerpAngle turns a fraction f of the shortest way from one heading to another
+func lerpAngle(from, to, f float64) float64 {
+	return normalizeHeading(from + normalizeHeading(to-from)*f)
+}
+
+// normalizeHeading wraps a heading into the -180..180 range
+func normalizeHeading(heading float64) float64 {
+	heading = math.Mod(heading+180, 360)
+	if heading < 0 {
+		heading += 360
+	}
+	return heading - 180
+}
+
+// timeAt returns the seconds taken to fly a distance
+func (p profile) timeAt(d float64) float64 {
+	a := p.acceleration
+	accelDistance := p.entry*p.accelTime + a*p.accelTime*p.accelTime/2
+	if d < accelDistance {
+		return (math.Sqrt(p.entry*p.entry+2*a*d) - p.entry) / a
+	}
+	cruiseDistance := p.peak * p.cruiseTime
+	if d < accelDistance+cruiseDistance {
+		return p.accelTime + (d-accelDistance)/p.peak
+	}
+	d -= accelDistance + cruiseDistance
+	return p.accelTime + p.cruiseTime + (p.peak-math.Sqrt(math.Max(0, p.peak*p.peak-2*a*d)))/a
+}
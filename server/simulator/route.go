@@ -0,0 +1,319 @@
+package simulator
+
+import (
+	"math"
+	"strconv"
+
+	"drone-planner/server/geometry"
+	"drone-planner/server/models"
+	"drone-planner/server/timeline"
+)
+
+const (
+	// minCornerRadius is the turn radius assumed at waypoints flown through
+	// without a corner radius (m)
+	minCornerRadius = 1.0
+	// headingTolerance is the heading jump (degrees) between legs the
+	// aircraft absorbs without stopping to turn
+	headingTolerance = 1.0
+)
+
+// route is a waypoint sequence to fly, shared by missions and flights
+type route struct {
+	waypoints []models.Waypoint
+	// legs[i] describes the leg leaving waypoints[i]
+	legs []geometry.Leg
+	// interpolate marks the legs that turn evenly to the next waypoint's
+	// heading; the others hold the heading of the waypoint they leave
+	interpolate []bool
+	speed       float64
+	maxSpeed    float64
+	// passThrough flies through waypoints without actions instead of
+	// stopping at each
+	passThrough bool
+	headingMode string
+	gimbal      bool
+	targets     []models.Target
+	repeat      int
+}
+
+// missionRoute returns the route of a waypoint mission
+func missionRoute(config *models.WaypointMissionConfig) route {
+	_, legs := geometry.WaypointLegs(config)
+	interpolate := make([]bool, len(legs))
+	for i := range interpolate {
+		interpolate[i] = true
+	}
+	return route{
+		waypoints:   config.Waypoints,
+		legs:        legs,
+		interpolate: interpolate,
+		speed:       config.AutoFlightSpeed,
+		maxSpeed:    config.MaxFlightSpeed,
+		passThrough: config.FlightPathMode == "CURVED",
+		headingMode: config.HeadingMode,
+		gimbal:      config.GimbalPitchRotationEnabled,
+		targets:     config.Targets,
+		repeat:      config.RepeatTimes,
+	}
+}
+
+// flightRoute returns the route of a saved flight. Flights fly through
+// waypoints and hold or interpolate headings per segment.
+func flightRoute(flight *models.Flight) route {
+	_, legs := geometry.FlightLegs(flight)
+	interpolate := make([]bool, len(legs))
+	for _, segment := range flight.SegmentSpeeds {
+		for i, wp := range flight.Waypoints {
+			if wp.ID == strconv.FormatInt(segment.FromID, 10) {
+				interpolate[i] = segment.InterpolateHeading
+			}
+		}
+	}
+	return route{
+		waypoints:   flight.Waypoints,
+		legs:        legs,
+		interpolate: interpolate,
+		speed:       flight.AutoFlightSpeed,
+		maxSpeed:    flight.MaxFlightSpeed,
+		passThrough: flight.FlightpathMode == "CURVED",
+		headingMode: "USING_WAYPOINT_HEADING",
+		gimbal:      true,
+		repeat:      flight.RepeatTimes,
+	}
+}
+
+// plannedLeg is one leg of the expanded route with its speed limits
+type plannedLeg struct {
+	from, to int
+	path     path
+	climb    float64
+	length   float64
+	cruise   float64
+	heading  headingFunc
+	// tracks is set when the heading follows the direction of travel
+	tracks bool
+	// interval is the distance (m) between triggered photos, 0 for none
+	interval float64
+}
+
+// flyRoute flies a route from the aircraft's position: to the first
+// waypoint, then through every leg of every repeat
+func (s *sim) flyRoute(r route) {
+	n := len(r.waypoints)
+	points := make([][2]float64, n)
+	for i, wp := range r.waypoints {
+		x, y := s.plane.Project(wp.Coordinate.Latitude, wp.Coordinate.Longitude)
+		points[i] = [2]float64{x, y}
+	}
+
+	first := r.waypoints[0]
+	s.takeoff(first.Altitude)
+	s.flyTo(points[0][0], points[0][1], first.Altitude, r.speed)
+	if r.gimbal {
+		s.tilt(first.GimbalPitch)
+	}
+	initial := s.current.heading
+
+	// Expand repeats, returning straight from the last waypoint to the first
+	sequence := []int{}
+	repeat := r.repeat
+	if repeat < 1 || n < 2 {
+		repeat = 1
+	}
+	for k := 0; k < repeat; k++ {
+		for i := 0; i < n; i++ {
+			sequence = append(sequence, i)
+		}
+	}
+
+	legs := make([]plannedLeg, 0, len(sequence))
+	for k := 1; k < len(sequence); k++ {
+		from, to := sequence[k-1], sequence[k]
+		leg := geometry.Leg{}
+		if to == from+1 {
+			leg = r.legs[from]
+		}
+		legs = append(legs, s.planLeg(r, points, from, to, leg, initial))
+	}
+
+	speeds := s.junctionSpeeds(r, legs)
+
+	s.arrive(r, first, 1, speeds[0] == 0)
+	for k, l := range legs {
+		if speeds[k] == 0 {
+			s.turnTo(l.heading(0, l.path.from[0], l.path.from[1], l.path.startTrack()), 0)
+		}
+		toPitch := s.current.gimbalPitch
+		if r.gimbal {
+			toPitch = r.waypoints[l.to].GimbalPitch
+		}
+		m := leg{
+			path:         l.path,
+			fromAltitude: s.current.altitude,
+			toAltitude:   r.waypoints[l.to].Altitude,
+			fromPitch:    s.current.gimbalPitch,
+			toPitch:      toPitch,
+			heading:      l.heading,
+			profile:      newProfile(l.length, speeds[k], l.cruise, speeds[k+1], s.options.Acceleration),
+		}
+		if l.interval > 0 {
+			for d := 0.0; d < l.length; d += l.interval {
+				s.eventAt(s.time+m.profile.timeAt(d), EventPhoto, l.from+1)
+			}
+		}
+		s.add(m)
+		s.arrive(r, r.waypoints[l.to], l.to+1, speeds[k+1] == 0)
+	}
+}
+
+// planLeg works out a leg's path, heading and cruise speed
+func (s *sim) planLeg(r route, points [][2]float64, from, to int, leg geometry.Leg, initial float64) plannedLeg {
+	l := plannedLeg{from: from, to: to, climb: r.waypoints[to].Altitude - r.waypoints[from].Altitude}
+	l.path = straightPath(points[from], points[to])
+	if leg.CurveOffset != 0 {
+		l.path = curvedPath(points[from], points[to], leg.CurveOffset)
+	}
+	horizontal := l.path.length()
+	l.length = math.Hypot(horizontal, l.climb)
+
+	speed := leg.Speed
+	if speed <= 0 {
+		speed = r.speed
+	}
+	if speed <= 0 {
+		speed = geometry.DefaultSpeed
+	}
+	if r.maxSpeed > 0 {
+		speed = math.Min(speed, r.maxSpeed)
+	}
+	speed = s.climbLimit(s.limitSpeed(speed), horizontal, l.climb)
+	if leg.CurveOffset != 0 {
+		// The curve is tightest at its apex, with radius chord²/(4·offset)
+		chord := math.Hypot(points[to][0]-points[from][0], points[to][1]-points[from][1])
+		radius := chord * chord / (4 * math.Abs(leg.CurveOffset))
+		speed = math.Min(speed, math.Sqrt(s.options.LateralAcceleration*radius))
+	}
+	l.cruise = speed
+
+	wp := r.waypoints[from]
+	switch r.headingMode {
+	case "USING_WAYPOINT_HEADING":
+		if r.interpolate[from] {
+			l.heading = interpolateHeading(wp.Heading, r.waypoints[to].Heading)
+		} else {
+			l.heading = holdHeading(wp.Heading)
+		}
+	case "USING_INITIAL_DIRECTION":
+		l.heading = holdHeading(initial)
+	case "TOWARD_POINT_OF_INTEREST":
+		if target, ok := routeTarget(r, wp); ok {
+			l.heading = towardHeading(s.plane.Project(target.Lat, target.Lng))
+			break
+		}
+		fallthrough
+	default:
+		l.heading, l.tracks = travelHeading, true
+	}
+
+	for _, action := range wp.Actions {
+		if models.NormalizeActionType(action.ActionType) == models.ActionPhotoInterval && action.ActionParam > 0 {
+			l.interval = math.Max(action.ActionParam, timeline.MinPhotoInterval)
+		}
+	}
+	return l
+}
+
+// junctionSpeeds returns the speed at the start of every leg and at the end
+// of the last. The aircraft stops where it must act or turn on the spot, and
+// elsewhere passes at the speed the corner's lateral acceleration allows,
+// slowed so that every leg can reach the next junction's speed.
+func (s *sim) junctionSpeeds(r route, legs []plannedLeg) []float64 {
+	speeds := make([]float64, len(legs)+1)
+	for k := 1; k < len(legs); k++ {
+		in, out := legs[k-1], legs[k]
+		wp := r.waypoints[out.from]
+		if !r.passThrough || stopsAt(wp) {
+			continue
+		}
+		// Headings that follow the track turn with it; others must not jump
+		endHeading := in.heading(1, in.path.to[0], in.path.to[1], in.path.endTrack())
+		startHeading := out.heading(0, out.path.from[0], out.path.from[1], out.path.startTrack())
+		if !(in.tracks && out.tracks) && math.Abs(normalizeHeading(startHeading-endHeading)) > headingTolerance {
+			continue
+		}
+		turn := math.Abs(normalizeHeading(out.path.startTrack() - in.path.endTrack()))
+
+		speed := math.Min(in.cruise, out.cruise)
+		if turn > headingTolerance {
+			radius := math.Max(math.Abs(wp.CornerRadius), minCornerRadius) / math.Sin(turn*math.Pi/360)
+			speed = math.Min(speed, math.Sqrt(s.options.LateralAcceleration*radius))
+		}
+		speeds[k] = speed
+	}
+
+	// Make every junction reachable from its neighbours
+	a := s.options.Acceleration
+	for k := 1; k < len(speeds); k++ {
+		speeds[k] = math.Min(speeds[k], math.Sqrt(speeds[k-1]*speeds[k-1]+2*a*legs[k-1].length))
+	}
+	for k := len(speeds) - 2; k >= 0; k-- {
+		speeds[k] = math.Min(speeds[k], math.Sqrt(speeds[k+1]*speeds[k+1]+2*a*legs[k].length))
+	}
+	return speeds
+}
+
+// stopsAt reports whether the aircraft must stop at a waypoint for its actions
+func stopsAt(wp models.Waypoint) bool {
+	for _, action := range wp.Actions {
+		if timeline.ActionDuration(action) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// arrive records reaching a waypoint and, when the aircraft stops there,
+// runs its actions. Actions passed through only mark events.
+func (s *sim) arrive(r route, wp models.Waypoint, index int, stopped bool) {
+	s.event(EventWaypoint, index)
+	for _, action := range wp.Actions {
+		kind := models.NormalizeActionType(action.ActionType)
+		switch kind {
+		case models.ActionStartRecording:
+			s.event(EventStartRecording, index)
+			s.recording = true
+		case models.ActionStopRecording:
+			s.event(EventStopRecording, index)
+			s.recording = false
+		}
+		if !stopped {
+			continue
+		}
+
+		seconds := timeline.ActionDuration(action)
+		switch kind {
+		case models.ActionTakePhoto:
+			s.event(EventPhoto, index)
+			s.wait(seconds)
+		case models.ActionRotateGimbal:
+			s.add(hold{from: s.current, heading: s.current.heading, pitch: action.ActionParam, altitude: s.current.altitude, seconds: seconds})
+		case models.ActionRotateAircraft:
+			s.turnTo(action.ActionParam, 0)
+		default:
+			s.wait(seconds)
+		}
+	}
+}
+
+// routeTarget returns the point of interest a waypoint faces, preferring its
+// own targets
+func routeTarget(r route, wp models.Waypoint) (models.Target, bool) {
+	if len(wp.Targets) > 0 {
+		return wp.Targets[0], true
+	}
+	if len(r.targets) > 0 {
+		return r.targets[0], true
+	}
+	return models.Target{}, false
+}
@@ -0,0 +1,504 @@
+// Package simulator flies missions and flights through time, producing the
+// trajectory clients play back and duration estimates can rely on.
+package simulator
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"drone-planner/server/drones"
+	"drone-planner/server/geometry"
+	"drone-planner/server/models"
+	"drone-planner/server/timeline"
+)
+
+const (
+	defaultRate                = 5.0
+	defaultAcceleration        = 2.0
+	defaultLateralAcceleration = 2.5
+	defaultAscentSpeed         = 5.0
+	defaultDescentSpeed        = 3.0
+	defaultYawRate             = 30.0
+	// MaxRate bounds the sampling rate (Hz) and MaxSamples the samples of
+	// one trajectory
+	MaxRate    = 50.0
+	MaxSamples = 200000
+)
+
+// Event types
+const (
+	EventTakeoff        = "takeoff"
+	EventElement        = "element" // a timeline element starts
+	EventWaypoint       = "waypoint"
+	EventPhoto          = "photo"
+	EventStartRecording = "startRecording"
+	EventStopRecording  = "stopRecording"
+	EventLand           = "land"
+)
+
+// Options are the aircraft limits and sampling rate of a simulation. Zero
+// values take defaults.
+type Options struct {
+	// Rate is the number of samples per second
+	Rate float64 `json:"rate"`
+	// Acceleration is the aircraft's horizontal acceleration and braking
+	// along its path (m/s²)
+	Acceleration float64 `json:"acceleration"`
+	// LateralAcceleration bounds the speed through corners and curves (m/s²)
+	LateralAcceleration float64 `json:"lateralAcceleration"`
+	// MaxSpeed caps the horizontal speed (m/s); zero leaves speeds to the plan
+	MaxSpeed        float64 `json:"maxSpeed"`
+	MaxAscentSpeed  float64 `json:"maxAscentSpeed"`
+	MaxDescentSpeed float64 `json:"maxDescentSpeed"`
+	// YawRate is how fast the aircraft turns on the spot (degrees/s)
+	YawRate float64 `json:"yawRate"`
+}
+
+// DroneOptions returns the options for a drone type, with default limits
+// when it is not in the catalog
+func DroneOptions(droneType string) Options {
+	var options Options
+	if profile, ok := drones.Lookup(droneType); ok {
+		options.MaxSpeed = profile.MaxHorizontalSpeed
+		options.MaxAscentSpeed = profile.MaxAscentSpeed
+		options.MaxDescentSpeed = profile.MaxDescentSpeed
+	}
+	return options
+}
+
+// withDefaults fills the options left at zero
+func (o Options) withDefaults() Options {
+	fill := func(value *float64, fallback float64) {
+		if *value <= 0 {
+			*value = fallback
+		}
+	}
+	fill(&o.Rate, defaultRate)
+	fill(&o.Acceleration, defaultAcceleration)
+	fill(&o.LateralAcceleration, defaultLateralAcceleration)
+	fill(&o.MaxAscentSpeed, defaultAscentSpeed)
+	fill(&o.MaxDescentSpeed, defaultDescentSpeed)
+	fill(&o.YawRate, defaultYawRate)
+	o.Rate = math.Min(o.Rate, MaxRate)
+	return o
+}
+
+// Sample is the aircraft's state at an instant
+type Sample struct {
+	// Time is the number of seconds since the start
+	Time      float64 `json:"time"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	// Altitude is relative to takeoff (m)
+	Altitude    float64 `json:"altitude"`
+	Heading     float64 `json:"heading"`
+	GimbalPitch float64 `json:"gimbalPitch"`
+	// Speed is the ground speed and VerticalSpeed the climb rate (m/s)
+	Speed         float64 `json:"speed"`
+	VerticalSpeed float64 `json:"verticalSpeed"`
+	// Element is the 1-based timeline element being flown, 0 during takeoff
+	// and the finished action
+	Element int `json:"element"`
+}
+
+// Event is something that happens at an instant of a trajectory
+type Event struct {
+	Time    float64 `json:"time"`
+	Type    string  `json:"type"`
+	Element int     `json:"element"`
+	// Waypoint is the 1-based waypoint of waypoint and waypoint action events
+	Waypoint int `json:"waypoint,omitempty"`
+}
+
+// Trajectory is a simulated flight sampled at a fixed rate
+type Trajectory struct {
+	Rate float64 `json:"rate"`
+	// Home is the takeoff point altitudes are relative to
+	Home models.Coordinate `json:"home"`
+	// Duration is the flight time (s) and Distance the length flown (m)
+	Duration float64  `json:"duration"`
+	Distance float64  `json:"distance"`
+	Samples  []Sample `json:"samples"`
+	Events   []Event  `json:"events"`
+}
+
+// AltitudeFunc converts a waypoint mission's altitudes to relative to takeoff
+type AltitudeFunc func(config *models.WaypointMissionConfig) (*models.WaypointMissionConfig, error)
+
+// span is a move placed on the timeline
+type span struct {
+	move    move
+	start   float64
+	element int
+}
+
+// sim accumulates the moves of a simulation
+type sim struct {
+	home      geometry.Point
+	options   Options
+	plane     geometry.Plane
+	spans     []span
+	events    []Event
+	time      float64
+	distance  float64
+	current   state
+	airborne  bool
+	recording bool
+	// element is the 1-based timeline element being flown
+	element int
+}
+
+func newSim(home geometry.Point, options Options) *sim {
+	return &sim{home: home, options: options.withDefaults(), plane: geometry.NewPlane(home)}
+}
+
+// SimulateMission flies a mission's timeline in order from takeoff at home
+// to its finished action. Waypoint altitudes are converted with relative.
+func SimulateMission(mission *models.Mission, relative AltitudeFunc, options Options) (*Trajectory, error) {
+	s, err := missionSim(mission, relative, options)
+	if err != nil {
+		return nil, err
+	}
+	return s.trajectory()
+}
+
+// missionSim runs a mission's simulation without sampling it
+func missionSim(mission *models.Mission, relative AltitudeFunc, options Options) (*sim, error) {
+	elements := append([]models.TimelineElement{}, mission.TimelineElements...)
+	sort.SliceStable(elements, func(i, j int) bool { return elements[i].Order < elements[j].Order })
+
+	configs := make([]interface{}, len(elements))
+	var first *models.WaypointMissionConfig
+	var home *geometry.Point
+	for i := range elements {
+		config, err := timeline.Decode(&elements[i])
+		if err != nil {
+			return nil, fmt.Errorf("timeline element %d: %v", i+1, err)
+		}
+		switch c := config.(type) {
+		case *models.WaypointMissionConfig:
+			if len(c.Waypoints) == 0 {
+				break
+			}
+			if config, err = relative(c); err != nil {
+				return nil, err
+			}
+			if first == nil {
+				first = config.(*models.WaypointMissionConfig)
+				point := geometry.HomePoint(mission.GlobalSettings, first)
+				home = &point
+			}
+		case *models.HotpointOrbitConfig:
+			if home == nil {
+				home = &geometry.Point{Latitude: c.Center.Latitude, Longitude: c.Center.Longitude}
+			}
+		}
+		configs[i] = config
+	}
+	if settings := mission.GlobalSettings; settings.HomeLat != nil && settings.HomeLng != nil {
+		home = &geometry.Point{Latitude: *settings.HomeLat, Longitude: *settings.HomeLng}
+	}
+	if home == nil {
+		return nil, fmt.Errorf("mission has no home point, waypoints or orbit to start from")
+	}
+
+	s := newSim(*home, options)
+	for i, config := range configs {
+		s.element = i + 1
+		s.event(EventElement, 0)
+		if err := s.runElement(&elements[i], config); err != nil {
+			return nil, fmt.Errorf("timeline element %d: %v", i+1, err)
+		}
+	}
+	if s.recording {
+		s.event(EventStopRecording, 0)
+	}
+
+	s.element = 0
+	if first != nil {
+		s.finish(first.FinishedAction, first.Waypoints[0])
+	}
+	return s, nil
+}
+
+// SimulateFlight flies a saved flight from takeoff at its first waypoint to
+// its finished action. Altitudes must be relative to takeoff.
+func SimulateFlight(flight *models.Flight, options Options) (*Trajectory, error) {
+	if len(flight.Waypoints) == 0 {
+		return nil, fmt.Errorf("flight has no waypoints")
+	}
+	first := flight.Waypoints[0].Coordinate
+	s := newSim(geometry.Point{Latitude: first.Latitude, Longitude: first.Longitude}, options)
+	s.element = 1
+	s.flyRoute(flightRoute(flight))
+	s.element = 0
+	s.finish(flight.FinishedAction, flight.Waypoints[0])
+	return s.trajectory()
+}
+
+// runElement flies one timeline element
+func (s *sim) runElement(element *models.TimelineElement, config interface{}) error {
+	elementType, ok := timeline.Lookup(element.Type)
+	if !ok {
+		return fmt.Errorf("unknown element type %q", element.Type)
+	}
+	duration := elementType.EstimateDuration(config)
+
+	switch c := config.(type) {
+	case *models.WaypointMissionConfig:
+		if len(c.Waypoints) > 0 {
+			s.flyRoute(missionRoute(c))
+		}
+	case *models.RecordVideoActionConfig:
+		s.event(EventStartRecording, 0)
+		s.recording = true
+	case *models.TakePhotoActionConfig:
+		count := 1
+		if c.PhotoType == "interval" && c.PhotoCount != nil {
+			count = *c.PhotoCount
+		}
+		for k := 0; k < count; k++ {
+			s.event(EventPhoto, 0)
+			s.wait(duration / float64(count))
+		}
+	case *models.ChangeHeadingActionConfig:
+		s.turnTo(c.Angle, c.AngularVelocity)
+	case *models.RotateGimbalActionConfig:
+		s.add(hold{from: s.current, heading: s.current.heading, pitch: c.Pitch, altitude: s.current.altitude, seconds: duration})
+	case *models.PanoramaActionConfig:
+		step, _ := timeline.PanoramaSteps(c)
+		s.tilt(c.GimbalPitch)
+		for k := 0; k < c.PhotoCount; k++ {
+			if k > 0 {
+				s.turnTo(s.current.heading+step, 0)
+			}
+			s.event(EventPhoto, 0)
+			s.wait(timeline.ActionDuration(models.WaypointAction{ActionType: models.ActionTakePhoto}))
+		}
+	case *models.HotpointOrbitConfig:
+		s.orbit(c)
+	default:
+		// Hover, zoom and custom elements keep the aircraft in place
+		s.wait(duration)
+	}
+	return nil
+}
+
+// orbit flies to the nearest point of a hotpoint's circle and circles it
+// facing the center. The orbit is flown at a constant speed, capped by the
+// lateral acceleration the circle needs.
+func (s *sim) orbit(c *models.HotpointOrbitConfig) {
+	s.takeoff(c.Altitude)
+	cx, cy := s.plane.Project(c.Center.Latitude, c.Center.Longitude)
+	start := 0.0
+	if math.Hypot(s.current.x-cx, s.current.y-cy) > 0.01 {
+		start = math.Atan2(s.current.y-cy, s.current.x-cx)
+	}
+	s.flyTo(cx+c.Radius*math.Cos(start), cy+c.Radius*math.Sin(start), c.Altitude, timeline.OrbitSpeed(c))
+	s.turnTo(bearing(s.current.x, s.current.y, cx, cy), 0)
+
+	sweep := 2 * math.Pi * c.Laps
+	if c.Clockwise {
+		sweep = -sweep
+	}
+	speed := s.limitSpeed(timeline.OrbitSpeed(c))
+	speed = math.Min(speed, math.Sqrt(s.options.LateralAcceleration*c.Radius))
+	if c.Radius <= 0 || speed <= 0 {
+		return
+	}
+	s.add(orbit{cx: cx, cy: cy, radius: c.Radius, altitude: c.Altitude, pitch: s.current.gimbalPitch, start: start, sweep: sweep, speed: speed})
+}
+
+// finish runs a waypoint mission's finished action
+func (s *sim) finish(action string, first models.Waypoint) {
+	switch action {
+	case "GO_HOME":
+		s.flyTo(0, 0, s.current.altitude, 0)
+		s.land()
+	case "LAND", "AUTO_LAND":
+		s.land()
+	case "GO_TO_FIRST_WAYPOINT", "GO_FIRST_WAYPOINT":
+		x, y := s.plane.Project(first.Coordinate.Latitude, first.Coordinate.Longitude)
+		s.flyTo(x, y, first.Altitude, 0)
+	}
+	// NO_ACTION and HOVER leave the aircraft holding position
+}
+
+// takeoff climbs from home to an altitude, unless already airborne
+func (s *sim) takeoff(altitude float64) {
+	if s.airborne {
+		return
+	}
+	s.airborne = true
+	s.event(EventTakeoff, 0)
+	s.climbTo(altitude)
+}
+
+// land descends to the takeoff altitude where the aircraft is
+func (s *sim) land() {
+	if !s.airborne {
+		return
+	}
+	s.event(EventLand, 0)
+	s.climbTo(0)
+	s.airborne = false
+}
+
+// climbTo changes altitude in place at the vertical speed limits
+func (s *sim) climbTo(altitude float64) {
+	climb := altitude - s.current.altitude
+	rate := s.options.MaxAscentSpeed
+	if climb < 0 {
+		rate = s.options.MaxDescentSpeed
+	}
+	s.add(hold{from: s.current, heading: s.current.heading, pitch: s.current.gimbalPitch, altitude: altitude, seconds: math.Abs(climb) / rate})
+}
+
+// turnTo turns in place to a heading at a rate (degrees/s), or the default
+// yaw rate when zero
+func (s *sim) turnTo(heading, rate float64) {
+	if rate <= 0 {
+		rate = s.options.YawRate
+	}
+	turn := math.Abs(normalizeHeading(heading - s.current.heading))
+	s.add(hold{from: s.current, heading: heading, pitch: s.current.gimbalPitch, altitude: s.current.altitude, seconds: turn / rate})
+}
+
+// tilt points the gimbal in the time a gimbal rotation takes
+func (s *sim) tilt(pitch float64) {
+	if pitch == s.current.gimbalPitch {
+		return
+	}
+	seconds := timeline.ActionDuration(models.WaypointAction{ActionType: models.ActionRotateGimbal})
+	s.add(hold{from: s.current, heading: s.current.heading, pitch: pitch, altitude: s.current.altitude, seconds: seconds})
+}
+
+// wait holds position
+func (s *sim) wait(seconds float64) {
+	s.add(hold{from: s.current, heading: s.current.heading, pitch: s.current.gimbalPitch, altitude: s.current.altitude, seconds: seconds})
+}
+
+// flyTo flies straight to a point from a stop to a stop, first turning to
+// face it. A zero speed flies at the default speed.
+func (s *sim) flyTo(x, y, altitude, speed float64) {
+	if math.Hypot(x-s.current.x, y-s.current.y) < 0.5 {
+		s.climbTo(altitude)
+		return
+	}
+	p := straightPath([2]float64{s.current.x, s.current.y}, [2]float64{x, y})
+	s.turnTo(p.startTrack(), 0)
+	if speed <= 0 {
+		speed = geometry.DefaultSpeed
+	}
+	length := math.Hypot(p.length(), altitude-s.current.altitude)
+	cruise := s.climbLimit(s.limitSpeed(speed), p.length(), altitude-s.current.altitude)
+	s.add(leg{
+		path:         p,
+		fromAltitude: s.current.altitude,
+		toAltitude:   altitude,
+		fromPitch:    s.current.gimbalPitch,
+		toPitch:      s.current.gimbalPitch,
+		heading:      travelHeading,
+		profile:      newProfile(length, 0, cruise, 0, s.options.Acceleration),
+	})
+}
+
+// limitSpeed caps a speed at the aircraft's maximum
+func (s *sim) limitSpeed(speed float64) float64 {
+	if s.options.MaxSpeed > 0 {
+		return math.Min(speed, s.options.MaxSpeed)
+	}
+	return speed
+}
+
+// climbLimit slows a speed along a path so that its vertical part stays
+// within the ascent or descent limit
+func (s *sim) climbLimit(speed, horizontal, climb float64) float64 {
+	if climb == 0 {
+		return speed
+	}
+	rate := s.options.MaxAscentSpeed
+	if climb < 0 {
+		rate = s.options.MaxDescentSpeed
+	}
+	return math.Min(speed, rate*math.Hypot(horizontal, climb)/math.Abs(climb))
+}
+
+// add appends a move and advances the simulation to its end
+func (s *sim) add(m move) {
+	if m.duration() <= 0 {
+		return
+	}
+	s.spans = append(s.spans, span{move: m, start: s.time, element: s.element})
+	s.time += m.duration()
+	s.distance += m.distance()
+	s.current = m.at(m.duration())
+	s.current.speed, s.current.verticalSpeed = 0, 0
+}
+
+// event records an event at the current time
+func (s *sim) event(kind string, waypoint int) {
+	s.eventAt(s.time, kind, waypoint)
+}
+
+func (s *sim) eventAt(time float64, kind string, waypoint int) {
+	s.events = append(s.events, Event{Time: time, Type: kind, Element: s.element, Waypoint: waypoint})
+}
+
+// trajectory samples the moves at the simulation's rate
+func (s *sim) trajectory() (*Trajectory, error) {
+	rate := s.options.Rate
+	count := int(math.Floor(s.time*rate)) + 1
+	if count > MaxSamples {
+		return nil, fmt.Errorf("a %.0f s flight sampled at %g Hz exceeds %d samples; lower the rate", s.time, rate, MaxSamples)
+	}
+
+	t := &Trajectory{
+		Home:     models.Coordinate{Latitude: s.home.Latitude, Longitude: s.home.Longitude},
+		Rate:     rate,
+		Duration: s.time,
+		Distance: s.distance,
+		Samples:  make([]Sample, 0, count+1),
+		Events:   s.events,
+	}
+	k := 0
+	for i := 0; i < count; i++ {
+		t.Samples = append(t.Samples, s.sample(float64(i)/rate, &k))
+	}
+	if last := t.Samples[len(t.Samples)-1].Time; last < s.time {
+		t.Samples = append(t.Samples, s.sample(s.time, &k))
+	}
+	sort.SliceStable(t.Events, func(i, j int) bool { return t.Events[i].Time < t.Events[j].Time })
+	return t, nil
+}
+
+// sample returns the state at a time, advancing k to the span flown then
+func (s *sim) sample(time float64, k *int) Sample {
+	st, element := s.stateAt(time, k)
+	lat, lng := s.plane.Unproject(st.x, st.y)
+	return Sample{
+		Time:          time,
+		Latitude:      lat,
+		Longitude:     lng,
+		Altitude:      st.altitude,
+		Heading:       st.heading,
+		GimbalPitch:   st.gimbalPitch,
+		Speed:         st.speed,
+		VerticalSpeed: st.verticalSpeed,
+		Element:       element,
+	}
+}
+
+// stateAt returns the state and timeline element at a time, advancing k to
+// the span flown then. Times must not decrease between calls sharing k.
+func (s *sim) stateAt(time float64, k *int) (state, int) {
+	for *k < len(s.spans)-1 && time >= s.spans[*k].start+s.spans[*k].move.duration() {
+		*k++
+	}
+	if *k >= len(s.spans) || time >= s.time {
+		return s.current, s.element
+	}
+	sp := s.spans[*k]
+	return sp.move.at(time - sp.start), sp.element
+}
@@ -0,0 +1,302 @@
+package simulator
+
+import (
+	"math"
+	"testing"
+
+	"drone-planner/server/geometry"
+	"drone-planner/server/models"
+)
+
+// testPlane places waypoints in meters east and north of home
+var testPlane = geometry.NewPlane(geometry.Point{Latitude: 47, Longitude: 8})
+
+// waypointAt returns a waypoint x m east and y m north of home
+func waypointAt(x, y, altitude float64) models.Waypoint {
+	lat, lng := testPlane.Unproject(x, y)
+	return models.Waypoint{Coordinate: models.Coordinate{Latitude: lat, Longitude: lng}, Altitude: altitude}
+}
+
+// element returns a timeline element of a type
+func element(t *testing.T, elementType string, order int, config interface{}) models.TimelineElement {
+	t.Helper()
+	encoded, err := models.EncodeConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return models.TimelineElement{Type: elementType, Order: order, Config: encoded}
+}
+
+// testMission returns a mission flying a waypoint mission, then more elements
+func testMission(t *testing.T, config models.WaypointMissionConfig, more ...models.TimelineElement) *models.Mission {
+	t.Helper()
+	elements := []models.TimelineElement{element(t, models.ElementWaypointMission, 0, config)}
+	return &models.Mission{TimelineElements: append(elements, more...)}
+}
+
+// relativeAltitudes leaves altitudes as they are
+func relativeAltitudes(config *models.WaypointMissionConfig) (*models.WaypointMissionConfig, error) {
+	return config, nil
+}
+
+// checkMotion checks that samples stay within the speed and acceleration
+// limits, returning the top speed
+func checkMotion(t *testing.T, trajectory *Trajectory, maxSpeed, acceleration float64) float64 {
+	t.Helper()
+	top := 0.0
+	for i, s := range trajectory.Samples {
+		top = math.Max(top, s.Speed)
+		if s.Speed > maxSpeed+1e-9 {
+			t.Errorf("sample %d at %g s flies %g m/s, above %g", i, s.Time, s.Speed, maxSpeed)
+		}
+		if i == 0 {
+			continue
+		}
+		// The last sample can follow the one before by a rounding error
+		previous := trajectory.Samples[i-1]
+		if s.Time-previous.Time < 1e-3 {
+			continue
+		}
+		if a := math.Abs(s.Speed-previous.Speed) / (s.Time - previous.Time); a > acceleration+1e-6 {
+			t.Errorf("sample %d at %g s accelerates at %g m/s²", i, s.Time, a)
+		}
+	}
+	return top
+}
+
+func TestProfile(t *testing.T) {
+	tests := []struct {
+		name                  string
+		length, entry, cruise float64
+		exit                  float64
+		peak, duration        float64
+	}{
+		// 5 s to reach 10 m/s over 25 m, 50 m cruising and 5 s braking
+		{"cruise", 100, 0, 10, 0, 10, 15},
+		// Too short to reach the cruise speed
+		{"triangle", 16, 0, 10, 0, math.Sqrt(32), 2 * math.Sqrt(8)},
+		{"flying start", 100, 10, 10, 0, 10, 12.5},
+		{"no length", 0, 0, 10, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newProfile(tt.length, tt.entry, tt.cruise, tt.exit, 2)
+			if math.Abs(p.peak-tt.peak) > 1e-9 || math.Abs(p.duration()-tt.duration) > 1e-9 {
+				t.Fatalf("peak %g m/s over %g s, want %g over %g", p.peak, p.duration(), tt.peak, tt.duration)
+			}
+			if d, v := p.at(p.duration()); math.Abs(d-tt.length) > 1e-9 || v != tt.exit {
+				t.Errorf("ends at %g m and %g m/s", d, v)
+			}
+			for _, f := range []float64{0.1, 0.5, 0.9} {
+				d, _ := p.at(f * p.duration())
+				if got := p.timeAt(d); math.Abs(got-f*p.duration()) > 1e-6 {
+					t.Errorf("timeAt(%g) = %g, want %g", d, got, f*p.duration())
+				}
+			}
+		})
+	}
+}
+
+func TestSimulateMission(t *testing.T) {
+	line := []models.Waypoint{waypointAt(0, 0, 20), waypointAt(100, 0, 20)}
+	corner := []models.Waypoint{waypointAt(0, 0, 20), waypointAt(100, 0, 20), waypointAt(100, 100, 20)}
+	// cornerSpeed passes the right angle with the minimum corner radius
+	cornerSpeed := math.Sqrt(defaultLateralAcceleration * minCornerRadius / math.Sin(math.Pi/4))
+	cornerLeg := 5 + (10-cornerSpeed)/2 + (100-25-(100-cornerSpeed*cornerSpeed)/4)/10
+
+	tests := []struct {
+		name    string
+		config  models.WaypointMissionConfig
+		options Options
+		// A 4 s climb to 20 m and a 3 s turn east start every flight
+		duration float64
+		maxSpeed float64
+		// stops is set when the aircraft stops between the first and last
+		// waypoints
+		stops bool
+	}{
+		{"straight", models.WaypointMissionConfig{AutoFlightSpeed: 10, Waypoints: line}, Options{}, 4 + 3 + 15, 10, false},
+		{"aircraft top speed", models.WaypointMissionConfig{AutoFlightSpeed: 10, Waypoints: line}, Options{MaxSpeed: 5}, 4 + 3 + 2.5 + 17.5 + 2.5, 5, false},
+		{"mission top speed", models.WaypointMissionConfig{AutoFlightSpeed: 10, MaxFlightSpeed: 5, Waypoints: line}, Options{}, 4 + 3 + 2.5 + 17.5 + 2.5, 5, false},
+		{"stop at the corner", models.WaypointMissionConfig{AutoFlightSpeed: 10, Waypoints: corner}, Options{}, 4 + 3 + 15 + 3 + 15, 10, true},
+		{"through the corner", models.WaypointMissionConfig{AutoFlightSpeed: 10, FlightPathMode: "CURVED", Waypoints: corner}, Options{}, 4 + 3 + 2*cornerLeg, 10, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trajectory, err := SimulateMission(testMission(t, tt.config), relativeAltitudes, tt.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if math.Abs(trajectory.Duration-tt.duration) > 1e-6 {
+				t.Errorf("duration = %g s, want %g", trajectory.Duration, tt.duration)
+			}
+			if top := checkMotion(t, trajectory, tt.maxSpeed, defaultAcceleration); math.Abs(top-tt.maxSpeed) > 1e-9 {
+				t.Errorf("top speed = %g m/s, want %g", top, tt.maxSpeed)
+			}
+			// Between leaving the first waypoint and reaching the last, the
+			// aircraft only stops at a corner it does not fly through
+			stopped := false
+			for _, s := range trajectory.Samples {
+				if s.Time > 8 && s.Time < trajectory.Duration-1 && s.Speed == 0 {
+					stopped = true
+				}
+			}
+			if stopped != tt.stops {
+				t.Errorf("stopped between the ends = %v, want %v", stopped, tt.stops)
+			}
+			if last := trajectory.Samples[len(trajectory.Samples)-1]; last.Time != trajectory.Duration {
+				t.Errorf("last sample at %g s, the flight ends at %g", last.Time, trajectory.Duration)
+			}
+		})
+	}
+}
+
+func TestCornerSpeed(t *testing.T) {
+	config := models.WaypointMissionConfig{
+		AutoFlightSpeed: 10, FlightPathMode: "CURVED",
+		Waypoints: []models.Waypoint{waypointAt(0, 0, 20), waypointAt(100, 0, 20), waypointAt(100, 100, 20)},
+	}
+	trajectory, err := SimulateMission(testMission(t, config), relativeAltitudes, Options{Rate: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The slowest sample of the flight through the corner is taken there
+	slowest := Sample{Speed: math.Inf(1)}
+	for _, s := range trajectory.Samples {
+		if s.Time > 8 && s.Time < trajectory.Duration-1 && s.Speed < slowest.Speed {
+			slowest = s
+		}
+	}
+	want := math.Sqrt(defaultLateralAcceleration * minCornerRadius / math.Sin(math.Pi/4))
+	if math.Abs(slowest.Speed-want) > 2*defaultAcceleration/50 {
+		t.Errorf("slowest speed %g m/s, want %g at the corner", slowest.Speed, want)
+	}
+	if x, y := testPlane.Project(slowest.Latitude, slowest.Longitude); math.Hypot(x-100, y) > 1 {
+		t.Errorf("slowest at %g, %g, want the corner", x, y)
+	}
+}
+
+func TestRepeatTimes(t *testing.T) {
+	config := models.WaypointMissionConfig{
+		AutoFlightSpeed: 10, RepeatTimes: 2,
+		Waypoints: []models.Waypoint{waypointAt(0, 0, 20), waypointAt(100, 0, 20), waypointAt(100, 100, 20)},
+	}
+	trajectory, err := SimulateMission(testMission(t, config), relativeAltitudes, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Both rounds fly every waypoint, returning straight to the first
+	var waypoints []int
+	for _, e := range trajectory.Events {
+		if e.Type == EventWaypoint {
+			waypoints = append(waypoints, e.Waypoint)
+		}
+	}
+	want := []int{1, 2, 3, 1, 2, 3}
+	if len(waypoints) != len(want) {
+		t.Fatalf("waypoint events %v, want %v", waypoints, want)
+	}
+	for i := range want {
+		if waypoints[i] != want[i] {
+			t.Errorf("waypoint event %d = %d, want %d", i, waypoints[i], want[i])
+		}
+	}
+	if distance := 20 + 4*100 + 100*math.Sqrt2; math.Abs(trajectory.Distance-distance) > 0.01 {
+		t.Errorf("distance = %g m, want %g", trajectory.Distance, distance)
+	}
+}
+
+func TestHover(t *testing.T) {
+	waypoints := []models.Waypoint{waypointAt(0, 0, 20), waypointAt(100, 0, 20)}
+	waypoints[1].Actions = []models.WaypointAction{{ActionType: models.ActionTakePhoto}, {ActionType: models.ActionHover, ActionParam: 5}}
+	mission := testMission(t, models.WaypointMissionConfig{AutoFlightSpeed: 10, Waypoints: waypoints},
+		element(t, models.ElementHover, 1, models.HoverActionConfig{Duration: 10}))
+	trajectory, err := SimulateMission(mission, relativeAltitudes, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The photo takes 2 s and the hovers 5 and 10 s after reaching the
+	// second waypoint at 22 s
+	if math.Abs(trajectory.Duration-(22+2+5+10)) > 1e-6 {
+		t.Errorf("duration = %g s, want 39", trajectory.Duration)
+	}
+	want := []Event{
+		{Time: 0, Type: EventElement, Element: 1},
+		{Time: 0, Type: EventTakeoff, Element: 1},
+		{Time: 4, Type: EventWaypoint, Element: 1, Waypoint: 1},
+		{Time: 22, Type: EventWaypoint, Element: 1, Waypoint: 2},
+		{Time: 22, Type: EventPhoto, Element: 1, Waypoint: 2},
+		{Time: 29, Type: EventElement, Element: 2},
+	}
+	if len(trajectory.Events) != len(want) {
+		t.Fatalf("events %+v, want %+v", trajectory.Events, want)
+	}
+	for i := range want {
+		if e := trajectory.Events[i]; math.Abs(e.Time-want[i].Time) > 1e-6 || e.Type != want[i].Type || e.Element != want[i].Element || e.Waypoint != want[i].Waypoint {
+			t.Errorf("event %d = %+v, want %+v", i, e, want[i])
+		}
+	}
+	for _, s := range trajectory.Samples {
+		if s.Time < 22.1 {
+			continue
+		}
+		if x, y := testPlane.Project(s.Latitude, s.Longitude); math.Hypot(x-100, y) > 1e-6 || s.Speed != 0 {
+			t.Errorf("sample at %g s flies %g m/s at %g, %g", s.Time, s.Speed, x, y)
+		}
+		element := 1
+		if s.Time > 29.1 {
+			element = 2
+		}
+		if s.Time < trajectory.Duration && math.Abs(s.Time-29) > 0.1 && s.Element != element {
+			t.Errorf("sample at %g s in element %d, want %d", s.Time, s.Element, element)
+		}
+	}
+}
+
+func TestHeadingInterpolation(t *testing.T) {
+	waypoints := []models.Waypoint{waypointAt(0, 0, 20), waypointAt(100, 0, 20)}
+	waypoints[1].Heading = 90
+	config := models.WaypointMissionConfig{AutoFlightSpeed: 10, HeadingMode: "USING_WAYPOINT_HEADING", Waypoints: waypoints}
+	trajectory, err := SimulateMission(testMission(t, config), relativeAltitudes, Options{Rate: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// No turn before leaving; the heading turns with the distance flown
+	if math.Abs(trajectory.Duration-(4+15)) > 1e-6 {
+		t.Fatalf("duration = %g s, want 19", trajectory.Duration)
+	}
+	for _, s := range trajectory.Samples {
+		x, _ := testPlane.Project(s.Latitude, s.Longitude)
+		if want := 90 * x / 100; math.Abs(s.Heading-want) > 1e-3 {
+			t.Errorf("heading at %g s, %g m along = %g°, want %g", s.Time, x, s.Heading, want)
+		}
+	}
+	if mid := trajectory.Samples[2*(4+7.5)]; math.Abs(mid.Heading-45) > 1e-3 {
+		t.Errorf("heading half way = %g°, want 45", mid.Heading)
+	}
+
+	// Flight segments without interpolation hold the heading they leave with
+	flight := &models.Flight{
+		AutoFlightSpeed: 10,
+		Waypoints:       []models.Waypoint{waypoints[0], waypoints[1]},
+		SegmentSpeeds:   []models.SegmentSpeed{{FromID: 1, ToID: 2}},
+	}
+	flight.Waypoints[0].ID, flight.Waypoints[1].ID = "1", "2"
+	flown, err := SimulateFlight(flight, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range flown.Samples {
+		if s.Heading != 0 {
+			t.Errorf("flight heading at %g s = %g°, want 0", s.Time, s.Heading)
+		}
+	}
+	flight.SegmentSpeeds[0].InterpolateHeading = true
+	if flown, err = SimulateFlight(flight, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if last := flown.Samples[len(flown.Samples)-1]; math.Abs(last.Heading-90) > 1e-9 {
+		t.Errorf("interpolated flight ends heading %g°, want 90", last.Heading)
+	}
+}
@@ -0,0 +1,173 @@
+// Package sun computes the position of the sun and the times of daylight and
+// twilight, following the NOAA solar calculator. Positions are accurate to
+// about a hundredth of a degree and times to about a minute between the
+// polar circles.
+package sun
+
+import (
+	"math"
+	"time"
+)
+
+// Sun elevations (degrees) that start and end the parts of the day
+const (
+	// SunriseElevation is the elevation of the sun's centre at sunrise and
+	// sunset, allowing for refraction and the radius of the disc
+	SunriseElevation = -0.833
+	// CivilElevation bounds civil twilight
+	CivilElevation = -6.0
+	// BlueElevation divides the blue hour from the golden hour
+	BlueElevation = -4.0
+	// GoldenElevation ends the golden hour
+	GoldenElevation = 6.0
+)
+
+// searchPrecision is how closely crossing times are found
+const searchPrecision = time.Second
+
+// Position returns the sun's azimuth (degrees clockwise from north) and
+// elevation (degrees above the horizon, without refraction) seen from a
+// place at a time
+func Position(t time.Time, lat, lng float64) (azimuth, elevation float64) {
+	declination, equationOfTime := orbit(t)
+	utc := t.UTC()
+	minutes := float64(utc.Hour()*60+utc.Minute()) + (float64(utc.Second())+float64(utc.Nanosecond())/1e9)/60
+	trueSolarTime := math.Mod(minutes+equationOfTime+4*lng, 1440)
+	hourAngle := radians(trueSolarTime/4 - 180)
+
+	phi, delta := radians(lat), radians(declination)
+	sinElevation := math.Sin(phi)*math.Sin(delta) + math.Cos(phi)*math.Cos(delta)*math.Cos(hourAngle)
+	elevation = degrees(math.Asin(math.Max(-1, math.Min(1, sinElevation))))
+	azimuth = math.Mod(degrees(math.Atan2(math.Sin(hourAngle), math.Cos(hourAngle)*math.Sin(phi)-math.Tan(delta)*math.Cos(phi)))+540, 360)
+	return azimuth, elevation
+}
+
+// orbit returns the sun's declination (degrees) and the equation of time
+// (minutes) at a time
+func orbit(t time.Time) (declination, equationOfTime float64) {
+	julianDay := float64(t.UnixNano())/864e11 + 2440587.5
+	c := (julianDay - 2451545) / 36525
+
+	meanLongitude := math.Mod(280.46646+c*(36000.76983+c*0.0003032), 360)
+	meanAnomaly := 357.52911 + c*(35999.05029-0.0001537*c)
+	eccentricity := 0.016708634 - c*(0.000042037+0.0000001267*c)
+	m := radians(meanAnomaly)
+	center := math.Sin(m)*(1.914602-c*(0.004817+0.000014*c)) + math.Sin(2*m)*(0.019993-0.000101*c) + math.Sin(3*m)*0.000289
+
+	omega := radians(125.04 - 1934.136*c)
+	apparentLongitude := radians(meanLongitude + center - 0.00569 - 0.00478*math.Sin(omega))
+	meanObliquity := 23 + (26+(21.448-c*(46.815+c*(0.00059-c*0.001813)))/60)/60
+	obliquity := radians(meanObliquity + 0.00256*math.Cos(omega))
+	declination = degrees(math.Asin(math.Sin(obliquity) * math.Sin(apparentLongitude)))
+
+	y := math.Pow(math.Tan(obliquity/2), 2)
+	l := radians(meanLongitude)
+	equationOfTime = 4 * degrees(y*math.Sin(2*l)-2*eccentricity*math.Sin(m)+
+		4*eccentricity*y*math.Sin(m)*math.Cos(2*l)-0.5*y*y*math.Sin(4*l)-1.25*eccentricity*eccentricity*math.Sin(2*m))
+	return declination, equationOfTime
+}
+
+// Interval is a span of time
+type Interval struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// Day is the daylight and twilight of a solar day at a place. Times the sun
+// does not reach that day, as near the poles, are nil.
+type Day struct {
+	SolarNoon time.Time `json:"solarNoon"`
+	// NoonElevation is the sun's elevation at solar noon (degrees)
+	NoonElevation float64    `json:"noonElevation"`
+	CivilDawn     *time.Time `json:"civilDawn"`
+	Sunrise       *time.Time `json:"sunrise"`
+	Sunset        *time.Time `json:"sunset"`
+	CivilDusk     *time.Time `json:"civilDusk"`
+	// The blue hour is while the sun is between 6° and 4° below the
+	// horizon, the golden hour from then until it is 6° above it
+	MorningBlueHour   *Interval `json:"morningBlueHour"`
+	MorningGoldenHour *Interval `json:"morningGoldenHour"`
+	EveningGoldenHour *Interval `json:"eveningGoldenHour"`
+	EveningBlueHour   *Interval `json:"eveningBlueHour"`
+}
+
+// DayAt returns the solar day at a place that a time falls in, from
+// midnight to midnight local solar time
+func DayAt(t time.Time, lat, lng float64) Day {
+	noon := solarNoon(t, lng)
+	_, noonElevation := Position(noon, lat, lng)
+	day := Day{SolarNoon: noon, NoonElevation: noonElevation}
+
+	morning := func(elevation float64) *time.Time {
+		return crossing(noon.Add(-12*time.Hour), noon, lat, lng, elevation)
+	}
+	evening := func(elevation float64) *time.Time {
+		return crossing(noon, noon.Add(12*time.Hour), lat, lng, elevation)
+	}
+	day.CivilDawn, day.Sunrise = morning(CivilElevation), morning(SunriseElevation)
+	day.Sunset, day.CivilDusk = evening(SunriseElevation), evening(CivilElevation)
+
+	blueEnd, goldenEnd := morning(BlueElevation), morning(GoldenElevation)
+	day.MorningBlueHour = interval(day.CivilDawn, blueEnd)
+	day.MorningGoldenHour = interval(blueEnd, goldenEnd)
+	goldenStart, blueStart := evening(GoldenElevation), evening(BlueElevation)
+	day.EveningGoldenHour = interval(goldenStart, blueStart)
+	day.EveningBlueHour = interval(blueStart, day.CivilDusk)
+	return day
+}
+
+// solarNoon returns the solar noon of the local solar day a time falls in
+func solarNoon(t time.Time, lng float64) time.Time {
+	local := t.UTC().Add(time.Duration(lng / 15 * float64(time.Hour)))
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+	noon := midnight.Add(time.Duration((720 - 4*lng) * float64(time.Minute)))
+	// The equation of time changes slowly, so two corrections settle it
+	for i := 0; i < 2; i++ {
+		_, equationOfTime := orbit(noon)
+		noon = midnight.Add(time.Duration((720 - 4*lng - equationOfTime) * float64(time.Minute)))
+	}
+	return noon.Round(searchPrecision)
+}
+
+// crossing returns when the sun passes an elevation between two times, over
+// which its elevation changes monotonically, or nil if it does not
+func crossing(from, to time.Time, lat, lng, elevation float64) *time.Time {
+	above := func(t time.Time) bool {
+		_, e := Position(t, lat, lng)
+		return e >= elevation
+	}
+	fromAbove := above(from)
+	if fromAbove == above(to) {
+		return nil
+	}
+	for to.Sub(from) > searchPrecision {
+		middle := from.Add(to.Sub(from) / 2)
+		if above(middle) == fromAbove {
+			from = middle
+		} else {
+			to = middle
+		}
+	}
+	result := from.Add(to.Sub(from) / 2).Round(searchPrecision)
+	return &result
+}
+
+// interval returns the interval between two times, or nil without both
+func interval(start, end *time.Time) *Interval {
+	if start == nil || end == nil {
+		return nil
+	}
+	return &Interval{Start: *start, End: *end}
+}
+
+// Separation returns the angle (degrees) between the sun and a direction
+// given by its azimuth and elevation
+func Separation(sunAzimuth, sunElevation, azimuth, elevation float64) float64 {
+	e1, e2 := radians(sunElevation), radians(elevation)
+	cos := math.Sin(e1)*math.Sin(e2) + math.Cos(e1)*math.Cos(e2)*math.Cos(radians(sunAzimuth-azimuth))
+	return degrees(math.Acos(math.Max(-1, math.Min(1, cos))))
+}
+
+func radians(degrees float64) float64 { return degrees * math.Pi / 180 }
+
+func degrees(radians float64) float64 { return radians * 180 / math.Pi }
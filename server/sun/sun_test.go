@@ -0,0 +1,141 @@
+package sun
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// utc returns a time on a day of 2024 in UTC
+func utc(month time.Month, day, hour, minute int) time.Time {
+	return time.Date(2024, month, day, hour, minute, 0, 0, time.UTC)
+}
+
+func TestPosition(t *testing.T) {
+	// NOAA solar calculator values
+	tests := []struct {
+		name               string
+		at                 time.Time
+		lat, lng           float64
+		azimuth, elevation float64
+	}{
+		{"Boulder morning", utc(time.June, 21, 18, 0), 40.015, -105.2705, 136.55, 68.75},
+		{"Greenwich noon", utc(time.June, 21, 12, 2), 51.4769, -0.0005, 180.02, 61.96},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			azimuth, elevation := Position(tt.at, tt.lat, tt.lng)
+			if math.Abs(azimuth-tt.azimuth) > 0.05 || math.Abs(elevation-tt.elevation) > 0.01 {
+				t.Errorf("azimuth %g° elevation %g°, want %g and %g", azimuth, elevation, tt.azimuth, tt.elevation)
+			}
+		})
+	}
+}
+
+func TestDayAt(t *testing.T) {
+	// clock returns a time of the day, nil for a time the sun does not reach
+	clock := func(month time.Month, day, hour, minute int) *time.Time {
+		result := utc(month, day, hour, minute)
+		return &result
+	}
+	// NOAA solar calculator values to the minute, in UTC
+	tests := []struct {
+		name          string
+		at            time.Time
+		lat, lng      float64
+		noon          time.Time
+		noonElevation float64
+		civilDawn     *time.Time
+		sunrise       *time.Time
+		sunset        *time.Time
+		civilDusk     *time.Time
+	}{
+		{"Greenwich midsummer", utc(time.June, 21, 12, 0), 51.4769, -0.0005,
+			utc(time.June, 21, 12, 2), 61.96,
+			clock(time.June, 21, 2, 55), clock(time.June, 21, 3, 43), clock(time.June, 21, 20, 21), clock(time.June, 21, 21, 9)},
+		// The evening falls on the next UTC day
+		{"Boulder midsummer", utc(time.June, 21, 18, 0), 40.015, -105.2705,
+			utc(time.June, 21, 19, 3), 73.42,
+			clock(time.June, 21, 11, 0), clock(time.June, 21, 11, 32), clock(time.June, 22, 2, 34), clock(time.June, 22, 3, 7)},
+		// The midnight sun neither rises nor sets
+		{"Tromsø polar day", utc(time.June, 21, 12, 0), 69.6496, 18.956,
+			utc(time.June, 21, 10, 46), 43.79,
+			nil, nil, nil, nil},
+		// In the polar night the sun stays below the horizon but there is
+		// civil twilight around noon
+		{"Tromsø polar night", utc(time.December, 21, 12, 0), 69.6496, 18.956,
+			utc(time.December, 21, 10, 42), -3.09,
+			clock(time.December, 21, 8, 31), nil, nil, clock(time.December, 21, 12, 53)},
+	}
+	near := func(got, want time.Time) bool {
+		return math.Abs(got.Sub(want).Seconds()) <= 60
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			day := DayAt(tt.at, tt.lat, tt.lng)
+			if !near(day.SolarNoon, tt.noon) || math.Abs(day.NoonElevation-tt.noonElevation) > 0.01 {
+				t.Errorf("noon at %v, %g° high, want %v and %g", day.SolarNoon, day.NoonElevation, tt.noon, tt.noonElevation)
+			}
+			for _, c := range []struct {
+				name      string
+				got, want *time.Time
+			}{
+				{"civil dawn", day.CivilDawn, tt.civilDawn},
+				{"sunrise", day.Sunrise, tt.sunrise},
+				{"sunset", day.Sunset, tt.sunset},
+				{"civil dusk", day.CivilDusk, tt.civilDusk},
+			} {
+				switch {
+				case c.want == nil && c.got != nil:
+					t.Errorf("%s at %v, want none", c.name, *c.got)
+				case c.want != nil && (c.got == nil || !near(*c.got, *c.want)):
+					t.Errorf("%s at %v, want %v", c.name, c.got, *c.want)
+				}
+			}
+			if tt.sunrise == nil && (day.MorningGoldenHour != nil || day.EveningGoldenHour != nil) {
+				t.Errorf("golden hours %+v and %+v without a sunrise", day.MorningGoldenHour, day.EveningGoldenHour)
+			}
+		})
+	}
+}
+
+func TestDayAtHours(t *testing.T) {
+	day := DayAt(utc(time.June, 21, 12, 0), 51.4769, -0.0005)
+	// The blue hour runs from civil dawn into the golden hour, which ends
+	// after sunrise
+	morningBlue, morningGolden := day.MorningBlueHour, day.MorningGoldenHour
+	if morningBlue == nil || morningGolden == nil {
+		t.Fatalf("morning hours %+v and %+v", morningBlue, morningGolden)
+	}
+	if !morningBlue.Start.Equal(*day.CivilDawn) || !morningBlue.End.Equal(morningGolden.Start) || !morningGolden.End.After(*day.Sunrise) {
+		t.Errorf("blue hour %+v and golden hour %+v around dawn %v and sunrise %v", morningBlue, morningGolden, *day.CivilDawn, *day.Sunrise)
+	}
+	eveningGolden, eveningBlue := day.EveningGoldenHour, day.EveningBlueHour
+	if eveningGolden == nil || eveningBlue == nil || !eveningBlue.End.Equal(*day.CivilDusk) || !eveningGolden.End.Equal(eveningBlue.Start) {
+		t.Errorf("evening hours %+v and %+v", eveningGolden, eveningBlue)
+	}
+	for _, c := range []struct {
+		at        time.Time
+		elevation float64
+	}{{morningBlue.End, BlueElevation}, {morningGolden.End, GoldenElevation}, {*day.Sunset, SunriseElevation}} {
+		if _, elevation := Position(c.at, 51.4769, -0.0005); math.Abs(elevation-c.elevation) > 0.01 {
+			t.Errorf("elevation at %v = %g°, want %g", c.at, elevation, c.elevation)
+		}
+	}
+}
+
+func TestSeparation(t *testing.T) {
+	tests := []struct {
+		sunAzimuth, sunElevation, azimuth, elevation, want float64
+	}{
+		{180, 30, 180, 30, 0},
+		{90, 0, 270, 0, 180},
+		{0, 90, 123, 0, 90},
+		{180, 30, 180, -30, 60},
+	}
+	for _, tt := range tests {
+		if got := Separation(tt.sunAzimuth, tt.sunElevation, tt.azimuth, tt.elevation); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Separation(%g, %g, %g, %g) = %g, want %g", tt.sunAzimuth, tt.sunElevation, tt.azimuth, tt.elevation, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,431 @@
+package terrain
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// TIFF tags read by the GeoTIFF loader
+const (
+	tagImageWidth      = 256
+	tagImageLength     = 257
+	tagBitsPerSample   = 258
+	tagCompression     = 259
+	tagStripOffsets    = 273
+	tagSamplesPerPixel = 277
+	tagRowsPerStrip    = 278
+	tagStripByteCounts = 279
+	tagPredictor       = 317
+	tagTileWidth       = 322
+	tagTileLength      = 323
+	tagTileOffsets     = 324
+	tagTileByteCounts  = 325
+	tagSampleFormat    = 339
+	tagModelPixelScale = 33550
+	tagModelTiepoint   = 33922
+	tagGeoKeyDirectory = 34735
+	tagGDALNoData      = 42113
+)
+
+// GeoKeys read from the GeoKeyDirectory and their values
+const (
+	geoKeyModelType    = 1024
+	geoKeyRasterType   = 1025
+	modelTypeProjected = 1
+	rasterPixelIsPoint = 2
+)
+
+// Supported sample formats, compressions and predictors
+const (
+	sampleFormatUint     = 1
+	sampleFormatInt      = 2
+	sampleFormatFloat    = 3
+	compressionNone      = 1
+	compressionDeflate   = 8
+	compressionDeflateV0 = 32946
+	predictorHorizontal  = 2
+)
+
+// geoTIFF is a single band DEM in geographic (EPSG:4326) coordinates.
+// Uncompressed and deflate-compressed strips and tiles are supported.
+type geoTIFF struct {
+	path      string
+	byteOrder binary.ByteOrder
+
+	width, height  int
+	bits, format   int
+	compression    int
+	predictor      int
+	blockWidth     int
+	blockHeight    int
+	offsets, sizes []uint64
+	noData         float64
+	hasNoData      bool
+
+	// Position of the first sample's center and the size of a pixel (degrees)
+	originLng, originLat float64
+	scaleLng, scaleLat   float64
+
+	load    sync.Once
+	loadErr error
+	samples []float32
+}
+
+// ifdEntry is a raw TIFF directory entry
+type ifdEntry struct {
+	kind  uint16
+	count uint32
+	value []byte
+}
+
+// openGeoTIFF reads a GeoTIFF's tags; the raster is read on first use
+func openGeoTIFF(path string) (*geoTIFF, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(file, header); err != nil {
+		return nil, fmt.Errorf("failed to read TIFF header: %v", err)
+	}
+	t := &geoTIFF{path: path}
+	switch string(header[:2]) {
+	case "II":
+		t.byteOrder = binary.LittleEndian
+	case "MM":
+		t.byteOrder = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("not a TIFF file")
+	}
+	if magic := t.byteOrder.Uint16(header[2:]); magic != 42 {
+		return nil, fmt.Errorf("unsupported TIFF variant %d (BigTIFF is not supported)", magic)
+	}
+
+	entries, err := t.readIFD(file, int64(t.byteOrder.Uint32(header[4:])))
+	if err != nil {
+		return nil, err
+	}
+	if err := t.parseTags(entries); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// readIFD reads the first image file directory
+func (t *geoTIFF) readIFD(file io.ReaderAt, offset int64) (map[uint16]ifdEntry, error) {
+	countBytes := make([]byte, 2)
+	if _, err := file.ReadAt(countBytes, offset); err != nil {
+		return nil, fmt.Errorf("failed to read TIFF directory: %v", err)
+	}
+	count := int(t.byteOrder.Uint16(countBytes))
+	raw := make([]byte, count*12)
+	if _, err := file.ReadAt(raw, offset+2); err != nil {
+		return nil, fmt.Errorf("failed to read TIFF directory: %v", err)
+	}
+
+	entries := make(map[uint16]ifdEntry, count)
+	for i := 0; i < count; i++ {
+		field := raw[i*12 : i*12+12]
+		entry := ifdEntry{
+			kind:  t.byteOrder.Uint16(field[2:]),
+			count: t.byteOrder.Uint32(field[4:]),
+		}
+		size := int(entry.count) * typeSize(entry.kind)
+		if size <= 4 {
+			entry.value = field[8 : 8+size]
+		} else {
+			entry.value = make([]byte, size)
+			if _, err := file.ReadAt(entry.value, int64(t.byteOrder.Uint32(field[8:]))); err != nil {
+				return nil, fmt.Errorf("failed to read TIFF tag %d: %v", t.byteOrder.Uint16(field), err)
+			}
+		}
+		entries[t.byteOrder.Uint16(field)] = entry
+	}
+	return entries, nil
+}
+
+// typeSize returns the size in bytes of a TIFF field type
+func typeSize(kind uint16) int {
+	switch kind {
+	case 1, 2, 6, 7:
+		return 1
+	case 3, 8:
+		return 2
+	case 4, 9, 11:
+		return 4
+	case 5, 10, 12:
+		return 8
+	}
+	return 0
+}
+
+// numbers decodes a numeric TIFF field
+func (t *geoTIFF) numbers(entry ifdEntry) []float64 {
+	values := make([]float64, 0, entry.count)
+	for i := 0; i < int(entry.count); i++ {
+		switch entry.kind {
+		case 1, 7:
+			values = append(values, float64(entry.value[i]))
+		case 3:
+			values = append(values, float64(t.byteOrder.Uint16(entry.value[i*2:])))
+		case 4:
+			values = append(values, float64(t.byteOrder.Uint32(entry.value[i*4:])))
+		case 11:
+			values = append(values, float64(math.Float32frombits(t.byteOrder.Uint32(entry.value[i*4:]))))
+		case 12:
+			values = append(values, math.Float64frombits(t.byteOrder.Uint64(entry.value[i*8:])))
+		}
+	}
+	return values
+}
+
+// number returns the first value of a numeric tag, or a default
+func (t *geoTIFF) number(entries map[uint16]ifdEntry, tag uint16, fallback float64) float64 {
+	entry, ok := entries[tag]
+	if !ok {
+		return fallback
+	}
+	values := t.numbers(entry)
+	if len(values) == 0 {
+		return fallback
+	}
+	return values[0]
+}
+
+// parseTags reads the raster layout and georeferencing
+func (t *geoTIFF) parseTags(entries map[uint16]ifdEntry) error {
+	t.width = int(t.number(entries, tagImageWidth, 0))
+	t.height = int(t.number(entries, tagImageLength, 0))
+	t.bits = int(t.number(entries, tagBitsPerSample, 1))
+	t.format = int(t.number(entries, tagSampleFormat, sampleFormatUint))
+	t.compression = int(t.number(entries, tagCompression, compressionNone))
+	t.predictor = int(t.number(entries, tagPredictor, 1))
+	if t.width == 0 || t.height == 0 {
+		return fmt.Errorf("missing image size")
+	}
+	if samples := t.number(entries, tagSamplesPerPixel, 1); samples != 1 {
+		return fmt.Errorf("expected a single band, got %g", samples)
+	}
+	switch t.compression {
+	case compressionNone, compressionDeflate, compressionDeflateV0:
+	default:
+		return fmt.Errorf("unsupported compression %d", t.compression)
+	}
+	if t.predictor != 1 && t.predictor != predictorHorizontal {
+		return fmt.Errorf("unsupported predictor %d", t.predictor)
+	}
+	if t.predictor == predictorHorizontal && t.format == sampleFormatFloat {
+		return fmt.Errorf("horizontal predictor is not supported for floating point samples")
+	}
+	switch {
+	case t.format == sampleFormatFloat && (t.bits == 32 || t.bits == 64):
+	case (t.format == sampleFormatUint || t.format == sampleFormatInt) && (t.bits == 8 || t.bits == 16 || t.bits == 32):
+	default:
+		return fmt.Errorf("unsupported sample format %d with %d bits", t.format, t.bits)
+	}
+
+	offsetsTag, sizesTag := uint16(tagStripOffsets), uint16(tagStripByteCounts)
+	t.blockWidth = t.width
+	t.blockHeight = int(t.number(entries, tagRowsPerStrip, float64(t.height)))
+	if _, tiled := entries[tagTileOffsets]; tiled {
+		offsetsTag, sizesTag = tagTileOffsets, tagTileByteCounts
+		t.blockWidth = int(t.number(entries, tagTileWidth, 0))
+		t.blockHeight = int(t.number(entries, tagTileLength, 0))
+	}
+	if t.blockWidth <= 0 || t.blockHeight <= 0 {
+		return fmt.Errorf("invalid strip or tile size")
+	}
+	for _, value := range t.numbers(entries[offsetsTag]) {
+		t.offsets = append(t.offsets, uint64(value))
+	}
+	for _, value := range t.numbers(entries[sizesTag]) {
+		t.sizes = append(t.sizes, uint64(value))
+	}
+	if len(t.offsets) == 0 || len(t.offsets) != len(t.sizes) {
+		return fmt.Errorf("missing strip or tile offsets")
+	}
+
+	if entry, ok := entries[tagGDALNoData]; ok {
+		text := strings.Trim(string(entry.value), "\x00 ")
+		if value, err := strconv.ParseFloat(text, 64); err == nil {
+			t.noData, t.hasNoData = value, true
+		}
+	}
+
+	return t.parseGeoreference(entries)
+}
+
+// parseGeoreference reads the tie point and pixel scale. Only geographic
+// rasters are supported, so both are in degrees.
+func (t *geoTIFF) parseGeoreference(entries map[uint16]ifdEntry) error {
+	scale := t.numbers(entries[tagModelPixelScale])
+	tiepoint := t.numbers(entries[tagModelTiepoint])
+	if len(scale) < 2 || len(tiepoint) < 6 {
+		return fmt.Errorf("missing GeoTIFF tie point or pixel scale")
+	}
+
+	pixelIsPoint := false
+	if keys := t.numbers(entries[tagGeoKeyDirectory]); len(keys) >= 4 {
+		for i := 4; i+3 < len(keys) && i < 4+int(keys[3])*4; i += 4 {
+			// Keys stored inline have a location of 0
+			if keys[i+1] != 0 {
+				continue
+			}
+			switch int(keys[i]) {
+			case geoKeyModelType:
+				if int(keys[i+3]) == modelTypeProjected {
+					return fmt.Errorf("projected GeoTIFFs are not supported; reproject to EPSG:4326")
+				}
+			case geoKeyRasterType:
+				pixelIsPoint = int(keys[i+3]) == rasterPixelIsPoint
+			}
+		}
+	}
+
+	t.scaleLng, t.scaleLat = scale[0], scale[1]
+	if t.scaleLng <= 0 || t.scaleLat <= 0 {
+		return fmt.Errorf("invalid pixel scale")
+	}
+	t.originLng = tiepoint[3] - tiepoint[0]*t.scaleLng
+	t.originLat = tiepoint[4] + tiepoint[1]*t.scaleLat
+	if !pixelIsPoint {
+		// The tie point is the corner of the pixel; samples sit at centers
+		t.originLng += t.scaleLng / 2
+		t.originLat -= t.scaleLat / 2
+	}
+	return nil
+}
+
+// contains reports whether a position lies within the raster's samples
+func (t *geoTIFF) contains(lat, lng float64) bool {
+	x := (lng - t.originLng) / t.scaleLng
+	y := (t.originLat - lat) / t.scaleLat
+	return x >= 0 && y >= 0 && x <= float64(t.width-1) && y <= float64(t.height-1)
+}
+
+// elevation interpolates the raster at a position
+func (t *geoTIFF) elevation(lat, lng float64) (float64, error) {
+	t.load.Do(func() { t.loadErr = t.readRaster() })
+	if t.loadErr != nil {
+		return 0, t.loadErr
+	}
+
+	x := (lng - t.originLng) / t.scaleLng
+	y := (t.originLat - lat) / t.scaleLat
+	col := int(math.Max(math.Min(math.Floor(x), float64(t.width-2)), 0))
+	row := int(math.Max(math.Min(math.Floor(y), float64(t.height-2)), 0))
+	corners := [4]float64{
+		t.sample(row, col), t.sample(row, col+1),
+		t.sample(row+1, col), t.sample(row+1, col+1),
+	}
+	return interpolate(corners, x-float64(col), y-float64(row))
+}
+
+// sample returns a sample, NaN for no data or positions outside the raster
+func (t *geoTIFF) sample(row, col int) float64 {
+	if row < 0 || col < 0 || row >= t.height || col >= t.width {
+		return math.NaN()
+	}
+	value := float64(t.samples[row*t.width+col])
+	if t.hasNoData && value == t.noData {
+		return math.NaN()
+	}
+	return value
+}
+
+// readRaster decodes every strip or tile into samples
+func (t *geoTIFF) readRaster() error {
+	file, err := os.Open(t.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	t.samples = make([]float32, t.width*t.height)
+	blocksAcross := (t.width + t.blockWidth - 1) / t.blockWidth
+	for i := range t.offsets {
+		data := make([]byte, t.sizes[i])
+		if _, err := file.ReadAt(data, int64(t.offsets[i])); err != nil {
+			return fmt.Errorf("failed to read %s: %v", t.path, err)
+		}
+		if t.compression != compressionNone {
+			reader, err := zlib.NewReader(bytes.NewReader(data))
+			if err != nil {
+				return fmt.Errorf("failed to decompress %s: %v", t.path, err)
+			}
+			data, err = io.ReadAll(reader)
+			if err != nil {
+				return fmt.Errorf("failed to decompress %s: %v", t.path, err)
+			}
+		}
+
+		values := t.decodeBlock(data)
+		left := (i % blocksAcross) * t.blockWidth
+		top := (i / blocksAcross) * t.blockHeight
+		for r := 0; r < t.blockHeight && top+r < t.height; r++ {
+			for c := 0; c < t.blockWidth && left+c < t.width; c++ {
+				index := r*t.blockWidth + c
+				if index < len(values) {
+					t.samples[(top+r)*t.width+left+c] = float32(values[index])
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// decodeBlock converts a decompressed strip or tile into sample values,
+// undoing horizontal differencing
+func (t *geoTIFF) decodeBlock(data []byte) []float64 {
+	size := t.bits / 8
+	count := len(data) / size
+	raw := make([]uint64, count)
+	for i := range raw {
+		chunk := data[i*size:]
+		switch size {
+		case 1:
+			raw[i] = uint64(chunk[0])
+		case 2:
+			raw[i] = uint64(t.byteOrder.Uint16(chunk))
+		case 4:
+			raw[i] = uint64(t.byteOrder.Uint32(chunk))
+		case 8:
+			raw[i] = t.byteOrder.Uint64(chunk)
+		}
+	}
+
+	if t.predictor == predictorHorizontal {
+		mask := uint64(1)<<uint(t.bits) - 1
+		for i := range raw {
+			if i%t.blockWidth != 0 {
+				raw[i] = (raw[i] + raw[i-1]) & mask
+			}
+		}
+	}
+
+	values := make([]float64, count)
+	for i, bits := range raw {
+		switch {
+		case t.format == sampleFormatFloat && t.bits == 32:
+			values[i] = float64(math.Float32frombits(uint32(bits)))
+		case t.format == sampleFormatFloat:
+			values[i] = math.Float64frombits(bits)
+		case t.format == sampleFormatInt:
+			shift := uint(64 - t.bits)
+			values[i] = float64(int64(bits<<shift) >> shift)
+		default:
+			values[i] = float64(bits)
+		}
+	}
+	return values
+}
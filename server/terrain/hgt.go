@@ -0,0 +1,100 @@
+package terrain
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+)
+
+// hgtVoid marks samples without data in SRTM tiles
+const hgtVoid = -32768
+
+// hgtTile is a one degree SRTM tile of big-endian 16-bit samples, stored in
+// rows from north to south. Edge rows and columns overlap neighbouring tiles.
+type hgtTile struct {
+	south, west float64
+	size        int
+	samples     []int16
+}
+
+// hgtName returns the SRTM file name of the tile covering a position
+func hgtName(lat, lng float64) string {
+	south, west := int(math.Floor(lat)), int(math.Floor(lng))
+	ns, ew := 'N', 'E'
+	if south < 0 {
+		ns, south = 'S', -south
+	}
+	if west < 0 {
+		ew, west = 'W', -west
+	}
+	return fmt.Sprintf("%c%02d%c%03d.hgt", ns, south, ew, west)
+}
+
+// loadHGT reads an SRTM1 (3601 samples per side) or SRTM3 (1201) tile
+func loadHGT(path string, south, west float64) (*hgtTile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var size int
+	switch len(data) {
+	case 3601 * 3601 * 2:
+		size = 3601
+	case 1201 * 1201 * 2:
+		size = 1201
+	default:
+		return nil, fmt.Errorf("%s: unexpected HGT size %d bytes", path, len(data))
+	}
+
+	samples := make([]int16, size*size)
+	for i := range samples {
+		samples[i] = int16(binary.BigEndian.Uint16(data[i*2:]))
+	}
+	return &hgtTile{south: south, west: west, size: size, samples: samples}, nil
+}
+
+// elevation interpolates the tile at a position. Voids next to the position
+// are skipped; a position surrounded by voids has no data.
+func (t *hgtTile) elevation(lat, lng float64) (float64, error) {
+	last := float64(t.size - 1)
+	x := math.Min(math.Max((lng-t.west)*last, 0), last)
+	y := math.Min(math.Max((t.south+1-lat)*last, 0), last)
+
+	col := int(math.Min(math.Floor(x), last-1))
+	row := int(math.Min(math.Floor(y), last-1))
+	corners := [4]float64{
+		t.sample(row, col), t.sample(row, col+1),
+		t.sample(row+1, col), t.sample(row+1, col+1),
+	}
+	return interpolate(corners, x-float64(col), y-float64(row))
+}
+
+// sample returns a sample in meters, NaN for voids
+func (t *hgtTile) sample(row, col int) float64 {
+	value := t.samples[row*t.size+col]
+	if value == hgtVoid {
+		return math.NaN()
+	}
+	return float64(value)
+}
+
+// interpolate blends four corner samples (top-left, top-right, bottom-left,
+// bottom-right), falling back to the mean of the corners that are not NaN
+func interpolate(corners [4]float64, x, y float64) (float64, error) {
+	sum, count := 0.0, 0
+	for _, corner := range corners {
+		if !math.IsNaN(corner) {
+			sum += corner
+			count++
+		}
+	}
+	switch count {
+	case 0:
+		return 0, ErrNoData
+	case 4:
+		return bilinear(corners[0], corners[1], corners[2], corners[3], x, y), nil
+	}
+	return sum / float64(count), nil
+}
@@ -0,0 +1,219 @@
+package terrain
+
+import (
+	"fmt"
+	"math"
+
+	"drone-planner/server/geometry"
+	"drone-planner/server/models"
+)
+
+// DefaultSpacing is the distance between terrain samples along a route (m),
+// close to the resolution of SRTM1 tiles
+const DefaultSpacing = 30.0
+
+// Sample is the terrain under one point of a route. Elevations are in meters
+// above mean sea level.
+type Sample struct {
+	// Segment is the index of the waypoint the segment starts at
+	Segment   int     `json:"segment"`
+	Distance  float64 `json:"distance"` // m along the route
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Ground    float64 `json:"ground"`
+	Altitude  float64 `json:"altitude"`
+	// Clearance is the height above ground (m)
+	Clearance float64 `json:"clearance"`
+}
+
+// Violation is a segment that flies lower above ground than the minimum
+type Violation struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+	// Lowest is the sample with the least clearance on the segment
+	Lowest Sample `json:"lowest"`
+}
+
+// Profile is the terrain under a route
+type Profile struct {
+	HomeElevation   float64     `json:"homeElevation"`
+	MinClearance    float64     `json:"minClearance"`
+	LowestClearance float64     `json:"lowestClearance"`
+	Samples         []Sample    `json:"samples"`
+	Violations      []Violation `json:"violations"`
+}
+
+// span is a sampled straight line between two waypoints
+type span struct {
+	distances []float64 // m from the start of the span
+	points    []geometry.Point
+	grounds   []float64
+}
+
+// sampleSpan samples the ground between two points at most spacing apart,
+// including both ends. Curved segments are sampled along their chord.
+func sampleSpan(provider Provider, from, to geometry.Point, spacing float64) (*span, error) {
+	length := geometry.Distance(from.Latitude, from.Longitude, to.Latitude, to.Longitude)
+	steps := int(math.Ceil(length / spacing))
+	if steps < 1 {
+		steps = 1
+	}
+
+	s := &span{}
+	for i := 0; i <= steps; i++ {
+		f := float64(i) / float64(steps)
+		point := geometry.Point{
+			Latitude:  from.Latitude + (to.Latitude-from.Latitude)*f,
+			Longitude: from.Longitude + (to.Longitude-from.Longitude)*f,
+			Altitude:  from.Altitude + (to.Altitude-from.Altitude)*f,
+		}
+		ground, err := provider.Elevation(point.Latitude, point.Longitude)
+		if err != nil {
+			return nil, fmt.Errorf("elevation at %.6f,%.6f: %v", point.Latitude, point.Longitude, err)
+		}
+		s.distances = append(s.distances, length*f)
+		s.points = append(s.points, point)
+		s.grounds = append(s.grounds, ground)
+	}
+	return s, nil
+}
+
+// BuildProfile samples the terrain under a route whose altitudes are relative
+// to home, flagging segments lower than clearance above ground
+func BuildProfile(provider Provider, home geometry.Point, points []geometry.Point, clearance, spacing float64) (*Profile, error) {
+	if spacing <= 0 {
+		spacing = DefaultSpacing
+	}
+	homeElevation, err := provider.Elevation(home.Latitude, home.Longitude)
+	if err != nil {
+		return nil, fmt.Errorf("elevation at home: %v", err)
+	}
+
+	profile := &Profile{
+		HomeElevation:   homeElevation,
+		MinClearance:    clearance,
+		LowestClearance: math.Inf(1),
+		Samples:         []Sample{},
+		Violations:      []Violation{},
+	}
+	offset := 0.0
+	for i := 1; i < len(points); i++ {
+		s, err := sampleSpan(provider, points[i-1], points[i], spacing)
+		if err != nil {
+			return nil, err
+		}
+
+		var lowest Sample
+		hasLowest := false
+		for j := range s.points {
+			if j == 0 && i > 1 {
+				// Shared with the end of the previous segment
+				continue
+			}
+			altitude := homeElevation + s.points[j].Altitude
+			sample := Sample{
+				Segment:   i - 1,
+				Distance:  offset + s.distances[j],
+				Latitude:  s.points[j].Latitude,
+				Longitude: s.points[j].Longitude,
+				Ground:    s.grounds[j],
+				Altitude:  altitude,
+				Clearance: altitude - s.grounds[j],
+			}
+			profile.Samples = append(profile.Samples, sample)
+			if !hasLowest || sample.Clearance < lowest.Clearance {
+				lowest, hasLowest = sample, true
+			}
+		}
+		if hasLowest {
+			profile.LowestClearance = math.Min(profile.LowestClearance, lowest.Clearance)
+			if lowest.Clearance < clearance {
+				profile.Violations = append(profile.Violations, Violation{From: i - 1, To: i, Lowest: lowest})
+			}
+		}
+		offset += s.distances[len(s.distances)-1]
+	}
+	if math.IsInf(profile.LowestClearance, 1) {
+		profile.LowestClearance = 0
+	}
+	return profile, nil
+}
+
+// Follow rewrites a waypoint mission to fly a constant height above ground.
+// Waypoints keep their positions; intermediate waypoints are inserted where
+// the terrain between two waypoints departs from a straight climb by more
+// than tolerance (m). It returns the rewritten config and the number of
+// inserted waypoints.
+func Follow(provider Provider, home geometry.Point, config *models.WaypointMissionConfig, height, tolerance, spacing float64) (*models.WaypointMissionConfig, int, error) {
+	if spacing <= 0 {
+		spacing = DefaultSpacing
+	}
+	homeElevation, err := provider.Elevation(home.Latitude, home.Longitude)
+	if err != nil {
+		return nil, 0, fmt.Errorf("elevation at home: %v", err)
+	}
+
+	followed := *config
+	followed.Waypoints = make([]models.Waypoint, 0, len(config.Waypoints))
+	inserted := 0
+	for i, wp := range config.Waypoints {
+		if i > 0 {
+			prev := config.Waypoints[i-1]
+			s, err := sampleSpan(provider, waypointPoint(prev), waypointPoint(wp), spacing)
+			if err != nil {
+				return nil, 0, err
+			}
+			targets := make([]float64, len(s.grounds))
+			for j, ground := range s.grounds {
+				targets[j] = ground + height - homeElevation
+			}
+			for _, j := range breakpoints(s.distances, targets, 0, len(targets)-1, tolerance) {
+				inserted++
+				point := prev
+				point.ID = fmt.Sprintf("%s-terrain-%d", prev.ID, inserted)
+				point.Coordinate = models.Coordinate{Latitude: s.points[j].Latitude, Longitude: s.points[j].Longitude}
+				point.Altitude = targets[j]
+				point.CornerRadius = 0
+				point.Actions = nil
+				point.Targets = nil
+				followed.Waypoints = append(followed.Waypoints, point)
+			}
+		}
+
+		ground, err := provider.Elevation(wp.Coordinate.Latitude, wp.Coordinate.Longitude)
+		if err != nil {
+			return nil, 0, fmt.Errorf("elevation at waypoint %d: %v", i+1, err)
+		}
+		wp.Altitude = ground + height - homeElevation
+		followed.Waypoints = append(followed.Waypoints, wp)
+	}
+	return &followed, inserted, nil
+}
+
+// breakpoints returns, in order, the sample indices strictly between first
+// and last that must become waypoints for straight lines between them to
+// stay within tolerance of the target altitudes
+func breakpoints(distances, targets []float64, first, last int, tolerance float64) []int {
+	if last-first < 2 {
+		return nil
+	}
+	worst, deviation := -1, tolerance
+	for i := first + 1; i < last; i++ {
+		f := (distances[i] - distances[first]) / (distances[last] - distances[first])
+		line := targets[first] + (targets[last]-targets[first])*f
+		if d := math.Abs(targets[i] - line); d > deviation {
+			worst, deviation = i, d
+		}
+	}
+	if worst < 0 {
+		return nil
+	}
+	points := breakpoints(distances, targets, first, worst, tolerance)
+	points = append(points, worst)
+	return append(points, breakpoints(distances, targets, worst, last, tolerance)...)
+}
+
+// waypointPoint converts a waypoint to a route point
+func waypointPoint(wp models.Waypoint) geometry.Point {
+	return geometry.Point{Latitude: wp.Coordinate.Latitude, Longitude: wp.Coordinate.Longitude, Altitude: wp.Altitude}
+}
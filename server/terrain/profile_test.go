@@ -0,0 +1,114 @@
+package terrain
+
+import (
+	"math"
+	"testing"
+
+	"drone-planner/server/geometry"
+	"drone-planner/server/models"
+)
+
+// ground is terrain given as a function of latitude
+type ground func(lat float64) float64
+
+func (g ground) Elevation(lat, lng float64) (float64, error) {
+	return g(lat), nil
+}
+
+func TestBuildProfile(t *testing.T) {
+	// Ground rises 11 m per 0.001° north of 100 m at home
+	provider := ground(func(lat float64) float64 { return 100 + (lat-47)*11000 })
+	home := geometry.Point{Latitude: 47, Longitude: 8}
+	points := []geometry.Point{
+		{Latitude: 47, Longitude: 8, Altitude: 50},
+		{Latitude: 47.001, Longitude: 8, Altitude: 50},
+		{Latitude: 47.002, Longitude: 8, Altitude: 150},
+	}
+
+	profile, err := BuildProfile(provider, home, points, 40, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.HomeElevation != 100 {
+		t.Errorf("home elevation = %g, want 100", profile.HomeElevation)
+	}
+	// Each 111 m segment is sampled in four steps, sharing the middle sample
+	if len(profile.Samples) != 9 {
+		t.Fatalf("got %d samples, want 9", len(profile.Samples))
+	}
+	for i, sample := range profile.Samples {
+		if want := 100 + (sample.Latitude-47)*11000; math.Abs(sample.Ground-want) > 1e-6 {
+			t.Errorf("sample %d ground = %g, want %g", i, sample.Ground, want)
+		}
+		if math.Abs(sample.Clearance-(sample.Altitude-sample.Ground)) > 1e-9 {
+			t.Errorf("sample %d clearance %g at %g m over %g m", i, sample.Clearance, sample.Altitude, sample.Ground)
+		}
+		if i > 0 && sample.Distance <= profile.Samples[i-1].Distance {
+			t.Errorf("sample %d at %g m after %g m", i, sample.Distance, profile.Samples[i-1].Distance)
+		}
+	}
+	if want := geometry.Distance(47, 8, 47.002, 8); math.Abs(profile.Samples[8].Distance-want) > 1e-6 {
+		t.Errorf("route ends at %g m, want %g", profile.Samples[8].Distance, want)
+	}
+
+	// The level first segment ends 39 m above the rising ground
+	if math.Abs(profile.LowestClearance-39) > 1e-6 {
+		t.Errorf("lowest clearance = %g, want 39", profile.LowestClearance)
+	}
+	if len(profile.Violations) != 1 || profile.Violations[0].From != 0 || profile.Violations[0].To != 1 ||
+		math.Abs(profile.Violations[0].Lowest.Latitude-47.001) > 1e-9 {
+		t.Errorf("violations = %+v, want the first segment at its end", profile.Violations)
+	}
+}
+
+func TestFollow(t *testing.T) {
+	// A 60 m ridge peaking at 47.005° on flat 100 m ground
+	provider := ground(func(lat float64) float64 {
+		return 100 + math.Max(0, 60-math.Abs(lat-47.005)*30000)
+	})
+	home := geometry.Point{Latitude: 47, Longitude: 8}
+	photo := []models.WaypointAction{{ActionType: models.ActionTakePhoto}}
+	config := &models.WaypointMissionConfig{
+		Waypoints: []models.Waypoint{
+			{ID: "wp1", Coordinate: models.Coordinate{Latitude: 47, Longitude: 8}, Altitude: 30, CornerRadius: 5, Actions: photo},
+			{ID: "wp2", Coordinate: models.Coordinate{Latitude: 47.01, Longitude: 8}, Altitude: 30, Actions: photo},
+		},
+	}
+	// Sample every 0.001° along the route
+	spacing := geometry.Distance(47, 8, 47.01, 8) / 10 * (1 + 1e-9)
+
+	followed, inserted, err := Follow(provider, home, config, 50, 5, spacing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Waypoints are needed at the feet and the top of the ridge only
+	want := []struct {
+		id       string
+		lat, alt float64
+	}{
+		{"wp1", 47, 50},
+		{"wp1-terrain-1", 47.003, 50},
+		{"wp1-terrain-2", 47.005, 110},
+		{"wp1-terrain-3", 47.007, 50},
+		{"wp2", 47.01, 50},
+	}
+	if inserted != 3 || len(followed.Waypoints) != len(want) {
+		t.Fatalf("inserted %d waypoints into %+v, want 3", inserted, followed.Waypoints)
+	}
+	for i, w := range want {
+		wp := followed.Waypoints[i]
+		if wp.ID != w.id || math.Abs(wp.Coordinate.Latitude-w.lat) > 1e-9 || math.Abs(wp.Altitude-w.alt) > 1e-6 {
+			t.Errorf("waypoint %d = %s at %g° and %g m, want %s at %g° and %g m", i, wp.ID, wp.Coordinate.Latitude, wp.Altitude, w.id, w.lat, w.alt)
+		}
+		added := i > 0 && i < len(want)-1
+		if added && (len(wp.Actions) != 0 || wp.CornerRadius != 0) {
+			t.Errorf("inserted waypoint %d kept actions %+v and corner radius %g", i, wp.Actions, wp.CornerRadius)
+		}
+		if !added && len(wp.Actions) != 1 {
+			t.Errorf("waypoint %d lost its actions", i)
+		}
+	}
+	if config.Waypoints[0].Altitude != 30 || len(config.Waypoints) != 2 {
+		t.Error("following the terrain changed the original mission")
+	}
+}
@@ -0,0 +1,100 @@
+// Package terrain looks up ground elevations from local DEM tiles and
+// measures how high a route flies above the terrain.
+package terrain
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ErrNoData is returned for positions no tile covers or that fall on voids
+var ErrNoData = errors.New("no elevation data")
+
+// Provider returns ground elevations in meters above mean sea level
+type Provider interface {
+	Elevation(lat, lng float64) (float64, error)
+}
+
+// DirectoryProvider serves elevations from the SRTM .hgt and GeoTIFF files in
+// a directory. HGT tiles are found by name; GeoTIFF bounds are read when the
+// provider is created and their rasters are loaded on first use.
+type DirectoryProvider struct {
+	dir      string
+	geotiffs []*geoTIFF
+
+	mutex sync.Mutex
+	tiles map[string]*hgtTile
+}
+
+// NewDirectoryProvider indexes the DEM files in dir
+func NewDirectoryProvider(dir string) (*DirectoryProvider, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read terrain directory: %v", err)
+	}
+
+	provider := &DirectoryProvider{dir: dir, tiles: map[string]*hgtTile{}}
+	for _, entry := range entries {
+		name := entry.Name()
+		switch strings.ToLower(filepath.Ext(name)) {
+		case ".tif", ".tiff":
+			geotiff, err := openGeoTIFF(filepath.Join(dir, name))
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", name, err)
+			}
+			provider.geotiffs = append(provider.geotiffs, geotiff)
+		}
+	}
+	return provider, nil
+}
+
+// Elevation returns the ground elevation at a position, preferring HGT tiles
+func (p *DirectoryProvider) Elevation(lat, lng float64) (float64, error) {
+	if hgt, err := p.hgt(lat, lng); err != nil {
+		return 0, err
+	} else if hgt != nil {
+		return hgt.elevation(lat, lng)
+	}
+	for _, geotiff := range p.geotiffs {
+		if geotiff.contains(lat, lng) {
+			return geotiff.elevation(lat, lng)
+		}
+	}
+	return 0, ErrNoData
+}
+
+// hgt returns the HGT tile covering a position, or nil when there is none
+func (p *DirectoryProvider) hgt(lat, lng float64) (*hgtTile, error) {
+	name := hgtName(lat, lng)
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if tile, ok := p.tiles[name]; ok {
+		return tile, nil
+	}
+
+	tile, err := loadHGT(filepath.Join(p.dir, name), math.Floor(lat), math.Floor(lng))
+	if errors.Is(err, os.ErrNotExist) {
+		// Remember missing tiles so the directory is not searched again
+		p.tiles[name] = nil
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.tiles[name] = tile
+	return tile, nil
+}
+
+// bilinear interpolates between four corner values at fractional offsets
+// x and y (0..1) from the top-left corner
+func bilinear(topLeft, topRight, bottomLeft, bottomRight, x, y float64) float64 {
+	top := topLeft + (topRight-topLeft)*x
+	bottom := bottomLeft + (bottomRight-bottomLeft)*x
+	return top + (bottom-top)*y
+}
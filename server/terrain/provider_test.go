@@ -0,0 +1,78 @@
+package terrain
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+// The testdata directory holds three DEMs:
+//   - N47E008.hgt, an SRTM3 tile whose samples fall 2 m per row south and
+//     rise 1 m per column east from 1000 m, with a 10/20/30/60 m cell at row
+//     200, column 100, a void at row 800, column 800 and a cell of voids at
+//     rows and columns 1000-1001
+//   - strips.tif, a 4x3 little-endian int16 raster of 0.01° pixels from
+//     46.03°N 7°E, deflated with the horizontal predictor, whose samples are
+//     100 m per row plus 10 m per column and whose last sample is no data
+//   - tiles.tif, a 20x20 big-endian float32 raster in 16 pixel tiles whose
+//     samples are points 0.001° apart from 45.02°N 9°E, falling 0.25 m per
+//     row and rising 0.5 m per column from 500 m
+func TestDirectoryProvider(t *testing.T) {
+	provider, err := NewDirectoryProvider("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		lat, lng float64
+		want     float64
+		err      error
+	}{
+		{"hgt sample", 47.5, 8.5, 400, nil},
+		{"hgt south-west corner", 47, 8, 1000 - 2*1200, nil},
+		{"hgt bilinear", 48 - 200.5/1200, 8 + 100.25/1200, 25, nil},
+		{"hgt next to a void", 48 - 800.5/1200, 8 + 800.5/1200, (201 + 198 + 199) / 3.0, nil},
+		{"hgt among voids", 48 - 1000.5/1200, 8 + 1000.5/1200, 0, ErrNoData},
+		{"strip sample", 46.025, 7.005, 0, nil},
+		{"strip bilinear", 46.02, 7.01, 55, nil},
+		{"strip in the second strip", 46.005, 7.015, 210, nil},
+		{"strip next to no data", 46.01, 7.03, (120 + 130 + 220) / 3.0, nil},
+		{"tile across tiles", 45.02 - 3.25*0.001, 9 + 17.5*0.001, 500 + 8.75 - 0.8125, nil},
+		{"tile in the last tile", 45.02 - 18.5*0.001, 9 + 18.5*0.001, 500 + 9.25 - 4.625, nil},
+		{"outside every DEM", 46.5, 7.5, 0, ErrNoData},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := provider.Elevation(tt.lat, tt.lng)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("got %g and error %v, want %v", got, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if math.Abs(got-tt.want) > 1e-6 {
+				t.Errorf("Elevation(%g, %g) = %g, want %g", tt.lat, tt.lng, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHGTName(t *testing.T) {
+	tests := []struct {
+		lat, lng float64
+		want     string
+	}{
+		{47.4, 8.5, "N47E008.hgt"},
+		{-33.9, 151.2, "S34E151.hgt"},
+		{40.7, -74.0, "N40W074.hgt"},
+		{-0.5, -0.5, "S01W001.hgt"},
+	}
+	for _, tt := range tests {
+		if got := hgtName(tt.lat, tt.lng); got != tt.want {
+			t.Errorf("hgtName(%g, %g) = %q, want %q", tt.lat, tt.lng, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,482 @@
+package timeline
+
+import (
+	"fmt"
+	"math"
+
+	"drone-planner/server/drones"
+	"drone-planner/server/geometry"
+	"drone-planner/server/models"
+)
+
+// Timing assumptions used by the duration estimators (s, deg/s)
+const (
+	photoCaptureTime   = 2.0
+	gimbalRotationTime = 1.0
+	zoomTime           = 2.0
+	defaultYawRate     = 30.0
+	defaultOrbitSpeed  = 5.0
+)
+
+// MinPhotoInterval is the shortest distance between photos a photoInterval
+// action may trigger (m)
+const MinPhotoInterval = 1.0
+
+// Accepted values for waypoint mission settings, including the aliases the
+// importers and exporters understand
+var (
+	finishedActions = []string{"NO_ACTION", "HOVER", "GO_HOME", "LAND", "AUTO_LAND", "GO_TO_FIRST_WAYPOINT", "GO_FIRST_WAYPOINT"}
+	headingModes    = []string{"AUTO", "USING_INITIAL_DIRECTION", "CONTROL_BY_REMOTE_CONTROLLER", "USING_WAYPOINT_HEADING", "TOWARD_POINT_OF_INTEREST"}
+	flightPathModes = []string{"NORMAL", "CURVED"}
+	turnModes       = []string{"CLOCKWISE", "COUNTER_CLOCKWISE"}
+	photoTypes      = []string{"single", "interval"}
+)
+
+// builtin is an ElementType assembled from functions
+type builtin struct {
+	schema    Schema
+	newConfig func() interface{}
+	validate  func(config interface{}, errs FieldErrors)
+	estimate  func(config interface{}) float64
+	// distance is set for elements that move the aircraft outside a route
+	distance func(config interface{}) float64
+	// limits is set for elements with values an aircraft may not be able to fly
+	limits func(config interface{}, profile *drones.Profile, errs FieldErrors)
+}
+
+func (b builtin) Name() string                                  { return b.schema.Type }
+func (b builtin) Schema() Schema                                { return b.schema }
+func (b builtin) NewConfig() interface{}                        { return b.newConfig() }
+func (b builtin) Validate(config interface{}, errs FieldErrors) { b.validate(config, errs) }
+func (b builtin) EstimateDuration(config interface{}) float64   { return b.estimate(config) }
+
+func (b builtin) EstimateDistance(config interface{}) float64 {
+	if b.distance == nil {
+		return 0
+	}
+	return b.distance(config)
+}
+
+func (b builtin) CheckLimits(config interface{}, profile *drones.Profile, errs FieldErrors) {
+	if b.limits != nil {
+		b.limits(config, profile, errs)
+	}
+}
+
+func init() {
+	Register(builtin{
+		schema: Schema{
+			Type:        models.ElementWaypointMission,
+			Label:       "Waypoint Mission",
+			Description: "Flies a sequence of waypoints with per-waypoint actions",
+			Fields: []SchemaField{
+				{Name: "autoFlightSpeed", Type: "number", Unit: "m/s", Min: bound(0), Required: true},
+				{Name: "maxFlightSpeed", Type: "number", Unit: "m/s", Min: bound(0), Required: true},
+				{Name: "finishedAction", Type: "string", Enum: finishedActions, Default: "GO_HOME"},
+				{Name: "repeatTimes", Type: "integer", Min: bound(0)},
+				{Name: "globalTurnMode", Type: "string", Enum: turnModes},
+				{Name: "gimbalPitchRotationEnabled", Type: "boolean"},
+				{Name: "headingMode", Type: "string", Enum: headingModes},
+				{Name: "flightPathMode", Type: "string", Enum: flightPathModes, Default: "NORMAL"},
+				{Name: "targets", Type: "array"},
+				{Name: "waypoints", Type: "array", Required: true, Description: "At least 2 waypoints"},
+			},
+		},
+		newConfig: func() interface{} { return &models.WaypointMissionConfig{} },
+		validate: func(config interface{}, errs FieldErrors) {
+			validateWaypointMission(config.(*models.WaypointMissionConfig), errs)
+		},
+		estimate: func(config interface{}) float64 {
+			return waypointMissionDuration(config.(*models.WaypointMissionConfig))
+		},
+		limits: func(config interface{}, profile *drones.Profile, errs FieldErrors) {
+			waypointMissionLimits(config.(*models.WaypointMissionConfig), profile, errs)
+		},
+	})
+
+	Register(builtin{
+		schema: Schema{
+			Type:        models.ElementRecordVideo,
+			Label:       "Record Video",
+			Description: "Starts recording until the end of the mission",
+			Fields: []SchemaField{
+				{Name: "cameraIndex", Type: "integer", Min: bound(0), Default: 0},
+			},
+		},
+		newConfig: func() interface{} { return &models.RecordVideoActionConfig{} },
+		validate: func(config interface{}, errs FieldErrors) {
+			if config.(*models.RecordVideoActionConfig).CameraIndex < 0 {
+				errs.Add("cameraIndex", "must not be negative")
+			}
+		},
+		estimate: func(config interface{}) float64 { return 0 },
+	})
+
+	Register(builtin{
+		schema: Schema{
+			Type:  models.ElementShootPhoto,
+			Label: "Shoot Photo",
+			Fields: []SchemaField{
+				{Name: "photoType", Type: "string", Enum: photoTypes, Required: true, Default: "single"},
+				{Name: "photoCount", Type: "integer", Min: bound(1), Max: bound(10), Description: "Required for interval photos"},
+				{Name: "timeInterval", Type: "integer", Unit: "s", Min: bound(1), Max: bound(60), Description: "Required for interval photos"},
+			},
+		},
+		newConfig: func() interface{} { return &models.TakePhotoActionConfig{} },
+		validate: func(config interface{}, errs FieldErrors) {
+			validateTakePhoto(config.(*models.TakePhotoActionConfig), errs)
+		},
+		estimate: func(config interface{}) float64 {
+			photo := config.(*models.TakePhotoActionConfig)
+			if photo.PhotoType == "interval" && photo.PhotoCount != nil && photo.TimeInterval != nil {
+				return float64(*photo.PhotoCount * *photo.TimeInterval)
+			}
+			return photoCaptureTime
+		},
+	})
+
+	Register(builtin{
+		schema: Schema{
+			Type:  models.ElementChangeHeading,
+			Label: "Change Heading",
+			Fields: []SchemaField{
+				{Name: "angle", Type: "number", Unit: "deg", Min: bound(-180), Max: bound(180), Required: true},
+				{Name: "angularVelocity", Type: "number", Unit: "deg/s", Min: bound(0), Default: defaultYawRate},
+			},
+		},
+		newConfig: func() interface{} { return &models.ChangeHeadingActionConfig{} },
+		validate: func(config interface{}, errs FieldErrors) {
+			heading := config.(*models.ChangeHeadingActionConfig)
+			errs.Between("angle", heading.Angle, -180, 180)
+			if heading.AngularVelocity < 0 {
+				errs.Add("angularVelocity", "must not be negative")
+			}
+		},
+		estimate: func(config interface{}) float64 {
+			heading := config.(*models.ChangeHeadingActionConfig)
+			return yawTime(heading.Angle, heading.AngularVelocity)
+		},
+	})
+
+	Register(builtin{
+		schema: Schema{
+			Type:        models.ElementHover,
+			Label:       "Hover",
+			Description: "Holds position for a fixed time",
+			Fields: []SchemaField{
+				{Name: "duration", Type: "number", Unit: "s", Min: bound(0), Max: bound(3600), Required: true},
+			},
+		},
+		newConfig: func() interface{} { return &models.HoverActionConfig{} },
+		validate: func(config interface{}, errs FieldErrors) {
+			errs.Between("duration", config.(*models.HoverActionConfig).Duration, 0, 3600)
+		},
+		estimate: func(config interface{}) float64 {
+			return config.(*models.HoverActionConfig).Duration
+		},
+	})
+
+	Register(builtin{
+		schema: Schema{
+			Type:  models.ElementRotateGimbal,
+			Label: "Rotate Gimbal",
+			Fields: []SchemaField{
+				{Name: "pitch", Type: "number", Unit: "deg", Min: bound(-90), Max: bound(30), Required: true},
+				{Name: "yaw", Type: "number", Unit: "deg", Min: bound(-180), Max: bound(180), Description: "Relative to the aircraft heading"},
+				{Name: "rotateTime", Type: "number", Unit: "s", Min: bound(0), Max: bound(100), Default: 0},
+			},
+		},
+		newConfig: func() interface{} { return &models.RotateGimbalActionConfig{} },
+		validate: func(config interface{}, errs FieldErrors) {
+			gimbal := config.(*models.RotateGimbalActionConfig)
+			errs.Between("pitch", gimbal.Pitch, -90, 30)
+			if gimbal.Yaw != nil {
+				errs.Between("yaw", *gimbal.Yaw, -180, 180)
+			}
+			errs.Between("rotateTime", gimbal.RotateTime, 0, 100)
+		},
+		estimate: func(config interface{}) float64 {
+			return math.Max(config.(*models.RotateGimbalActionConfig).RotateTime, gimbalRotationTime)
+		},
+	})
+
+	Register(builtin{
+		schema: Schema{
+			Type:  models.ElementCameraZoom,
+			Label: "Camera Zoom",
+			Fields: []SchemaField{
+				{Name: "focalLength", Type: "number", Unit: "mm", Min: bound(1), Max: bound(2000), Required: true, Description: "35mm equivalent"},
+			},
+		},
+		newConfig: func() interface{} { return &models.CameraZoomActionConfig{} },
+		validate: func(config interface{}, errs FieldErrors) {
+			errs.Between("focalLength", config.(*models.CameraZoomActionConfig).FocalLength, 1, 2000)
+		},
+		estimate: func(config interface{}) float64 { return zoomTime },
+	})
+
+	Register(builtin{
+		schema: Schema{
+			Type:        models.ElementPanorama,
+			Label:       "Panorama",
+			Description: "Yaws in place, taking a photo at each step",
+			Fields: []SchemaField{
+				{Name: "photoCount", Type: "integer", Min: bound(2), Max: bound(36), Required: true},
+				{Name: "sweepAngle", Type: "number", Unit: "deg", Min: bound(1), Max: bound(360), Default: 360},
+				{Name: "gimbalPitch", Type: "number", Unit: "deg", Min: bound(-90), Max: bound(30), Default: 0},
+			},
+		},
+		newConfig: func() interface{} { return &models.PanoramaActionConfig{} },
+		validate: func(config interface{}, errs FieldErrors) {
+			panorama := config.(*models.PanoramaActionConfig)
+			errs.Between("photoCount", float64(panorama.PhotoCount), 2, 36)
+			errs.Between("sweepAngle", panorama.SweepAngle, 1, 360)
+			errs.Between("gimbalPitch", panorama.GimbalPitch, -90, 30)
+		},
+		estimate: func(config interface{}) float64 {
+			panorama := config.(*models.PanoramaActionConfig)
+			_, sweep := PanoramaSteps(panorama)
+			return gimbalRotationTime + float64(panorama.PhotoCount)*photoCaptureTime + sweep/defaultYawRate
+		},
+	})
+
+	Register(builtin{
+		schema: Schema{
+			Type:        models.ElementHotpointOrbit,
+			Label:       "Hotpoint Orbit",
+			Description: "Circles a point of interest while facing it",
+			Fields: []SchemaField{
+				{Name: "center", Type: "object", Required: true, Description: "Latitude and longitude of the point of interest"},
+				{Name: "radius", Type: "number", Unit: "m", Min: bound(5), Max: bound(500), Required: true},
+				{Name: "altitude", Type: "number", Unit: "m", Min: bound(-200), Max: bound(500), Required: true},
+				{Name: "speed", Type: "number", Unit: "m/s", Min: bound(0), Max: bound(15), Default: defaultOrbitSpeed},
+				{Name: "laps", Type: "number", Min: bound(0), Max: bound(100), Default: 1},
+				{Name: "clockwise", Type: "boolean", Default: false},
+			},
+		},
+		newConfig: func() interface{} { return &models.HotpointOrbitConfig{} },
+		validate: func(config interface{}, errs FieldErrors) {
+			orbit := config.(*models.HotpointOrbitConfig)
+			errs.Between("center.latitude", orbit.Center.Latitude, -90, 90)
+			errs.Between("center.longitude", orbit.Center.Longitude, -180, 180)
+			errs.Between("radius", orbit.Radius, 5, 500)
+			errs.Between("altitude", orbit.Altitude, -200, 500)
+			errs.Between("speed", orbit.Speed, 0, 15)
+			if orbit.Laps <= 0 {
+				errs.Add("laps", "must be greater than 0")
+			} else {
+				errs.Between("laps", orbit.Laps, 0, 100)
+			}
+		},
+		estimate: func(config interface{}) float64 {
+			orbit := config.(*models.HotpointOrbitConfig)
+			return OrbitLength(orbit) / OrbitSpeed(orbit)
+		},
+		distance: func(config interface{}) float64 {
+			return OrbitLength(config.(*models.HotpointOrbitConfig))
+		},
+		limits: func(config interface{}, profile *drones.Profile, errs FieldErrors) {
+			orbit := config.(*models.HotpointOrbitConfig)
+			checkMax(errs, "speed", orbit.Speed, profile.MaxHorizontalSpeed, "m/s", profile)
+			checkMax(errs, "altitude", orbit.Altitude, profile.MaxAltitude, "m", profile)
+		},
+	})
+}
+
+// bound returns a pointer for a schema limit
+func bound(value float64) *float64 {
+	return &value
+}
+
+// PanoramaSteps returns the yaw between photos and the total yaw (deg). A
+// full circle spaces photos evenly; a partial sweep puts photos at both ends.
+func PanoramaSteps(panorama *models.PanoramaActionConfig) (step, sweep float64) {
+	sweep = panorama.SweepAngle
+	if sweep <= 0 || sweep > 360 {
+		sweep = 360
+	}
+	if panorama.PhotoCount < 2 {
+		return 0, 0
+	}
+	if sweep == 360 {
+		step = sweep / float64(panorama.PhotoCount)
+	} else {
+		step = sweep / float64(panorama.PhotoCount-1)
+	}
+	return step, step * float64(panorama.PhotoCount-1)
+}
+
+// OrbitLength returns the distance flown around a hotpoint (m)
+func OrbitLength(orbit *models.HotpointOrbitConfig) float64 {
+	return 2 * math.Pi * orbit.Radius * orbit.Laps
+}
+
+// OrbitSpeed returns the speed flown around a hotpoint (m/s)
+func OrbitSpeed(orbit *models.HotpointOrbitConfig) float64 {
+	if orbit.Speed > 0 {
+		return orbit.Speed
+	}
+	return defaultOrbitSpeed
+}
+
+// yawTime returns the seconds needed to turn through angle at rate
+func yawTime(angle, rate float64) float64 {
+	if rate <= 0 {
+		rate = defaultYawRate
+	}
+	return math.Abs(angle) / rate
+}
+
+// ActionDuration returns the seconds the aircraft spends on a waypoint action
+func ActionDuration(action models.WaypointAction) float64 {
+	switch models.NormalizeActionType(action.ActionType) {
+	case models.ActionHover:
+		return action.ActionParam
+	case models.ActionTakePhoto:
+		return photoCaptureTime
+	case models.ActionRotateGimbal:
+		return gimbalRotationTime
+	case models.ActionZoom:
+		return zoomTime
+	case models.ActionRotateAircraft:
+		return yawTime(action.ActionParam, defaultYawRate)
+	}
+	return 0
+}
+
+// waypointMissionDuration is the flight time of the route plus the time spent
+// on waypoint actions, for every repeat
+func waypointMissionDuration(config *models.WaypointMissionConfig) float64 {
+	actions := 0.0
+	for _, wp := range config.Waypoints {
+		for _, action := range wp.Actions {
+			actions += ActionDuration(action)
+		}
+	}
+
+	repeats := config.RepeatTimes
+	if repeats < 1 {
+		repeats = 1
+	}
+	return geometry.WaypointRoute(config).Duration + actions*float64(repeats)
+}
+
+func validateWaypointMission(config *models.WaypointMissionConfig, errs FieldErrors) {
+	if config.AutoFlightSpeed <= 0 {
+		errs.Add("autoFlightSpeed", "must be greater than 0")
+	}
+	if config.MaxFlightSpeed <= 0 {
+		errs.Add("maxFlightSpeed", "must be greater than 0")
+	} else if config.AutoFlightSpeed > config.MaxFlightSpeed {
+		errs.Add("autoFlightSpeed", "must not exceed maxFlightSpeed")
+	}
+	if config.RepeatTimes < 0 {
+		errs.Add("repeatTimes", "must not be negative")
+	}
+	errs.OneOf("finishedAction", config.FinishedAction, finishedActions...)
+	errs.OneOf("globalTurnMode", config.GlobalTurnMode, turnModes...)
+	errs.OneOf("headingMode", config.HeadingMode, headingModes...)
+	errs.OneOf("flightPathMode", config.FlightPathMode, flightPathModes...)
+	errs.OneOf("altitudeMode", config.AltitudeMode, models.AltitudeModes...)
+
+	for i, target := range config.Targets {
+		validateTarget(target, errs.At(fmt.Sprintf("targets[%d]", i)))
+	}
+
+	if len(config.Waypoints) < 2 {
+		errs.Add("waypoints", "at least 2 waypoints are required")
+	}
+	for i, wp := range config.Waypoints {
+		validateWaypoint(wp, config, errs.At(fmt.Sprintf("waypoints[%d]", i)))
+	}
+}
+
+func validateWaypoint(wp models.Waypoint, config *models.WaypointMissionConfig, errs FieldErrors) {
+	errs.Between("coordinate.latitude", wp.Coordinate.Latitude, -90, 90)
+	errs.Between("coordinate.longitude", wp.Coordinate.Longitude, -180, 180)
+	minAltitude, maxAltitude := altitudeRange(config.AltitudeMode)
+	errs.Between("altitude", wp.Altitude, minAltitude, maxAltitude)
+	errs.Between("heading", wp.Heading, -180, 180)
+	errs.Between("gimbalPitch", wp.GimbalPitch, -90, 30)
+	errs.Between("cornerRadius", wp.CornerRadius, -50, 100)
+	errs.OneOf("turnMode", wp.TurnMode, turnModes...)
+
+	if wp.Speed < 0 {
+		errs.Add("speed", "must not be negative")
+	} else if config.MaxFlightSpeed > 0 && wp.Speed > config.MaxFlightSpeed {
+		errs.Add("speed", "must not exceed maxFlightSpeed")
+	}
+
+	for i, target := range wp.Targets {
+		validateTarget(target, errs.At(fmt.Sprintf("targets[%d]", i)))
+	}
+	for i, action := range wp.Actions {
+		validateWaypointAction(action, errs.At(fmt.Sprintf("actions[%d]", i)))
+	}
+}
+
+// altitudeRange returns the accepted waypoint altitudes (m) for an altitude
+// mode. Heights above sea level or the ellipsoid include the ground elevation.
+func altitudeRange(mode string) (float64, float64) {
+	if isAbsoluteAltitude(mode) {
+		return -500, 9000
+	}
+	return -200, 500
+}
+
+// isAbsoluteAltitude reports whether an altitude mode is measured from a
+// global datum rather than the ground
+func isAbsoluteAltitude(mode string) bool {
+	return mode == models.AltitudeMSL || mode == models.AltitudeEllipsoid
+}
+
+func validateWaypointAction(action models.WaypointAction, errs FieldErrors) {
+	switch models.NormalizeActionType(action.ActionType) {
+	case models.ActionTakePhoto, models.ActionStartRecording, models.ActionStopRecording, models.ActionFocus:
+	case models.ActionCustom:
+		// The parameter means whatever the client's custom action defines
+	case models.ActionRotateGimbal:
+		errs.Between("actionParam", action.ActionParam, -90, 30)
+	case models.ActionRotateAircraft:
+		errs.Between("actionParam", action.ActionParam, -180, 180)
+	case models.ActionHover:
+		if action.ActionParam < 0 {
+			errs.Add("actionParam", "must not be negative")
+		}
+	case models.ActionZoom:
+		if action.ActionParam <= 0 {
+			errs.Add("actionParam", "must be greater than 0")
+		}
+	case models.ActionPhotoInterval:
+		if action.ActionParam < MinPhotoInterval {
+			errs.Add("actionParam", "must be at least %g m", MinPhotoInterval)
+		}
+	case "":
+		errs.Add("actionType", "is required")
+	default:
+		errs.Add("actionType", "unknown action type %q", action.ActionType)
+	}
+}
+
+func validateTarget(target models.Target, errs FieldErrors) {
+	errs.Between("lat", target.Lat, -90, 90)
+	errs.Between("lng", target.Lng, -180, 180)
+}
+
+func validateTakePhoto(photo *models.TakePhotoActionConfig, errs FieldErrors) {
+	errs.OneOf("photoType", photo.PhotoType, photoTypes...)
+	if photo.PhotoType == "interval" {
+		if photo.PhotoCount == nil {
+			errs.Add("photoCount", "is required for interval photos")
+		}
+		if photo.TimeInterval == nil {
+			errs.Add("timeInterval", "is required for interval photos")
+		}
+	}
+	if photo.PhotoCount != nil {
+		errs.Between("photoCount", float64(*photo.PhotoCount), 1, 10)
+	}
+	if photo.TimeInterval != nil {
+		errs.Between("timeInterval", float64(*photo.TimeInterval), 1, 60)
+	}
+}
@@ -0,0 +1,35 @@
+package timeline
+
+import (
+	"testing"
+
+	"drone-planner/server/models"
+)
+
+func TestValidateWaypointAction(t *testing.T) {
+	tests := []struct {
+		name   string
+		action models.WaypointAction
+		valid  bool
+	}{
+		{"photo", models.WaypointAction{ActionType: models.ActionTakePhoto}, true},
+		{"gimbal in range", models.WaypointAction{ActionType: models.ActionRotateGimbal, ActionParam: -90}, true},
+		{"gimbal too high", models.WaypointAction{ActionType: models.ActionRotateGimbal, ActionParam: 45}, false},
+		{"negative hover", models.WaypointAction{ActionType: models.ActionHover, ActionParam: -1}, false},
+		{"photo interval", models.WaypointAction{ActionType: models.ActionPhotoInterval, ActionParam: MinPhotoInterval}, true},
+		{"photo interval too short", models.WaypointAction{ActionType: models.ActionPhotoInterval, ActionParam: 0.001}, false},
+		{"zero photo interval", models.WaypointAction{ActionType: models.ActionPhotoInterval}, false},
+		{"custom", models.WaypointAction{ActionType: models.ActionCustom, ActionParam: -12345}, true},
+		{"missing type", models.WaypointAction{}, false},
+		{"unknown type", models.WaypointAction{ActionType: "selfDestruct"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := NewFieldErrors("actions[0]")
+			validateWaypointAction(tt.action, errs)
+			if err := errs.Err(); (err == nil) != tt.valid {
+				t.Errorf("validateWaypointAction(%+v) = %v, want valid %v", tt.action, err, tt.valid)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package timeline
+
+import (
+	"fmt"
+	"math"
+
+	"drone-planner/server/drones"
+	"drone-planner/server/geometry"
+	"drone-planner/server/models"
+)
+
+// LimitChecker is implemented by element types whose configs can exceed what
+// an aircraft is able to fly
+type LimitChecker interface {
+	// CheckLimits reports config fields outside the profile's envelope
+	CheckLimits(config interface{}, profile *drones.Profile, errs FieldErrors)
+}
+
+// speedTolerance absorbs rounding in speeds derived from distances (m/s)
+const speedTolerance = 0.05
+
+// lookupDrone resolves a drone type, reporting unknown types. An empty drone
+// type selects no profile.
+func lookupDrone(droneType string, errs FieldErrors) *drones.Profile {
+	if droneType == "" {
+		return nil
+	}
+	profile, ok := drones.Lookup(droneType)
+	if !ok {
+		errs.Add("droneType", "unknown drone type %q", droneType)
+		return nil
+	}
+	return profile
+}
+
+// checkMax reports a value above an aircraft limit
+func checkMax(errs FieldErrors, path string, value, limit float64, unit string, profile *drones.Profile) {
+	if value > limit {
+		errs.Add(path, "exceeds the %s limit of %g %s", profile.Name, limit, unit)
+	}
+}
+
+// checkWaypointCount reports missions with more waypoints than the firmware accepts
+func checkWaypointCount(errs FieldErrors, path string, count int, profile *drones.Profile) {
+	if profile.MaxWaypoints > 0 && count > profile.MaxWaypoints {
+		errs.Add(path, "has %d waypoints; the %s accepts at most %d", count, profile.Name, profile.MaxWaypoints)
+	}
+}
+
+// checkCornerRadius reports curved corners the aircraft cannot fly
+func checkCornerRadius(errs FieldErrors, radius float64, profile *drones.Profile) {
+	radius = math.Abs(radius)
+	if radius == 0 {
+		return
+	}
+	if radius < profile.MinCornerRadius || radius > profile.MaxCornerRadius {
+		errs.Add("cornerRadius", "must be between %g and %g m for the %s", profile.MinCornerRadius, profile.MaxCornerRadius, profile.Name)
+	}
+}
+
+// checkClimbRates reports legs that climb or descend faster than the aircraft
+// can. Each leg is reported on the waypoint it arrives at.
+func checkClimbRates(errs FieldErrors, route geometry.Route, profile *drones.Profile) {
+	for _, segment := range route.Segments {
+		if segment.Duration <= 0 {
+			continue
+		}
+		rate := segment.Climb / segment.Duration
+		path := fmt.Sprintf("waypoints[%d]", segment.To)
+		switch {
+		case rate > profile.MaxAscentSpeed+speedTolerance:
+			errs.Add(path, "climbs at %.1f m/s, above the %s ascent limit of %g m/s", rate, profile.Name, profile.MaxAscentSpeed)
+		case -rate > profile.MaxDescentSpeed+speedTolerance:
+			errs.Add(path, "descends at %.1f m/s, above the %s descent limit of %g m/s", -rate, profile.Name, profile.MaxDescentSpeed)
+		}
+	}
+}
+
+func waypointMissionLimits(config *models.WaypointMissionConfig, profile *drones.Profile, errs FieldErrors) {
+	checkMax(errs, "maxFlightSpeed", config.MaxFlightSpeed, profile.MaxHorizontalSpeed, "m/s", profile)
+	checkMax(errs, "autoFlightSpeed", config.AutoFlightSpeed, profile.MaxHorizontalSpeed, "m/s", profile)
+	for i, wp := range config.Waypoints {
+		wpErrs := errs.At(fmt.Sprintf("waypoints[%d]", i))
+		// The aircraft's ceiling is a height above takeoff, not above sea level
+		if !isAbsoluteAltitude(config.AltitudeMode) {
+			checkMax(wpErrs, "altitude", wp.Altitude, profile.MaxAltitude, "m", profile)
+		}
+		checkMax(wpErrs, "speed", wp.Speed, profile.MaxHorizontalSpeed, "m/s", profile)
+		if config.FlightPathMode == "CURVED" {
+			checkCornerRadius(wpErrs, wp.CornerRadius, profile)
+		}
+	}
+
+	points, legs := geometry.WaypointLegs(config)
+	checkClimbRates(errs, geometry.Measure(points, legs, config.AutoFlightSpeed), profile)
+}
+
+// ValidateFlight checks a flight's altitude mode and the limits of its drone type,
+// returning a *ValidationError that lists each invalid field
+func ValidateFlight(flight *models.Flight) error {
+	errs := NewFieldErrors("")
+	errs.OneOf("altitudeMode", flight.AltitudeMode, models.AltitudeModes...)
+	profile := lookupDrone(flight.DroneType, errs)
+	if profile == nil {
+		return errs.Err()
+	}
+
+	checkMax(errs, "maxFlightSpeed", flight.MaxFlightSpeed, profile.MaxHorizontalSpeed, "m/s", profile)
+	checkMax(errs, "autoFlightSpeed", flight.AutoFlightSpeed, profile.MaxHorizontalSpeed, "m/s", profile)
+	checkWaypointCount(errs, "waypoints", len(flight.Waypoints), profile)
+	for i, wp := range flight.Waypoints {
+		wpErrs := errs.At(fmt.Sprintf("waypoints[%d]", i))
+		if !isAbsoluteAltitude(flight.AltitudeMode) {
+			checkMax(wpErrs, "altitude", wp.Altitude, profile.MaxAltitude, "m", profile)
+		}
+		checkMax(wpErrs, "speed", wp.Speed, profile.MaxHorizontalSpeed, "m/s", profile)
+	}
+	for i, segment := range flight.SegmentSpeeds {
+		checkMax(errs.At(fmt.Sprintf("segmentSpeeds[%d]", i)), "speed", segment.Speed, profile.MaxHorizontalSpeed, "m/s", profile)
+	}
+
+	points, legs := geometry.FlightLegs(flight)
+	checkClimbRates(errs, geometry.Measure(points, legs, flight.AutoFlightSpeed), profile)
+	return errs.Err()
+}
+
+// ClampSpeeds lowers the speeds of a mission's waypoint missions that are
+// above its aircraft's maximum to it, returning a warning for each. It is for
+// clients that send their default speeds, 15 m/s maximum and 10 m/s per
+// waypoint, and ask for them to fit slower aircraft; other values are left
+// for Validate to reject. Configs that fail to decode are left as they are.
+func ClampSpeeds(mission *models.Mission) []FieldError {
+	warnings := NewFieldErrors("")
+	profile, ok := drones.Lookup(mission.GlobalSettings.DroneType)
+	if !ok {
+		return nil
+	}
+	limit, name := profile.MaxHorizontalSpeed, profile.Name+" maximum speed"
+	for i := range mission.TimelineElements {
+		element := &mission.TimelineElements[i]
+		if element.Type != models.ElementWaypointMission {
+			continue
+		}
+		var config models.WaypointMissionConfig
+		if err := element.UnmarshalConfig(&config); err != nil {
+			continue
+		}
+		elementWarnings := warnings.At(fmt.Sprintf("timelineElements[%d].config", i))
+		lowerSpeed(element.Config, "maxFlightSpeed", config.MaxFlightSpeed, limit, name, elementWarnings)
+		lowerSpeed(element.Config, "autoFlightSpeed", config.AutoFlightSpeed, limit, name, elementWarnings)
+		waypoints, _ := element.Config["waypoints"].([]interface{})
+		for j := 0; j < len(config.Waypoints) && j < len(waypoints); j++ {
+			if raw, ok := waypoints[j].(map[string]interface{}); ok {
+				lowerSpeed(raw, "speed", config.Waypoints[j].Speed, limit, name, elementWarnings.At(fmt.Sprintf("waypoints[%d]", j)))
+			}
+		}
+	}
+	return *warnings.errors
+}
+
+// lowerSpeed sets a speed field of a raw config to the limit when it is
+// above it, warning about the change
+func lowerSpeed(raw map[string]interface{}, key string, speed, limit float64, name string, warnings FieldErrors) {
+	if limit <= 0 || speed <= limit+speedTolerance {
+		return
+	}
+	raw[key] = limit
+	warnings.Add(key, "lowered from %g m/s to the %g m/s %s", speed, limit, name)
+}
+
+// ClampFlightSpeeds lowers flight speeds above the aircraft's maximum to it,
+// returning a warning for each. Like ClampSpeeds it is only for client
+// defaults.
+func ClampFlightSpeeds(flight *models.Flight) []FieldError {
+	warnings := NewFieldErrors("")
+	profile, ok := drones.Lookup(flight.DroneType)
+	if !ok {
+		return nil
+	}
+	limit := profile.MaxHorizontalSpeed
+	lower := func(path string, speed *float64) {
+		if *speed > limit+speedTolerance {
+			warnings.Add(path, "lowered from %g m/s to the %g m/s %s maximum speed", *speed, limit, profile.Name)
+			*speed = limit
+		}
+	}
+	lower("maxFlightSpeed", &flight.MaxFlightSpeed)
+	lower("autoFlightSpeed", &flight.AutoFlightSpeed)
+	for i := range flight.Waypoints {
+		lower(fmt.Sprintf("waypoints[%d].speed", i), &flight.Waypoints[i].Speed)
+	}
+	return *warnings.errors
+}
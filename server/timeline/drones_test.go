@@ -0,0 +1,113 @@
+package timeline
+
+import (
+	"errors"
+	"testing"
+
+	"drone-planner/server/models"
+)
+
+func TestClampSpeeds(t *testing.T) {
+	tests := []struct {
+		name      string
+		droneType string
+		maxSpeed  float64
+		speeds    []float64
+		want      []float64
+		warnings  int
+		// invalid is the path Validate rejects afterwards, if any
+		invalid string
+	}{
+		{"mission limit", "", 8, []float64{10, 5}, []float64{10, 5}, 0, "timelineElements[0].config.waypoints[0].speed"},
+		{"aircraft limit", "P4_MULTISPECTRAL", 15, []float64{15, 10}, []float64{14, 10}, 2, ""},
+		{"waypoint above the mission limit", "P4_MULTISPECTRAL", 8, []float64{10, 5}, []float64{10, 5}, 0, "timelineElements[0].config.waypoints[0].speed"},
+		{"client defaults", "DJI_MINI_SE", 15, []float64{10, 10}, []float64{10, 10}, 1, ""},
+		{"within limits", "M300_RTK", 15, []float64{10, 10}, []float64{10, 10}, 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			waypoints := []interface{}{}
+			for i, speed := range tt.speeds {
+				waypoints = append(waypoints, map[string]interface{}{
+					"coordinate": map[string]interface{}{"latitude": 47.0, "longitude": 8.0 + float64(i)*0.001},
+					"altitude":   50.0,
+					"speed":      speed,
+				})
+			}
+			mission := &models.Mission{
+				Name:           "clamp",
+				GlobalSettings: models.GlobalMissionSettings{DroneType: tt.droneType},
+				TimelineElements: []models.TimelineElement{{
+					Type: models.ElementWaypointMission,
+					Config: map[string]interface{}{
+						"autoFlightSpeed": 5.0,
+						"maxFlightSpeed":  tt.maxSpeed,
+						"waypoints":       waypoints,
+					},
+				}},
+			}
+
+			warnings := ClampSpeeds(mission)
+			for i, want := range tt.want {
+				got := waypoints[i].(map[string]interface{})["speed"].(float64)
+				if got != want {
+					t.Errorf("waypoint %d speed = %g, want %g", i, got, want)
+				}
+			}
+			if len(warnings) != tt.warnings {
+				t.Errorf("got %d warnings, want %d: %v", len(warnings), tt.warnings, warnings)
+			}
+			checkInvalid(t, Validate(mission), tt.invalid)
+		})
+	}
+}
+
+func TestValidateSpeedLimits(t *testing.T) {
+	// Without clamping, speeds above the aircraft's maximum are rejected
+	mission := &models.Mission{
+		Name:           "unclamped",
+		GlobalSettings: models.GlobalMissionSettings{DroneType: "P4_MULTISPECTRAL"},
+		TimelineElements: []models.TimelineElement{{
+			Type: models.ElementWaypointMission,
+			Config: map[string]interface{}{
+				"autoFlightSpeed": 5.0,
+				"maxFlightSpeed":  15.0,
+				"waypoints": []interface{}{
+					map[string]interface{}{"coordinate": map[string]interface{}{"latitude": 47.0, "longitude": 8.0}, "altitude": 50.0},
+					map[string]interface{}{"coordinate": map[string]interface{}{"latitude": 47.0, "longitude": 8.001}, "altitude": 50.0},
+				},
+			},
+		}},
+	}
+	checkInvalid(t, Validate(mission), "timelineElements[0].config.maxFlightSpeed")
+
+	flight := &models.Flight{
+		Name:      "unclamped",
+		DroneType: "P4_MULTISPECTRAL",
+		Waypoints: []models.Waypoint{
+			{Coordinate: models.Coordinate{Latitude: 47, Longitude: 8}, Altitude: 50, Speed: 15},
+			{Coordinate: models.Coordinate{Latitude: 47, Longitude: 8.001}, Altitude: 50, Speed: 10},
+		},
+	}
+	checkInvalid(t, ValidateFlight(flight), "waypoints[0].speed")
+	if warnings := ClampFlightSpeeds(flight); len(warnings) != 1 || flight.Waypoints[0].Speed != 14 {
+		t.Errorf("clamped speed %g with warnings %v, want 14 and one warning", flight.Waypoints[0].Speed, warnings)
+	}
+	checkInvalid(t, ValidateFlight(flight), "")
+}
+
+// checkInvalid checks that a validation error has its first error at path,
+// or that there is none when path is empty
+func checkInvalid(t *testing.T, err error, path string) {
+	t.Helper()
+	if path == "" {
+		if err != nil {
+			t.Errorf("got error %v, want none", err)
+		}
+		return
+	}
+	var validation *ValidationError
+	if !errors.As(err, &validation) || len(validation.Errors) == 0 || validation.Errors[0].Path != path {
+		t.Errorf("got error %v, want a validation error at %q", err, path)
+	}
+}
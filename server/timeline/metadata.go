@@ -0,0 +1,40 @@
+package timeline
+
+import (
+	"fmt"
+
+	"drone-planner/server/geometry"
+	"drone-planner/server/models"
+)
+
+// Metadata computes a mission's metadata from its timeline. Waypoint missions
+// contribute their measured route; every element adds its estimated duration.
+func Metadata(mission *models.Mission) (models.MissionMetadata, error) {
+	metadata := models.MissionMetadata{
+		TotalTimelineElements: len(mission.TimelineElements),
+	}
+	for i := range mission.TimelineElements {
+		element := &mission.TimelineElements[i]
+		elementType, ok := Lookup(element.Type)
+		if !ok {
+			continue
+		}
+		config, err := Decode(element)
+		if err != nil {
+			return models.MissionMetadata{}, fmt.Errorf("timeline element %d: %v", i+1, err)
+		}
+
+		if waypointMission, ok := config.(*models.WaypointMissionConfig); ok {
+			route := geometry.WaypointRoute(waypointMission)
+			metadata.HasWaypointMission = true
+			metadata.TotalWaypoints += len(waypointMission.Waypoints)
+			metadata.TotalDistance += route.Distance / 1000
+			metadata.TotalClimb += route.Climb
+			metadata.TotalDescent += route.Descent
+		} else if estimator, ok := elementType.(DistanceEstimator); ok {
+			metadata.TotalDistance += estimator.EstimateDistance(config) / 1000
+		}
+		metadata.EstimatedDuration += elementType.EstimateDuration(config)
+	}
+	return metadata, nil
+}
@@ -0,0 +1,187 @@
+package timeline
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"drone-planner/server/drones"
+	"drone-planner/server/models"
+)
+
+// SchemaField describes one config field of an element type, for clients
+// that build editors from the registry
+type SchemaField struct {
+	Name        string      `json:"name"`
+	Type        string      `json:"type"` // "number", "integer", "string", "boolean", "object" or "array"
+	Unit        string      `json:"unit,omitempty"`
+	Min         *float64    `json:"min,omitempty"`
+	Max         *float64    `json:"max,omitempty"`
+	Enum        []string    `json:"enum,omitempty"`
+	Required    bool        `json:"required,omitempty"`
+	Default     interface{} `json:"default,omitempty"`
+	Description string      `json:"description,omitempty"`
+}
+
+// Schema describes the config of an element type
+type Schema struct {
+	Type        string        `json:"type"`
+	Label       string        `json:"label"`
+	Description string        `json:"description,omitempty"`
+	Fields      []SchemaField `json:"fields"`
+}
+
+// ElementType is implemented by every kind of timeline element. Exporters
+// register their own hooks keyed by the same name (see mavlink.RegisterElement).
+type ElementType interface {
+	// Name is the TimelineElement.Type the element type handles
+	Name() string
+	// Schema describes the element's config
+	Schema() Schema
+	// NewConfig returns a pointer to an empty typed config
+	NewConfig() interface{}
+	// Validate checks a config returned by NewConfig, reporting fields
+	// relative to the config
+	Validate(config interface{}, errs FieldErrors)
+	// EstimateDuration returns the seconds the element takes to fly
+	EstimateDuration(config interface{}) float64
+}
+
+// DistanceEstimator is implemented by element types that fly a distance of
+// their own, such as orbits
+type DistanceEstimator interface {
+	// EstimateDistance returns the meters the element flies
+	EstimateDistance(config interface{}) float64
+}
+
+var (
+	registryMutex sync.RWMutex
+	registry      = map[string]ElementType{}
+)
+
+// Register adds an element type, replacing any type with the same name
+func Register(elementType ElementType) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	registry[elementType.Name()] = elementType
+}
+
+// Lookup returns the element type registered under name
+func Lookup(name string) (ElementType, bool) {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	elementType, ok := registry[name]
+	return elementType, ok
+}
+
+// Types returns every registered element type, sorted by name
+func Types() []ElementType {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	types := make([]ElementType, 0, len(registry))
+	for _, elementType := range registry {
+		types = append(types, elementType)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].Name() < types[j].Name() })
+	return types
+}
+
+// Decode decodes an element's config into its registered typed config
+// without validating it
+func Decode(element *models.TimelineElement) (interface{}, error) {
+	elementType, ok := Lookup(element.Type)
+	if !ok {
+		return nil, fmt.Errorf("unknown timeline element type %q", element.Type)
+	}
+	config := elementType.NewConfig()
+	if err := element.DecodeConfig(config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// DecodeValid decodes an element's config and validates it. Failures are
+// returned as a *ValidationError with paths relative to the element.
+func DecodeValid(element *models.TimelineElement) (interface{}, error) {
+	errs := NewFieldErrors("")
+	config := decode(element, errs)
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func decode(element *models.TimelineElement, errs FieldErrors) interface{} {
+	elementType, ok := Lookup(element.Type)
+	if !ok {
+		errs.Add("type", "unknown timeline element type %q", element.Type)
+		return nil
+	}
+
+	config := elementType.NewConfig()
+	if err := element.UnmarshalConfig(config); err != nil {
+		errs.At("config").decodeError(err)
+		return nil
+	}
+	elementType.Validate(config, errs.At("config"))
+	return config
+}
+
+// Validate checks every timeline element and the global settings of a
+// mission, returning a *ValidationError that lists each invalid field. When
+// the mission names a drone type, elements are also checked against the
+// aircraft's limits.
+func Validate(mission *models.Mission) error {
+	errs := NewFieldErrors("")
+	profile := lookupDrone(mission.GlobalSettings.DroneType, errs.At("globalSettings"))
+
+	hasWaypointMission := false
+	waypoints := 0
+	for i := range mission.TimelineElements {
+		element := &mission.TimelineElements[i]
+		elementErrs := errs.At(fmt.Sprintf("timelineElements[%d]", i))
+		config := decode(element, elementErrs)
+		if element.Type == models.ElementWaypointMission {
+			hasWaypointMission = true
+		}
+		if waypointMission, ok := config.(*models.WaypointMissionConfig); ok {
+			waypoints += len(waypointMission.Waypoints)
+		}
+		if config != nil && profile != nil {
+			checkLimits(element.Type, config, profile, elementErrs.At("config"))
+		}
+	}
+	if len(mission.TimelineElements) == 0 {
+		errs.Add("timelineElements", "at least one timeline element is required")
+	} else if !hasWaypointMission {
+		errs.Add("timelineElements", "at least one waypoint mission is required")
+	}
+	if profile != nil {
+		checkWaypointCount(errs, "timelineElements", waypoints, profile)
+	}
+
+	validateGlobalSettings(mission.GlobalSettings, errs.At("globalSettings"))
+	return errs.Err()
+}
+
+// checkLimits checks a decoded config against an aircraft when its element
+// type supports it
+func checkLimits(name string, config interface{}, profile *drones.Profile, errs FieldErrors) {
+	elementType, ok := Lookup(name)
+	if !ok {
+		return
+	}
+	if checker, ok := elementType.(LimitChecker); ok {
+		checker.CheckLimits(config, profile, errs)
+	}
+}
+
+func validateGlobalSettings(settings models.GlobalMissionSettings, errs FieldErrors) {
+	errs.Between("batteryThreshold", float64(settings.BatteryThreshold), 0, 100)
+	if settings.HomeLat != nil {
+		errs.Between("homeLat", *settings.HomeLat, -90, 90)
+	}
+	if settings.HomeLng != nil {
+		errs.Between("homeLng", *settings.HomeLng, -180, 180)
+	}
+}
@@ -0,0 +1,122 @@
+package timeline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// FieldError is a validation failure for the value at Path, written as a
+// JSON path such as "timelineElements[0].config.waypoints[2].heading"
+type FieldError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every field that failed validation
+type ValidationError struct {
+	Errors []FieldError `json:"errors"`
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Errors) == 0 {
+		return "validation failed"
+	}
+	first := e.Errors[0]
+	if len(e.Errors) == 1 {
+		return fmt.Sprintf("%s: %s", first.Path, first.Message)
+	}
+	return fmt.Sprintf("%s: %s (and %d more)", first.Path, first.Message, len(e.Errors)-1)
+}
+
+// FieldErrors collects field errors under a path prefix. Element types use it
+// to report invalid config fields.
+type FieldErrors struct {
+	errors *[]FieldError
+	prefix string
+}
+
+// NewFieldErrors returns an empty collector rooted at prefix
+func NewFieldErrors(prefix string) FieldErrors {
+	return FieldErrors{errors: &[]FieldError{}, prefix: prefix}
+}
+
+// At returns a collector for a nested path. Index segments such as "[2]"
+// are appended without a separating dot.
+func (f FieldErrors) At(path string) FieldErrors {
+	return FieldErrors{errors: f.errors, prefix: joinPath(f.prefix, path)}
+}
+
+// Add records an error for a field below the collector's path
+func (f FieldErrors) Add(path, format string, args ...interface{}) {
+	*f.errors = append(*f.errors, FieldError{
+		Path:    joinPath(f.prefix, path),
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// Between checks that a value lies within an inclusive range
+func (f FieldErrors) Between(path string, value, min, max float64) {
+	if value < min || value > max {
+		f.Add(path, "must be between %g and %g", min, max)
+	}
+}
+
+// OneOf checks that a non-empty value is one of the allowed values
+func (f FieldErrors) OneOf(path, value string, allowed ...string) {
+	if value == "" {
+		return
+	}
+	for _, candidate := range allowed {
+		if value == candidate {
+			return
+		}
+	}
+	f.Add(path, "must be one of %s", strings.Join(allowed, ", "))
+}
+
+// Err returns the collected errors as a *ValidationError, or nil
+func (f FieldErrors) Err() error {
+	if len(*f.errors) == 0 {
+		return nil
+	}
+	return &ValidationError{Errors: *f.errors}
+}
+
+// decodeError converts a JSON decoding error into a field error
+func (f FieldErrors) decodeError(err error) {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		f.Add(typeErr.Field, "must be a %s", jsonKind(typeErr.Type.Kind().String()))
+		return
+	}
+	f.Add("", "%v", err)
+}
+
+// jsonKind names a Go kind the way it appears in JSON
+func jsonKind(kind string) string {
+	switch {
+	case strings.HasPrefix(kind, "int"), strings.HasPrefix(kind, "uint"), strings.HasPrefix(kind, "float"):
+		return "number"
+	case kind == "slice", kind == "array":
+		return "array"
+	case kind == "struct", kind == "map":
+		return "object"
+	case kind == "bool":
+		return "boolean"
+	}
+	return kind
+}
+
+func joinPath(prefix, path string) string {
+	switch {
+	case path == "":
+		return prefix
+	case prefix == "":
+		return path
+	case strings.HasPrefix(path, "["):
+		return prefix + path
+	}
+	return prefix + "." + path
+}
@@ -0,0 +1,213 @@
+package weather
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"drone-planner/server/drones"
+)
+
+// Verdicts, from best to worst
+const (
+	VerdictGo      = "go"
+	VerdictCaution = "caution"
+	VerdictNoGo    = "no-go"
+)
+
+// Weather factors
+const (
+	FactorWind          = "wind"
+	FactorGusts         = "gusts"
+	FactorPrecipitation = "precipitation"
+	FactorVisibility    = "visibility"
+	FactorTemperature   = "temperature"
+	FactorCeiling       = "ceiling"
+)
+
+const (
+	// windNoGo and windCaution are the fractions of an aircraft's wind
+	// resistance at which sustained wind stops or cautions a flight, leaving
+	// speed to make headway home
+	windNoGo    = 0.8
+	windCaution = 0.6
+	// gustCaution is the fraction of the wind resistance at which gusts
+	// caution a flight; gusts beyond the resistance stop it
+	gustCaution = 0.8
+	// defaultMaxWind is the wind resistance assumed for unknown aircraft (m/s)
+	defaultMaxWind = 8
+)
+
+// Thresholds are the weather limits a flight is assessed against. Values
+// beyond the caution limits caution the flight, values beyond the no-go
+// limits stop it.
+type Thresholds struct {
+	Drone string `json:"drone,omitempty"`
+
+	CautionWind float64 `json:"cautionWind"` // m/s
+	MaxWind     float64 `json:"maxWind"`
+	CautionGust float64 `json:"cautionGust"` // m/s
+	MaxGust     float64 `json:"maxGust"`
+	// CautionPrecipitation and MaxPrecipitation are rain rates (mm/h)
+	CautionPrecipitation float64 `json:"cautionPrecipitation"`
+	MaxPrecipitation     float64 `json:"maxPrecipitation"`
+	// CautionVisibility and MinVisibility keep the aircraft in sight (m)
+	CautionVisibility float64 `json:"cautionVisibility"`
+	MinVisibility     float64 `json:"minVisibility"`
+	MinTemperature    float64 `json:"minTemperature"` // °C
+	MaxTemperature    float64 `json:"maxTemperature"`
+	// TemperatureMargin is how close to a temperature limit cautions (°C)
+	TemperatureMargin float64 `json:"temperatureMargin"`
+	// CeilingMargin is the clearance below the cloud base the highest point
+	// of the flight must keep to go without caution (m)
+	CeilingMargin float64 `json:"ceilingMargin"`
+}
+
+// DroneThresholds returns the thresholds of an aircraft from its published
+// limits. Unknown aircraft (nil) get conservative limits.
+func DroneThresholds(profile *drones.Profile) Thresholds {
+	limits := drones.WeatherLimits{MaxWind: defaultMaxWind, MinTemperature: 0, MaxTemperature: 40}
+	thresholds := Thresholds{
+		CautionVisibility: 3000,
+		MinVisibility:     1000,
+		TemperatureMargin: 5,
+		CeilingMargin:     30,
+	}
+	if profile != nil && profile.Weather.MaxWind > 0 {
+		limits = profile.Weather
+		thresholds.Drone = profile.ID
+	}
+
+	thresholds.CautionWind = limits.MaxWind * windCaution
+	thresholds.MaxWind = limits.MaxWind * windNoGo
+	thresholds.CautionGust = limits.MaxWind * gustCaution
+	thresholds.MaxGust = limits.MaxWind
+	thresholds.MinTemperature = limits.MinTemperature
+	thresholds.MaxTemperature = limits.MaxTemperature
+	if limits.MaxPrecipitation > 0 {
+		// Sealed aircraft fly through light rain with caution
+		thresholds.CautionPrecipitation = 0.1
+		thresholds.MaxPrecipitation = limits.MaxPrecipitation
+	} else {
+		// Any measurable rain stops unsealed aircraft; drizzle cautions
+		thresholds.MaxPrecipitation = 0.1
+	}
+	return thresholds
+}
+
+// Reason is a forecast value that cautions or stops a flight
+type Reason struct {
+	Time    time.Time `json:"time"`
+	Factor  string    `json:"factor"`
+	Verdict string    `json:"verdict"`
+	Value   float64   `json:"value"`
+	Limit   float64   `json:"limit"`
+	Message string    `json:"message"`
+}
+
+// Assessment is the go/no-go verdict of a flight
+type Assessment struct {
+	Verdict string   `json:"verdict"`
+	Reasons []Reason `json:"reasons"`
+	// Unknown lists the factors the forecast does not give
+	Unknown []string `json:"unknown"`
+}
+
+// Assess rates the weather of the forecast hours a flight spans. maxAltitude
+// is the highest point of the flight (m above takeoff); the wind is taken at
+// its worst between the ground and there.
+func Assess(hours []Hour, thresholds Thresholds, maxAltitude float64) Assessment {
+	assessment := Assessment{Verdict: VerdictGo, Reasons: []Reason{}, Unknown: []string{}}
+	unknown := map[string]bool{}
+	add := func(hour Hour, factor, verdict string, value, limit float64, format string, args ...interface{}) {
+		assessment.Reasons = append(assessment.Reasons, Reason{
+			Time:    hour.Time,
+			Factor:  factor,
+			Verdict: verdict,
+			Value:   value,
+			Limit:   limit,
+			Message: fmt.Sprintf(format, args...),
+		})
+		if verdict == VerdictNoGo || assessment.Verdict == VerdictGo {
+			assessment.Verdict = verdict
+		}
+	}
+	// above cautions or stops a value over its limits
+	above := func(hour Hour, factor string, value *float64, caution, noGo float64, unit string) {
+		if value == nil {
+			unknown[factor] = true
+			return
+		}
+		switch {
+		case *value > noGo:
+			add(hour, factor, VerdictNoGo, *value, noGo, "%s: %.1f %s exceeds the %.1f %s limit", factor, *value, unit, noGo, unit)
+		case *value > caution:
+			add(hour, factor, VerdictCaution, *value, caution, "%s: %.1f %s is above the %.1f %s caution level", factor, *value, unit, caution, unit)
+		}
+	}
+
+	for _, hour := range hours {
+		if len(hour.Wind) == 0 {
+			unknown[FactorWind] = true
+		} else {
+			wind := hour.Wind.strongest(maxAltitude)
+			above(hour, FactorWind, &wind.Speed, thresholds.CautionWind, thresholds.MaxWind, "m/s")
+		}
+		above(hour, FactorGusts, hour.Gusts, thresholds.CautionGust, thresholds.MaxGust, "m/s")
+		above(hour, FactorPrecipitation, hour.Precipitation, thresholds.CautionPrecipitation, thresholds.MaxPrecipitation, "mm/h")
+
+		if hour.Visibility == nil {
+			unknown[FactorVisibility] = true
+		} else if v := *hour.Visibility; v < thresholds.MinVisibility {
+			add(hour, FactorVisibility, VerdictNoGo, v, thresholds.MinVisibility, "visibility of %.0f m is below the %.0f m minimum", v, thresholds.MinVisibility)
+		} else if v < thresholds.CautionVisibility {
+			add(hour, FactorVisibility, VerdictCaution, v, thresholds.CautionVisibility, "visibility of %.0f m is below %.0f m", v, thresholds.CautionVisibility)
+		}
+
+		if hour.Temperature == nil {
+			unknown[FactorTemperature] = true
+		} else if t := *hour.Temperature; t < thresholds.MinTemperature {
+			add(hour, FactorTemperature, VerdictNoGo, t, thresholds.MinTemperature, "%.0f °C is below the %.0f °C operating minimum", t, thresholds.MinTemperature)
+		} else if t > thresholds.MaxTemperature {
+			add(hour, FactorTemperature, VerdictNoGo, t, thresholds.MaxTemperature, "%.0f °C is above the %.0f °C operating maximum", t, thresholds.MaxTemperature)
+		} else if t < thresholds.MinTemperature+thresholds.TemperatureMargin {
+			add(hour, FactorTemperature, VerdictCaution, t, thresholds.MinTemperature+thresholds.TemperatureMargin, "%.0f °C is near the %.0f °C operating minimum; warm the batteries", t, thresholds.MinTemperature)
+		} else if t > thresholds.MaxTemperature-thresholds.TemperatureMargin {
+			add(hour, FactorTemperature, VerdictCaution, t, thresholds.MaxTemperature-thresholds.TemperatureMargin, "%.0f °C is near the %.0f °C operating maximum", t, thresholds.MaxTemperature)
+		}
+
+		// A missing ceiling means clear skies, so it is never unknown
+		if hour.Ceiling != nil {
+			if c := *hour.Ceiling; c < maxAltitude {
+				add(hour, FactorCeiling, VerdictNoGo, c, maxAltitude, "the %.0f m cloud base is below the %.0f m flight altitude", c, maxAltitude)
+			} else if c < maxAltitude+thresholds.CeilingMargin {
+				add(hour, FactorCeiling, VerdictCaution, c, maxAltitude+thresholds.CeilingMargin, "the %.0f m cloud base is within %.0f m of the %.0f m flight altitude", c, thresholds.CeilingMargin, maxAltitude)
+			}
+		}
+	}
+
+	for factor := range unknown {
+		assessment.Unknown = append(assessment.Unknown, factor)
+	}
+	sort.Strings(assessment.Unknown)
+	return assessment
+}
+
+// strongest returns the strongest wind between the ground and an altitude
+// (m relative to takeoff)
+func (p WindProfile) strongest(altitude float64) Wind {
+	altitudes := []float64{0, math.Max(altitude, 0)}
+	for _, layer := range p {
+		if layer.Altitude > 0 && layer.Altitude < altitude {
+			altitudes = append(altitudes, layer.Altitude)
+		}
+	}
+	var strongest Wind
+	for _, a := range altitudes {
+		if wind := p.At(a); wind.Speed > strongest.Speed {
+			strongest = wind
+		}
+	}
+	return strongest
+}
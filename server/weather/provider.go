@@ -0,0 +1,184 @@
+package weather
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"drone-planner/server/geometry"
+)
+
+// ErrNoForecast is returned when a provider has no forecast for a time or
+// place
+var ErrNoForecast = errors.New("no forecast")
+
+// maxHourGap is how far from a requested time the nearest forecast hour may be
+const maxHourGap = time.Hour
+
+// Provider returns hourly forecasts
+type Provider interface {
+	Forecast(lat, lng float64, from, to time.Time) (*Forecast, error)
+}
+
+// Forecast is the hourly weather at a place
+type Forecast struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Hours     []Hour  `json:"hours"`
+}
+
+// Hour is the forecast weather for an hour. Values a forecast does not
+// give are nil.
+type Hour struct {
+	Time time.Time   `json:"time"`
+	Wind WindProfile `json:"wind"`
+	// Gusts is the peak gust speed near the ground (m/s)
+	Gusts         *float64 `json:"gusts,omitempty"`
+	Precipitation *float64 `json:"precipitation,omitempty"` // mm/h
+	Visibility    *float64 `json:"visibility,omitempty"`    // m
+	Temperature   *float64 `json:"temperature,omitempty"`   // °C
+	// Ceiling is the height of the cloud base above the ground (m), nil
+	// when there is no ceiling or it is not forecast
+	Ceiling *float64 `json:"ceiling,omitempty"`
+}
+
+// At returns the forecast hour nearest a time
+func (f *Forecast) At(t time.Time) (*Hour, error) {
+	var nearest *Hour
+	for i := range f.Hours {
+		hour := &f.Hours[i]
+		if nearest == nil || absDuration(hour.Time.Sub(t)) < absDuration(nearest.Time.Sub(t)) {
+			nearest = hour
+		}
+	}
+	if nearest == nil || absDuration(nearest.Time.Sub(t)) > maxHourGap {
+		return nil, fmt.Errorf("%w for %s", ErrNoForecast, t.UTC().Format(time.RFC3339))
+	}
+	return nearest, nil
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// FileProvider serves forecasts from a local JSON file holding one forecast
+// or a list of them. The forecast nearest the requested place is used.
+type FileProvider struct {
+	forecasts []Forecast
+}
+
+// NewFileProvider reads the forecasts in a JSON file
+func NewFileProvider(path string) (*FileProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read weather file: %v", err)
+	}
+	var forecasts []Forecast
+	if err := json.Unmarshal(data, &forecasts); err != nil {
+		var forecast Forecast
+		if err := json.Unmarshal(data, &forecast); err != nil {
+			return nil, fmt.Errorf("failed to parse weather file: %v", err)
+		}
+		forecasts = []Forecast{forecast}
+	}
+	return &FileProvider{forecasts: forecasts}, nil
+}
+
+// Forecast returns the hours between from and to of the nearest forecast
+func (p *FileProvider) Forecast(lat, lng float64, from, to time.Time) (*Forecast, error) {
+	var nearest *Forecast
+	best := math.Inf(1)
+	for i := range p.forecasts {
+		forecast := &p.forecasts[i]
+		if distance := geometry.Distance(lat, lng, forecast.Latitude, forecast.Longitude); distance < best {
+			nearest, best = forecast, distance
+		}
+	}
+	if nearest == nil {
+		return nil, ErrNoForecast
+	}
+
+	result := &Forecast{Latitude: nearest.Latitude, Longitude: nearest.Longitude, Hours: []Hour{}}
+	for _, hour := range nearest.Hours {
+		if !hour.Time.Before(from.Add(-maxHourGap)) && !hour.Time.After(to.Add(maxHourGap)) {
+			result.Hours = append(result.Hours, hour)
+		}
+	}
+	return result, nil
+}
+
+// ServeHTTP answers forecast requests like a forecast server, so that a
+// recorded forecast file can stand in for one
+func (p *FileProvider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	lat, latErr := strconv.ParseFloat(query.Get("lat"), 64)
+	lng, lngErr := strconv.ParseFloat(query.Get("lng"), 64)
+	from, fromErr := time.Parse(time.RFC3339, query.Get("start"))
+	to, toErr := time.Parse(time.RFC3339, query.Get("end"))
+	if latErr != nil || lngErr != nil || fromErr != nil || toErr != nil {
+		http.Error(w, "lat, lng, start and end are required", http.StatusBadRequest)
+		return
+	}
+
+	forecast, err := p.Forecast(lat, lng, from, to)
+	if errors.Is(err, ErrNoForecast) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(forecast)
+}
+
+// HTTPProvider fetches forecasts from a server answering
+// GET <url>?lat=&lng=&start=&end= with a Forecast document, so that a
+// forecast API adapter or a stub server can be plugged in
+type HTTPProvider struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPProvider creates a provider for a forecast server
+func NewHTTPProvider(serverURL string) *HTTPProvider {
+	return &HTTPProvider{url: serverURL, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Forecast requests the forecast between from and to
+func (p *HTTPProvider) Forecast(lat, lng float64, from, to time.Time) (*Forecast, error) {
+	query := url.Values{}
+	query.Set("lat", fmt.Sprintf("%.6f", lat))
+	query.Set("lng", fmt.Sprintf("%.6f", lng))
+	query.Set("start", from.UTC().Format(time.RFC3339))
+	query.Set("end", to.UTC().Format(time.RFC3339))
+
+	resp, err := p.client.Get(p.url + "?" + query.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to call weather server: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNoForecast
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("weather server returned status %d", resp.StatusCode)
+	}
+
+	var forecast Forecast
+	if err := json.NewDecoder(resp.Body).Decode(&forecast); err != nil {
+		return nil, fmt.Errorf("failed to decode forecast: %v", err)
+	}
+	return &forecast, nil
+}
@@ -0,0 +1,98 @@
+package weather
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAssessForecast(t *testing.T) {
+	file, err := NewFileProvider("testdata/forecast.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(file)
+	defer server.Close()
+	provider := NewHTTPProvider(server.URL)
+
+	// reason is the part of a Reason the tests check
+	type reason struct {
+		hour    int
+		factor  string
+		verdict string
+	}
+	tests := []struct {
+		name        string
+		start, end  int
+		maxAltitude float64
+		hours       int
+		verdict     string
+		// reasons must all be given; with exact, no others may be
+		reasons []reason
+		exact   bool
+	}{
+		{"calm morning", 5, 6, 60, 3, VerdictGo, nil, true},
+		{"breeze aloft", 6, 6, 120, 3, VerdictCaution, []reason{
+			{6, FactorWind, VerdictCaution},
+			{7, FactorWind, VerdictCaution},
+		}, true},
+		{"near the cloud base", 9, 9, 430, 3, VerdictNoGo, []reason{
+			{10, FactorCeiling, VerdictCaution},
+			{10, FactorPrecipitation, VerdictNoGo},
+			{9, FactorGusts, VerdictNoGo},
+		}, false},
+		{"storm", 11, 11, 200, 3, VerdictNoGo, []reason{
+			{11, FactorWind, VerdictNoGo},
+			{11, FactorGusts, VerdictNoGo},
+			{11, FactorPrecipitation, VerdictNoGo},
+			{11, FactorVisibility, VerdictCaution},
+			{11, FactorCeiling, VerdictNoGo},
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Date(2026, 6, 12, tt.start, 0, 0, 0, time.UTC)
+			end := time.Date(2026, 6, 12, tt.end, 0, 0, 0, time.UTC)
+			forecast, err := provider.Forecast(47.38, 8.54, start, end)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(forecast.Hours) != tt.hours {
+				t.Fatalf("got %d forecast hours, want %d", len(forecast.Hours), tt.hours)
+			}
+
+			assessment := Assess(forecast.Hours, DroneThresholds(nil), tt.maxAltitude)
+			if assessment.Verdict != tt.verdict {
+				t.Errorf("verdict = %s, want %s: %+v", assessment.Verdict, tt.verdict, assessment.Reasons)
+			}
+			if len(assessment.Unknown) > 0 {
+				t.Errorf("unknown factors %v", assessment.Unknown)
+			}
+			got := map[reason]bool{}
+			for _, r := range assessment.Reasons {
+				if r.Message == "" {
+					t.Errorf("reason %+v has no message", r)
+				}
+				got[reason{r.Time.Hour(), r.Factor, r.Verdict}] = true
+			}
+			for _, want := range tt.reasons {
+				if !got[want] {
+					t.Errorf("missing %s %s at %02d:00 in %+v", want.verdict, want.factor, want.hour, assessment.Reasons)
+				}
+			}
+			if tt.exact && len(assessment.Reasons) != len(tt.reasons) {
+				t.Errorf("got %d reasons, want %d: %+v", len(assessment.Reasons), len(tt.reasons), assessment.Reasons)
+			}
+		})
+	}
+}
+
+func TestHTTPProviderNoForecast(t *testing.T) {
+	server := httptest.NewServer(&FileProvider{})
+	defer server.Close()
+
+	now := time.Date(2026, 6, 12, 8, 0, 0, 0, time.UTC)
+	if _, err := NewHTTPProvider(server.URL).Forecast(47.38, 8.54, now, now); err != ErrNoForecast {
+		t.Errorf("got error %v, want ErrNoForecast", err)
+	}
+}
@@ -0,0 +1,72 @@
+// Package weather reads forecasts from a weather provider and models the
+// wind a mission is flown in.
+package weather
+
+import (
+	"math"
+	"sort"
+)
+
+// Wind is a horizontal wind. Direction is where it blows from, in degrees
+// clockwise from north, as forecasts report it.
+type Wind struct {
+	Speed     float64 `json:"speed"`     // m/s
+	Direction float64 `json:"direction"` // degrees
+}
+
+// Vector returns the velocity of the air (m/s east and north)
+func (w Wind) Vector() (east, north float64) {
+	rad := w.Direction * math.Pi / 180
+	return -w.Speed * math.Sin(rad), -w.Speed * math.Cos(rad)
+}
+
+// windFromVector returns the wind moving air at a velocity
+func windFromVector(east, north float64) Wind {
+	speed := math.Hypot(east, north)
+	if speed == 0 {
+		return Wind{}
+	}
+	direction := math.Mod(math.Atan2(-east, -north)*180/math.Pi+360, 360)
+	return Wind{Speed: speed, Direction: direction}
+}
+
+// WindLayer is the wind at an altitude (m relative to takeoff)
+type WindLayer struct {
+	Altitude float64 `json:"altitude"`
+	Wind
+}
+
+// WindProfile is the wind at several altitudes. A single layer is a
+// constant wind; no layers is calm air.
+type WindProfile []WindLayer
+
+// Constant returns a profile with the same wind at every altitude
+func Constant(wind Wind) WindProfile {
+	return WindProfile{{Wind: wind}}
+}
+
+// At returns the wind at an altitude (m relative to takeoff), interpolating
+// the velocity between layers and holding the lowest and highest layers
+// beyond them
+func (p WindProfile) At(altitude float64) Wind {
+	if len(p) == 0 {
+		return Wind{}
+	}
+	layers := append(WindProfile{}, p...)
+	sort.SliceStable(layers, func(i, j int) bool { return layers[i].Altitude < layers[j].Altitude })
+
+	if altitude <= layers[0].Altitude {
+		return layers[0].Wind
+	}
+	for i := 1; i < len(layers); i++ {
+		below, above := layers[i-1], layers[i]
+		if altitude > above.Altitude {
+			continue
+		}
+		f := (altitude - below.Altitude) / (above.Altitude - below.Altitude)
+		e1, n1 := below.Vector()
+		e2, n2 := above.Vector()
+		return windFromVector(e1+(e2-e1)*f, n1+(n2-n1)*f)
+	}
+	return layers[len(layers)-1].Wind
+}
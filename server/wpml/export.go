@@ -0,0 +1,423 @@
+package wpml
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strconv"
+
+	"drone-planner/server/altitude"
+	"drone-planner/server/drones"
+	"drone-planner/server/geometry"
+	"drone-planner/server/models"
+)
+
+const (
+	// defaultSpeed is used when a mission has no auto flight speed (m/s)
+	defaultSpeed = 10.0
+	// takeOffSecurityHeight is the height the aircraft climbs to before flying to the first waypoint (m)
+	takeOffSecurityHeight = 20.0
+	// minDampingDist is the smallest turn damping distance DJI accepts for coordinated turns (m)
+	minDampingDist = 0.2
+)
+
+// Export compiles the mission's waypoint mission into a KMZ archive containing
+// template.kml and waylines.wpml. The wayline keeps relative altitudes and
+// flies any other mode as WGS84 ellipsoid heights, converted with altitudes.
+// Template waypoints carry their ellipsoid heights when altitudes can
+// convert them.
+func Export(mission *models.Mission, altitudes *altitude.Converter) ([]byte, error) {
+	config, err := mission.DecodeWaypointMission()
+	if err != nil {
+		return nil, err
+	}
+	if len(config.Waypoints) < 2 {
+		return nil, fmt.Errorf("waypoint mission must have at least 2 waypoints")
+	}
+
+	template, err := altitudes.Mission(mission.GlobalSettings, config, altitude.Target(config.AltitudeMode, templateAltitudeModes...))
+	if err != nil {
+		return nil, err
+	}
+	wayline, err := altitudes.Mission(mission.GlobalSettings, config, altitude.Target(config.AltitudeMode, waylineAltitudeModes...))
+	if err != nil {
+		return nil, err
+	}
+	ellipsoid, err := altitudes.Mission(mission.GlobalSettings, config, models.AltitudeEllipsoid)
+	if err != nil {
+		ellipsoid = nil
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	if err := writeDocument(zw, TemplatePath, buildDocument(mission, template, ellipsoid, false)); err != nil {
+		return nil, err
+	}
+	if err := writeDocument(zw, WaylinesPath, buildDocument(mission, wayline, ellipsoid, true)); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finalize KMZ: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// writeDocument encodes a WPML document into the archive
+func writeDocument(zw *zip.Writer, path string, doc KML) error {
+	w, err := zw.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", path, err)
+	}
+	if _, err := w.Write([]byte(xml.Header)); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("failed to encode %s: %v", path, err)
+	}
+	return nil
+}
+
+// buildDocument builds either the editable template or the executable wayline
+// document. ellipsoid is the route with ellipsoid heights, nil when they are
+// not known.
+func buildDocument(mission *models.Mission, config, ellipsoid *models.WaypointMissionConfig, executable bool) KML {
+	speed := config.AutoFlightSpeed
+	if speed <= 0 {
+		speed = defaultSpeed
+	}
+
+	doc := Document{
+		MissionConfig: buildMissionConfig(mission, config, speed),
+	}
+	folder := Folder{
+		TemplateID:      0,
+		AutoFlightSpeed: speed,
+	}
+
+	if executable {
+		folder.WaylineID = intPtr(0)
+		folder.ExecuteHeightMode = waylineHeightModes[config.AltitudeMode]
+		points, legs := geometry.WaypointLegs(config)
+		route := geometry.Measure(points, legs, speed)
+		folder.Distance, folder.Duration = route.Distance, route.Duration
+	} else {
+		doc.Author = "drone-planner"
+		doc.CreateTime = mission.CreatedAt.UnixMilli()
+		doc.UpdateTime = mission.UpdatedAt.UnixMilli()
+
+		gimbalPitchMode := "manual"
+		if config.GimbalPitchRotationEnabled {
+			gimbalPitchMode = "usePointSetting"
+		}
+		folder.TemplateType = "waypoint"
+		folder.WaylineCoordinateSysParam = &CoordinateSysParam{
+			CoordinateMode: "WGS84",
+			HeightMode:     templateHeightModes[config.AltitudeMode],
+		}
+		folder.GlobalHeight = floatPtr(config.Waypoints[0].Altitude)
+		folder.GimbalPitchMode = gimbalPitchMode
+		folder.GlobalWaypointHeadingParam = &HeadingParam{
+			WaypointHeadingMode: lookup(headingModes, config.HeadingMode, "followWayline"),
+		}
+		folder.GlobalWaypointTurnMode = turnMode(config.FlightPathMode, false)
+		folder.GlobalUseStraightLine = intPtr(1)
+	}
+
+	groupID := 0
+	for i, wp := range config.Waypoints {
+		var ellipsoidHeight *float64
+		if ellipsoid != nil {
+			ellipsoidHeight = floatPtr(ellipsoid.Waypoints[i].Altitude)
+		}
+		placemark := buildPlacemark(i, wp, config, ellipsoidHeight, speed, executable)
+		if group, ok := buildActionGroup(i, wp, config, groupID); ok {
+			placemark.ActionGroups = append(placemark.ActionGroups, group)
+			groupID++
+		}
+		if group, ok := buildIntervalGroup(i, wp, config, groupID); ok {
+			placemark.ActionGroups = append(placemark.ActionGroups, group)
+			groupID++
+		}
+		folder.Placemarks = append(folder.Placemarks, placemark)
+	}
+	doc.Folder = folder
+
+	return KML{
+		XMLNS:     NamespaceKML,
+		XMLNSWPML: NamespaceWPML,
+		Document:  doc,
+	}
+}
+
+// buildMissionConfig maps the mission's global settings to the WPML missionConfig
+func buildMissionConfig(mission *models.Mission, config *models.WaypointMissionConfig, speed float64) MissionConfig {
+	settings := mission.GlobalSettings
+
+	enum := defaultDroneEnum
+	if profile, ok := drones.Lookup(settings.DroneType); ok && profile.WPML != nil {
+		enum = *profile.WPML
+	}
+
+	missionConfig := MissionConfig{
+		FlyToWaylineMode:        "safely",
+		FinishAction:            lookup(finishActions, config.FinishedAction, "goHome"),
+		ExitOnRCLost:            "executeLostAction",
+		ExecuteRCLostAction:     lookup(rcLostActions, settings.SignalLostAction, "goBack"),
+		TakeOffSecurityHeight:   takeOffSecurityHeight,
+		GlobalTransitionalSpeed: speed,
+		DroneInfo: DroneInfo{
+			DroneEnumValue:    enum.Value,
+			DroneSubEnumValue: enum.SubValue,
+		},
+	}
+	if settings.SignalLostAction == "continue" {
+		missionConfig.ExitOnRCLost = "goContinue"
+		missionConfig.ExecuteRCLostAction = ""
+	}
+	if settings.HomeLat != nil && settings.HomeLng != nil {
+		missionConfig.TakeOffRefPoint = fmt.Sprintf("%s,%s,0", formatFloat(*settings.HomeLat), formatFloat(*settings.HomeLng))
+	}
+	return missionConfig
+}
+
+// buildPlacemark converts a waypoint into a WPML placemark. ellipsoidHeight is
+// the waypoint's height above the WGS84 ellipsoid, nil when unknown.
+func buildPlacemark(index int, wp models.Waypoint, config *models.WaypointMissionConfig, ellipsoidHeight *float64, speed float64, executable bool) Placemark {
+	waypointSpeed := wp.Speed
+	if waypointSpeed <= 0 {
+		waypointSpeed = speed
+	}
+	height := wp.Altitude
+	endpoint := index == 0 || index == len(config.Waypoints)-1
+
+	placemark := Placemark{
+		Point: Point{
+			Coordinates: fmt.Sprintf("%s,%s", formatFloat(wp.Coordinate.Longitude), formatFloat(wp.Coordinate.Latitude)),
+		},
+		Index:                index,
+		WaypointSpeed:        waypointSpeed,
+		WaypointHeadingParam: headingParam(wp, config),
+		WaypointTurnParam:    turnParam(config.FlightPathMode, wp.CornerRadius, endpoint),
+		UseStraightLine:      intPtr(1),
+	}
+
+	if executable {
+		placemark.ExecuteHeight = &height
+	} else {
+		placemark.Height = &height
+		placemark.EllipsoidHeight = ellipsoidHeight
+		placemark.UseGlobalHeight = intPtr(0)
+		placemark.UseGlobalSpeed = intPtr(0)
+		placemark.UseGlobalHeadingParam = intPtr(0)
+		placemark.UseGlobalTurnParam = intPtr(0)
+		placemark.GimbalPitchAngle = floatPtr(wp.GimbalPitch)
+	}
+	return placemark
+}
+
+// headingParam builds the per-waypoint heading parameters
+func headingParam(wp models.Waypoint, config *models.WaypointMissionConfig) *HeadingParam {
+	param := &HeadingParam{
+		WaypointHeadingMode:     lookup(headingModes, config.HeadingMode, "followWayline"),
+		WaypointHeadingAngle:    normalizeHeading(wp.Heading),
+		WaypointHeadingPathMode: lookup(headingPathModes, wp.TurnMode, "followBadArc"),
+	}
+
+	if param.WaypointHeadingMode == "towardPOI" {
+		target, ok := waypointTarget(wp, config)
+		if !ok {
+			// Without a POI there is nothing to face, so fall back to following the route
+			param.WaypointHeadingMode = "followWayline"
+		} else {
+			param.WaypointPoiPoint = fmt.Sprintf("%s,%s,0", formatFloat(target.Lat), formatFloat(target.Lng))
+		}
+	}
+	return param
+}
+
+// waypointTarget returns the POI a waypoint should face, preferring its own targets
+func waypointTarget(wp models.Waypoint, config *models.WaypointMissionConfig) (models.Target, bool) {
+	if len(wp.Targets) > 0 {
+		return wp.Targets[0], true
+	}
+	if len(config.Targets) > 0 {
+		return config.Targets[0], true
+	}
+	return models.Target{}, false
+}
+
+// turnMode returns the WPML turn mode for the mission's flight path mode.
+// The first and last waypoints must always stop.
+func turnMode(flightPathMode string, endpoint bool) string {
+	if flightPathMode == "CURVED" && !endpoint {
+		return "coordinateTurn"
+	}
+	return "toPointAndStopWithDiscontinuityCurvature"
+}
+
+// turnParam builds the per-waypoint turn parameters
+func turnParam(flightPathMode string, cornerRadius float64, endpoint bool) *TurnParam {
+	mode := turnMode(flightPathMode, endpoint)
+	param := &TurnParam{WaypointTurnMode: mode}
+	if mode == "coordinateTurn" {
+		param.WaypointTurnDampingDist = math.Max(math.Abs(cornerRadius), minDampingDist)
+	}
+	return param
+}
+
+// buildActionGroup collects the gimbal setting and actions executed when reaching a waypoint
+func buildActionGroup(index int, wp models.Waypoint, config *models.WaypointMissionConfig, groupID int) (ActionGroup, bool) {
+	var actions []Action
+
+	if config.GimbalPitchRotationEnabled {
+		actions = append(actions, gimbalRotateAction(len(actions), wp.GimbalPitch))
+	}
+	for _, action := range wp.Actions {
+		if converted, ok := convertAction(len(actions), action, wp); ok {
+			actions = append(actions, converted)
+		}
+	}
+	if len(actions) == 0 {
+		return ActionGroup{}, false
+	}
+
+	return ActionGroup{
+		ActionGroupID:         groupID,
+		ActionGroupStartIndex: index,
+		ActionGroupEndIndex:   index,
+		ActionGroupMode:       "sequence",
+		ActionTrigger:         ActionTrigger{ActionTriggerType: "reachPoint"},
+		Actions:               actions,
+	}, true
+}
+
+// buildIntervalGroup builds the distance-triggered photos taken along the leg
+// leaving a waypoint
+func buildIntervalGroup(index int, wp models.Waypoint, config *models.WaypointMissionConfig, groupID int) (ActionGroup, bool) {
+	if index == len(config.Waypoints)-1 {
+		return ActionGroup{}, false
+	}
+	for _, action := range wp.Actions {
+		if models.NormalizeActionType(action.ActionType) != models.ActionPhotoInterval {
+			continue
+		}
+		photo, _ := convertAction(0, models.WaypointAction{ActionType: models.ActionTakePhoto}, wp)
+		return ActionGroup{
+			ActionGroupID:         groupID,
+			ActionGroupStartIndex: index,
+			ActionGroupEndIndex:   index + 1,
+			ActionGroupMode:       "sequence",
+			ActionTrigger: ActionTrigger{
+				ActionTriggerType:  "multipleDistance",
+				ActionTriggerParam: floatPtr(action.ActionParam),
+			},
+			Actions: []Action{photo},
+		}, true
+	}
+	return ActionGroup{}, false
+}
+
+// convertAction maps a waypoint action to a WPML action. Unsupported types are skipped.
+func convertAction(id int, action models.WaypointAction, wp models.Waypoint) (Action, bool) {
+	actionType := models.NormalizeActionType(action.ActionType)
+	fn, ok := actuatorFuncs[actionType]
+	if !ok {
+		return Action{}, false
+	}
+
+	var params []Param
+	switch actionType {
+	case models.ActionTakePhoto, models.ActionStartRecording:
+		params = []Param{
+			param("payloadPositionIndex", "0"),
+			param("useGlobalPayloadLensIndex", "1"),
+		}
+	case models.ActionStopRecording:
+		params = []Param{param("payloadPositionIndex", "0")}
+	case models.ActionRotateGimbal:
+		return gimbalRotateAction(id, action.ActionParam), true
+	case models.ActionRotateAircraft:
+		pathMode := "clockwise"
+		if wp.TurnMode == "COUNTER_CLOCKWISE" {
+			pathMode = "counterClockwise"
+		}
+		params = []Param{
+			param("aircraftHeading", formatFloat(normalizeHeading(action.ActionParam))),
+			param("aircraftPathMode", pathMode),
+		}
+	case models.ActionHover:
+		params = []Param{param("hoverTime", formatFloat(action.ActionParam))}
+	case models.ActionZoom:
+		params = []Param{
+			param("payloadPositionIndex", "0"),
+			param("focalLength", formatFloat(action.ActionParam)),
+		}
+	case models.ActionFocus:
+		params = []Param{
+			param("payloadPositionIndex", "0"),
+			param("isPointFocus", "0"),
+			param("focusX", "0.5"),
+			param("focusY", "0.5"),
+			param("isInfiniteFocus", "0"),
+		}
+	}
+
+	return Action{
+		ActionID:                id,
+		ActionActuatorFunc:      fn,
+		ActionActuatorFuncParam: ActuatorFuncParam{Params: params},
+	}, true
+}
+
+// gimbalRotateAction builds an absolute gimbal pitch rotation
+func gimbalRotateAction(id int, pitch float64) Action {
+	return Action{
+		ActionID:           id,
+		ActionActuatorFunc: "gimbalRotate",
+		ActionActuatorFuncParam: ActuatorFuncParam{Params: []Param{
+			param("gimbalHeadingYawBase", "north"),
+			param("gimbalRotateMode", "absoluteAngle"),
+			param("gimbalPitchRotateEnable", "1"),
+			param("gimbalPitchRotateAngle", formatFloat(pitch)),
+			param("gimbalRollRotateEnable", "0"),
+			param("gimbalRollRotateAngle", "0"),
+			param("gimbalYawRotateEnable", "0"),
+			param("gimbalYawRotateAngle", "0"),
+			param("gimbalRotateTimeEnable", "0"),
+			param("gimbalRotateTime", "0"),
+			param("payloadPositionIndex", "0"),
+		}},
+	}
+}
+
+// normalizeHeading wraps a heading into the -180..180 range WPML expects
+func normalizeHeading(heading float64) float64 {
+	heading = math.Mod(heading, 360)
+	if heading > 180 {
+		heading -= 360
+	} else if heading < -180 {
+		heading += 360
+	}
+	return heading
+}
+
+// param builds a wpml-prefixed action parameter
+func param(name, value string) Param {
+	return Param{XMLName: xml.Name{Local: "wpml:" + name}, Value: value}
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func intPtr(value int) *int {
+	return &value
+}
+
+func floatPtr(value float64) *float64 {
+	return &value
+}
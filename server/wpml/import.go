@@ -0,0 +1,503 @@
+package wpml
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"drone-planner/server/drones"
+	"drone-planner/server/models"
+	"drone-planner/server/timeline"
+)
+
+// maxDocumentSize limits how much of a single KMZ entry is read (bytes)
+const maxDocumentSize = 32 << 20
+
+// ImportReport describes how a KMZ was mapped onto a mission
+type ImportReport struct {
+	Source    string   `json:"source"`
+	Waypoints int      `json:"waypoints"`
+	Unmapped  []string `json:"unmapped"`
+}
+
+// addf records something that could not be mapped
+func (r *ImportReport) addf(format string, args ...interface{}) {
+	r.Unmapped = append(r.Unmapped, fmt.Sprintf(format, args...))
+}
+
+// Import parses a DJI KMZ (or a bare template.kml/waylines.wpml document) into a
+// new mission with a single waypoint-mission timeline element
+func Import(data []byte) (*models.Mission, *ImportReport, error) {
+	template, waylines, err := readDocuments(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	report := &ImportReport{Unmapped: []string{}}
+	doc, err := chooseDocument(template, waylines, report)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	config := buildWaypointMissionConfig(doc, report)
+	if len(config.Waypoints) < 2 {
+		return nil, nil, fmt.Errorf("waypoint mission must have at least 2 waypoints (found %d)", len(config.Waypoints))
+	}
+	report.Waypoints = len(config.Waypoints)
+
+	configMap, err := models.EncodeConfig(config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mission := models.NewMission("", "")
+	mission.GlobalSettings = buildGlobalSettings(doc.Document.MissionConfig, report)
+	mission.AddTimelineElement(models.ElementWaypointMission, configMap)
+
+	mission.Metadata, err = timeline.Metadata(mission)
+	if err != nil {
+		return nil, nil, err
+	}
+	return mission, report, nil
+}
+
+// readDocuments extracts template.kml and waylines.wpml from a KMZ archive.
+// Data that is not a zip archive is treated as a single KML document.
+func readDocuments(data []byte) (*KML, *KML, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		doc, err := decodeDocument(bytes.NewReader(data))
+		if err != nil {
+			return nil, nil, fmt.Errorf("file is neither a KMZ archive nor a WPML document: %v", err)
+		}
+		return doc, nil, nil
+	}
+
+	var template, waylines *KML
+	for _, file := range zr.File {
+		var target **KML
+		switch {
+		case strings.HasSuffix(file.Name, "template.kml"):
+			target = &template
+		case strings.HasSuffix(file.Name, "waylines.wpml"):
+			target = &waylines
+		default:
+			continue
+		}
+
+		rc, err := file.Open()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open %s: %v", file.Name, err)
+		}
+		doc, err := decodeDocument(io.LimitReader(rc, maxDocumentSize))
+		rc.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse %s: %v", file.Name, err)
+		}
+		*target = doc
+	}
+
+	if template == nil && waylines == nil {
+		return nil, nil, fmt.Errorf("KMZ contains neither template.kml nor waylines.wpml")
+	}
+	return template, waylines, nil
+}
+
+// decodeDocument decodes a WPML document into the shared KML structs
+func decodeDocument(r io.Reader) (*KML, error) {
+	var doc KML
+	dec := xml.NewTokenDecoder(prefixedTokenReader{xml.NewDecoder(r)})
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// prefixedTokenReader rewrites WPML-namespaced element names back to their
+// "wpml:" prefixed form so the same structs can be used for reading and writing
+type prefixedTokenReader struct {
+	dec *xml.Decoder
+}
+
+func (r prefixedTokenReader) Token() (xml.Token, error) {
+	tok, err := r.dec.Token()
+	switch t := tok.(type) {
+	case xml.StartElement:
+		t.Name = prefixedName(t.Name)
+		return t, err
+	case xml.EndElement:
+		t.Name = prefixedName(t.Name)
+		return t, err
+	}
+	return tok, err
+}
+
+// prefixedName maps any WPML namespace version onto the "wpml:" prefix
+func prefixedName(name xml.Name) xml.Name {
+	if strings.HasPrefix(name.Space, "http://www.dji.com/wpmz/") {
+		return xml.Name{Local: "wpml:" + name.Local}
+	}
+	return xml.Name{Local: name.Local}
+}
+
+// chooseDocument prefers the editable template and falls back to the
+// executable wayline when the template is not a plain waypoint route
+func chooseDocument(template, waylines *KML, report *ImportReport) (*KML, error) {
+	if template != nil {
+		folder := template.Document.Folder
+		if (folder.TemplateType == "" || folder.TemplateType == "waypoint") && len(folder.Placemarks) > 0 {
+			report.Source = "template.kml"
+			return template, nil
+		}
+		if waylines == nil {
+			return nil, fmt.Errorf("template type %q has no waypoints and the KMZ has no waylines.wpml", folder.TemplateType)
+		}
+		report.addf("template type %q imported from its generated waylines", folder.TemplateType)
+		// The wayline document does not repeat the template's mission config in all exports
+		if waylines.Document.MissionConfig.FinishAction == "" {
+			waylines.Document.MissionConfig = template.Document.MissionConfig
+		}
+	}
+	if waylines == nil || len(waylines.Document.Folder.Placemarks) == 0 {
+		return nil, fmt.Errorf("KMZ contains no waypoints")
+	}
+	report.Source = "waylines.wpml"
+	return waylines, nil
+}
+
+// buildWaypointMissionConfig maps the route folder onto a waypoint mission config
+func buildWaypointMissionConfig(doc *KML, report *ImportReport) *models.WaypointMissionConfig {
+	folder := doc.Document.Folder
+	missionConfig := doc.Document.MissionConfig
+
+	speed := folder.AutoFlightSpeed
+	if speed <= 0 {
+		speed = defaultSpeed
+	}
+
+	config := &models.WaypointMissionConfig{
+		AutoFlightSpeed:            speed,
+		MaxFlightSpeed:             math.Max(speed, missionConfig.GlobalTransitionalSpeed),
+		FinishedAction:             lookup(importFinishActions, missionConfig.FinishAction, "GO_HOME"),
+		RepeatTimes:                1,
+		GlobalTurnMode:             "CLOCKWISE",
+		GimbalPitchRotationEnabled: folder.GimbalPitchMode == "usePointSetting",
+		HeadingMode:                "AUTO",
+		FlightPathMode:             "NORMAL",
+		AltitudeMode:               models.AltitudeRelative,
+		Targets:                    []models.Target{},
+		Waypoints:                  []models.Waypoint{},
+	}
+
+	if mode := heightMode(folder); mode != "" {
+		if altitudeMode, ok := importHeightModes[mode]; ok {
+			config.AltitudeMode = altitudeMode
+		} else {
+			report.addf("height mode %q imported as relative-to-takeoff altitudes", mode)
+		}
+		if mode == "realTimeFollowSurface" {
+			report.addf("height mode %q imported as fixed above-ground altitudes", mode)
+		}
+	}
+	if missionConfig.FinishAction != "" {
+		if _, ok := importFinishActions[missionConfig.FinishAction]; !ok {
+			report.addf("finish action %q", missionConfig.FinishAction)
+		}
+	}
+
+	globalHeading := folder.GlobalWaypointHeadingParam
+	if globalHeading != nil {
+		config.HeadingMode = reverseLookup(headingModes, globalHeading.WaypointHeadingMode, "AUTO")
+	}
+
+	poiIndex := map[string]int{}
+	for _, placemark := range folder.Placemarks {
+		wp, ok := buildWaypoint(placemark, folder, speed, report)
+		if !ok {
+			continue
+		}
+
+		heading := placemark.WaypointHeadingParam
+		if heading == nil || (placemark.UseGlobalHeadingParam != nil && *placemark.UseGlobalHeadingParam == 1) {
+			heading = globalHeading
+		}
+		if heading != nil && heading.WaypointPoiPoint != "" && heading.WaypointHeadingMode == "towardPOI" {
+			if target, ok := parsePoi(heading.WaypointPoiPoint, len(poiIndex)); ok {
+				if _, seen := poiIndex[heading.WaypointPoiPoint]; !seen {
+					poiIndex[heading.WaypointPoiPoint] = len(config.Targets)
+					config.Targets = append(config.Targets, target)
+				}
+				config.HeadingMode = "TOWARD_POINT_OF_INTEREST"
+			}
+		}
+
+		if isCurvedTurn(placemark.WaypointTurnParam) {
+			config.FlightPathMode = "CURVED"
+		}
+		for _, action := range wp.Actions {
+			if action.ActionType == models.ActionRotateGimbal {
+				config.GimbalPitchRotationEnabled = true
+			}
+		}
+		config.MaxFlightSpeed = math.Max(config.MaxFlightSpeed, wp.Speed)
+		config.Waypoints = append(config.Waypoints, wp)
+	}
+
+	// Gimbal pitch is carried on the waypoint itself, so drop the duplicate gimbal actions
+	if config.GimbalPitchRotationEnabled {
+		for i := range config.Waypoints {
+			config.Waypoints[i].Actions = withoutGimbalActions(config.Waypoints[i].Actions)
+		}
+	}
+	if len(config.Waypoints) > 0 && config.Waypoints[0].TurnMode != "" {
+		config.GlobalTurnMode = config.Waypoints[0].TurnMode
+	}
+	return config
+}
+
+// buildWaypoint maps a placemark onto a waypoint
+func buildWaypoint(placemark Placemark, folder Folder, speed float64, report *ImportReport) (models.Waypoint, bool) {
+	lat, lng, err := parseCoordinates(placemark.Point.Coordinates)
+	if err != nil {
+		report.addf("waypoint %d: %v", placemark.Index, err)
+		return models.Waypoint{}, false
+	}
+
+	wp := models.Waypoint{
+		ID:         strconv.Itoa(placemark.Index + 1),
+		Coordinate: models.Coordinate{Latitude: lat, Longitude: lng},
+		Altitude:   placemarkHeight(placemark, folder),
+		Speed:      speed,
+		Targets:    []models.Target{},
+		Actions:    []models.WaypointAction{},
+	}
+	if placemark.WaypointSpeed > 0 && (placemark.UseGlobalSpeed == nil || *placemark.UseGlobalSpeed == 0) {
+		wp.Speed = placemark.WaypointSpeed
+	}
+	if placemark.GimbalPitchAngle != nil {
+		wp.GimbalPitch = *placemark.GimbalPitchAngle
+	}
+	if heading := placemark.WaypointHeadingParam; heading != nil {
+		wp.Heading = heading.WaypointHeadingAngle
+		wp.TurnMode = reverseLookup(headingPathModes, heading.WaypointHeadingPathMode, "")
+	}
+	if turn := placemark.WaypointTurnParam; turn != nil && isCurvedTurn(turn) {
+		wp.CornerRadius = turn.WaypointTurnDampingDist
+	}
+
+	for _, group := range placemark.ActionGroups {
+		trigger := group.ActionTrigger.ActionTriggerType
+		if trigger == "multipleDistance" {
+			importIntervalGroup(group, placemark, &wp, report)
+			continue
+		}
+		if trigger != "" && trigger != "reachPoint" {
+			report.addf("waypoint %d: action group %d with %q trigger", placemark.Index, group.ActionGroupID, trigger)
+			continue
+		}
+		if group.ActionGroupEndIndex > group.ActionGroupStartIndex {
+			report.addf("waypoint %d: action group %d spans waypoints %d-%d, applied to the first only",
+				placemark.Index, group.ActionGroupID, group.ActionGroupStartIndex, group.ActionGroupEndIndex)
+		}
+		for _, action := range group.Actions {
+			converted, ok := importAction(action, &wp)
+			if !ok {
+				report.addf("waypoint %d: action %q", placemark.Index, action.ActionActuatorFunc)
+				continue
+			}
+			wp.Actions = append(wp.Actions, converted)
+		}
+	}
+	return wp, true
+}
+
+// importIntervalGroup maps distance-triggered photos along the leg leaving a
+// waypoint onto a photo interval action
+func importIntervalGroup(group ActionGroup, placemark Placemark, wp *models.Waypoint, report *ImportReport) {
+	distance := group.ActionTrigger.ActionTriggerParam
+	if distance == nil || *distance <= 0 {
+		report.addf("waypoint %d: action group %d with a %q trigger and no distance", placemark.Index, group.ActionGroupID, "multipleDistance")
+		return
+	}
+	photos := false
+	for _, action := range group.Actions {
+		if action.ActionActuatorFunc == "takePhoto" {
+			photos = true
+			continue
+		}
+		report.addf("waypoint %d: action %q repeated every %g m", placemark.Index, action.ActionActuatorFunc, *distance)
+	}
+	if photos {
+		wp.Actions = append(wp.Actions, models.WaypointAction{ActionType: models.ActionPhotoInterval, ActionParam: *distance})
+	}
+}
+
+// importAction maps a WPML action onto a waypoint action
+func importAction(action Action, wp *models.Waypoint) (models.WaypointAction, bool) {
+	params := action.ActionActuatorFuncParam
+	switch action.ActionActuatorFunc {
+	case "takePhoto":
+		return models.WaypointAction{ActionType: models.ActionTakePhoto}, true
+	case "startRecord":
+		return models.WaypointAction{ActionType: models.ActionStartRecording}, true
+	case "stopRecord":
+		return models.WaypointAction{ActionType: models.ActionStopRecording}, true
+	case "gimbalRotate", "gimbalEvenlyRotate":
+		pitch, ok := floatParam(params, "gimbalPitchRotateAngle")
+		if !ok {
+			return models.WaypointAction{}, false
+		}
+		wp.GimbalPitch = pitch
+		return models.WaypointAction{ActionType: models.ActionRotateGimbal, ActionParam: pitch}, true
+	case "rotateYaw":
+		heading, ok := floatParam(params, "aircraftHeading")
+		if !ok {
+			return models.WaypointAction{}, false
+		}
+		return models.WaypointAction{ActionType: models.ActionRotateAircraft, ActionParam: heading}, true
+	case "hover":
+		hoverTime, _ := floatParam(params, "hoverTime")
+		return models.WaypointAction{ActionType: models.ActionHover, ActionParam: hoverTime}, true
+	case "zoom":
+		focalLength, _ := floatParam(params, "focalLength")
+		return models.WaypointAction{ActionType: models.ActionZoom, ActionParam: focalLength}, true
+	case "focus":
+		return models.WaypointAction{ActionType: models.ActionFocus}, true
+	}
+	return models.WaypointAction{}, false
+}
+
+// buildGlobalSettings maps the WPML missionConfig onto the mission's global settings
+func buildGlobalSettings(config MissionConfig, report *ImportReport) models.GlobalMissionSettings {
+	settings := models.GlobalMissionSettings{
+		SignalLostAction: "return_home",
+	}
+
+	switch {
+	case config.ExitOnRCLost == "goContinue":
+		settings.SignalLostAction = "continue"
+	case config.ExecuteRCLostAction != "":
+		settings.SignalLostAction = lookup(importRCLostActions, config.ExecuteRCLostAction, "return_home")
+	}
+
+	if config.TakeOffRefPoint != "" {
+		parts := strings.Split(config.TakeOffRefPoint, ",")
+		lat, latErr := parseFloat(parts[0])
+		var lng float64
+		lngErr := fmt.Errorf("missing longitude")
+		if len(parts) > 1 {
+			lng, lngErr = parseFloat(parts[1])
+		}
+		if latErr != nil || lngErr != nil {
+			report.addf("take-off reference point %q", config.TakeOffRefPoint)
+		} else {
+			settings.HomeLat = &lat
+			settings.HomeLng = &lng
+		}
+	}
+
+	droneInfo := config.DroneInfo
+	if profile, ok := drones.LookupWPML(droneInfo.DroneEnumValue, droneInfo.DroneSubEnumValue); ok {
+		settings.DroneType = profile.ID
+	} else if droneInfo.DroneEnumValue != 0 {
+		report.addf("drone model %d-%d", droneInfo.DroneEnumValue, droneInfo.DroneSubEnumValue)
+	}
+	return settings
+}
+
+// placemarkHeight returns the waypoint altitude, honouring the global height
+func placemarkHeight(placemark Placemark, folder Folder) float64 {
+	if placemark.UseGlobalHeight != nil && *placemark.UseGlobalHeight == 1 && folder.GlobalHeight != nil {
+		return *folder.GlobalHeight
+	}
+	switch {
+	case placemark.Height != nil:
+		return *placemark.Height
+	case placemark.ExecuteHeight != nil:
+		return *placemark.ExecuteHeight
+	case folder.GlobalHeight != nil:
+		return *folder.GlobalHeight
+	}
+	return 0
+}
+
+// heightMode returns the height reference declared by the document
+func heightMode(folder Folder) string {
+	if folder.WaylineCoordinateSysParam != nil {
+		return folder.WaylineCoordinateSysParam.HeightMode
+	}
+	return folder.ExecuteHeightMode
+}
+
+// isCurvedTurn reports whether a turn mode flies through the waypoint
+func isCurvedTurn(turn *TurnParam) bool {
+	if turn == nil {
+		return false
+	}
+	return turn.WaypointTurnMode == "coordinateTurn" || turn.WaypointTurnMode == "toPointAndPassWithContinuityCurvature"
+}
+
+// withoutGimbalActions removes gimbal pitch actions that duplicate the waypoint's gimbal pitch
+func withoutGimbalActions(actions []models.WaypointAction) []models.WaypointAction {
+	kept := make([]models.WaypointAction, 0, len(actions))
+	for _, action := range actions {
+		if action.ActionType != models.ActionRotateGimbal {
+			kept = append(kept, action)
+		}
+	}
+	return kept
+}
+
+// parseCoordinates parses a KML "lng,lat[,alt]" coordinate
+func parseCoordinates(coordinates string) (float64, float64, error) {
+	parts := strings.Split(strings.TrimSpace(coordinates), ",")
+	if len(parts) < 2 {
+		return 0, 0, fmt.Errorf("invalid coordinates %q", coordinates)
+	}
+	lng, err := parseFloat(parts[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid longitude %q", parts[0])
+	}
+	lat, err := parseFloat(parts[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid latitude %q", parts[1])
+	}
+	return lat, lng, nil
+}
+
+// parsePoi parses a WPML "lat,lng,alt" point of interest into a target
+func parsePoi(point string, index int) (models.Target, bool) {
+	parts := strings.Split(point, ",")
+	if len(parts) < 2 {
+		return models.Target{}, false
+	}
+	lat, latErr := parseFloat(parts[0])
+	lng, lngErr := parseFloat(parts[1])
+	if latErr != nil || lngErr != nil {
+		return models.Target{}, false
+	}
+	return models.Target{
+		ID:   fmt.Sprintf("poi-%d", index+1),
+		Name: fmt.Sprintf("POI %d", index+1),
+		Lat:  lat,
+		Lng:  lng,
+	}, true
+}
+
+func floatParam(params ActuatorFuncParam, name string) (float64, bool) {
+	value, ok := params.Get(name)
+	if !ok {
+		return 0, false
+	}
+	f, err := parseFloat(value)
+	return f, err == nil
+}
+
+func parseFloat(value string) (float64, error) {
+	return strconv.ParseFloat(strings.TrimSpace(value), 64)
+}
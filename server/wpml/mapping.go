@@ -0,0 +1,119 @@
+package wpml
+
+import (
+	"drone-planner/server/drones"
+	"drone-planner/server/models"
+)
+
+// defaultDroneEnum is used when the mission's drone type has no WPML equivalent (Mavic 3 Enterprise)
+var defaultDroneEnum = drones.WPMLEnum{Value: 77, SubValue: 0}
+
+// finishActions maps planner finished actions to WPML finishAction values
+var finishActions = map[string]string{
+	"NO_ACTION":            "noAction",
+	"HOVER":                "noAction",
+	"GO_HOME":              "goHome",
+	"LAND":                 "autoLand",
+	"AUTO_LAND":            "autoLand",
+	"GO_TO_FIRST_WAYPOINT": "gotoFirstWaypoint",
+	"GO_FIRST_WAYPOINT":    "gotoFirstWaypoint",
+}
+
+// rcLostActions maps planner signal-lost actions to WPML executeRCLostAction values
+var rcLostActions = map[string]string{
+	"hover":       "hover",
+	"landing":     "landing",
+	"land":        "landing",
+	"return_home": "goBack",
+	"GO_HOME":     "goBack",
+}
+
+// importFinishActions maps WPML finishAction values back to planner finished actions
+var importFinishActions = map[string]string{
+	"noAction":          "NO_ACTION",
+	"goHome":            "GO_HOME",
+	"autoLand":          "LAND",
+	"gotoFirstWaypoint": "GO_TO_FIRST_WAYPOINT",
+}
+
+// importRCLostActions maps WPML executeRCLostAction values back to planner signal-lost actions
+var importRCLostActions = map[string]string{
+	"hover":   "hover",
+	"landing": "landing",
+	"goBack":  "return_home",
+}
+
+// headingModes maps planner heading modes to WPML waypointHeadingMode values
+var headingModes = map[string]string{
+	"AUTO":                         "followWayline",
+	"USING_INITIAL_DIRECTION":      "fixed",
+	"CONTROL_BY_REMOTE_CONTROLLER": "manually",
+	"USING_WAYPOINT_HEADING":       "smoothTransition",
+	"TOWARD_POINT_OF_INTEREST":     "towardPOI",
+}
+
+// headingPathModes maps planner turn directions to WPML waypointHeadingPathMode values
+var headingPathModes = map[string]string{
+	"CLOCKWISE":         "clockwise",
+	"COUNTER_CLOCKWISE": "counterClockwise",
+}
+
+// Altitude modes a template and an executable wayline can store. Other modes
+// are converted to the first one.
+var (
+	templateAltitudeModes = []string{models.AltitudeMSL, models.AltitudeRelative, models.AltitudeAGL}
+	waylineAltitudeModes  = []string{models.AltitudeEllipsoid, models.AltitudeRelative}
+)
+
+// templateHeightModes maps planner altitude modes to template heightMode values
+var templateHeightModes = map[string]string{
+	models.AltitudeRelative: "relativeToStartPoint",
+	models.AltitudeMSL:      "EGM96",
+	models.AltitudeAGL:      "aboveGroundLevel",
+}
+
+// waylineHeightModes maps planner altitude modes to wayline executeHeightMode values
+var waylineHeightModes = map[string]string{
+	models.AltitudeRelative:  "relativeToStartPoint",
+	models.AltitudeEllipsoid: "WGS84",
+}
+
+// importHeightModes maps WPML heightMode and executeHeightMode values back to planner altitude modes
+var importHeightModes = map[string]string{
+	"relativeToStartPoint":  models.AltitudeRelative,
+	"EGM96":                 models.AltitudeMSL,
+	"aboveGroundLevel":      models.AltitudeAGL,
+	"realTimeFollowSurface": models.AltitudeAGL,
+	"WGS84":                 models.AltitudeEllipsoid,
+}
+
+// actuatorFuncs maps canonical waypoint action types to WPML actuator functions
+var actuatorFuncs = map[string]string{
+	models.ActionTakePhoto:      "takePhoto",
+	models.ActionStartRecording: "startRecord",
+	models.ActionStopRecording:  "stopRecord",
+	models.ActionRotateGimbal:   "gimbalRotate",
+	models.ActionRotateAircraft: "rotateYaw",
+	models.ActionHover:          "hover",
+	models.ActionFocus:          "focus",
+	models.ActionZoom:           "zoom",
+}
+
+// lookup returns the mapped value for key, or fallback when there is none
+func lookup(table map[string]string, key, fallback string) string {
+	if value, ok := table[key]; ok {
+		return value
+	}
+	return fallback
+}
+
+// reverseLookup returns the key whose value matches, or fallback when there is none.
+// It is only used with one-to-one tables.
+func reverseLookup(table map[string]string, value, fallback string) string {
+	for key, mapped := range table {
+		if mapped == value {
+			return key
+		}
+	}
+	return fallback
+}
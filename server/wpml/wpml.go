@@ -0,0 +1,170 @@
+// Package wpml reads and writes DJI WPML waypoint files (template.kml and
+// waylines.wpml packaged as a KMZ), as used by DJI Pilot 2 and FlightHub 2.
+package wpml
+
+import "encoding/xml"
+
+// XML namespaces used by WPML documents
+const (
+	NamespaceKML  = "http://www.opengis.net/kml/2.2"
+	NamespaceWPML = "http://www.dji.com/wpmz/1.0.2"
+)
+
+// Paths of the documents inside a KMZ archive
+const (
+	TemplatePath = "wpmz/template.kml"
+	WaylinesPath = "wpmz/waylines.wpml"
+)
+
+// KML is the root element of both template.kml and waylines.wpml
+type KML struct {
+	XMLName   xml.Name `xml:"kml"`
+	XMLNS     string   `xml:"xmlns,attr"`
+	XMLNSWPML string   `xml:"xmlns:wpml,attr"`
+	Document  Document `xml:"Document"`
+}
+
+// Document holds the mission-wide configuration and the route folder
+type Document struct {
+	Author        string        `xml:"wpml:author,omitempty"`
+	CreateTime    int64         `xml:"wpml:createTime,omitempty"`
+	UpdateTime    int64         `xml:"wpml:updateTime,omitempty"`
+	MissionConfig MissionConfig `xml:"wpml:missionConfig"`
+	Folder        Folder        `xml:"Folder"`
+}
+
+// MissionConfig contains the global flight and failsafe behaviour
+type MissionConfig struct {
+	FlyToWaylineMode        string    `xml:"wpml:flyToWaylineMode"`
+	FinishAction            string    `xml:"wpml:finishAction"`
+	ExitOnRCLost            string    `xml:"wpml:exitOnRCLost"`
+	ExecuteRCLostAction     string    `xml:"wpml:executeRCLostAction,omitempty"`
+	TakeOffSecurityHeight   float64   `xml:"wpml:takeOffSecurityHeight"`
+	TakeOffRefPoint         string    `xml:"wpml:takeOffRefPoint,omitempty"`
+	GlobalTransitionalSpeed float64   `xml:"wpml:globalTransitionalSpeed"`
+	DroneInfo               DroneInfo `xml:"wpml:droneInfo"`
+}
+
+// DroneInfo identifies the aircraft model the route was planned for
+type DroneInfo struct {
+	DroneEnumValue    int `xml:"wpml:droneEnumValue"`
+	DroneSubEnumValue int `xml:"wpml:droneSubEnumValue"`
+}
+
+// CoordinateSysParam describes the coordinate and height reference of a template
+type CoordinateSysParam struct {
+	CoordinateMode string `xml:"wpml:coordinateMode"`
+	HeightMode     string `xml:"wpml:heightMode"`
+}
+
+// Folder contains the waypoints of a template or an executable wayline.
+// Template-only and wayline-only fields are optional pointers.
+type Folder struct {
+	TemplateType               string              `xml:"wpml:templateType,omitempty"`
+	TemplateID                 int                 `xml:"wpml:templateId"`
+	WaylineCoordinateSysParam  *CoordinateSysParam `xml:"wpml:waylineCoordinateSysParam,omitempty"`
+	WaylineID                  *int                `xml:"wpml:waylineId,omitempty"`
+	Distance                   float64             `xml:"wpml:distance,omitempty"`
+	Duration                   float64             `xml:"wpml:duration,omitempty"`
+	ExecuteHeightMode          string              `xml:"wpml:executeHeightMode,omitempty"`
+	AutoFlightSpeed            float64             `xml:"wpml:autoFlightSpeed"`
+	GlobalHeight               *float64            `xml:"wpml:globalHeight,omitempty"`
+	GimbalPitchMode            string              `xml:"wpml:gimbalPitchMode,omitempty"`
+	GlobalWaypointHeadingParam *HeadingParam       `xml:"wpml:globalWaypointHeadingParam,omitempty"`
+	GlobalWaypointTurnMode     string              `xml:"wpml:globalWaypointTurnMode,omitempty"`
+	GlobalUseStraightLine      *int                `xml:"wpml:globalUseStraightLine,omitempty"`
+	Placemarks                 []Placemark         `xml:"Placemark"`
+}
+
+// Placemark is a single waypoint
+type Placemark struct {
+	Point                 Point         `xml:"Point"`
+	Index                 int           `xml:"wpml:index"`
+	EllipsoidHeight       *float64      `xml:"wpml:ellipsoidHeight,omitempty"`
+	Height                *float64      `xml:"wpml:height,omitempty"`
+	ExecuteHeight         *float64      `xml:"wpml:executeHeight,omitempty"`
+	UseGlobalHeight       *int          `xml:"wpml:useGlobalHeight,omitempty"`
+	UseGlobalSpeed        *int          `xml:"wpml:useGlobalSpeed,omitempty"`
+	WaypointSpeed         float64       `xml:"wpml:waypointSpeed"`
+	UseGlobalHeadingParam *int          `xml:"wpml:useGlobalHeadingParam,omitempty"`
+	WaypointHeadingParam  *HeadingParam `xml:"wpml:waypointHeadingParam,omitempty"`
+	UseGlobalTurnParam    *int          `xml:"wpml:useGlobalTurnParam,omitempty"`
+	WaypointTurnParam     *TurnParam    `xml:"wpml:waypointTurnParam,omitempty"`
+	UseStraightLine       *int          `xml:"wpml:useStraightLine,omitempty"`
+	GimbalPitchAngle      *float64      `xml:"wpml:gimbalPitchAngle,omitempty"`
+	ActionGroups          []ActionGroup `xml:"wpml:actionGroup"`
+}
+
+// Point holds a KML "lng,lat" coordinate pair
+type Point struct {
+	Coordinates string `xml:"coordinates"`
+}
+
+// HeadingParam controls how the aircraft yaws between waypoints
+type HeadingParam struct {
+	WaypointHeadingMode     string  `xml:"wpml:waypointHeadingMode"`
+	WaypointHeadingAngle    float64 `xml:"wpml:waypointHeadingAngle"`
+	WaypointPoiPoint        string  `xml:"wpml:waypointPoiPoint,omitempty"`
+	WaypointHeadingPathMode string  `xml:"wpml:waypointHeadingPathMode,omitempty"`
+	WaypointHeadingPoiIndex int     `xml:"wpml:waypointHeadingPoiIndex"`
+}
+
+// TurnParam controls how the aircraft passes through a waypoint
+type TurnParam struct {
+	WaypointTurnMode        string  `xml:"wpml:waypointTurnMode"`
+	WaypointTurnDampingDist float64 `xml:"wpml:waypointTurnDampingDist"`
+}
+
+// ActionGroup is a list of actions executed when its trigger fires
+type ActionGroup struct {
+	ActionGroupID         int           `xml:"wpml:actionGroupId"`
+	ActionGroupStartIndex int           `xml:"wpml:actionGroupStartIndex"`
+	ActionGroupEndIndex   int           `xml:"wpml:actionGroupEndIndex"`
+	ActionGroupMode       string        `xml:"wpml:actionGroupMode"`
+	ActionTrigger         ActionTrigger `xml:"wpml:actionTrigger"`
+	Actions               []Action      `xml:"wpml:action"`
+}
+
+// ActionTrigger describes when an action group starts
+type ActionTrigger struct {
+	ActionTriggerType  string   `xml:"wpml:actionTriggerType"`
+	ActionTriggerParam *float64 `xml:"wpml:actionTriggerParam,omitempty"`
+}
+
+// Action is a single actuator command
+type Action struct {
+	ActionID                int               `xml:"wpml:actionId"`
+	ActionActuatorFunc      string            `xml:"wpml:actionActuatorFunc"`
+	ActionActuatorFuncParam ActuatorFuncParam `xml:"wpml:actionActuatorFuncParam"`
+}
+
+// ActuatorFuncParam holds the function-specific parameters of an action
+type ActuatorFuncParam struct {
+	Params []Param `xml:",any"`
+}
+
+// Param is a single named action parameter
+type Param struct {
+	XMLName xml.Name
+	Value   string `xml:",chardata"`
+}
+
+// Get returns the value of the named parameter, ignoring any namespace prefix
+func (p ActuatorFuncParam) Get(name string) (string, bool) {
+	for _, param := range p.Params {
+		if localName(param.XMLName.Local) == name {
+			return param.Value, true
+		}
+	}
+	return "", false
+}
+
+// localName strips a "prefix:" from an element name
+func localName(name string) string {
+	for i := len(name) - 1; i >= 0; i-- {
+		if name[i] == ':' {
+			return name[i+1:]
+		}
+	}
+	return name
+}
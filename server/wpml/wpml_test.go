@@ -0,0 +1,183 @@
+package wpml
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"drone-planner/server/altitude"
+	"drone-planner/server/models"
+)
+
+// tolerance is how far a coordinate may move through the KML text (degrees)
+const tolerance = 1e-7
+
+func TestExportImportRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		actions []models.WaypointAction
+		// kept are the actions expected back; gimbal rotations become the waypoint's pitch
+		kept        []models.WaypointAction
+		gimbalPitch float64
+	}{
+		{"no actions", nil, nil, 0},
+		{"photo", []models.WaypointAction{{ActionType: models.ActionTakePhoto}}, []models.WaypointAction{{ActionType: models.ActionTakePhoto}}, 0},
+		{"photo interval", []models.WaypointAction{{ActionType: models.ActionPhotoInterval, ActionParam: 25}}, []models.WaypointAction{{ActionType: models.ActionPhotoInterval, ActionParam: 25}}, 0},
+		{"gimbal and hover", []models.WaypointAction{{ActionType: models.ActionRotateGimbal, ActionParam: -45}, {ActionType: models.ActionHover, ActionParam: 3}}, []models.WaypointAction{{ActionType: models.ActionHover, ActionParam: 3}}, -45},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := []models.Waypoint{
+				{Coordinate: models.Coordinate{Latitude: 47.3769, Longitude: 8.5417}, Altitude: 40, Actions: tt.actions},
+				{Coordinate: models.Coordinate{Latitude: 47.3779, Longitude: 8.5427}, Altitude: 55},
+				{Coordinate: models.Coordinate{Latitude: 47.3789, Longitude: 8.5417}, Altitude: 70},
+			}
+			config, err := models.EncodeConfig(models.WaypointMissionConfig{
+				AutoFlightSpeed: 8,
+				MaxFlightSpeed:  12,
+				AltitudeMode:    models.AltitudeRelative,
+				Waypoints:       want,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			mission := models.NewMission("", "")
+			mission.GlobalSettings.DroneType = "M3E"
+			mission.AddTimelineElement(models.ElementWaypointMission, config)
+
+			data, err := Export(mission, &altitude.Converter{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			imported, report, err := Import(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(report.Unmapped) > 0 {
+				t.Errorf("unmapped on import: %v", report.Unmapped)
+			}
+			got, err := imported.DecodeWaypointMission()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.AutoFlightSpeed != 8 {
+				t.Errorf("autoFlightSpeed = %g, want 8", got.AutoFlightSpeed)
+			}
+			if len(got.Waypoints) != len(want) {
+				t.Fatalf("got %d waypoints, want %d", len(got.Waypoints), len(want))
+			}
+			for i, wp := range got.Waypoints {
+				if math.Abs(wp.Coordinate.Latitude-want[i].Coordinate.Latitude) > tolerance || math.Abs(wp.Coordinate.Longitude-want[i].Coordinate.Longitude) > tolerance {
+					t.Errorf("waypoint %d at %+v, want %+v", i, wp.Coordinate, want[i].Coordinate)
+				}
+				if wp.Altitude != want[i].Altitude {
+					t.Errorf("waypoint %d altitude = %g, want %g", i, wp.Altitude, want[i].Altitude)
+				}
+			}
+			if got.Waypoints[0].GimbalPitch != tt.gimbalPitch {
+				t.Errorf("first waypoint gimbal pitch = %g, want %g", got.Waypoints[0].GimbalPitch, tt.gimbalPitch)
+			}
+			for _, action := range tt.kept {
+				if !hasAction(got.Waypoints[0], action) {
+					t.Errorf("first waypoint lost %+v: got %+v", action, got.Waypoints[0].Actions)
+				}
+			}
+		})
+	}
+}
+
+// hasAction reports whether a waypoint carries the action with its parameter
+func hasAction(wp models.Waypoint, want models.WaypointAction) bool {
+	for _, action := range wp.Actions {
+		if models.NormalizeActionType(action.ActionType) == want.ActionType && action.ActionParam == want.ActionParam {
+			return true
+		}
+	}
+	return false
+}
+
+// flat is level ground 400 m above sea level
+type flat struct{}
+
+func (flat) Elevation(lat, lng float64) (float64, error) {
+	return 400, nil
+}
+
+func TestExportEllipsoidHeight(t *testing.T) {
+	// A geoid 50 m above the ellipsoid everywhere, as a GeographicLib PGM
+	var pgm bytes.Buffer
+	fmt.Fprintf(&pgm, "P5\n# Offset -100\n# Scale 0.01\n2 2\n65535\n")
+	for i := 0; i < 4; i++ {
+		binary.Write(&pgm, binary.BigEndian, uint16(15000))
+	}
+	path := filepath.Join(t.TempDir(), "geoid.pgm")
+	if err := os.WriteFile(path, pgm.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	geoid, err := altitude.LoadGeoid(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		converter *altitude.Converter
+		// want are the template's ellipsoid heights, none when they cannot
+		// be converted
+		want []string
+	}{
+		{"converted", &altitude.Converter{Terrain: flat{}, Geoid: geoid}, []string{"490", "505", "520"}},
+		{"without a geoid", &altitude.Converter{Terrain: flat{}}, nil},
+		{"without terrain", &altitude.Converter{Geoid: geoid}, nil},
+	}
+	heights := regexp.MustCompile(`<wpml:ellipsoidHeight>([^<]*)</wpml:ellipsoidHeight>`)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := models.EncodeConfig(models.WaypointMissionConfig{
+				AltitudeMode: models.AltitudeRelative,
+				Waypoints: []models.Waypoint{
+					{Coordinate: models.Coordinate{Latitude: 47.3769, Longitude: 8.5417}, Altitude: 40},
+					{Coordinate: models.Coordinate{Latitude: 47.3779, Longitude: 8.5427}, Altitude: 55},
+					{Coordinate: models.Coordinate{Latitude: 47.3789, Longitude: 8.5417}, Altitude: 70},
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			mission := models.NewMission("", "")
+			mission.AddTimelineElement(models.ElementWaypointMission, config)
+			data, err := Export(mission, tt.converter)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			file, err := archive.Open(TemplatePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			template, err := io.ReadAll(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, match := range heights.FindAllSubmatch(template, -1) {
+				got = append(got, string(match[1]))
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("ellipsoid heights = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}